@@ -2,11 +2,14 @@ package main
 
 import (
 	"context"
+	_ "github.com/gogf/gf/contrib/drivers/mysql/v2"
+	_ "github.com/gogf/gf/contrib/nosql/redis/v2"
 	"github.com/gogf/gf/v2/frame/g"
 	"github.com/gogf/gf/v2/os/gctx"
 	"os"
 	"os/signal"
 	"syscall"
+	taskprocessor "ai-translate/internal/infrastructure/task"
 	"ai-translate/internal/interfaces/router"
 	"ai-translate/internal/service"
 )
@@ -25,9 +28,25 @@ func main() {
 		g.Log().Fatalf(ctx, "启动任务服务失败: %v", err)
 	}
 
+	// 启动工作流任务处理器，发件箱中继随处理器一起运行
+	processorCtx, stopProcessor := context.WithCancel(ctx)
+	processor, err := taskprocessor.NewProcessor()
+	if err != nil {
+		g.Log().Fatalf(ctx, "创建任务处理器失败: %v", err)
+	}
+	processorDone := make(chan struct{})
+	go func() {
+		defer close(processorDone)
+		if err := processor.Start(processorCtx); err != nil {
+			g.Log().Errorf(ctx, "任务处理器异常退出: %v", err)
+		}
+	}()
+
 	// 注册路由
 	s := g.Server()
-	router.Register(s)
+	if err := router.Register(s, taskService); err != nil {
+		g.Log().Fatalf(ctx, "注册路由失败: %v", err)
+	}
 
 	// 启动HTTP服务
 	go func() {
@@ -47,6 +66,10 @@ func main() {
 	// 停止任务服务
 	taskService.Stop()
 
+	// 停止任务处理器，等待进行中的任务放回队列
+	stopProcessor()
+	<-processorDone
+
 	// 关闭HTTP服务
 	if err := s.Shutdown(); err != nil {
 		g.Log().Errorf(ctx, "关闭HTTP服务失败: %v", err)
@@ -1,5 +1,5 @@
 import axios from 'axios';
-import type { Task, PriorityRule, RuleTemplate, RuleGroup, PriorityLog, TaskStats } from '@/types/task';
+import type { Task, PriorityRule, RuleTemplate, RuleGroup, PriorityLog, TaskStats, TaskEvent, TaskEventFilter } from '@/types/task';
 
 const api = axios.create({
   baseURL: '/api/v1',
@@ -193,4 +193,68 @@ export const ruleGroupApi = {
   getGroupRules: (groupId: string) => {
     return api.get<PriorityRule[]>(`/tasks/groups/${groupId}/rules`);
   },
-}; 
\ No newline at end of file
+}; 
+
+// 订阅任务事件（SSE），返回取消订阅函数
+// EventSource无法携带Authorization请求头，这里用fetch读取事件流
+export const subscribeTaskEvents = (
+  filter: TaskEventFilter,
+  onEvent: (event: TaskEvent) => void,
+  onError?: (error: unknown) => void,
+) => {
+  const controller = new AbortController();
+  const params = new URLSearchParams();
+  Object.entries(filter).forEach(([key, value]) => {
+    if (value) {
+      params.set(key, value);
+    }
+  });
+
+  const headers: Record<string, string> = { Accept: 'text/event-stream' };
+  const token = localStorage.getItem('token');
+  if (token) {
+    headers.Authorization = `Bearer ${token}`;
+  }
+
+  (async () => {
+    const response = await fetch(`/api/v1/tasks/events?${params.toString()}`, {
+      headers,
+      signal: controller.signal,
+    });
+    if (!response.ok || !response.body) {
+      throw new Error(`订阅任务事件失败: ${response.status}`);
+    }
+
+    const reader = response.body.getReader();
+    const decoder = new TextDecoder();
+    let buffer = '';
+    for (;;) {
+      const { value, done } = await reader.read();
+      if (done) {
+        break;
+      }
+      buffer += decoder.decode(value, { stream: true });
+
+      // 事件之间以空行分隔，只处理data行
+      let index: number;
+      while ((index = buffer.indexOf('\n\n')) >= 0) {
+        const block = buffer.slice(0, index);
+        buffer = buffer.slice(index + 2);
+        const data = block
+          .split('\n')
+          .filter((line) => line.startsWith('data:'))
+          .map((line) => line.slice(5).trim())
+          .join('\n');
+        if (data) {
+          onEvent(JSON.parse(data) as TaskEvent);
+        }
+      }
+    }
+  })().catch((error) => {
+    if (!controller.signal.aborted) {
+      onError?.(error);
+    }
+  });
+
+  return () => controller.abort();
+};
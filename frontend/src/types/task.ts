@@ -9,10 +9,34 @@ export interface Task {
   content: string;
   result: string;
   error: string;
+  progress: number;
+  partial_result?: string;
   created_at: string;
   updated_at: string;
 }
 
+// 任务事件类型定义
+export interface TaskEvent {
+  type: 'task_status' | 'task_progress' | 'task_priority';
+  task_id: string;
+  batch_id: string;
+  work_id: string;
+  status?: string;
+  error?: string;
+  progress: number;
+  old_priority: number;
+  new_priority: number;
+  reason?: string;
+  time: string;
+}
+
+// 任务事件订阅条件，均为空时订阅全部任务
+export interface TaskEventFilter {
+  task_id?: string;
+  batch_id?: string;
+  work_id?: string;
+}
+
 // 任务类型枚举
 export enum TaskType {
   ContentGeneration = 'content_generation',
@@ -2,30 +2,102 @@ package application
 
 import (
 	"ai-translate/internal/domain/task"
+	"ai-translate/internal/infrastructure/ai"
 	"ai-translate/internal/infrastructure/persistence"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"time"
 )
 
 type taskService struct {
-	taskRepo  task.TaskRepository
-	taskQueue task.TaskQueue
+	taskRepo       task.TaskRepository
+	dependencyRepo task.TaskDependencyRepository
+	outboxRepo     task.OutboxRepository
+	taskQueue      task.TaskQueue
+	tx             *persistence.Tx // 非空时仓储已绑定到该事务
 }
 
 // NewTaskService 创建任务服务实例
 func NewTaskService() task.TaskService {
+	return newTaskService()
+}
+
+func newTaskService() *taskService {
+	return &taskService{
+		taskRepo:       persistence.NewTaskRepository(),
+		dependencyRepo: persistence.NewTaskDependencyRepository(),
+		outboxRepo:     persistence.NewOutboxRepository(),
+		taskQueue:      persistence.NewTaskQueue(),
+	}
+}
+
+// withTx 返回仓储绑定到事务的任务服务
+func (s *taskService) withTx(tx *persistence.Tx) *taskService {
 	return &taskService{
-		taskRepo:  persistence.NewTaskRepository(),
-		taskQueue: persistence.NewTaskQueue(),
+		taskRepo:       tx.Tasks(),
+		dependencyRepo: tx.TaskDependencies(),
+		outboxRepo:     tx.Outbox(),
+		taskQueue:      s.taskQueue,
+		tx:             tx,
 	}
 }
 
-func (s *taskService) CreateTask(task *task.Task) error {
-	err := s.taskRepo.Save(task)
+// transaction 在事务中执行fn，已在事务中时直接执行
+func (s *taskService) transaction(fn func(s *taskService) error) error {
+	if s.tx != nil {
+		return fn(s)
+	}
+	return persistence.Transaction(func(tx *persistence.Tx) error {
+		return fn(s.withTx(tx))
+	})
+}
+
+// CreateTask 创建任务，有依赖时先以等待依赖状态保存，依赖的任务全部完成后才入队
+// 任务、依赖关系与入队消息在同一事务中写入
+func (s *taskService) CreateTask(t *task.Task, dependsOn ...uint64) error {
+	return s.transaction(func(s *taskService) error {
+		return s.createTask(t, dependsOn)
+	})
+}
+
+func (s *taskService) createTask(t *task.Task, dependsOn []uint64) error {
+	if len(dependsOn) == 0 {
+		err := s.taskRepo.Save(t)
+		if err != nil {
+			return err
+		}
+		return s.publish(t)
+	}
+
+	for _, id := range dependsOn {
+		if _, err := s.taskRepo.FindByID(id); err != nil {
+			return fmt.Errorf("依赖任务%d不存在: %v", id, err)
+		}
+	}
+
+	t.Status = task.StatusBlocked
+	err := s.taskRepo.Save(t)
 	if err != nil {
 		return err
 	}
-	return s.taskQueue.Push(task)
+
+	dependencies := make([]*task.TaskDependency, 0, len(dependsOn))
+	for _, id := range dependsOn {
+		dependency := &task.TaskDependency{
+			WorkID:      t.WorkID,
+			TaskID:      t.ID,
+			DependsOnID: id,
+			CreatedAt:   time.Now(),
+		}
+		if err := s.dependencyRepo.Save(dependency); err != nil {
+			return err
+		}
+		dependencies = append(dependencies, dependency)
+	}
+
+	// 依赖关系写入后再检查一次，依赖的任务可能在此之前已完成或失败
+	return s.release(t, dependencies)
 }
 
 func (s *taskService) GetTask(id uint64) (*task.Task, error) {
@@ -66,7 +138,7 @@ func (s *taskService) PauseTask(id uint64) error {
 	}
 
 	// 更新任务状态为暂停
-	t.Status = 2 // 2:暂停
+	t.Status = task.StatusPaused
 	t.UpdatedAt = time.Now()
 	return s.taskRepo.Update(t)
 }
@@ -77,16 +149,8 @@ func (s *taskService) ResumeTask(id uint64) error {
 		return err
 	}
 
-	// 更新任务状态为等待中
-	t.Status = 0 // 0:等待中
-	t.UpdatedAt = time.Now()
-	err = s.taskRepo.Update(t)
-	if err != nil {
-		return err
-	}
-
-	// 重新加入队列
-	return s.taskQueue.Push(t)
+	// 重新等待，依赖未完成时先等待依赖
+	return s.enqueue(t)
 }
 
 func (s *taskService) RetryTask(id uint64) error {
@@ -96,19 +160,210 @@ func (s *taskService) RetryTask(id uint64) error {
 	}
 
 	// 检查重试次数
-	if t.RetryCount >= t.MaxRetry {
+	if !ai.CanRetry(t.RetryCount, t.MaxRetry) {
 		return errors.New("超过最大重试次数")
 	}
 
-	// 更新重试次数和状态
+	// 更新重试次数并重新等待，依赖未完成时先等待依赖
 	t.RetryCount++
-	t.Status = 0 // 0:等待中
-	t.UpdatedAt = time.Now()
-	err = s.taskRepo.Update(t)
+	return s.enqueue(t)
+}
+
+// CompleteTask 将任务标记为完成，并放行依赖已全部完成的下游任务
+// 完成状态与下游任务的放行在同一事务中提交
+func (s *taskService) CompleteTask(id uint64) error {
+	return s.transaction(func(s *taskService) error {
+		// 处理期间被取消或暂停的任务保持原状态，不再标记完成
+		completed, err := s.taskRepo.UpdateStatusFrom(id, task.StatusWaiting, task.StatusCompleted, "")
+		if err != nil || !completed {
+			return err
+		}
+
+		dependents, err := s.dependencyRepo.FindByDependsOnID(id)
+		if err != nil {
+			return err
+		}
+		for _, d := range dependents {
+			dependent, err := s.taskRepo.FindByID(d.TaskID)
+			if err != nil {
+				return err
+			}
+			if dependent.Status != task.StatusBlocked {
+				continue
+			}
+			dependencies, err := s.dependencyRepo.FindByTaskID(dependent.ID)
+			if err != nil {
+				return err
+			}
+			if err := s.release(dependent, dependencies); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// FailTask 将任务标记为失败，并使所有等待它的下游任务逐级失败
+// 失败状态与下游任务的失败在同一事务中提交
+func (s *taskService) FailTask(id uint64, reason string) error {
+	return s.transaction(func(s *taskService) error {
+		// 处理期间被取消或暂停的任务保持原状态，不再标记失败
+		failed, err := s.taskRepo.UpdateStatusFrom(id, task.StatusWaiting, task.StatusFailed, reason)
+		if err != nil || !failed {
+			return err
+		}
+		return s.propagateFailure(id)
+	})
+}
+
+// CancelTask 取消未结束的任务，已完成分块的译文保留在任务中，恢复后跳过这些分块
+// 处理中的任务由处理器检测到状态变化后中断；等待它的下游任务保持等待依赖，任务恢复并完成后放行
+func (s *taskService) CancelTask(id uint64) error {
+	t, err := s.taskRepo.FindByID(id)
+	if err != nil {
+		return err
+	}
+
+	canceled, err := s.cancel(t)
+	if err != nil {
+		return err
+	}
+	if !canceled {
+		return fmt.Errorf("任务%d已结束，无法取消", id)
+	}
+	return nil
+}
+
+// SavePartialResult 保存任务已完成分块的译文
+func (s *taskService) SavePartialResult(id uint64, partial string) error {
+	return s.taskRepo.UpdatePartialResult(id, partial)
+}
+
+// cancel 按当前状态取消任务，任务已结束或状态已被修改时不取消，返回是否已取消
+// 不从队列中移除任务，已取消任务的消息在出队时丢弃
+func (s *taskService) cancel(t *task.Task) (bool, error) {
+	switch t.Status {
+	case task.StatusCompleted, task.StatusFailed, task.StatusCanceled:
+		return false, nil
+	}
+	return s.taskRepo.UpdateStatusFrom(t.ID, t.Status, task.StatusCanceled, "任务已取消")
+}
+
+// GetWorkTaskGraph 获取作品下的任务及其依赖关系
+func (s *taskService) GetWorkTaskGraph(workID uint64) (*task.TaskGraph, error) {
+	tasks, err := s.taskRepo.FindByWorkID(workID)
+	if err != nil {
+		return nil, err
+	}
+	edges, err := s.dependencyRepo.FindByWorkID(workID)
+	if err != nil {
+		return nil, err
+	}
+	return &task.TaskGraph{
+		WorkID: workID,
+		Tasks:  tasks,
+		Edges:  edges,
+	}, nil
+}
+
+// enqueue 任务重新进入等待：有依赖的任务先检查依赖，没有依赖的任务直接入队
+func (s *taskService) enqueue(t *task.Task) error {
+	return s.transaction(func(s *taskService) error {
+		return s.requeue(t)
+	})
+}
+
+func (s *taskService) requeue(t *task.Task) error {
+	dependencies, err := s.dependencyRepo.FindByTaskID(t.ID)
 	if err != nil {
 		return err
 	}
 
-	// 重新加入队列
-	return s.taskQueue.Push(t)
-} 
\ No newline at end of file
+	if len(dependencies) == 0 {
+		t.Status = task.StatusWaiting
+	} else {
+		t.Status = task.StatusBlocked
+	}
+	t.Error = ""
+	t.UpdatedAt = time.Now()
+	if err := s.taskRepo.Update(t); err != nil {
+		return err
+	}
+
+	if len(dependencies) == 0 {
+		return s.publish(t)
+	}
+	return s.release(t, dependencies)
+}
+
+// release 检查等待依赖的任务：依赖全部完成时入队，任一依赖失败时任务失败，否则继续等待
+// 状态按等待依赖条件更新，并发放行同一任务时只会入队一次
+func (s *taskService) release(t *task.Task, dependencies []*task.TaskDependency) error {
+	for _, d := range dependencies {
+		dependency, err := s.taskRepo.FindByID(d.DependsOnID)
+		if err != nil {
+			return err
+		}
+		switch dependency.Status {
+		case task.StatusCompleted:
+			continue
+		case task.StatusFailed:
+			reason := fmt.Sprintf("依赖任务%d失败", dependency.ID)
+			failed, err := s.taskRepo.UpdateStatusFrom(t.ID, task.StatusBlocked, task.StatusFailed, reason)
+			if err != nil || !failed {
+				return err
+			}
+			return s.propagateFailure(t.ID)
+		default:
+			return nil
+		}
+	}
+
+	return s.transaction(func(s *taskService) error {
+		released, err := s.taskRepo.UpdateStatusFrom(t.ID, task.StatusBlocked, task.StatusWaiting, "")
+		if err != nil || !released {
+			return err
+		}
+		t.Status = task.StatusWaiting
+		t.Error = ""
+		return s.publish(t)
+	})
+}
+
+// publish 写入入队消息，由发件箱中继投递到任务队列，与任务状态的修改在同一事务中提交
+func (s *taskService) publish(t *task.Task) error {
+	payload, err := json.Marshal(t)
+	if err != nil {
+		return fmt.Errorf("序列化任务失败: %v", err)
+	}
+	return s.outboxRepo.Save(&task.OutboxMessage{
+		TaskID:    t.ID,
+		Payload:   string(payload),
+		Status:    task.OutboxPending,
+		CreatedAt: time.Now(),
+	})
+}
+
+// propagateFailure 使等待指定任务的下游任务逐级失败
+func (s *taskService) propagateFailure(id uint64) error {
+	pending := []uint64{id}
+	for len(pending) > 0 {
+		failedID := pending[0]
+		pending = pending[1:]
+
+		dependents, err := s.dependencyRepo.FindByDependsOnID(failedID)
+		if err != nil {
+			return err
+		}
+		for _, d := range dependents {
+			failed, err := s.taskRepo.UpdateStatusFrom(d.TaskID, task.StatusBlocked, task.StatusFailed, fmt.Sprintf("依赖任务%d失败", failedID))
+			if err != nil {
+				return err
+			}
+			if failed {
+				pending = append(pending, d.TaskID)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,273 @@
+package application
+
+import (
+	"ai-translate/internal/domain/task"
+	"ai-translate/internal/infrastructure/persistence"
+	"database/sql"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// fakeTaskRepository 内存任务仓储
+type fakeTaskRepository struct {
+	task.TaskRepository
+	tasks  map[uint64]*task.Task
+	nextID uint64
+}
+
+func (r *fakeTaskRepository) FindByID(id uint64) (*task.Task, error) {
+	t, ok := r.tasks[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	copied := *t
+	return &copied, nil
+}
+
+func (r *fakeTaskRepository) Save(t *task.Task) error {
+	r.nextID++
+	t.ID = r.nextID
+	copied := *t
+	r.tasks[t.ID] = &copied
+	return nil
+}
+
+func (r *fakeTaskRepository) Update(t *task.Task) error {
+	copied := *t
+	r.tasks[t.ID] = &copied
+	return nil
+}
+
+func (r *fakeTaskRepository) UpdateStatusFrom(id uint64, from, to int, errMsg string) (bool, error) {
+	t, ok := r.tasks[id]
+	if !ok || t.Status != from {
+		return false, nil
+	}
+	t.Status = to
+	t.Error = errMsg
+	return true, nil
+}
+
+// fakeDependencyRepository 内存任务依赖仓储
+type fakeDependencyRepository struct {
+	task.TaskDependencyRepository
+	dependencies []*task.TaskDependency
+}
+
+func (r *fakeDependencyRepository) FindByTaskID(taskID uint64) ([]*task.TaskDependency, error) {
+	var result []*task.TaskDependency
+	for _, d := range r.dependencies {
+		if d.TaskID == taskID {
+			result = append(result, d)
+		}
+	}
+	return result, nil
+}
+
+func (r *fakeDependencyRepository) FindByDependsOnID(dependsOnID uint64) ([]*task.TaskDependency, error) {
+	var result []*task.TaskDependency
+	for _, d := range r.dependencies {
+		if d.DependsOnID == dependsOnID {
+			result = append(result, d)
+		}
+	}
+	return result, nil
+}
+
+func (r *fakeDependencyRepository) Save(dependency *task.TaskDependency) error {
+	r.dependencies = append(r.dependencies, dependency)
+	return nil
+}
+
+// fakeOutboxRepository 记录写入的入队消息
+type fakeOutboxRepository struct {
+	task.OutboxRepository
+	taskIDs []uint64
+}
+
+func (r *fakeOutboxRepository) Save(message *task.OutboxMessage) error {
+	r.taskIDs = append(r.taskIDs, message.TaskID)
+	return nil
+}
+
+// newTestTaskService 创建使用内存仓储的任务服务，tasks为任务ID到状态，edges为任务ID到其依赖的任务ID
+// 服务视为已在事务中，不访问数据库
+func newTestTaskService(tasks map[uint64]int, edges map[uint64][]uint64) (*taskService, *fakeTaskRepository, *fakeOutboxRepository) {
+	taskRepo := &fakeTaskRepository{tasks: make(map[uint64]*task.Task)}
+	for id, status := range tasks {
+		taskRepo.tasks[id] = &task.Task{ID: id, Status: status}
+		if id > taskRepo.nextID {
+			taskRepo.nextID = id
+		}
+	}
+
+	dependencyRepo := &fakeDependencyRepository{}
+	ids := make([]uint64, 0, len(edges))
+	for id := range edges {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	for _, id := range ids {
+		for _, dependsOn := range edges[id] {
+			dependencyRepo.dependencies = append(dependencyRepo.dependencies, &task.TaskDependency{TaskID: id, DependsOnID: dependsOn})
+		}
+	}
+
+	outboxRepo := &fakeOutboxRepository{}
+	return &taskService{
+		taskRepo:       taskRepo,
+		dependencyRepo: dependencyRepo,
+		outboxRepo:     outboxRepo,
+		tx:             &persistence.Tx{},
+	}, taskRepo, outboxRepo
+}
+
+// statuses 获取每个任务的状态
+func statuses(repo *fakeTaskRepository) map[uint64]int {
+	result := make(map[uint64]int, len(repo.tasks))
+	for id, t := range repo.tasks {
+		result[id] = t.Status
+	}
+	return result
+}
+
+func TestCompleteTask(t *testing.T) {
+	cases := []struct {
+		name      string
+		tasks     map[uint64]int
+		edges     map[uint64][]uint64
+		want      map[uint64]int
+		published []uint64
+	}{
+		{
+			name:      "依赖全部完成的下游任务入队",
+			tasks:     map[uint64]int{1: task.StatusWaiting, 2: task.StatusBlocked, 3: task.StatusBlocked, 4: task.StatusCompleted},
+			edges:     map[uint64][]uint64{2: {1}, 3: {4, 1}},
+			want:      map[uint64]int{1: task.StatusCompleted, 2: task.StatusWaiting, 3: task.StatusWaiting, 4: task.StatusCompleted},
+			published: []uint64{2, 3},
+		},
+		{
+			name:  "还有依赖未完成的下游任务继续等待",
+			tasks: map[uint64]int{1: task.StatusWaiting, 2: task.StatusBlocked, 3: task.StatusWaiting},
+			edges: map[uint64][]uint64{2: {1, 3}},
+			want:  map[uint64]int{1: task.StatusCompleted, 2: task.StatusBlocked, 3: task.StatusWaiting},
+		},
+		{
+			name:  "不是等待依赖状态的下游任务不处理",
+			tasks: map[uint64]int{1: task.StatusWaiting, 2: task.StatusCanceled},
+			edges: map[uint64][]uint64{2: {1}},
+			want:  map[uint64]int{1: task.StatusCompleted, 2: task.StatusCanceled},
+		},
+		{
+			name:  "处理期间已取消的任务不标记完成，也不放行下游任务",
+			tasks: map[uint64]int{1: task.StatusCanceled, 2: task.StatusBlocked},
+			edges: map[uint64][]uint64{2: {1}},
+			want:  map[uint64]int{1: task.StatusCanceled, 2: task.StatusBlocked},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s, taskRepo, outboxRepo := newTestTaskService(c.tasks, c.edges)
+			if err := s.CompleteTask(1); err != nil {
+				t.Fatalf("完成任务失败: %v", err)
+			}
+			if got := statuses(taskRepo); !reflect.DeepEqual(got, c.want) {
+				t.Errorf("任务状态 = %v, 期望 %v", got, c.want)
+			}
+			if !reflect.DeepEqual(outboxRepo.taskIDs, c.published) {
+				t.Errorf("入队消息 = %v, 期望 %v", outboxRepo.taskIDs, c.published)
+			}
+		})
+	}
+}
+
+func TestFailTask(t *testing.T) {
+	cases := []struct {
+		name   string
+		tasks  map[uint64]int
+		edges  map[uint64][]uint64
+		want   map[uint64]int
+		errors map[uint64]string
+	}{
+		{
+			name:   "下游任务逐级失败",
+			tasks:  map[uint64]int{1: task.StatusWaiting, 2: task.StatusBlocked, 3: task.StatusBlocked, 4: task.StatusBlocked},
+			edges:  map[uint64][]uint64{2: {1}, 3: {2}, 4: {3, 1}},
+			want:   map[uint64]int{1: task.StatusFailed, 2: task.StatusFailed, 3: task.StatusFailed, 4: task.StatusFailed},
+			errors: map[uint64]string{1: "超时", 2: "依赖任务1失败", 3: "依赖任务2失败", 4: "依赖任务1失败"},
+		},
+		{
+			name:   "不是等待依赖状态的下游任务保持原状态，也不再向下传递",
+			tasks:  map[uint64]int{1: task.StatusWaiting, 2: task.StatusPaused, 3: task.StatusBlocked},
+			edges:  map[uint64][]uint64{2: {1}, 3: {2}},
+			want:   map[uint64]int{1: task.StatusFailed, 2: task.StatusPaused, 3: task.StatusBlocked},
+			errors: map[uint64]string{1: "超时"},
+		},
+		{
+			name:  "处理期间已暂停的任务不标记失败",
+			tasks: map[uint64]int{1: task.StatusPaused, 2: task.StatusBlocked},
+			edges: map[uint64][]uint64{2: {1}},
+			want:  map[uint64]int{1: task.StatusPaused, 2: task.StatusBlocked},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s, taskRepo, _ := newTestTaskService(c.tasks, c.edges)
+			if err := s.FailTask(1, "超时"); err != nil {
+				t.Fatalf("标记任务失败出错: %v", err)
+			}
+			if got := statuses(taskRepo); !reflect.DeepEqual(got, c.want) {
+				t.Errorf("任务状态 = %v, 期望 %v", got, c.want)
+			}
+			for id, stored := range taskRepo.tasks {
+				if stored.Error != c.errors[id] {
+					t.Errorf("任务%d失败原因 = %q, 期望 %q", id, stored.Error, c.errors[id])
+				}
+			}
+		})
+	}
+}
+
+func TestCreateTaskWithDependencies(t *testing.T) {
+	cases := []struct {
+		name      string
+		tasks     map[uint64]int
+		dependsOn []uint64
+		status    int
+		published bool
+		wantErr   bool
+	}{
+		{"没有依赖时直接入队", nil, nil, task.StatusWaiting, true, false},
+		{"依赖已全部完成时入队", map[uint64]int{1: task.StatusCompleted, 2: task.StatusCompleted}, []uint64{1, 2}, task.StatusWaiting, true, false},
+		{"依赖未完成时等待依赖", map[uint64]int{1: task.StatusCompleted, 2: task.StatusWaiting}, []uint64{1, 2}, task.StatusBlocked, false, false},
+		{"依赖已失败时直接失败", map[uint64]int{1: task.StatusFailed}, []uint64{1}, task.StatusFailed, false, false},
+		{"依赖不存在", nil, []uint64{9}, 0, false, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s, taskRepo, outboxRepo := newTestTaskService(c.tasks, nil)
+			created := &task.Task{Status: task.StatusWaiting}
+			err := s.CreateTask(created, c.dependsOn...)
+			if c.wantErr {
+				if err == nil {
+					t.Fatal("期望创建失败")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("创建任务失败: %v", err)
+			}
+
+			if got := taskRepo.tasks[created.ID].Status; got != c.status {
+				t.Errorf("任务状态 = %d, 期望 %d", got, c.status)
+			}
+			if published := len(outboxRepo.taskIDs) > 0; published != c.published {
+				t.Errorf("是否入队 = %v, 期望 %v", published, c.published)
+			}
+		})
+	}
+}
@@ -1,16 +1,16 @@
 package application
 
 import (
-	"ai-translate/internal/domain/ai"
 	"ai-translate/internal/domain/prompt"
 	"ai-translate/internal/domain/task"
 	"ai-translate/internal/domain/work"
 	"ai-translate/internal/infrastructure/ai"
 	"ai-translate/internal/infrastructure/persistence"
 	"ai-translate/internal/infrastructure/storage"
+	"ai-translate/internal/infrastructure/utils"
 	"context"
 	"errors"
-	"github.com/gogf/gf/v2/frame/g"
+	"fmt"
 	"time"
 )
 
@@ -20,9 +20,8 @@ type workService struct {
 	translationBatchRepo  work.TranslationBatchRepository
 	translationResultRepo work.TranslationResultRepository
 	promptRepo           prompt.PromptRepository
-	taskRepo             task.TaskRepository
-	taskQueue            task.TaskQueue
-	aiService            ai.ModelService
+	taskService          *taskService
+	aiDrivers            *ai.Registry
 	storageService       *storage.OSSService
 }
 
@@ -33,13 +32,7 @@ func NewWorkService() (work.WorkService, error) {
 		return nil, err
 	}
 
-	aiConfig := &ai.ModelConfig{
-		Type:      ai.ModelTypeGemini,
-		APIKey:    g.Cfg().MustGet(context.Background(), "gemini.apiKey").String(),
-		ModelName: g.Cfg().MustGet(context.Background(), "gemini.model").String(),
-	}
-
-	aiService, err := ai.NewGeminiService(aiConfig)
+	aiDrivers, err := ai.NewRegistry(context.Background())
 	if err != nil {
 		return nil, err
 	}
@@ -50,25 +43,32 @@ func NewWorkService() (work.WorkService, error) {
 		translationBatchRepo:  persistence.NewTranslationBatchRepository(),
 		translationResultRepo: persistence.NewTranslationResultRepository(),
 		promptRepo:           persistence.NewPromptRepository(),
-		taskRepo:             persistence.NewTaskRepository(),
-		taskQueue:            persistence.NewTaskQueue(),
-		aiService:            aiService,
+		taskService:          newTaskService(),
+		aiDrivers:            aiDrivers,
 		storageService:       storageService,
 	}, nil
 }
 
 func (s *workService) CreateWork(work *work.Work) error {
+	// 作品、内容生成任务与入队消息在同一事务中写入
+	return persistence.Transaction(func(tx *persistence.Tx) error {
+		return s.createWork(tx, work)
+	})
+}
+
+func (s *workService) createWork(tx *persistence.Tx, work *work.Work) error {
 	// 保存作品
-	err := s.workRepo.Save(work)
+	err := tx.Works().Save(work)
 	if err != nil {
 		return err
 	}
 
-	// 创建内容生成任务
+	// 创建内容生成任务并加入队列
 	task := &task.Task{
 		Type:        1, // 内容生成
 		Priority:    0,
-		Status:      0,
+		Status:      task.StatusWaiting,
+		WorkID:      work.ID,
 		ReferenceID: work.ID,
 		RetryCount:  0,
 		MaxRetry:    3,
@@ -76,13 +76,7 @@ func (s *workService) CreateWork(work *work.Work) error {
 		UpdatedAt:   time.Now(),
 	}
 
-	err = s.taskRepo.Save(task)
-	if err != nil {
-		return err
-	}
-
-	// 将任务加入队列
-	return s.taskQueue.Push(task)
+	return s.taskService.withTx(tx).CreateTask(task)
 }
 
 func (s *workService) GetWork(id uint64) (*work.Work, error) {
@@ -101,7 +95,48 @@ func (s *workService) DeleteWork(id uint64) error {
 	return s.workRepo.Delete(id)
 }
 
-func (s *workService) GenerateContentSummary(workID uint64) error {
+// CancelWork 取消作品，作品下未完成的翻译批次与未结束的任务一并取消
+// 作品的任务全部完成时视为已完成，不能取消
+func (s *workService) CancelWork(id uint64) error {
+	return persistence.Transaction(func(tx *persistence.Tx) error {
+		w, err := tx.Works().FindByID(id)
+		if err != nil {
+			return err
+		}
+
+		tasks, err := tx.Tasks().FindByWorkID(id)
+		if err != nil {
+			return err
+		}
+		if w.Status == utils.WorkStatusSuccess || allCompleted(tasks) {
+			return errors.New("作品已完成，无法取消")
+		}
+		if err := s.cancelTasks(tx, tasks); err != nil {
+			return err
+		}
+
+		batches, err := tx.TranslationBatches().FindByWorkID(id)
+		if err != nil {
+			return err
+		}
+		for _, batch := range batches {
+			if batch.Status == utils.TranslationBatchStatusSuccess || allCompleted(batchTasks(tasks, batch.ID)) {
+				continue
+			}
+			batch.Status = utils.TranslationBatchStatusCanceled
+			batch.UpdatedAt = time.Now()
+			if err := tx.TranslationBatches().Update(batch); err != nil {
+				return err
+			}
+		}
+
+		w.Status = utils.WorkStatusCanceled
+		w.UpdatedAt = time.Now()
+		return tx.Works().Update(w)
+	})
+}
+
+func (s *workService) GenerateContentSummary(ctx context.Context, workID uint64, driver string) error {
 	// 获取作品信息
 	w, err := s.workRepo.FindByID(workID)
 	if err != nil {
@@ -117,21 +152,22 @@ func (s *workService) GenerateContentSummary(workID uint64) error {
 		return errors.New("未找到内容简介提示词")
 	}
 
-	// 调用AI生成内容
-	req := &ai.GenerateContentRequest{
-		VideoURL:    w.VideoURL,
-		SubtitleURL: w.SubtitleURL,
-		Prompt:      prompts[0].Content,
+	// 按任务指定的驱动获取AI服务
+	aiService, err := s.aiDrivers.Get(ai.DriverType(driver))
+	if err != nil {
+		return err
 	}
 
-	resp, err := s.aiService.GenerateContent(context.Background(), req)
+	// 调用AI生成内容
+	prompt := prompts[0].Content + "\n视频URL: " + w.VideoURL + "\n字幕URL: " + w.SubtitleURL
+	content, err := aiService.Generate(ctx, prompt)
 	if err != nil {
 		return err
 	}
 
 	// 上传内容到OSS
 	objectKey := s.storageService.GenerateObjectKey("content_summaries", w.Title)
-	ossURL, err := s.storageService.UploadContent(objectKey, []byte(resp.Content))
+	ossURL, err := s.storageService.UploadContent(objectKey, []byte(content))
 	if err != nil {
 		return err
 	}
@@ -139,7 +175,7 @@ func (s *workService) GenerateContentSummary(workID uint64) error {
 	// 保存内容简介
 	summary := &work.ContentSummary{
 		WorkID:    workID,
-		Content:   resp.Content,
+		Content:   content,
 		OssURL:    ossURL,
 		Status:    1,
 		CreatedAt: time.Now(),
@@ -149,17 +185,35 @@ func (s *workService) GenerateContentSummary(workID uint64) error {
 }
 
 func (s *workService) CreateTranslationBatch(batch *work.TranslationBatch) error {
+	// 翻译批次、翻译任务、依赖关系与入队消息在同一事务中写入
+	return persistence.Transaction(func(tx *persistence.Tx) error {
+		return s.createTranslationBatch(tx, batch)
+	})
+}
+
+func (s *workService) createTranslationBatch(tx *persistence.Tx, batch *work.TranslationBatch) error {
 	// 保存翻译批次
-	err := s.translationBatchRepo.Save(batch)
+	err := tx.TranslationBatches().Save(batch)
 	if err != nil {
 		return err
 	}
 
-	// 创建翻译任务
+	// 翻译需要作品的内容简介，依赖作品最近一次内容生成任务
+	contentTasks, err := tx.Tasks().FindByReference(1, batch.WorkID) // 1:内容生成
+	if err != nil {
+		return err
+	}
+	var dependsOn []uint64
+	if len(contentTasks) > 0 {
+		dependsOn = append(dependsOn, contentTasks[len(contentTasks)-1].ID)
+	}
+
+	// 创建翻译任务，内容生成任务完成后才入队
 	task := &task.Task{
 		Type:        2, // 翻译
 		Priority:    0,
-		Status:      0,
+		Status:      task.StatusWaiting,
+		WorkID:      batch.WorkID,
 		ReferenceID: batch.ID,
 		RetryCount:  0,
 		MaxRetry:    3,
@@ -167,13 +221,23 @@ func (s *workService) CreateTranslationBatch(batch *work.TranslationBatch) error
 		UpdatedAt:   time.Now(),
 	}
 
-	err = s.taskRepo.Save(task)
+	return s.taskService.withTx(tx).CreateTask(task, dependsOn...)
+}
+
+func (s *workService) GetWorkTaskGraph(workID uint64) (*task.TaskGraph, error) {
+	return s.taskService.GetWorkTaskGraph(workID)
+}
+
+// CancelWorkTask 取消作品下的任务
+func (s *workService) CancelWorkTask(workID, taskID uint64) error {
+	t, err := s.taskService.GetTask(taskID)
 	if err != nil {
 		return err
 	}
-
-	// 将任务加入队列
-	return s.taskQueue.Push(task)
+	if t.WorkID != workID {
+		return fmt.Errorf("任务%d不属于作品%d", taskID, workID)
+	}
+	return s.taskService.CancelTask(taskID)
 }
 
 func (s *workService) GetTranslationBatch(id uint64) (*work.TranslationBatch, error) {
@@ -182,4 +246,65 @@ func (s *workService) GetTranslationBatch(id uint64) (*work.TranslationBatch, er
 
 func (s *workService) GetWorkTranslationBatches(workID uint64) ([]*work.TranslationBatch, error) {
 	return s.translationBatchRepo.FindByWorkID(workID)
+}
+
+func (s *workService) GetTranslationResult(batchID uint64) (*work.TranslationResult, error) {
+	return s.translationResultRepo.FindByBatchID(batchID)
+}
+
+// CancelTranslationBatch 取消翻译批次及其未结束的翻译任务，翻译任务全部完成时视为已完成，不能取消
+func (s *workService) CancelTranslationBatch(id uint64) error {
+	return persistence.Transaction(func(tx *persistence.Tx) error {
+		batch, err := tx.TranslationBatches().FindByID(id)
+		if err != nil {
+			return err
+		}
+
+		tasks, err := tx.Tasks().FindByReference(2, id) // 2:翻译
+		if err != nil {
+			return err
+		}
+		if batch.Status == utils.TranslationBatchStatusSuccess || allCompleted(tasks) {
+			return errors.New("翻译批次已完成，无法取消")
+		}
+		if err := s.cancelTasks(tx, tasks); err != nil {
+			return err
+		}
+
+		batch.Status = utils.TranslationBatchStatusCanceled
+		batch.UpdatedAt = time.Now()
+		return tx.TranslationBatches().Update(batch)
+	})
+}
+
+// allCompleted 任务是否全部完成，没有任务时返回false
+func allCompleted(tasks []*task.Task) bool {
+	for _, t := range tasks {
+		if t.Status != task.StatusCompleted {
+			return false
+		}
+	}
+	return len(tasks) > 0
+}
+
+// batchTasks 筛选翻译批次的翻译任务
+func batchTasks(tasks []*task.Task, batchID uint64) []*task.Task {
+	var result []*task.Task
+	for _, t := range tasks {
+		if t.Type == 2 && t.ReferenceID == batchID { // 2:翻译
+			result = append(result, t)
+		}
+	}
+	return result
+}
+
+// cancelTasks 在事务中取消未结束的任务，已结束的任务保持不变
+func (s *workService) cancelTasks(tx *persistence.Tx, tasks []*task.Task) error {
+	taskService := s.taskService.withTx(tx)
+	for _, t := range tasks {
+		if _, err := taskService.cancel(t); err != nil {
+			return err
+		}
+	}
+	return nil
 } 
\ No newline at end of file
@@ -4,17 +4,67 @@ import (
 	"time"
 )
 
+// 任务状态
+const (
+	StatusWaiting   = 0 // 等待中
+	StatusCompleted = 1 // 完成
+	StatusPaused    = 2 // 暂停
+	StatusFailed    = 3 // 失败
+	StatusBlocked   = 4 // 等待依赖
+	StatusCanceled  = 5 // 已取消
+)
+
+// 发件箱消息状态
+const (
+	OutboxPending   = 0 // 待投递
+	OutboxPublished = 1 // 已投递
+	OutboxSkipped   = 2 // 已跳过
+	OutboxFailed    = 3 // 投递失败
+)
+
 // Task 任务实体
 type Task struct {
+	ID            uint64    `json:"id"`
+	Type          int       `json:"type"` // 1:内容生成 2:翻译
+	Priority      int       `json:"priority"`
+	Status        int       `json:"status"`         // 0:等待中 1:完成 2:暂停 3:失败 4:等待依赖 5:已取消
+	WorkID        uint64    `json:"work_id"`        // 所属作品ID
+	ReferenceID   uint64    `json:"reference_id"`   // 关联ID
+	Driver        string    `json:"driver"`         // AI驱动名，为空时使用默认驱动
+	Error         string    `json:"error"`          // 失败原因
+	PartialResult string    `json:"partial_result"` // 已完成分块的译文，恢复处理时跳过这些分块
+	RetryCount    int       `json:"retry_count"`
+	MaxRetry      int       `json:"max_retry"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// TaskDependency 任务依赖，TaskID在DependsOnID完成前保持等待依赖状态，不会入队
+type TaskDependency struct {
 	ID          uint64    `json:"id"`
-	Type        int       `json:"type"` // 1:内容生成 2:翻译
-	Priority    int       `json:"priority"`
-	Status      int       `json:"status"`
-	ReferenceID uint64    `json:"reference_id"` // 关联ID
-	RetryCount  int       `json:"retry_count"`
-	MaxRetry    int       `json:"max_retry"`
+	WorkID      uint64    `json:"work_id"`       // 所属作品ID
+	TaskID      uint64    `json:"task_id"`       // 被阻塞的任务
+	DependsOnID uint64    `json:"depends_on_id"` // 依赖的任务
 	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// TaskGraph 作品的任务依赖图
+type TaskGraph struct {
+	WorkID uint64            `json:"work_id"`
+	Tasks  []*Task           `json:"tasks"`
+	Edges  []*TaskDependency `json:"edges"`
+}
+
+// OutboxMessage 发件箱消息，与任务在同一事务中写入，由中继投递到任务队列
+type OutboxMessage struct {
+	ID          uint64     `json:"id"`
+	TaskID      uint64     `json:"task_id"`
+	Payload     string     `json:"payload"`      // 入队的任务快照
+	Status      int        `json:"status"`       // 0:待投递 1:已投递 2:已跳过 3:投递失败
+	Attempts    int        `json:"attempts"`     // 投递失败次数
+	LastError   string     `json:"last_error"`   // 最近一次投递失败原因
+	CreatedAt   time.Time  `json:"created_at"`
+	PublishedAt *time.Time `json:"published_at"`
 }
 
 // TaskRepository 任务仓储接口
@@ -22,14 +72,34 @@ type TaskRepository interface {
 	FindByID(id uint64) (*Task, error)
 	FindByType(taskType int) ([]*Task, error)
 	FindByStatus(status int) ([]*Task, error)
+	FindByWorkID(workID uint64) ([]*Task, error)
+	FindByReference(taskType int, referenceID uint64) ([]*Task, error)
 	Save(task *Task) error
 	Update(task *Task) error
+	UpdateStatusFrom(id uint64, from, to int, errMsg string) (bool, error) // 仅当任务处于from状态时更新，返回是否已更新
+	UpdatePartialResult(id uint64, partial string) error
 	Delete(id uint64) error
 }
 
+// TaskDependencyRepository 任务依赖仓储接口
+type TaskDependencyRepository interface {
+	FindByTaskID(taskID uint64) ([]*TaskDependency, error)
+	FindByDependsOnID(dependsOnID uint64) ([]*TaskDependency, error)
+	FindByWorkID(workID uint64) ([]*TaskDependency, error)
+	Save(dependency *TaskDependency) error
+}
+
+// OutboxRepository 发件箱仓储接口
+type OutboxRepository interface {
+	FindPending(limit int) ([]*OutboxMessage, error) // 按ID升序获取待投递消息
+	Save(message *OutboxMessage) error
+	UpdateStatus(id uint64, status int) error
+	RecordFailure(id uint64, errMsg string) error
+}
+
 // TaskService 任务服务接口
 type TaskService interface {
-	CreateTask(task *Task) error
+	CreateTask(task *Task, dependsOn ...uint64) error
 	GetTask(id uint64) (*Task, error)
 	GetTasksByType(taskType int) ([]*Task, error)
 	GetTasksByStatus(status int) ([]*Task, error)
@@ -38,11 +108,17 @@ type TaskService interface {
 	PauseTask(id uint64) error
 	ResumeTask(id uint64) error
 	RetryTask(id uint64) error
+	CompleteTask(id uint64) error
+	FailTask(id uint64, reason string) error
+	CancelTask(id uint64) error
+	SavePartialResult(id uint64, partial string) error
+	GetWorkTaskGraph(workID uint64) (*TaskGraph, error)
 }
 
 // TaskQueue 任务队列接口
 type TaskQueue interface {
 	Push(task *Task) error
+	PushUnique(key string, task *Task) (bool, error) // 同一key在去重窗口内只入队一次，返回是否实际入队
 	Pop() (*Task, error)
 	Remove(id uint64) error
 	GetLength() (int64, error)
@@ -1,6 +1,8 @@
 package work
 
 import (
+	"ai-translate/internal/domain/task"
+	"context"
 	"time"
 )
 
@@ -37,13 +39,22 @@ type TranslationBatch struct {
 	UpdatedAt      time.Time `json:"updated_at"`
 }
 
+// TermViolation 译文未遵守的术语
+type TermViolation struct {
+	CueIndex int    `json:"cue_index"` // 字幕条目编号
+	Source   string `json:"source"`    // 原文术语
+	Target   string `json:"target"`    // 要求使用的译文术语
+}
+
 // TranslationResult 翻译结果实体
 type TranslationResult struct {
-	ID        uint64    `json:"id"`
-	BatchID   uint64    `json:"batch_id"`
-	SrtURL    string    `json:"srt_url"`
-	Status    int       `json:"status"`
-	CreatedAt time.Time `json:"created_at"`
+	ID         uint64           `json:"id"`
+	BatchID    uint64           `json:"batch_id"`
+	TaskID     string           `json:"task_id"` // 生成结果的翻译任务ID，唯一，重新处理同一任务时覆盖原结果
+	SrtURL     string           `json:"srt_url"`
+	Status     int              `json:"status"`
+	Violations []*TermViolation `json:"violations"` // 重试后仍未遵守术语表的条目
+	CreatedAt  time.Time        `json:"created_at"`
 }
 
 // WorkRepository 作品仓储接口
@@ -84,8 +95,13 @@ type WorkService interface {
 	GetUserWorks(userID uint64) ([]*Work, error)
 	UpdateWork(work *Work) error
 	DeleteWork(id uint64) error
-	GenerateContentSummary(workID uint64) error
+	CancelWork(id uint64) error
+	GenerateContentSummary(ctx context.Context, workID uint64, driver string) error
 	CreateTranslationBatch(batch *TranslationBatch) error
 	GetTranslationBatch(id uint64) (*TranslationBatch, error)
 	GetWorkTranslationBatches(workID uint64) ([]*TranslationBatch, error)
+	GetTranslationResult(batchID uint64) (*TranslationResult, error)
+	CancelTranslationBatch(id uint64) error
+	GetWorkTaskGraph(workID uint64) (*task.TaskGraph, error)
+	CancelWorkTask(workID, taskID uint64) error
 } 
\ No newline at end of file
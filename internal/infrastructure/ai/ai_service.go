@@ -3,52 +3,66 @@ package ai
 import (
 	"context"
 	"fmt"
-	"github.com/gogf/gf/v2/frame/g"
 )
 
 // DriverType AI驱动类型
 type DriverType string
 
 const (
-	DriverOpenAI  DriverType = "openai"
-	DriverGemini  DriverType = "gemini"
+	DriverOpenAI    DriverType = "openai"
+	DriverGemini    DriverType = "gemini"
+	DriverAnthropic DriverType = "anthropic"
 )
 
 // AIService AI服务接口
 type AIService interface {
+	AIDriver
+
 	// GenerateContent 生成内容
 	GenerateContent(ctx context.Context, prompt string, language string) (string, error)
 
 	// Translate 翻译内容
 	Translate(ctx context.Context, content string, sourceLang string, targetLang string) (string, error)
-}
 
-// NewAIService 创建AI服务
-func NewAIService(driverType DriverType) (AIService, error) {
-	switch driverType {
-	case DriverOpenAI:
-		return NewOpenAIService(
-			g.Cfg().MustGet("ai.apiKey").String(),
-			g.Cfg().MustGet("ai.model").String(),
-			g.Cfg().MustGet("ai.maxTokens").Int(),
-			g.Cfg().MustGet("ai.temperature").Float64(),
-			g.Cfg().MustGet("ai.timeout").Int(),
-		)
-	case DriverGemini:
-		return NewGeminiService(
-			g.Cfg().MustGet("gemini.apiKey").String(),
-			g.Cfg().MustGet("gemini.model").String(),
-			g.Cfg().MustGet("ai.timeout").Int(),
-		)
-	default:
-		return nil, fmt.Errorf("不支持的AI驱动类型: %s", driverType)
-	}
+	// GenerateContentStream 流式生成内容
+	GenerateContentStream(ctx context.Context, prompt string, language string, onDelta func(delta string)) (string, error)
+
+	// TranslateStream 流式翻译内容
+	TranslateStream(ctx context.Context, content string, sourceLang string, targetLang string, onDelta func(delta string)) (string, error)
 }
 
 // AIDriver AI驱动接口
 type AIDriver interface {
 	// Generate 生成内容
 	Generate(ctx context.Context, prompt string) (string, error)
+
+	// GenerateStream 流式生成内容，每收到一段文本调用一次onDelta，返回完整内容
+	GenerateStream(ctx context.Context, prompt string, onDelta func(delta string)) (string, error)
+}
+
+// DriverConfig AI驱动配置
+type DriverConfig struct {
+	Type        DriverType `json:"type"`        // 驱动类型
+	APIKey      string     `json:"apiKey"`      // API密钥
+	Model       string     `json:"model"`       // 模型名称
+	MaxTokens   int        `json:"maxTokens"`   // 最大输出token数
+	Temperature float64    `json:"temperature"` // 温度
+	Timeout     int        `json:"timeout"`     // 超时时间（秒）
+
+	// 以下配置用于OpenAI兼容接口（vLLM、llama.cpp、Azure等）
+	BaseURL    string            `json:"baseURL"`    // 接口地址，如 http://127.0.0.1:8000/v1
+	APIVersion string            `json:"apiVersion"` // Azure api-version 查询参数
+	AuthHeader string            `json:"authHeader"` // 密钥请求头，默认Authorization（Bearer），Azure为api-key
+	Headers    map[string]string `json:"headers"`    // 额外请求头
+}
+
+// NewAIService 根据配置创建AI服务
+func NewAIService(config *DriverConfig) (AIService, error) {
+	factory, ok := lookupDriver(config.Type)
+	if !ok {
+		return nil, fmt.Errorf("不支持的AI驱动类型: %s", config.Type)
+	}
+	return factory(config)
 }
 
 // BaseAIService 基础AI服务
@@ -76,40 +90,16 @@ func (s *BaseAIService) Translate(ctx context.Context, content string, sourceLan
 	return s.driver.Generate(ctx, fullPrompt)
 }
 
-// OpenAIService OpenAI服务实现
-type OpenAIService struct {
-	apiKey      string
-	model       string
-	maxTokens   int
-	temperature float64
-	timeout     int
-}
-
-// NewOpenAIService 创建OpenAI服务
-func NewOpenAIService(apiKey, model string, maxTokens int, temperature float64, timeout int) (*OpenAIService, error) {
-	if apiKey == "" {
-		return nil, fmt.Errorf("API密钥不能为空")
-	}
-
-	return &OpenAIService{
-		apiKey:      apiKey,
-		model:       model,
-		maxTokens:   maxTokens,
-		temperature: temperature,
-		timeout:     timeout,
-	}, nil
+// GenerateContentStream 流式生成内容
+func (s *BaseAIService) GenerateContentStream(ctx context.Context, prompt string, language string, onDelta func(delta string)) (string, error) {
+	systemPrompt := fmt.Sprintf("你是一个专业的内容生成助手。请用%s语言生成内容。", language)
+	fullPrompt := fmt.Sprintf("%s\n\n请根据以下内容生成相关内容：\n%s", systemPrompt, prompt)
+	return s.driver.GenerateStream(ctx, fullPrompt, onDelta)
 }
 
-// GenerateContent 生成内容
-func (s *OpenAIService) GenerateContent(ctx context.Context, prompt string, language string) (string, error) {
-	// TODO: 实现OpenAI API调用
-	// 这里需要根据实际的OpenAI API实现
-	return "", fmt.Errorf("未实现")
+// TranslateStream 流式翻译内容
+func (s *BaseAIService) TranslateStream(ctx context.Context, content string, sourceLang string, targetLang string, onDelta func(delta string)) (string, error) {
+	systemPrompt := fmt.Sprintf("你是一个专业的翻译助手。请将%s语言翻译成%s语言。", sourceLang, targetLang)
+	fullPrompt := fmt.Sprintf("%s\n\n请翻译以下内容：\n%s", systemPrompt, content)
+	return s.driver.GenerateStream(ctx, fullPrompt, onDelta)
 }
-
-// Translate 翻译内容
-func (s *OpenAIService) Translate(ctx context.Context, content string, sourceLang string, targetLang string) (string, error) {
-	// TODO: 实现OpenAI API调用
-	// 这里需要根据实际的OpenAI API实现
-	return "", fmt.Errorf("未实现")
-} 
\ No newline at end of file
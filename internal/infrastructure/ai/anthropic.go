@@ -0,0 +1,327 @@
+package ai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultAnthropicBaseURL Anthropic官方接口地址
+	defaultAnthropicBaseURL = "https://api.anthropic.com/v1"
+	// anthropicVersion Messages API版本
+	anthropicVersion = "2023-06-01"
+	// defaultAnthropicMaxTokens Messages API要求必须指定max_tokens
+	defaultAnthropicMaxTokens = 4096
+)
+
+func init() {
+	RegisterDriver(DriverAnthropic, func(config *DriverConfig) (AIService, error) {
+		return NewAnthropicService(config)
+	})
+}
+
+// AnthropicService Anthropic服务实现
+type AnthropicService struct {
+	BaseAIService
+	apiKey      string
+	model       string
+	maxTokens   int
+	temperature float64
+	timeout     int
+	baseURL     string
+	headers     map[string]string
+}
+
+// NewAnthropicService 创建Anthropic服务
+func NewAnthropicService(config *DriverConfig) (*AnthropicService, error) {
+	if config.APIKey == "" {
+		return nil, fmt.Errorf("API密钥不能为空")
+	}
+	if config.Model == "" {
+		return nil, fmt.Errorf("模型名称不能为空")
+	}
+
+	baseURL := strings.TrimRight(config.BaseURL, "/")
+	if baseURL == "" {
+		baseURL = defaultAnthropicBaseURL
+	}
+	maxTokens := config.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = defaultAnthropicMaxTokens
+	}
+
+	service := &AnthropicService{
+		apiKey:      config.APIKey,
+		model:       config.Model,
+		maxTokens:   maxTokens,
+		temperature: config.Temperature,
+		timeout:     config.Timeout,
+		baseURL:     baseURL,
+		headers:     config.Headers,
+	}
+	service.driver = service
+	return service, nil
+}
+
+// AnthropicRequest Anthropic请求结构
+type AnthropicRequest struct {
+	Model       string             `json:"model"`
+	System      string             `json:"system,omitempty"`
+	Messages    []AnthropicMessage `json:"messages"`
+	MaxTokens   int                `json:"max_tokens"`
+	Temperature float64            `json:"temperature"`
+	Stream      bool               `json:"stream,omitempty"`
+}
+
+// AnthropicMessage Anthropic消息结构
+type AnthropicMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// AnthropicResponse Anthropic响应结构
+type AnthropicResponse struct {
+	Content    []AnthropicContentBlock `json:"content"`
+	StopReason string                  `json:"stop_reason"`
+}
+
+// AnthropicContentBlock Anthropic内容块结构
+type AnthropicContentBlock struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// AnthropicError Anthropic错误结构
+type AnthropicError struct {
+	Type  string `json:"type"`
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// AnthropicStreamEvent Anthropic流式事件结构
+type AnthropicStreamEvent struct {
+	Type  string `json:"type"`
+	Delta struct {
+		Type       string `json:"type"`
+		Text       string `json:"text"`
+		StopReason string `json:"stop_reason"`
+	} `json:"delta"`
+}
+
+// Generate 生成内容
+func (s *AnthropicService) Generate(ctx context.Context, prompt string) (string, error) {
+	return s.complete(ctx, "", prompt)
+}
+
+// GenerateContent 生成内容
+func (s *AnthropicService) GenerateContent(ctx context.Context, prompt string, language string) (string, error) {
+	systemPrompt := fmt.Sprintf("你是一个专业的内容生成助手。请用%s语言生成内容。", language)
+	userPrompt := fmt.Sprintf("请根据以下内容生成相关内容：\n%s", prompt)
+	return s.complete(ctx, systemPrompt, userPrompt)
+}
+
+// Translate 翻译内容
+func (s *AnthropicService) Translate(ctx context.Context, content string, sourceLang string, targetLang string) (string, error) {
+	systemPrompt := fmt.Sprintf("你是一个专业的翻译助手。请将%s语言翻译成%s语言。", sourceLang, targetLang)
+	userPrompt := fmt.Sprintf("请翻译以下内容：\n%s", content)
+	return s.complete(ctx, systemPrompt, userPrompt)
+}
+
+// GenerateStream 流式生成内容，每收到一段文本调用一次onDelta，返回完整内容
+func (s *AnthropicService) GenerateStream(ctx context.Context, prompt string, onDelta func(delta string)) (string, error) {
+	req := s.newRequest("", prompt)
+	req.Stream = true
+
+	resp, err := s.sendRequest(ctx, req)
+	if err != nil {
+		return "", fmt.Errorf("发送请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var content strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var event, stopReason string
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+			if event == "error" {
+				return content.String(), s.parseError(0, nil, []byte(data))
+			}
+
+			var streamEvent AnthropicStreamEvent
+			if err := json.Unmarshal([]byte(data), &streamEvent); err != nil {
+				return content.String(), fmt.Errorf("解析流式事件失败: %v", err)
+			}
+			if streamEvent.Type == "content_block_delta" && streamEvent.Delta.Type == "text_delta" {
+				content.WriteString(streamEvent.Delta.Text)
+				if onDelta != nil {
+					onDelta(streamEvent.Delta.Text)
+				}
+			}
+			if streamEvent.Type == "message_delta" && streamEvent.Delta.StopReason != "" {
+				stopReason = streamEvent.Delta.StopReason
+			}
+			if streamEvent.Type == "message_stop" {
+				switch stopReason {
+				case "refusal":
+					return content.String(), contentFiltered(DriverAnthropic, resp.StatusCode)
+				case "max_tokens":
+					return content.String(), truncated(DriverAnthropic, resp.StatusCode)
+				}
+				return content.String(), nil
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return content.String(), fmt.Errorf("读取流式响应失败: %w", err)
+	}
+
+	return content.String(), nil
+}
+
+// complete 发送非流式请求并拼接文本内容
+func (s *AnthropicService) complete(ctx context.Context, systemPrompt, userPrompt string) (string, error) {
+	resp, err := s.sendRequest(ctx, s.newRequest(systemPrompt, userPrompt))
+	if err != nil {
+		return "", fmt.Errorf("发送请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("读取响应失败: %v", err)
+	}
+
+	var anthropicResp AnthropicResponse
+	if err := json.Unmarshal(body, &anthropicResp); err != nil {
+		return "", fmt.Errorf("解析响应失败: %v", err)
+	}
+
+	switch anthropicResp.StopReason {
+	case "refusal":
+		return "", contentFiltered(DriverAnthropic, resp.StatusCode)
+	case "max_tokens":
+		return "", truncated(DriverAnthropic, resp.StatusCode)
+	}
+
+	var content strings.Builder
+	for _, block := range anthropicResp.Content {
+		if block.Type == "text" {
+			content.WriteString(block.Text)
+		}
+	}
+	if content.Len() == 0 {
+		return "", fmt.Errorf("未获取到生成内容")
+	}
+
+	return content.String(), nil
+}
+
+// newRequest 构建请求
+func (s *AnthropicService) newRequest(systemPrompt, userPrompt string) AnthropicRequest {
+	return AnthropicRequest{
+		Model:       s.model,
+		System:      systemPrompt,
+		MaxTokens:   s.maxTokens,
+		Temperature: s.temperature,
+		Messages: []AnthropicMessage{
+			{
+				Role:    "user",
+				Content: userPrompt,
+			},
+		},
+	}
+}
+
+// sendRequest 发送HTTP请求，状态码非200时返回服务商错误
+func (s *AnthropicService) sendRequest(ctx context.Context, req AnthropicRequest) (*http.Response, error) {
+	// 序列化请求
+	reqBody, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("序列化请求失败: %v", err)
+	}
+
+	// 创建HTTP请求
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", s.baseURL+"/messages", bytes.NewBuffer(reqBody))
+	if err != nil {
+		return nil, fmt.Errorf("创建HTTP请求失败: %v", err)
+	}
+
+	// 设置请求头
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("x-api-key", s.apiKey)
+	httpReq.Header.Set("anthropic-version", anthropicVersion)
+	for key, value := range s.headers {
+		httpReq.Header.Set(key, value)
+	}
+
+	// 流式请求不设置整体超时，由ctx控制
+	client := &http.Client{}
+	if !req.Stream {
+		client.Timeout = time.Duration(s.timeout) * time.Second
+	}
+
+	// 发送请求
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("发送HTTP请求失败: %w", err)
+	}
+
+	// 检查状态码
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return nil, s.parseError(resp.StatusCode, resp.Header, body)
+	}
+
+	return resp, nil
+}
+
+// parseError 将Anthropic错误响应映射为服务商错误
+func (s *AnthropicService) parseError(statusCode int, header http.Header, body []byte) error {
+	var anthropicErr AnthropicError
+	message := string(body)
+	if err := json.Unmarshal(body, &anthropicErr); err == nil && anthropicErr.Error.Message != "" {
+		message = anthropicErr.Error.Message
+	}
+
+	kind := classifyStatus(statusCode)
+	switch anthropicErr.Error.Type {
+	case "rate_limit_error":
+		kind = ErrorKindRateLimited
+	case "overloaded_error":
+		kind = ErrorKindOverloaded
+	case "api_error":
+		kind = ErrorKindServerError
+	case "authentication_error", "permission_error":
+		kind = ErrorKindAuthFailed
+	case "invalid_request_error", "not_found_error", "request_too_large":
+		kind = ErrorKindBadRequest
+	}
+	if kind == ErrorKindBadRequest && isContextOverflow(message) {
+		kind = ErrorKindContextTooLong
+	}
+
+	return &ProviderError{
+		Provider:   DriverAnthropic,
+		Kind:       kind,
+		StatusCode: statusCode,
+		RetryAfter: parseRetryAfter(header),
+		Message:    message,
+	}
+}
@@ -0,0 +1,173 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrorKind 服务商错误类型
+type ErrorKind string
+
+const (
+	ErrorKindRateLimited     ErrorKind = "rate_limited"     // 触发限流
+	ErrorKindOverloaded      ErrorKind = "overloaded"       // 服务过载
+	ErrorKindServerError     ErrorKind = "server_error"     // 服务端错误
+	ErrorKindAuthFailed      ErrorKind = "auth_failed"      // 认证或权限失败
+	ErrorKindQuotaExceeded   ErrorKind = "quota_exceeded"   // 账户额度不足
+	ErrorKindContentFiltered ErrorKind = "content_filtered" // 内容被安全策略拦截
+	ErrorKindContextTooLong  ErrorKind = "context_too_long" // 上下文超长
+	ErrorKindBadRequest      ErrorKind = "bad_request"      // 请求参数错误
+	ErrorKindTruncated       ErrorKind = "truncated"        // 输出达到最大token数被截断
+	ErrorKindUnknown         ErrorKind = "unknown"          // 未归类错误
+)
+
+// ProviderError 服务商错误
+type ProviderError struct {
+	Provider   DriverType    // 驱动类型
+	Kind       ErrorKind     // 错误类型
+	StatusCode int           // HTTP状态码，流式错误事件为0
+	RetryAfter time.Duration // 服务商建议的重试等待时间
+	Message    string        // 服务商返回的错误信息
+}
+
+// Error 实现error接口
+func (e *ProviderError) Error() string {
+	return fmt.Sprintf("%s请求失败(%s)，状态码：%d，响应：%s", e.Provider, e.Kind, e.StatusCode, e.Message)
+}
+
+// Retryable 是否可稍后重试
+func (e *ProviderError) Retryable() bool {
+	switch e.Kind {
+	case ErrorKindRateLimited, ErrorKindOverloaded, ErrorKindServerError, ErrorKindUnknown:
+		return true
+	}
+	return false
+}
+
+// AsProviderError 从错误链中提取服务商错误
+func AsProviderError(err error) (*ProviderError, bool) {
+	var providerErr *ProviderError
+	if errors.As(err, &providerErr) {
+		return providerErr, true
+	}
+	return nil, false
+}
+
+// IsRetryable 判断错误是否可稍后重试
+// 服务商错误按错误类型判断；超时与网络传输错误可重试；任务被取消、配置或数据错误等其余错误不重试
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if providerErr, ok := AsProviderError(err); ok {
+		return providerErr.Retryable()
+	}
+	return isTransportError(err)
+}
+
+// isTransportError 是否为超时或网络传输错误，如连接被拒绝、连接被重置、响应中途断开
+func isTransportError(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return true
+	}
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && (dnsErr.IsTemporary || dnsErr.IsTimeout)
+}
+
+// RetryAfter 获取服务商建议的重试等待时间，未建议时返回0
+func RetryAfter(err error) time.Duration {
+	if providerErr, ok := AsProviderError(err); ok {
+		return providerErr.RetryAfter
+	}
+	return 0
+}
+
+// contentFiltered 创建内容被拦截错误，用于状态码正常但结果被安全策略拦截的响应
+func contentFiltered(provider DriverType, statusCode int) *ProviderError {
+	return &ProviderError{
+		Provider:   provider,
+		Kind:       ErrorKindContentFiltered,
+		StatusCode: statusCode,
+		Message:    "内容被服务商安全策略拦截",
+	}
+}
+
+// truncated 创建输出被截断错误，用于因达到最大token数而停止生成的响应，原样重试仍会被截断
+func truncated(provider DriverType, statusCode int) *ProviderError {
+	return &ProviderError{
+		Provider:   provider,
+		Kind:       ErrorKindTruncated,
+		StatusCode: statusCode,
+		Message:    "输出达到最大token数被截断",
+	}
+}
+
+// classifyStatus 按HTTP状态码归类错误
+func classifyStatus(statusCode int) ErrorKind {
+	switch {
+	case statusCode == http.StatusTooManyRequests:
+		return ErrorKindRateLimited
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden:
+		return ErrorKindAuthFailed
+	case statusCode == http.StatusRequestEntityTooLarge:
+		return ErrorKindContextTooLong
+	case statusCode == http.StatusServiceUnavailable || statusCode == 529:
+		return ErrorKindOverloaded
+	case statusCode >= 500:
+		return ErrorKindServerError
+	case statusCode >= 400:
+		return ErrorKindBadRequest
+	}
+	return ErrorKindUnknown
+}
+
+// isContextOverflow 根据错误信息判断是否为上下文超长
+func isContextOverflow(message string) bool {
+	message = strings.ToLower(message)
+	for _, keyword := range []string{"context length", "context_length", "context window", "maximum context", "too many tokens", "prompt is too long", "exceeds the maximum number of tokens"} {
+		if strings.Contains(message, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseRetryAfter 解析Retry-After响应头，支持秒数与HTTP日期两种格式
+func parseRetryAfter(header http.Header) time.Duration {
+	if header == nil {
+		return 0
+	}
+	value := strings.TrimSpace(header.Get("Retry-After"))
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if delay := time.Until(at); delay > 0 {
+			return delay
+		}
+	}
+	return 0
+}
@@ -0,0 +1,270 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestParseError(t *testing.T) {
+	anthropic := &AnthropicService{}
+	openai := &OpenAIService{}
+	gemini := &GeminiService{}
+
+	cases := []struct {
+		name       string
+		parse      func(statusCode int, header http.Header, body []byte) error
+		statusCode int
+		header     http.Header
+		body       string
+		kind       ErrorKind
+		retryAfter time.Duration
+		retryable  bool
+	}{
+		{
+			name:       "Anthropic限流并给出Retry-After",
+			parse:      anthropic.parseError,
+			statusCode: 429,
+			header:     http.Header{"Retry-After": []string{"30"}},
+			body:       `{"type":"error","error":{"type":"rate_limit_error","message":"rate limited"}}`,
+			kind:       ErrorKindRateLimited,
+			retryAfter: 30 * time.Second,
+			retryable:  true,
+		},
+		{
+			name:       "Anthropic过载",
+			parse:      anthropic.parseError,
+			statusCode: 529,
+			body:       `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`,
+			kind:       ErrorKindOverloaded,
+			retryable:  true,
+		},
+		{
+			name:       "Anthropic流式错误事件",
+			parse:      anthropic.parseError,
+			statusCode: 0,
+			body:       `{"type":"error","error":{"type":"api_error","message":"internal"}}`,
+			kind:       ErrorKindServerError,
+			retryable:  true,
+		},
+		{
+			name:       "Anthropic上下文超长",
+			parse:      anthropic.parseError,
+			statusCode: 400,
+			body:       `{"type":"error","error":{"type":"invalid_request_error","message":"prompt is too long: 210000 tokens > 200000 maximum"}}`,
+			kind:       ErrorKindContextTooLong,
+		},
+		{
+			name:       "Anthropic认证失败",
+			parse:      anthropic.parseError,
+			statusCode: 401,
+			body:       `{"type":"error","error":{"type":"authentication_error","message":"invalid x-api-key"}}`,
+			kind:       ErrorKindAuthFailed,
+		},
+		{
+			name:       "OpenAI额度不足",
+			parse:      openai.parseError,
+			statusCode: 429,
+			body:       `{"error":{"message":"quota","type":"insufficient_quota","code":"insufficient_quota"}}`,
+			kind:       ErrorKindQuotaExceeded,
+		},
+		{
+			name:       "OpenAI上下文超长",
+			parse:      openai.parseError,
+			statusCode: 400,
+			body:       `{"error":{"message":"too long","type":"invalid_request_error","code":"context_length_exceeded"}}`,
+			kind:       ErrorKindContextTooLong,
+		},
+		{
+			name:       "OpenAI内容被拦截",
+			parse:      openai.parseError,
+			statusCode: 400,
+			body:       `{"error":{"message":"blocked","code":"content_filter"}}`,
+			kind:       ErrorKindContentFiltered,
+		},
+		{
+			name:       "OpenAI兼容接口返回非JSON的服务端错误",
+			parse:      openai.parseError,
+			statusCode: 502,
+			body:       `Bad Gateway`,
+			kind:       ErrorKindServerError,
+			retryable:  true,
+		},
+		{
+			name:       "Gemini资源耗尽",
+			parse:      gemini.parseError,
+			statusCode: 429,
+			body:       `{"error":{"code":429,"message":"quota","status":"RESOURCE_EXHAUSTED"}}`,
+			kind:       ErrorKindRateLimited,
+			retryable:  true,
+		},
+		{
+			name:       "Gemini服务不可用",
+			parse:      gemini.parseError,
+			statusCode: 503,
+			body:       `{"error":{"code":503,"message":"unavailable","status":"UNAVAILABLE"}}`,
+			kind:       ErrorKindOverloaded,
+			retryable:  true,
+		},
+		{
+			name:       "Gemini上下文超长",
+			parse:      gemini.parseError,
+			statusCode: 400,
+			body:       `{"error":{"code":400,"message":"The input token count exceeds the maximum number of tokens allowed","status":"INVALID_ARGUMENT"}}`,
+			kind:       ErrorKindContextTooLong,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := c.parse(c.statusCode, c.header, []byte(c.body))
+			providerErr, ok := AsProviderError(fmt.Errorf("发送请求失败: %w", err))
+			if !ok {
+				t.Fatalf("期望服务商错误，实际 %T", err)
+			}
+			if providerErr.Kind != c.kind {
+				t.Errorf("错误类型 = %s, 期望 %s", providerErr.Kind, c.kind)
+			}
+			if providerErr.RetryAfter != c.retryAfter {
+				t.Errorf("Retry-After = %s, 期望 %s", providerErr.RetryAfter, c.retryAfter)
+			}
+			if got := IsRetryable(err); got != c.retryable {
+				t.Errorf("IsRetryable = %v, 期望 %v", got, c.retryable)
+			}
+		})
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"任务被取消", fmt.Errorf("请求失败: %w", context.Canceled), false},
+		{"请求超时", fmt.Errorf("请求失败: %w", context.DeadlineExceeded), true},
+		{"响应中途断开", fmt.Errorf("读取流式响应失败: %w", io.ErrUnexpectedEOF), true},
+		{"连接被重置", fmt.Errorf("发送HTTP请求失败: %w", syscall.ECONNRESET), true},
+		{"网络错误", &net.OpError{Op: "dial", Err: errors.New("no route to host")}, true},
+		{"临时DNS错误", &net.DNSError{Err: "timeout", IsTemporary: true}, true},
+		{"DNS解析失败", &net.DNSError{Err: "no such host", IsNotFound: true}, false},
+		{"输出被截断", truncated(DriverAnthropic, 200), false},
+		{"内容被拦截", contentFiltered(DriverOpenAI, 200), false},
+		{"配置错误", errors.New("未找到内容简介提示词"), false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := IsRetryable(c.err); got != c.want {
+				t.Errorf("IsRetryable(%v) = %v, 期望 %v", c.err, got, c.want)
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	cases := []struct {
+		name   string
+		header http.Header
+		min    time.Duration
+		max    time.Duration
+	}{
+		{"没有响应头", nil, 0, 0},
+		{"秒数", http.Header{"Retry-After": []string{"12"}}, 12 * time.Second, 12 * time.Second},
+		{"无效值", http.Header{"Retry-After": []string{"soon"}}, 0, 0},
+		{"负数", http.Header{"Retry-After": []string{"-5"}}, 0, 0},
+		{"HTTP日期", http.Header{"Retry-After": []string{time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)}}, 55 * time.Second, time.Minute},
+		{"已过去的HTTP日期", http.Header{"Retry-After": []string{time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat)}}, 0, 0},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := parseRetryAfter(c.header); got < c.min || got > c.max {
+				t.Errorf("parseRetryAfter = %s, 期望在 [%s, %s] 内", got, c.min, c.max)
+			}
+		})
+	}
+}
+
+func TestAnthropicStopReason(t *testing.T) {
+	cases := []struct {
+		name    string
+		stream  bool
+		body    string
+		content string
+		kind    ErrorKind
+	}{
+		{
+			name:    "正常结束",
+			body:    `{"content":[{"type":"text","text":"你好"}],"stop_reason":"end_turn"}`,
+			content: "你好",
+		},
+		{
+			name: "达到最大token数",
+			body: `{"content":[{"type":"text","text":"你"}],"stop_reason":"max_tokens"}`,
+			kind: ErrorKindTruncated,
+		},
+		{
+			name: "拒绝回答",
+			body: `{"content":[],"stop_reason":"refusal"}`,
+			kind: ErrorKindContentFiltered,
+		},
+		{
+			name:   "流式正常结束",
+			stream: true,
+			body: "event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"你好\"}}\n\n" +
+				"event: message_delta\ndata: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"end_turn\"}}\n\n" +
+				"event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n",
+			content: "你好",
+		},
+		{
+			name:   "流式达到最大token数时返回已生成的内容",
+			stream: true,
+			body: "event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"你\"}}\n\n" +
+				"event: message_delta\ndata: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"max_tokens\"}}\n\n" +
+				"event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n",
+			content: "你",
+			kind:    ErrorKindTruncated,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				io.WriteString(w, c.body)
+			}))
+			defer server.Close()
+
+			service, err := NewAnthropicService(&DriverConfig{APIKey: "test", Model: "test", BaseURL: server.URL, Timeout: 5})
+			if err != nil {
+				t.Fatalf("创建服务失败: %v", err)
+			}
+
+			var content string
+			if c.stream {
+				content, err = service.GenerateStream(context.Background(), "hi", nil)
+			} else {
+				content, err = service.Generate(context.Background(), "hi")
+			}
+
+			if c.kind == "" {
+				if err != nil {
+					t.Fatalf("期望成功，实际错误: %v", err)
+				}
+			} else if providerErr, ok := AsProviderError(err); !ok || providerErr.Kind != c.kind {
+				t.Fatalf("错误 = %v, 期望类型 %s", err, c.kind)
+			}
+			if content != c.content {
+				t.Errorf("内容 = %q, 期望 %q", content, c.content)
+			}
+		})
+	}
+}
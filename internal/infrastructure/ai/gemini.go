@@ -1,12 +1,14 @@
 package ai
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"time"
 )
 
@@ -18,6 +20,12 @@ type GeminiService struct {
 	timeout int
 }
 
+func init() {
+	RegisterDriver(DriverGemini, func(config *DriverConfig) (AIService, error) {
+		return NewGeminiService(config.APIKey, config.Model, config.Timeout)
+	})
+}
+
 // NewGeminiService 创建Gemini服务
 func NewGeminiService(apiKey, model string, timeout int) (*GeminiService, error) {
 	if apiKey == "" {
@@ -51,7 +59,7 @@ func (s *GeminiService) Generate(ctx context.Context, prompt string) (string, er
 	// 发送请求
 	resp, err := s.sendRequest(ctx, fmt.Sprintf("https://generativelanguage.googleapis.com/v1beta/models/%s:generateContent?key=%s", s.model, s.apiKey), req)
 	if err != nil {
-		return "", fmt.Errorf("发送请求失败: %v", err)
+		return "", fmt.Errorf("发送请求失败: %w", err)
 	}
 
 	// 解析响应
@@ -62,6 +70,72 @@ func (s *GeminiService) Generate(ctx context.Context, prompt string) (string, er
 	return resp.Candidates[0].Content.Parts[0].Text, nil
 }
 
+// GenerateStream 流式生成内容，每收到一段文本调用一次onDelta，返回完整内容
+func (s *GeminiService) GenerateStream(ctx context.Context, prompt string, onDelta func(delta string)) (string, error) {
+	req := GeminiRequest{
+		Contents: []GeminiContent{
+			{
+				Parts: []GeminiPart{
+					{
+						Text: prompt,
+					},
+				},
+			},
+		},
+	}
+
+	httpReq, err := s.newHTTPRequest(ctx, fmt.Sprintf("https://generativelanguage.googleapis.com/v1beta/models/%s:streamGenerateContent?alt=sse&key=%s", s.model, s.apiKey), req)
+	if err != nil {
+		return "", err
+	}
+
+	// 流式请求不设置整体超时，由ctx控制
+	resp, err := (&http.Client{}).Do(httpReq)
+	if err != nil {
+		return "", fmt.Errorf("发送HTTP请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", s.parseError(resp.StatusCode, resp.Header, body)
+	}
+
+	var content strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+
+		var chunk GeminiResponse
+		if err := json.Unmarshal([]byte(strings.TrimSpace(strings.TrimPrefix(line, "data:"))), &chunk); err != nil {
+			return content.String(), fmt.Errorf("解析流式响应失败: %v", err)
+		}
+		if chunk.blocked() {
+			return content.String(), contentFiltered(DriverGemini, resp.StatusCode)
+		}
+		for _, candidate := range chunk.Candidates {
+			for _, part := range candidate.Content.Parts {
+				if part.Text == "" {
+					continue
+				}
+				content.WriteString(part.Text)
+				if onDelta != nil {
+					onDelta(part.Text)
+				}
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return content.String(), fmt.Errorf("读取流式响应失败: %w", err)
+	}
+
+	return content.String(), nil
+}
+
 // GeminiRequest Gemini请求结构
 type GeminiRequest struct {
 	Contents []GeminiContent `json:"contents"`
@@ -79,16 +153,43 @@ type GeminiPart struct {
 
 // GeminiResponse Gemini响应结构
 type GeminiResponse struct {
-	Candidates []GeminiCandidate `json:"candidates"`
+	Candidates     []GeminiCandidate `json:"candidates"`
+	PromptFeedback struct {
+		BlockReason string `json:"blockReason"`
+	} `json:"promptFeedback"`
 }
 
 // GeminiCandidate Gemini候选结构
 type GeminiCandidate struct {
-	Content GeminiContent `json:"content"`
+	Content      GeminiContent `json:"content"`
+	FinishReason string        `json:"finishReason"`
 }
 
-// sendRequest 发送HTTP请求
-func (s *GeminiService) sendRequest(ctx context.Context, url string, req GeminiRequest) (*GeminiResponse, error) {
+// GeminiError Gemini错误结构
+type GeminiError struct {
+	Error struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Status  string `json:"status"`
+	} `json:"error"`
+}
+
+// blocked 提示词或生成内容是否被安全策略拦截
+func (r *GeminiResponse) blocked() bool {
+	if r.PromptFeedback.BlockReason != "" {
+		return true
+	}
+	for _, candidate := range r.Candidates {
+		switch candidate.FinishReason {
+		case "SAFETY", "BLOCKLIST", "PROHIBITED_CONTENT", "SPII":
+			return true
+		}
+	}
+	return false
+}
+
+// newHTTPRequest 构建HTTP请求
+func (s *GeminiService) newHTTPRequest(ctx context.Context, url string, req GeminiRequest) (*http.Request, error) {
 	// 序列化请求
 	reqBody, err := json.Marshal(req)
 	if err != nil {
@@ -104,6 +205,16 @@ func (s *GeminiService) sendRequest(ctx context.Context, url string, req GeminiR
 	// 设置请求头
 	httpReq.Header.Set("Content-Type", "application/json")
 
+	return httpReq, nil
+}
+
+// sendRequest 发送HTTP请求
+func (s *GeminiService) sendRequest(ctx context.Context, url string, req GeminiRequest) (*GeminiResponse, error) {
+	httpReq, err := s.newHTTPRequest(ctx, url, req)
+	if err != nil {
+		return nil, err
+	}
+
 	// 创建HTTP客户端
 	client := &http.Client{
 		Timeout: time.Duration(s.timeout) * time.Second,
@@ -112,7 +223,7 @@ func (s *GeminiService) sendRequest(ctx context.Context, url string, req GeminiR
 	// 发送请求
 	resp, err := client.Do(httpReq)
 	if err != nil {
-		return nil, fmt.Errorf("发送HTTP请求失败: %v", err)
+		return nil, fmt.Errorf("发送HTTP请求失败: %w", err)
 	}
 	defer resp.Body.Close()
 
@@ -124,7 +235,7 @@ func (s *GeminiService) sendRequest(ctx context.Context, url string, req GeminiR
 
 	// 检查状态码
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("请求失败，状态码：%d，响应：%s", resp.StatusCode, string(body))
+		return nil, s.parseError(resp.StatusCode, resp.Header, body)
 	}
 
 	// 解析响应
@@ -132,6 +243,39 @@ func (s *GeminiService) sendRequest(ctx context.Context, url string, req GeminiR
 	if err := json.Unmarshal(body, &geminiResp); err != nil {
 		return nil, fmt.Errorf("解析响应失败: %v", err)
 	}
+	if geminiResp.blocked() {
+		return nil, contentFiltered(DriverGemini, resp.StatusCode)
+	}
 
 	return &geminiResp, nil
-} 
\ No newline at end of file
+}
+
+// parseError 将Gemini错误响应映射为服务商错误
+func (s *GeminiService) parseError(statusCode int, header http.Header, body []byte) error {
+	var geminiErr GeminiError
+	message := string(body)
+	if err := json.Unmarshal(body, &geminiErr); err == nil && geminiErr.Error.Message != "" {
+		message = geminiErr.Error.Message
+	}
+
+	kind := classifyStatus(statusCode)
+	switch geminiErr.Error.Status {
+	case "RESOURCE_EXHAUSTED":
+		kind = ErrorKindRateLimited
+	case "UNAUTHENTICATED", "PERMISSION_DENIED":
+		kind = ErrorKindAuthFailed
+	case "UNAVAILABLE":
+		kind = ErrorKindOverloaded
+	}
+	if kind == ErrorKindBadRequest && isContextOverflow(message) {
+		kind = ErrorKindContextTooLong
+	}
+
+	return &ProviderError{
+		Provider:   DriverGemini,
+		Kind:       kind,
+		StatusCode: statusCode,
+		RetryAfter: parseRetryAfter(header),
+		Message:    message,
+	}
+}
@@ -1,15 +1,21 @@
 package ai
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 )
 
+// defaultOpenAIBaseURL OpenAI官方接口地址
+const defaultOpenAIBaseURL = "https://api.openai.com/v1"
+
 // OpenAIService OpenAI服务实现
 type OpenAIService struct {
 	BaseAIService
@@ -18,20 +24,48 @@ type OpenAIService struct {
 	maxTokens   int
 	temperature float64
 	timeout     int
+	baseURL     string
+	apiVersion  string
+	authHeader  string
+	headers     map[string]string
+}
+
+func init() {
+	RegisterDriver(DriverOpenAI, func(config *DriverConfig) (AIService, error) {
+		return NewOpenAIService(config)
+	})
 }
 
-// NewOpenAIService 创建OpenAI服务
-func NewOpenAIService(apiKey, model string, maxTokens int, temperature float64, timeout int) (*OpenAIService, error) {
-	if apiKey == "" {
+// NewOpenAIService 创建OpenAI服务，配置BaseURL后可对接任意OpenAI兼容接口
+func NewOpenAIService(config *DriverConfig) (*OpenAIService, error) {
+	baseURL := strings.TrimRight(config.BaseURL, "/")
+	if baseURL == "" {
+		baseURL = defaultOpenAIBaseURL
+	}
+
+	// 自建服务可不设密钥，官方接口必须设置
+	if config.APIKey == "" && baseURL == defaultOpenAIBaseURL {
 		return nil, fmt.Errorf("API密钥不能为空")
 	}
+	if _, err := url.Parse(baseURL); err != nil {
+		return nil, fmt.Errorf("接口地址无效: %v", err)
+	}
+
+	authHeader := config.AuthHeader
+	if authHeader == "" {
+		authHeader = "Authorization"
+	}
 
 	service := &OpenAIService{
-		apiKey:      apiKey,
-		model:       model,
-		maxTokens:   maxTokens,
-		temperature: temperature,
-		timeout:     timeout,
+		apiKey:      config.APIKey,
+		model:       config.Model,
+		maxTokens:   config.MaxTokens,
+		temperature: config.Temperature,
+		timeout:     config.Timeout,
+		baseURL:     baseURL,
+		apiVersion:  config.APIVersion,
+		authHeader:  authHeader,
+		headers:     config.Headers,
 	}
 	service.driver = service
 	return service, nil
@@ -53,9 +87,9 @@ func (s *OpenAIService) Generate(ctx context.Context, prompt string) (string, er
 	}
 
 	// 发送请求
-	resp, err := s.sendRequest(ctx, "https://api.openai.com/v1/chat/completions", req)
+	resp, err := s.sendRequest(ctx, req)
 	if err != nil {
-		return "", fmt.Errorf("发送请求失败: %v", err)
+		return "", fmt.Errorf("发送请求失败: %w", err)
 	}
 
 	// 解析响应
@@ -68,10 +102,11 @@ func (s *OpenAIService) Generate(ctx context.Context, prompt string) (string, er
 
 // OpenAIRequest OpenAI请求结构
 type OpenAIRequest struct {
-	Model       string    `json:"model"`
+	Model       string    `json:"model,omitempty"`
 	Messages    []Message `json:"messages"`
-	MaxTokens   int       `json:"max_tokens"`
+	MaxTokens   int       `json:"max_tokens,omitempty"`
 	Temperature float64   `json:"temperature"`
+	Stream      bool      `json:"stream,omitempty"`
 }
 
 // Message 消息结构
@@ -87,7 +122,94 @@ type OpenAIResponse struct {
 
 // Choice 选择结构
 type Choice struct {
-	Message Message `json:"message"`
+	Message      Message `json:"message"`
+	FinishReason string  `json:"finish_reason"`
+}
+
+// OpenAIError OpenAI错误结构，兼容接口通常沿用该格式
+type OpenAIError struct {
+	Error struct {
+		Message string      `json:"message"`
+		Type    string      `json:"type"`
+		Code    interface{} `json:"code"`
+	} `json:"error"`
+}
+
+// OpenAIStreamChunk OpenAI流式响应块结构
+type OpenAIStreamChunk struct {
+	Choices []struct {
+		Delta        Message `json:"delta"`
+		FinishReason string  `json:"finish_reason"`
+	} `json:"choices"`
+}
+
+// GenerateStream 流式生成内容，每收到一段文本调用一次onDelta，返回完整内容
+func (s *OpenAIService) GenerateStream(ctx context.Context, prompt string, onDelta func(delta string)) (string, error) {
+	req := OpenAIRequest{
+		Model:       s.model,
+		MaxTokens:   s.maxTokens,
+		Temperature: s.temperature,
+		Stream:      true,
+		Messages: []Message{
+			{
+				Role:    "user",
+				Content: prompt,
+			},
+		},
+	}
+
+	httpReq, err := s.newHTTPRequest(ctx, req)
+	if err != nil {
+		return "", err
+	}
+
+	// 流式请求不设置整体超时，由ctx控制
+	resp, err := (&http.Client{}).Do(httpReq)
+	if err != nil {
+		return "", fmt.Errorf("发送HTTP请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", s.parseError(resp.StatusCode, resp.Header, body)
+	}
+
+	var content strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			return content.String(), nil
+		}
+
+		var chunk OpenAIStreamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return content.String(), fmt.Errorf("解析流式响应失败: %v", err)
+		}
+		for _, choice := range chunk.Choices {
+			if choice.FinishReason == "content_filter" {
+				return content.String(), contentFiltered(DriverOpenAI, resp.StatusCode)
+			}
+			if choice.Delta.Content == "" {
+				continue
+			}
+			content.WriteString(choice.Delta.Content)
+			if onDelta != nil {
+				onDelta(choice.Delta.Content)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return content.String(), fmt.Errorf("读取流式响应失败: %w", err)
+	}
+
+	return content.String(), nil
 }
 
 // GenerateContent 生成内容
@@ -114,9 +236,9 @@ func (s *OpenAIService) GenerateContent(ctx context.Context, prompt string, lang
 	}
 
 	// 发送请求
-	resp, err := s.sendRequest(ctx, "https://api.openai.com/v1/chat/completions", req)
+	resp, err := s.sendRequest(ctx, req)
 	if err != nil {
-		return "", fmt.Errorf("发送请求失败: %v", err)
+		return "", fmt.Errorf("发送请求失败: %w", err)
 	}
 
 	// 解析响应
@@ -151,9 +273,9 @@ func (s *OpenAIService) Translate(ctx context.Context, content string, sourceLan
 	}
 
 	// 发送请求
-	resp, err := s.sendRequest(ctx, "https://api.openai.com/v1/chat/completions", req)
+	resp, err := s.sendRequest(ctx, req)
 	if err != nil {
-		return "", fmt.Errorf("发送请求失败: %v", err)
+		return "", fmt.Errorf("发送请求失败: %w", err)
 	}
 
 	// 解析响应
@@ -164,8 +286,17 @@ func (s *OpenAIService) Translate(ctx context.Context, content string, sourceLan
 	return resp.Choices[0].Message.Content, nil
 }
 
-// sendRequest 发送HTTP请求
-func (s *OpenAIService) sendRequest(ctx context.Context, url string, req OpenAIRequest) (*OpenAIResponse, error) {
+// endpoint 获取对话补全接口地址
+func (s *OpenAIService) endpoint() string {
+	endpoint := s.baseURL + "/chat/completions"
+	if s.apiVersion != "" {
+		endpoint += "?api-version=" + url.QueryEscape(s.apiVersion)
+	}
+	return endpoint
+}
+
+// newHTTPRequest 构建HTTP请求
+func (s *OpenAIService) newHTTPRequest(ctx context.Context, req OpenAIRequest) (*http.Request, error) {
 	// 序列化请求
 	reqBody, err := json.Marshal(req)
 	if err != nil {
@@ -173,14 +304,33 @@ func (s *OpenAIService) sendRequest(ctx context.Context, url string, req OpenAIR
 	}
 
 	// 创建HTTP请求
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(reqBody))
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", s.endpoint(), bytes.NewBuffer(reqBody))
 	if err != nil {
 		return nil, fmt.Errorf("创建HTTP请求失败: %v", err)
 	}
 
 	// 设置请求头
 	httpReq.Header.Set("Content-Type", "application/json")
-	httpReq.Header.Set("Authorization", "Bearer "+s.apiKey)
+	if s.apiKey != "" {
+		if s.authHeader == "Authorization" {
+			httpReq.Header.Set("Authorization", "Bearer "+s.apiKey)
+		} else {
+			httpReq.Header.Set(s.authHeader, s.apiKey)
+		}
+	}
+	for key, value := range s.headers {
+		httpReq.Header.Set(key, value)
+	}
+
+	return httpReq, nil
+}
+
+// sendRequest 发送HTTP请求
+func (s *OpenAIService) sendRequest(ctx context.Context, req OpenAIRequest) (*OpenAIResponse, error) {
+	httpReq, err := s.newHTTPRequest(ctx, req)
+	if err != nil {
+		return nil, err
+	}
 
 	// 创建HTTP客户端
 	client := &http.Client{
@@ -190,7 +340,7 @@ func (s *OpenAIService) sendRequest(ctx context.Context, url string, req OpenAIR
 	// 发送请求
 	resp, err := client.Do(httpReq)
 	if err != nil {
-		return nil, fmt.Errorf("发送HTTP请求失败: %v", err)
+		return nil, fmt.Errorf("发送HTTP请求失败: %w", err)
 	}
 	defer resp.Body.Close()
 
@@ -202,7 +352,7 @@ func (s *OpenAIService) sendRequest(ctx context.Context, url string, req OpenAIR
 
 	// 检查状态码
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("请求失败，状态码：%d，响应：%s", resp.StatusCode, string(body))
+		return nil, s.parseError(resp.StatusCode, resp.Header, body)
 	}
 
 	// 解析响应
@@ -210,6 +360,41 @@ func (s *OpenAIService) sendRequest(ctx context.Context, url string, req OpenAIR
 	if err := json.Unmarshal(body, &openAIResp); err != nil {
 		return nil, fmt.Errorf("解析响应失败: %v", err)
 	}
+	for _, choice := range openAIResp.Choices {
+		if choice.FinishReason == "content_filter" {
+			return nil, contentFiltered(DriverOpenAI, resp.StatusCode)
+		}
+	}
 
 	return &openAIResp, nil
-} 
\ No newline at end of file
+}
+
+// parseError 将OpenAI错误响应映射为服务商错误
+func (s *OpenAIService) parseError(statusCode int, header http.Header, body []byte) error {
+	var openAIErr OpenAIError
+	message := string(body)
+	if err := json.Unmarshal(body, &openAIErr); err == nil && openAIErr.Error.Message != "" {
+		message = openAIErr.Error.Message
+	}
+
+	kind := classifyStatus(statusCode)
+	code := fmt.Sprint(openAIErr.Error.Code)
+	switch {
+	case code == "insufficient_quota" || openAIErr.Error.Type == "insufficient_quota":
+		kind = ErrorKindQuotaExceeded
+	case code == "context_length_exceeded" || isContextOverflow(message):
+		kind = ErrorKindContextTooLong
+	case code == "content_filter" || code == "content_policy_violation":
+		kind = ErrorKindContentFiltered
+	case code == "invalid_api_key" || openAIErr.Error.Type == "authentication_error":
+		kind = ErrorKindAuthFailed
+	}
+
+	return &ProviderError{
+		Provider:   DriverOpenAI,
+		Kind:       kind,
+		StatusCode: statusCode,
+		RetryAfter: parseRetryAfter(header),
+		Message:    message,
+	}
+}
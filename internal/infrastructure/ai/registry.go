@@ -0,0 +1,124 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/util/gconv"
+	"sort"
+	"sync"
+)
+
+// DriverFactory AI驱动工厂
+type DriverFactory func(config *DriverConfig) (AIService, error)
+
+var (
+	driversMu sync.RWMutex
+	drivers   = make(map[DriverType]DriverFactory)
+)
+
+// RegisterDriver 注册AI驱动工厂，通常在驱动实现的init中调用
+func RegisterDriver(driverType DriverType, factory DriverFactory) {
+	driversMu.Lock()
+	defer driversMu.Unlock()
+
+	if _, ok := drivers[driverType]; ok {
+		panic(fmt.Sprintf("AI驱动重复注册: %s", driverType))
+	}
+	drivers[driverType] = factory
+}
+
+// lookupDriver 查找AI驱动工厂
+func lookupDriver(driverType DriverType) (DriverFactory, bool) {
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+
+	factory, ok := drivers[driverType]
+	return factory, ok
+}
+
+// Registry AI服务注册表，按名称管理已创建的AI服务实例
+type Registry struct {
+	services      map[DriverType]AIService
+	defaultDriver DriverType
+}
+
+// NewRegistry 根据配置文件ai节点创建所有AI服务
+// ai.default 为默认驱动名，其余每个子节点为一个驱动实例，节点名即实例名
+func NewRegistry(ctx context.Context) (*Registry, error) {
+	sections := g.Cfg().MustGet(ctx, "ai").Map()
+	registry := &Registry{
+		services:      make(map[DriverType]AIService),
+		defaultDriver: DriverType(g.Cfg().MustGet(ctx, "ai.default").String()),
+	}
+
+	names := make([]string, 0, len(sections))
+	for name := range sections {
+		if name != "default" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	// 单个驱动配置无效时记录日志并跳过，仅默认驱动不可用时返回错误
+	var registered []string
+	for _, name := range names {
+		var config DriverConfig
+		if err := gconv.Struct(sections[name], &config); err != nil {
+			g.Log().Warningf(ctx, "解析AI驱动配置失败，跳过(%s): %v", name, err)
+			continue
+		}
+		if config.Type == "" {
+			config.Type = DriverType(name)
+		}
+
+		service, err := NewAIService(&config)
+		if err != nil {
+			g.Log().Warningf(ctx, "创建AI驱动失败，跳过(%s): %v", name, err)
+			continue
+		}
+		registry.services[DriverType(name)] = service
+		registered = append(registered, name)
+	}
+
+	if len(registered) == 0 {
+		return nil, fmt.Errorf("未配置任何可用的AI驱动")
+	}
+	if registry.defaultDriver == "" {
+		registry.defaultDriver = DriverType(registered[0])
+	}
+	if _, ok := registry.services[registry.defaultDriver]; !ok {
+		return nil, fmt.Errorf("默认AI驱动未配置或不可用: %s", registry.defaultDriver)
+	}
+
+	return registry, nil
+}
+
+// Get 获取AI服务，driverType为空时返回默认驱动
+func (r *Registry) Get(driverType DriverType) (AIService, error) {
+	service, ok := r.services[r.Resolve(driverType)]
+	if !ok {
+		return nil, fmt.Errorf("不支持的AI驱动类型: %s", driverType)
+	}
+	return service, nil
+}
+
+// Resolve 解析实际使用的驱动名，driverType为空时返回默认驱动名
+func (r *Registry) Resolve(driverType DriverType) DriverType {
+	if driverType == "" {
+		return r.defaultDriver
+	}
+	return driverType
+}
+
+// Drivers 获取所有已创建的驱动名
+func (r *Registry) Drivers() []DriverType {
+	names := make([]DriverType, 0, len(r.services))
+	for name := range r.services {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return names[i] < names[j]
+	})
+	return names
+}
@@ -0,0 +1,59 @@
+package ai
+
+import (
+	"context"
+	"github.com/gogf/gf/v2/frame/g"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy 任务重试策略，指数退避并加入随机抖动，服务商给出Retry-After时以其为下限
+type RetryPolicy struct {
+	BaseDelay time.Duration // 首次重试的基础等待时间
+	MaxDelay  time.Duration // 单次等待时间上限
+}
+
+// NewRetryPolicy 从配置文件 queue.worker 读取重试策略
+func NewRetryPolicy(ctx context.Context) RetryPolicy {
+	return RetryPolicy{
+		BaseDelay: time.Duration(g.Cfg().MustGet(ctx, "queue.worker.retryBaseDelay", 2).Int()) * time.Second,
+		MaxDelay:  time.Duration(g.Cfg().MustGet(ctx, "queue.worker.retryMaxDelay", 300).Int()) * time.Second,
+	}
+}
+
+// CanRun 已重试retries次的任务是否还能执行，maxRetries为首次执行之外允许的重试次数
+// 每安排一次重试retries加1，因此最后一次重试时retries等于maxRetries，仍可执行
+// 调度器、队列处理器、卡住任务回收与任务领取条件统一按此计数
+func CanRun(retries, maxRetries int) bool {
+	return retries <= maxRetries
+}
+
+// CanRetry 已重试retries次后是否还能再重试，即再安排一次重试后任务仍可执行
+func CanRetry(retries, maxRetries int) bool {
+	return CanRun(retries+1, maxRetries)
+}
+
+// ShouldRetry 错误可重试且重试次数未用尽
+func ShouldRetry(err error, retries, maxRetries int) bool {
+	return IsRetryable(err) && CanRetry(retries, maxRetries)
+}
+
+// Delay 计算第retries次重试前的等待时间（retries从0开始）
+// 取 [0, BaseDelay*2^retries] 内的随机值（Full Jitter），不超过MaxDelay，且不小于服务商建议的Retry-After
+func (p RetryPolicy) Delay(retries int, err error) time.Duration {
+	delay := p.BaseDelay
+	for i := 0; i < retries && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if delay > 0 {
+		delay = time.Duration(rand.Int63n(int64(delay) + 1))
+	}
+
+	if retryAfter := RetryAfter(err); retryAfter > delay {
+		delay = retryAfter
+	}
+	return delay
+}
@@ -0,0 +1,80 @@
+package ai
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetryBoundary(t *testing.T) {
+	cases := []struct {
+		name       string
+		retries    int
+		maxRetries int
+		canRetry   bool
+		canRun     bool
+	}{
+		{"不允许重试时首次执行", 0, 0, false, true},
+		{"首次执行失败", 0, 3, true, true},
+		{"第一次重试", 1, 3, true, true},
+		{"倒数第二次重试", 2, 3, true, true},
+		{"最后一次重试", 3, 3, false, true},
+		{"重试次数已超出", 4, 3, false, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := CanRetry(c.retries, c.maxRetries); got != c.canRetry {
+				t.Errorf("CanRetry(%d, %d) = %v, 期望 %v", c.retries, c.maxRetries, got, c.canRetry)
+			}
+			if got := CanRun(c.retries, c.maxRetries); got != c.canRun {
+				t.Errorf("CanRun(%d, %d) = %v, 期望 %v", c.retries, c.maxRetries, got, c.canRun)
+			}
+		})
+	}
+}
+
+// 每次允许的重试安排后（重试次数加1），任务都必须仍可被领取执行，且总执行次数为 maxRetries+1
+func TestScheduledRetryIsRunnable(t *testing.T) {
+	for maxRetries := 0; maxRetries <= 5; maxRetries++ {
+		runs := 1
+		for retries := 0; CanRetry(retries, maxRetries); retries++ {
+			if !CanRun(retries+1, maxRetries) {
+				t.Fatalf("maxRetries=%d: 第%d次重试已安排但无法领取", maxRetries, retries+1)
+			}
+			runs++
+		}
+		if runs != maxRetries+1 {
+			t.Errorf("maxRetries=%d: 执行%d次, 期望 %d", maxRetries, runs, maxRetries+1)
+		}
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: 2 * time.Second, MaxDelay: 30 * time.Second}
+	rateLimited := &ProviderError{Provider: DriverOpenAI, Kind: ErrorKindRateLimited, RetryAfter: time.Minute}
+
+	cases := []struct {
+		name    string
+		policy  RetryPolicy
+		retries int
+		err     error
+		min     time.Duration
+		max     time.Duration
+	}{
+		{"首次重试不超过基础等待时间", policy, 0, nil, 0, 2 * time.Second},
+		{"按重试次数指数增长", policy, 2, nil, 0, 8 * time.Second},
+		{"不超过等待时间上限", policy, 10, nil, 0, 30 * time.Second},
+		{"服务商建议的等待时间为下限", policy, 0, rateLimited, time.Minute, time.Minute},
+		{"未配置基础等待时间", RetryPolicy{}, 3, nil, 0, 0},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			for i := 0; i < 100; i++ {
+				if got := c.policy.Delay(c.retries, c.err); got < c.min || got > c.max {
+					t.Fatalf("Delay(%d) = %s, 期望在 [%s, %s] 内", c.retries, got, c.min, c.max)
+				}
+			}
+		})
+	}
+}
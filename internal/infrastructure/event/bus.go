@@ -0,0 +1,149 @@
+package event
+
+import (
+	"sync"
+	"time"
+)
+
+// Type 事件类型
+type Type string
+
+const (
+	TypeTaskStatus   Type = "task_status"   // 任务状态变更
+	TypeTaskProgress Type = "task_progress" // 任务进度更新
+	TypeTaskPriority Type = "task_priority" // 任务优先级调整
+)
+
+// subscriberBuffer 每个订阅者的事件缓冲数，缓冲满时丢弃新事件，避免慢订阅者阻塞发布方
+const subscriberBuffer = 64
+
+// Event 任务事件
+type Event struct {
+	Type        Type      `json:"type"`
+	TaskID      string    `json:"task_id"`
+	BatchID     string    `json:"batch_id"`
+	WorkID      string    `json:"work_id"`
+	Status      string    `json:"status,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	Progress    int       `json:"progress"`
+	OldPriority int       `json:"old_priority"`
+	NewPriority int       `json:"new_priority"`
+	Reason      string    `json:"reason,omitempty"`
+	Time        time.Time `json:"time"`
+}
+
+// Filter 订阅过滤条件，为空的字段不参与过滤
+type Filter struct {
+	TaskID  string
+	BatchID string
+	WorkID  string
+	WorkIDs map[string]struct{} // 可订阅的作品范围，非nil时只推送这些作品的事件
+}
+
+// Match 判断事件是否满足过滤条件
+func (f Filter) Match(e *Event) bool {
+	if f.TaskID != "" && f.TaskID != e.TaskID {
+		return false
+	}
+	if f.BatchID != "" && f.BatchID != e.BatchID {
+		return false
+	}
+	if f.WorkID != "" && f.WorkID != e.WorkID {
+		return false
+	}
+	if f.WorkIDs != nil {
+		if _, ok := f.WorkIDs[e.WorkID]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Subscription 事件订阅
+type Subscription struct {
+	bus    *Bus
+	filter Filter
+	ch     chan *Event
+	once   sync.Once
+}
+
+// Events 获取事件通道，订阅关闭后通道关闭
+func (s *Subscription) Events() <-chan *Event {
+	return s.ch
+}
+
+// Close 取消订阅
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		s.bus.mu.Lock()
+		delete(s.bus.subscribers, s)
+		s.bus.mu.Unlock()
+		close(s.ch)
+	})
+}
+
+// Bus 进程内事件总线
+type Bus struct {
+	mu          sync.RWMutex
+	subscribers map[*Subscription]struct{}
+}
+
+// NewBus 创建事件总线
+func NewBus() *Bus {
+	return &Bus{
+		subscribers: make(map[*Subscription]struct{}),
+	}
+}
+
+// Subscribe 订阅满足过滤条件的事件
+func (b *Bus) Subscribe(filter Filter) *Subscription {
+	sub := &Subscription{
+		bus:    b,
+		filter: filter,
+		ch:     make(chan *Event, subscriberBuffer),
+	}
+
+	b.mu.Lock()
+	b.subscribers[sub] = struct{}{}
+	b.mu.Unlock()
+
+	return sub
+}
+
+// Publish 发布事件，不阻塞发布方
+func (b *Bus) Publish(e *Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for sub := range b.subscribers {
+		if !sub.filter.Match(e) {
+			continue
+		}
+		select {
+		case sub.ch <- e:
+		default:
+		}
+	}
+}
+
+// defaultBus 默认事件总线，调度器、处理器与接口层共用
+var defaultBus = NewBus()
+
+// Default 获取默认事件总线
+func Default() *Bus {
+	return defaultBus
+}
+
+// Publish 向默认事件总线发布事件
+func Publish(e *Event) {
+	defaultBus.Publish(e)
+}
+
+// Subscribe 订阅默认事件总线
+func Subscribe(filter Filter) *Subscription {
+	return defaultBus.Subscribe(filter)
+}
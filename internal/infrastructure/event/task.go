@@ -0,0 +1,72 @@
+package event
+
+import (
+	"ai-translate/internal/domain/task"
+	"ai-translate/internal/infrastructure/utils"
+	"ai-translate/internal/model"
+	"strconv"
+)
+
+// TaskStatusChanged 发布任务状态变更事件，errMsg为失败原因
+func TaskStatusChanged(task *model.Task, status model.TaskStatus, errMsg string) {
+	Publish(&Event{
+		Type:    TypeTaskStatus,
+		TaskID:  task.ID,
+		BatchID: task.BatchID,
+		WorkID:  task.WorkID,
+		Status:  string(status),
+		Error:   errMsg,
+	})
+}
+
+// TaskProgressed 发布任务进度事件
+func TaskProgressed(task *model.Task, progress int) {
+	Publish(&Event{
+		Type:     TypeTaskProgress,
+		TaskID:   task.ID,
+		BatchID:  task.BatchID,
+		WorkID:   task.WorkID,
+		Progress: progress,
+	})
+}
+
+// TaskPriorityAdjusted 发布任务优先级调整事件
+func TaskPriorityAdjusted(task *model.Task, oldPriority, newPriority model.TaskPriority, reason string) {
+	Publish(&Event{
+		Type:        TypeTaskPriority,
+		TaskID:      task.ID,
+		BatchID:     task.BatchID,
+		WorkID:      task.WorkID,
+		OldPriority: int(oldPriority),
+		NewPriority: int(newPriority),
+		Reason:      reason,
+	})
+}
+
+// workTaskEvent 创建工作流任务事件，翻译任务的关联ID为翻译批次ID
+func workTaskEvent(eventType Type, t *task.Task) *Event {
+	e := &Event{
+		Type:   eventType,
+		TaskID: strconv.FormatUint(t.ID, 10),
+		WorkID: strconv.FormatUint(t.WorkID, 10),
+	}
+	if t.Type == utils.TaskTypeTranslation {
+		e.BatchID = strconv.FormatUint(t.ReferenceID, 10)
+	}
+	return e
+}
+
+// WorkTaskStatusChanged 发布工作流任务状态变更事件，errMsg为失败原因
+func WorkTaskStatusChanged(t *task.Task, status model.TaskStatus, errMsg string) {
+	e := workTaskEvent(TypeTaskStatus, t)
+	e.Status = string(status)
+	e.Error = errMsg
+	Publish(e)
+}
+
+// WorkTaskProgressed 发布工作流任务进度事件
+func WorkTaskProgressed(t *task.Task, progress int) {
+	e := workTaskEvent(TypeTaskProgress, t)
+	e.Progress = progress
+	Publish(e)
+}
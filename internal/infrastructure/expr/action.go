@@ -0,0 +1,202 @@
+package expr
+
+import (
+	"ai-translate/internal/model"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ActionType 动作类型
+type ActionType string
+
+const (
+	ActionSetPriority   ActionType = "set_priority"   // 设置优先级：set_priority(n)
+	ActionRaisePriority ActionType = "raise_priority" // 提升优先级：raise_priority(n)，n默认为1
+	ActionLowerPriority ActionType = "lower_priority" // 降低优先级：lower_priority(n)，n默认为1
+	ActionPause         ActionType = "pause"          // 暂停任务：pause()
+	ActionResume        ActionType = "resume"         // 恢复已暂停的任务：resume()
+	ActionReroute       ActionType = "reroute"        // 切换AI驱动：reroute("driver")
+	ActionTag           ActionType = "tag"            // 添加标签：tag("name")
+)
+
+// Action 编译后的单个动作
+type Action struct {
+	Type   ActionType
+	Number int    // 优先级动作的参数
+	Text   string // 切换驱动与添加标签动作的参数
+}
+
+// Effect 动作作用于任务后的结果，调用方据此更新任务
+type Effect struct {
+	Priority model.TaskPriority // 调整后的优先级
+	Pause    bool               // 是否暂停任务
+	Resume   bool               // 是否恢复已暂停的任务，与Pause以最后执行的动作为准
+	Driver   string             // 切换后的AI驱动，为空表示不切换
+	Tags     []string           // 新增的标签，不包含任务已有的标签
+}
+
+// actionArgs 各动作的参数类型与是否可省略
+var actionArgs = map[ActionType]struct {
+	typ      tokenKind
+	optional bool
+}{
+	ActionSetPriority:   {typ: tokenNumber},
+	ActionRaisePriority: {typ: tokenNumber, optional: true},
+	ActionLowerPriority: {typ: tokenNumber, optional: true},
+	ActionPause:         {typ: tokenEOF},
+	ActionResume:        {typ: tokenEOF},
+	ActionReroute:       {typ: tokenString},
+	ActionTag:           {typ: tokenString},
+}
+
+// ParseActions 解析以分号分隔的动作列表
+func ParseActions(src string) ([]Action, error) {
+	if len(src) > maxExprLength {
+		return nil, fmt.Errorf("动作长度不能超过%d", maxExprLength)
+	}
+	tokens, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	var actions []Action
+	for p.peek().kind != tokenEOF {
+		if p.isOp(";") {
+			p.next()
+			continue
+		}
+		action, err := p.parseAction()
+		if err != nil {
+			return nil, err
+		}
+		actions = append(actions, action)
+		if t := p.peek(); t.kind != tokenEOF && !p.isOp(";") {
+			return nil, fmt.Errorf("位置%d: 动作之间需以分号分隔", t.pos)
+		}
+	}
+	return actions, nil
+}
+
+// parseAction 解析单个动作 name '(' 参数? ')'
+func (p *parser) parseAction() (Action, error) {
+	name := p.next()
+	if name.kind != tokenIdent {
+		return Action{}, fmt.Errorf("位置%d: 缺少动作名称", name.pos)
+	}
+	action := Action{Type: ActionType(name.text)}
+	arg, ok := actionArgs[action.Type]
+	if !ok {
+		return Action{}, fmt.Errorf("位置%d: 未知动作 %q", name.pos, name.text)
+	}
+	if err := p.expect("("); err != nil {
+		return Action{}, err
+	}
+
+	if p.isOp(")") {
+		if arg.typ != tokenEOF && !arg.optional {
+			return Action{}, fmt.Errorf("位置%d: 动作 %s 缺少参数", name.pos, name.text)
+		}
+		p.next()
+		action.Number = 1
+		return action, nil
+	}
+
+	t := p.next()
+	if arg.typ == tokenEOF || t.kind != arg.typ {
+		return Action{}, fmt.Errorf("位置%d: 动作 %s 的参数无效", t.pos, name.text)
+	}
+	switch t.kind {
+	case tokenNumber:
+		n, err := strconv.Atoi(t.text)
+		if err != nil {
+			return Action{}, fmt.Errorf("位置%d: 动作 %s 的参数必须为整数", t.pos, name.text)
+		}
+		if action.Type == ActionSetPriority && (n < int(model.TaskPriorityLow) || n > int(model.TaskPriorityUrgent)) {
+			return Action{}, fmt.Errorf("位置%d: 优先级必须在%d到%d之间", t.pos, model.TaskPriorityLow, model.TaskPriorityUrgent)
+		}
+		action.Number = n
+	case tokenString:
+		if strings.TrimSpace(t.text) == "" {
+			return Action{}, fmt.Errorf("位置%d: 动作 %s 的参数不能为空", t.pos, name.text)
+		}
+		action.Text = t.text
+	}
+	if err := p.expect(")"); err != nil {
+		return Action{}, err
+	}
+	return action, nil
+}
+
+// Apply 依次执行动作，计算对任务的影响，不修改任务本身
+func Apply(actions []Action, task *model.Task) Effect {
+	effect := Effect{Priority: task.Priority}
+	for _, action := range actions {
+		switch action.Type {
+		case ActionSetPriority:
+			effect.Priority = model.TaskPriority(action.Number)
+		case ActionRaisePriority:
+			effect.Priority += model.TaskPriority(action.Number)
+		case ActionLowerPriority:
+			effect.Priority -= model.TaskPriority(action.Number)
+		case ActionPause:
+			effect.Pause, effect.Resume = true, false
+		case ActionResume:
+			effect.Pause, effect.Resume = false, true
+		case ActionReroute:
+			if action.Text != string(task.Driver) {
+				effect.Driver = action.Text
+			}
+		case ActionTag:
+			if !hasTag(task.Tags, action.Text) && !hasTag(effect.Tags, action.Text) {
+				effect.Tags = append(effect.Tags, action.Text)
+			}
+		}
+	}
+
+	if effect.Priority < model.TaskPriorityLow {
+		effect.Priority = model.TaskPriorityLow
+	}
+	if effect.Priority > model.TaskPriorityUrgent {
+		effect.Priority = model.TaskPriorityUrgent
+	}
+	return effect
+}
+
+// Changed 是否对任务有实际影响
+func (e Effect) Changed(task *model.Task) bool {
+	return e.Priority != task.Priority || (e.Pause && task.Status != model.TaskStatusPaused) ||
+		(e.Resume && task.Status == model.TaskStatusPaused) || e.Driver != "" || len(e.Tags) > 0
+}
+
+// Describe 以动作语法描述对任务的实际影响，用于调整日志
+func (e Effect) Describe(task *model.Task) string {
+	var parts []string
+	if e.Priority != task.Priority {
+		parts = append(parts, fmt.Sprintf("set_priority(%d)", e.Priority))
+	}
+	if e.Pause && task.Status != model.TaskStatusPaused {
+		parts = append(parts, "pause()")
+	}
+	if e.Resume && task.Status == model.TaskStatusPaused {
+		parts = append(parts, "resume()")
+	}
+	if e.Driver != "" {
+		parts = append(parts, fmt.Sprintf("reroute(%q)", e.Driver))
+	}
+	for _, tag := range e.Tags {
+		parts = append(parts, fmt.Sprintf("tag(%q)", tag))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// hasTag 判断标签是否存在
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,481 @@
+package expr
+
+import (
+	"ai-translate/internal/model"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// maxClauses 条件展开为析取范式后的最大子句数，超过时不再分析
+const maxClauses = 64
+
+// ConflictKind 规则冲突类型
+type ConflictKind string
+
+const (
+	ConflictOverlap       ConflictKind = "overlap"       // 条件可能同时满足，且调整同一项属性
+	ConflictContradiction ConflictKind = "contradiction" // 条件可能同时满足，且动作方向相反
+)
+
+// Conflict 两条规则之间的冲突
+type Conflict struct {
+	RuleID      string       `json:"rule_id"`
+	OtherRuleID string       `json:"other_rule_id"`
+	Kind        ConflictKind `json:"kind"`
+	Reason      string       `json:"reason"`
+}
+
+// constraint 字段与字面量的一次比较
+type constraint struct {
+	field string
+	op    string      // == != < <= > >= in not_in has
+	value interface{} // float64、string、[]float64 或 []string
+}
+
+// integerFields 取值只能为整数的字段
+var integerFields = map[string]bool{
+	"priority":       true,
+	"retry_count":    true,
+	"max_retries":    true,
+	"progress":       true,
+	"content_length": true,
+}
+
+// negated 比较运算取反
+var negated = map[string]string{
+	"==": "!=", "!=": "==",
+	"<": ">=", ">=": "<",
+	">": "<=", "<=": ">",
+	"in": "not_in", "not_in": "in",
+}
+
+// flipped 交换比较运算两侧
+var flipped = map[string]string{
+	"==": "==", "!=": "!=",
+	"<": ">", ">": "<",
+	"<=": ">=", ">=": "<=",
+}
+
+// withConstraint 比较的一侧为字段、另一侧为字面量时，将比较记录为约束
+func withConstraint(n *node, op string, left, right *node) *node {
+	var c constraint
+	switch {
+	case left.field != "" && right.literal != nil:
+		c = constraint{field: left.field, op: op, value: right.literal}
+	case left.literal != nil && right.field != "" && op == "in":
+		// "vip" in tags
+		c = constraint{field: right.field, op: "has", value: left.literal}
+	case left.literal != nil && right.field != "" && flipped[op] != "":
+		c = constraint{field: right.field, op: flipped[op], value: left.literal}
+	default:
+		return n
+	}
+	n.simple = true
+	n.clauses = [][]constraint{{c}}
+	return n
+}
+
+// andClauses 合并 && 两侧的子句
+func andClauses(n, left, right *node) {
+	if !left.simple || !right.simple || len(left.clauses)*len(right.clauses) > maxClauses {
+		return
+	}
+	n.simple = true
+	n.clauses = [][]constraint{}
+	for _, l := range left.clauses {
+		for _, r := range right.clauses {
+			n.clauses = append(n.clauses, append(append([]constraint(nil), l...), r...))
+		}
+	}
+}
+
+// orClauses 合并 || 两侧的子句
+func orClauses(n, left, right *node) {
+	if !left.simple || !right.simple || len(left.clauses)+len(right.clauses) > maxClauses {
+		return
+	}
+	n.simple = true
+	n.clauses = append(append([][]constraint{}, left.clauses...), right.clauses...)
+}
+
+// notClauses 对只含一个约束的条件取反
+func notClauses(n, operand *node) {
+	if !operand.simple {
+		return
+	}
+	switch {
+	case len(operand.clauses) == 0:
+		n.simple = true
+		n.clauses = [][]constraint{{}}
+	case len(operand.clauses) == 1 && len(operand.clauses[0]) == 0:
+		n.simple = true
+		n.clauses = [][]constraint{}
+	case len(operand.clauses) == 1 && len(operand.clauses[0]) == 1:
+		c := operand.clauses[0][0]
+		if op, ok := negated[c.op]; ok {
+			n.simple = true
+			n.clauses = [][]constraint{{{field: c.field, op: op, value: c.value}}}
+		}
+	}
+}
+
+// FindConflicts 检测规则与其他规则之间的重叠与矛盾，无法编译的规则被忽略
+func FindConflicts(rule *model.PriorityAdjustRule, others []*model.PriorityAdjustRule) []Conflict {
+	compiled, err := Compile(rule)
+	if err != nil {
+		return nil
+	}
+
+	var conflicts []Conflict
+	for _, other := range others {
+		if other.ID == rule.ID {
+			continue
+		}
+		otherCompiled, err := Compile(other)
+		if err != nil {
+			continue
+		}
+
+		overlap, exact := mayOverlap(rule, compiled.Condition, other, otherCompiled.Condition)
+		if !overlap {
+			continue
+		}
+		kind, detail := compareActions(compiled.Actions, otherCompiled.Actions)
+		if kind == "" {
+			continue
+		}
+		reason := "条件可能同时满足，" + detail
+		if !exact {
+			reason = "条件无法静态分析，可能同时满足，" + detail
+		}
+		conflicts = append(conflicts, Conflict{
+			RuleID:      rule.ID,
+			OtherRuleID: other.ID,
+			Kind:        kind,
+			Reason:      reason,
+		})
+	}
+	return conflicts
+}
+
+// mayOverlap 判断两条规则的作用范围与条件是否可能同时满足；exact为false表示条件无法静态分析，按可能满足处理
+func mayOverlap(a *model.PriorityAdjustRule, ac *Condition, b *model.PriorityAdjustRule, bc *Condition) (overlap, exact bool) {
+	aClauses, ok := ruleClauses(a, ac)
+	if !ok {
+		return true, false
+	}
+	bClauses, ok := ruleClauses(b, bc)
+	if !ok {
+		return true, false
+	}
+
+	for _, x := range aClauses {
+		for _, y := range bClauses {
+			if satisfiable(append(append([]constraint(nil), x...), y...)) {
+				return true, true
+			}
+		}
+	}
+	return false, true
+}
+
+// ruleClauses 规则条件的析取范式，每个子句都附加作用范围约束
+func ruleClauses(r *model.PriorityAdjustRule, c *Condition) ([][]constraint, bool) {
+	var scope []constraint
+	for _, s := range []struct{ field, value string }{
+		{"work_id", r.WorkID},
+		{"batch_id", r.BatchID},
+		{"type", string(r.TaskType)},
+		{"status", string(r.Status)},
+	} {
+		if s.value != "" {
+			scope = append(scope, constraint{field: s.field, op: "==", value: s.value})
+		}
+	}
+
+	clauses := [][]constraint{{}}
+	if c.root != nil {
+		if !c.root.simple {
+			return nil, false
+		}
+		clauses = c.root.clauses
+	}
+
+	result := make([][]constraint, 0, len(clauses))
+	for _, clause := range clauses {
+		result = append(result, append(append([]constraint(nil), scope...), clause...))
+	}
+	return result, true
+}
+
+// numberDomain 数值字段的取值范围
+type numberDomain struct {
+	lo, hi         float64
+	loOpen, hiOpen bool
+	allowed        []float64 // 为nil表示不限
+	excluded       []float64
+}
+
+// stringDomain 字符串字段的取值范围
+type stringDomain struct {
+	allowed  []string // 为nil表示不限
+	excluded []string
+}
+
+// satisfiable 判断一组约束能否同时成立
+func satisfiable(constraints []constraint) bool {
+	numbers := make(map[string]*numberDomain)
+	strs := make(map[string]*stringDomain)
+
+	for _, c := range constraints {
+		switch v := c.value.(type) {
+		case float64:
+			d := numbers[c.field]
+			if d == nil {
+				d = &numberDomain{lo: math.Inf(-1), hi: math.Inf(1)}
+				numbers[c.field] = d
+			}
+			d.restrict(c.op, []float64{v})
+		case []float64:
+			d := numbers[c.field]
+			if d == nil {
+				d = &numberDomain{lo: math.Inf(-1), hi: math.Inf(1)}
+				numbers[c.field] = d
+			}
+			d.restrict(c.op, v)
+		case string:
+			if c.op == "has" {
+				continue
+			}
+			d := strs[c.field]
+			if d == nil {
+				d = &stringDomain{}
+				strs[c.field] = d
+			}
+			d.restrict(c.op, []string{v})
+		case []string:
+			d := strs[c.field]
+			if d == nil {
+				d = &stringDomain{}
+				strs[c.field] = d
+			}
+			d.restrict(c.op, v)
+		}
+	}
+
+	for field, d := range numbers {
+		if !d.satisfiable(integerFields[field]) {
+			return false
+		}
+	}
+	for _, d := range strs {
+		if !d.satisfiable() {
+			return false
+		}
+	}
+	return true
+}
+
+// restrict 按比较运算收窄数值范围
+func (d *numberDomain) restrict(op string, values []float64) {
+	switch op {
+	case "==", "in":
+		if d.allowed == nil {
+			d.allowed = append([]float64{}, values...)
+			return
+		}
+		var kept []float64
+		for _, a := range d.allowed {
+			for _, x := range values {
+				if a == x {
+					kept = append(kept, a)
+					break
+				}
+			}
+		}
+		d.allowed = append([]float64{}, kept...)
+	case "!=", "not_in":
+		d.excluded = append(d.excluded, values...)
+	}
+	if len(values) != 1 {
+		return
+	}
+
+	v := values[0]
+	switch op {
+	case ">":
+		if v > d.lo || (v == d.lo && !d.loOpen) {
+			d.lo, d.loOpen = v, true
+		}
+	case ">=":
+		if v > d.lo {
+			d.lo, d.loOpen = v, false
+		}
+	case "<":
+		if v < d.hi || (v == d.hi && !d.hiOpen) {
+			d.hi, d.hiOpen = v, true
+		}
+	case "<=":
+		if v < d.hi {
+			d.hi, d.hiOpen = v, false
+		}
+	}
+}
+
+// contains 判断数值是否在范围内
+func (d *numberDomain) contains(v float64, integer bool) bool {
+	if integer && v != math.Trunc(v) {
+		return false
+	}
+	if v < d.lo || (v == d.lo && d.loOpen) || v > d.hi || (v == d.hi && d.hiOpen) {
+		return false
+	}
+	for _, x := range d.excluded {
+		if x == v {
+			return false
+		}
+	}
+	return true
+}
+
+// satisfiable 判断范围内是否存在可取的值
+func (d *numberDomain) satisfiable(integer bool) bool {
+	if d.allowed != nil {
+		for _, v := range d.allowed {
+			if d.contains(v, integer) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if !integer {
+		if d.lo < d.hi {
+			return true
+		}
+		return d.lo == d.hi && d.contains(d.lo, false)
+	}
+
+	// 整数字段：收窄到整数边界后，范围内的整数个数需多于被排除的个数
+	lo, hi := math.Ceil(d.lo), math.Floor(d.hi)
+	if d.loOpen && lo == d.lo {
+		lo++
+	}
+	if d.hiOpen && hi == d.hi {
+		hi--
+	}
+	if lo > hi {
+		return false
+	}
+	excluded := make(map[float64]bool)
+	for _, x := range d.excluded {
+		if x >= lo && x <= hi && x == math.Trunc(x) {
+			excluded[x] = true
+		}
+	}
+	return hi-lo+1 > float64(len(excluded))
+}
+
+// restrict 按比较运算收窄字符串范围
+func (d *stringDomain) restrict(op string, values []string) {
+	switch op {
+	case "==", "in":
+		if d.allowed == nil {
+			d.allowed = append([]string{}, values...)
+			return
+		}
+		var kept []string
+		for _, a := range d.allowed {
+			for _, x := range values {
+				if a == x {
+					kept = append(kept, a)
+					break
+				}
+			}
+		}
+		d.allowed = append([]string{}, kept...)
+	case "!=", "not_in":
+		d.excluded = append(d.excluded, values...)
+	}
+}
+
+// satisfiable 判断是否存在可取的字符串，未限定取值时总能取到未被排除的值
+func (d *stringDomain) satisfiable() bool {
+	if d.allowed == nil {
+		return true
+	}
+	for _, v := range d.allowed {
+		excluded := false
+		for _, x := range d.excluded {
+			if x == v {
+				excluded = true
+				break
+			}
+		}
+		if !excluded {
+			return true
+		}
+	}
+	return false
+}
+
+// actionSummary 规则动作对优先级与驱动的影响
+type actionSummary struct {
+	absolute bool               // 是否包含set_priority，此时调整后的优先级与任务原优先级无关
+	target   model.TaskPriority // absolute为true时调整后的优先级
+	relative int                // absolute为false时相对调整的净值，正数为提升
+	driver   string             // reroute的目标驱动
+}
+
+// summarize 汇总动作对优先级与驱动的影响
+func summarize(actions []Action) actionSummary {
+	var s actionSummary
+	for _, action := range actions {
+		switch action.Type {
+		case ActionSetPriority:
+			s.absolute = true
+		case ActionRaisePriority:
+			s.relative += action.Number
+		case ActionLowerPriority:
+			s.relative -= action.Number
+		case ActionReroute:
+			s.driver = action.Text
+		}
+	}
+	if s.absolute {
+		s.target = Apply(actions, &model.Task{}).Priority
+		s.relative = 0
+	}
+	return s
+}
+
+// compareActions 比较两组动作，返回冲突类型与说明；两组动作不调整同一项属性时返回空
+func compareActions(a, b []Action) (ConflictKind, string) {
+	x, y := summarize(a), summarize(b)
+
+	var contradictions, overlaps []string
+	switch {
+	case x.absolute && y.absolute && x.target != y.target:
+		contradictions = append(contradictions, fmt.Sprintf("分别将优先级设置为%d与%d", x.target, y.target))
+	case !x.absolute && !y.absolute && x.relative*y.relative < 0:
+		contradictions = append(contradictions, "一条提升优先级、一条降低优先级")
+	case (x.absolute || x.relative != 0) && (y.absolute || y.relative != 0):
+		overlaps = append(overlaps, "均调整优先级")
+	}
+
+	switch {
+	case x.driver != "" && y.driver != "" && x.driver != y.driver:
+		contradictions = append(contradictions, fmt.Sprintf("分别切换到%s与%s", x.driver, y.driver))
+	case x.driver != "" && y.driver != "":
+		overlaps = append(overlaps, "均切换AI驱动")
+	}
+
+	if len(contradictions) > 0 {
+		return ConflictContradiction, "且动作互相矛盾：" + strings.Join(contradictions, "，")
+	}
+	if len(overlaps) > 0 {
+		return ConflictOverlap, "且" + strings.Join(overlaps, "，")
+	}
+	return "", ""
+}
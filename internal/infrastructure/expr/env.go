@@ -0,0 +1,95 @@
+package expr
+
+import (
+	"ai-translate/internal/model"
+	"time"
+	"unicode/utf8"
+)
+
+// valueType 表达式值类型
+type valueType int
+
+const (
+	typeNumber     valueType = iota // 数值
+	typeString                      // 字符串
+	typeBool                        // 布尔
+	typeStringList                  // 字符串列表
+	typeNumberList                  // 数值列表
+)
+
+// String 类型名称，用于错误提示
+func (t valueType) String() string {
+	switch t {
+	case typeNumber:
+		return "数值"
+	case typeString:
+		return "字符串"
+	case typeBool:
+		return "布尔"
+	case typeStringList:
+		return "字符串列表"
+	case typeNumberList:
+		return "数值列表"
+	}
+	return "未知"
+}
+
+// Env 规则求值环境
+type Env struct {
+	Task *model.Task
+	Now  time.Time // 计算等待时长的基准时间
+}
+
+// NewEnv 创建以当前时间为基准的求值环境
+func NewEnv(task *model.Task) *Env {
+	return &Env{Task: task, Now: time.Now()}
+}
+
+// field 表达式可引用的任务字段
+type field struct {
+	typ   valueType
+	value func(env *Env) interface{}
+}
+
+// fields 表达式可引用的任务字段
+var fields = map[string]field{
+	"id":          stringField(func(t *model.Task) string { return t.ID }),
+	"work_id":     stringField(func(t *model.Task) string { return t.WorkID }),
+	"batch_id":    stringField(func(t *model.Task) string { return t.BatchID }),
+	"type":        stringField(func(t *model.Task) string { return string(t.Type) }),
+	"status":      stringField(func(t *model.Task) string { return string(t.Status) }),
+	"driver":      stringField(func(t *model.Task) string { return string(t.Driver) }),
+	"language":    stringField(func(t *model.Task) string { return t.Language }),
+	"source_lang": stringField(func(t *model.Task) string { return t.SourceLang }),
+	"target_lang": stringField(func(t *model.Task) string { return t.TargetLang }),
+	"error":       stringField(func(t *model.Task) string { return t.Error }),
+
+	"priority":       numberField(func(t *model.Task) float64 { return float64(t.Priority) }),
+	"retry_count":    numberField(func(t *model.Task) float64 { return float64(t.RetryCount) }),
+	"max_retries":    numberField(func(t *model.Task) float64 { return float64(t.MaxRetries) }),
+	"progress":       numberField(func(t *model.Task) float64 { return float64(t.Progress) }),
+	"content_length": numberField(func(t *model.Task) float64 { return float64(utf8.RuneCountInString(t.Content)) }),
+
+	// 任务创建至今的分钟数
+	"age_minutes": {typ: typeNumber, value: func(env *Env) interface{} {
+		return env.Now.Sub(env.Task.CreatedAt).Minutes()
+	}},
+	// 任务最后更新至今的分钟数
+	"idle_minutes": {typ: typeNumber, value: func(env *Env) interface{} {
+		return env.Now.Sub(env.Task.UpdatedAt).Minutes()
+	}},
+
+	"tags": {typ: typeStringList, value: func(env *Env) interface{} {
+		return []string(env.Task.Tags)
+	}},
+}
+
+// stringField 创建字符串字段
+func stringField(get func(t *model.Task) string) field {
+	return field{typ: typeString, value: func(env *Env) interface{} { return get(env.Task) }}
+}
+
+// numberField 创建数值字段
+func numberField(get func(t *model.Task) float64) field {
+	return field{typ: typeNumber, value: func(env *Env) interface{} { return get(env.Task) }}
+}
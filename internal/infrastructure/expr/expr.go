@@ -0,0 +1,466 @@
+package expr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	// maxExprLength 表达式最大长度
+	maxExprLength = 2048
+	// maxExprDepth 表达式最大嵌套深度
+	maxExprDepth = 32
+)
+
+// node 编译后的表达式节点，类型在编译期确定，求值时不会出错
+type node struct {
+	typ  valueType
+	eval func(env *Env) interface{}
+
+	// 以下字段用于规则冲突检测
+	field   string         // 字段节点的字段名
+	literal interface{}    // 字面量节点的值
+	clauses [][]constraint // 条件的析取范式，每个子句中的约束同时成立
+	simple  bool           // 条件是否可完整分解为clauses
+}
+
+// Condition 编译后的触发条件
+type Condition struct {
+	source string
+	root   *node
+}
+
+// CompileCondition 编译触发条件表达式，空表达式表示总是满足
+//
+// 语法：
+//   - 字面量：数值 30、字符串 "translation"、布尔 true/false、列表 ["a", "b"]
+//   - 字段：见 fields，如 type、status、priority、retry_count、age_minutes、tags
+//   - 比较：== != < <= > >=，数值之间可比较大小，字符串只能判断是否相等
+//   - 包含：x in [..]、"vip" in tags
+//   - 逻辑：&& || !，括号改变优先级
+//   - 函数：contains(s, sub)、starts_with(s, prefix)、ends_with(s, suffix)
+//
+// 示例：age_minutes > 30 && type == "translation" && retry_count >= 2
+func CompileCondition(src string) (*Condition, error) {
+	if strings.TrimSpace(src) == "" {
+		return &Condition{source: src}, nil
+	}
+	if len(src) > maxExprLength {
+		return nil, fmt.Errorf("表达式长度不能超过%d", maxExprLength)
+	}
+
+	tokens, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, fmt.Errorf("位置%d: 多余的内容 %q", t.pos, t.text)
+	}
+	if root.typ != typeBool {
+		return nil, fmt.Errorf("条件表达式结果必须为布尔值，实际为%s", root.typ)
+	}
+
+	return &Condition{source: src, root: root}, nil
+}
+
+// String 条件表达式原文
+func (c *Condition) String() string {
+	return c.source
+}
+
+// Match 判断任务是否满足条件
+func (c *Condition) Match(env *Env) bool {
+	if c.root == nil {
+		return true
+	}
+	return c.root.eval(env).(bool)
+}
+
+// parser 递归下降语法分析器
+type parser struct {
+	tokens []token
+	pos    int
+	depth  int
+}
+
+// peek 查看当前词法单元
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+// next 读取当前词法单元
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+// isOp 当前词法单元是否为指定运算符
+func (p *parser) isOp(op string) bool {
+	t := p.peek()
+	return t.kind == tokenOp && t.text == op
+}
+
+// expect 读取指定运算符
+func (p *parser) expect(op string) error {
+	t := p.next()
+	if t.kind != tokenOp || t.text != op {
+		return fmt.Errorf("位置%d: 缺少 %q", t.pos, op)
+	}
+	return nil
+}
+
+// enter 进入一层嵌套，超过深度上限时报错
+func (p *parser) enter() error {
+	p.depth++
+	if p.depth > maxExprDepth {
+		return fmt.Errorf("表达式嵌套不能超过%d层", maxExprDepth)
+	}
+	return nil
+}
+
+// parseOr or := and ('||' and)*
+func (p *parser) parseOr() (*node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("||") {
+		t := p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		if err := expectType(t, typeBool, left, right); err != nil {
+			return nil, err
+		}
+		l, r := left, right
+		left = &node{typ: typeBool, eval: func(env *Env) interface{} {
+			return l.eval(env).(bool) || r.eval(env).(bool)
+		}}
+		orClauses(left, l, r)
+	}
+	return left, nil
+}
+
+// parseAnd and := not ('&&' not)*
+func (p *parser) parseAnd() (*node, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("&&") {
+		t := p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		if err := expectType(t, typeBool, left, right); err != nil {
+			return nil, err
+		}
+		l, r := left, right
+		left = &node{typ: typeBool, eval: func(env *Env) interface{} {
+			return l.eval(env).(bool) && r.eval(env).(bool)
+		}}
+		andClauses(left, l, r)
+	}
+	return left, nil
+}
+
+// parseNot not := '!' not | compare
+func (p *parser) parseNot() (*node, error) {
+	if !p.isOp("!") {
+		return p.parseCompare()
+	}
+	t := p.next()
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer func() { p.depth-- }()
+
+	operand, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	if err := expectType(t, typeBool, operand); err != nil {
+		return nil, err
+	}
+	n := &node{typ: typeBool, eval: func(env *Env) interface{} {
+		return !operand.eval(env).(bool)
+	}}
+	notClauses(n, operand)
+	return n, nil
+}
+
+// parseCompare compare := primary (('=='|'!='|'<'|'<='|'>'|'>=') primary | 'in' primary)?
+func (p *parser) parseCompare() (*node, error) {
+	left, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	t := p.peek()
+	if t.kind == tokenIdent && t.text == "in" {
+		p.next()
+		right, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		return compileIn(t, left, right)
+	}
+	if t.kind != tokenOp {
+		return left, nil
+	}
+	switch t.text {
+	case "==", "!=", "<", "<=", ">", ">=":
+	default:
+		return left, nil
+	}
+	p.next()
+	right, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	return compileCompare(t, left, right)
+}
+
+// parsePrimary primary := 字面量 | 字段 | 函数调用 | 列表 | '(' or ')'
+func (p *parser) parsePrimary() (*node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenNumber:
+		return numberLiteral(t, false)
+	case tokenString:
+		value := t.text
+		return &node{typ: typeString, eval: func(*Env) interface{} { return value }, literal: value}, nil
+	case tokenIdent:
+		switch t.text {
+		case "true", "false":
+			value := t.text == "true"
+			n := &node{typ: typeBool, eval: func(*Env) interface{} { return value }, literal: value, simple: true}
+			if value {
+				n.clauses = [][]constraint{{}}
+			}
+			return n, nil
+		}
+		if p.isOp("(") {
+			return p.parseCall(t)
+		}
+		f, ok := fields[t.text]
+		if !ok {
+			return nil, fmt.Errorf("位置%d: 未知字段 %q", t.pos, t.text)
+		}
+		return &node{typ: f.typ, eval: f.value, field: t.text}, nil
+	case tokenOp:
+		switch t.text {
+		case "-":
+			n := p.next()
+			if n.kind != tokenNumber {
+				return nil, fmt.Errorf("位置%d: 负号后必须为数值", t.pos)
+			}
+			return numberLiteral(n, true)
+		case "(":
+			if err := p.enter(); err != nil {
+				return nil, err
+			}
+			defer func() { p.depth-- }()
+			inner, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			return inner, nil
+		case "[":
+			return p.parseList(t)
+		}
+	case tokenEOF:
+		return nil, fmt.Errorf("位置%d: 表达式不完整", t.pos)
+	}
+	return nil, fmt.Errorf("位置%d: 意外的 %q", t.pos, t.text)
+}
+
+// parseList 解析列表字面量，元素必须为同类型的字面量
+func (p *parser) parseList(start token) (*node, error) {
+	var strs []string
+	var nums []float64
+	for !p.isOp("]") {
+		if len(strs)+len(nums) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		t := p.next()
+		negative := false
+		if t.kind == tokenOp && t.text == "-" {
+			negative = true
+			t = p.next()
+		}
+		switch {
+		case t.kind == tokenString && !negative && nums == nil:
+			strs = append(strs, t.text)
+		case t.kind == tokenNumber && strs == nil:
+			n, err := numberLiteral(t, negative)
+			if err != nil {
+				return nil, err
+			}
+			nums = append(nums, n.eval(nil).(float64))
+		default:
+			return nil, fmt.Errorf("位置%d: 列表元素必须为同类型的字符串或数值字面量", t.pos)
+		}
+	}
+	p.next()
+
+	if nums != nil {
+		return &node{typ: typeNumberList, eval: func(*Env) interface{} { return nums }, literal: nums}, nil
+	}
+	if strs == nil {
+		return nil, fmt.Errorf("位置%d: 列表不能为空", start.pos)
+	}
+	return &node{typ: typeStringList, eval: func(*Env) interface{} { return strs }, literal: strs}, nil
+}
+
+// parseCall 解析函数调用
+func (p *parser) parseCall(name token) (*node, error) {
+	fn, ok := functions[name.text]
+	if !ok {
+		return nil, fmt.Errorf("位置%d: 未知函数 %q", name.pos, name.text)
+	}
+	p.next()
+
+	var args []*node
+	for !p.isOp(")") {
+		if len(args) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		arg, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	p.next()
+
+	if len(args) != len(fn.args) {
+		return nil, fmt.Errorf("位置%d: 函数 %s 需要%d个参数", name.pos, name.text, len(fn.args))
+	}
+	for i, arg := range args {
+		if arg.typ != fn.args[i] {
+			return nil, fmt.Errorf("位置%d: 函数 %s 第%d个参数必须为%s", name.pos, name.text, i+1, fn.args[i])
+		}
+	}
+	return &node{typ: typeBool, eval: func(env *Env) interface{} {
+		return fn.call(args[0].eval(env).(string), args[1].eval(env).(string))
+	}}, nil
+}
+
+// function 内置函数，均为两个字符串参数并返回布尔值
+type function struct {
+	args []valueType
+	call func(a, b string) bool
+}
+
+// functions 内置函数
+var functions = map[string]function{
+	"contains":    {args: []valueType{typeString, typeString}, call: strings.Contains},
+	"starts_with": {args: []valueType{typeString, typeString}, call: strings.HasPrefix},
+	"ends_with":   {args: []valueType{typeString, typeString}, call: strings.HasSuffix},
+}
+
+// numberLiteral 创建数值字面量
+func numberLiteral(t token, negative bool) (*node, error) {
+	value, err := strconv.ParseFloat(t.text, 64)
+	if err != nil {
+		return nil, fmt.Errorf("位置%d: 无效的数值 %q", t.pos, t.text)
+	}
+	if negative {
+		value = -value
+	}
+	return &node{typ: typeNumber, eval: func(*Env) interface{} { return value }, literal: value}, nil
+}
+
+// expectType 检查操作数类型
+func expectType(op token, typ valueType, operands ...*node) error {
+	for _, operand := range operands {
+		if operand.typ != typ {
+			return fmt.Errorf("位置%d: 运算符 %s 的操作数必须为%s，实际为%s", op.pos, op.text, typ, operand.typ)
+		}
+	}
+	return nil
+}
+
+// compileCompare 编译比较运算
+func compileCompare(op token, left, right *node) (*node, error) {
+	if left.typ != right.typ {
+		return nil, fmt.Errorf("位置%d: 无法比较%s与%s", op.pos, left.typ, right.typ)
+	}
+
+	switch left.typ {
+	case typeNumber:
+		var cmp func(a, b float64) bool
+		switch op.text {
+		case "==":
+			cmp = func(a, b float64) bool { return a == b }
+		case "!=":
+			cmp = func(a, b float64) bool { return a != b }
+		case "<":
+			cmp = func(a, b float64) bool { return a < b }
+		case "<=":
+			cmp = func(a, b float64) bool { return a <= b }
+		case ">":
+			cmp = func(a, b float64) bool { return a > b }
+		case ">=":
+			cmp = func(a, b float64) bool { return a >= b }
+		}
+		return withConstraint(&node{typ: typeBool, eval: func(env *Env) interface{} {
+			return cmp(left.eval(env).(float64), right.eval(env).(float64))
+		}}, op.text, left, right), nil
+	case typeString, typeBool:
+		if op.text != "==" && op.text != "!=" {
+			return nil, fmt.Errorf("位置%d: %s只支持 == 与 !=", op.pos, left.typ)
+		}
+		equal := op.text == "=="
+		return withConstraint(&node{typ: typeBool, eval: func(env *Env) interface{} {
+			return (left.eval(env) == right.eval(env)) == equal
+		}}, op.text, left, right), nil
+	}
+	return nil, fmt.Errorf("位置%d: %s不支持比较", op.pos, left.typ)
+}
+
+// compileIn 编译包含运算
+func compileIn(op token, left, right *node) (*node, error) {
+	switch {
+	case left.typ == typeString && right.typ == typeStringList:
+		return withConstraint(&node{typ: typeBool, eval: func(env *Env) interface{} {
+			value := left.eval(env).(string)
+			for _, item := range right.eval(env).([]string) {
+				if item == value {
+					return true
+				}
+			}
+			return false
+		}}, "in", left, right), nil
+	case left.typ == typeNumber && right.typ == typeNumberList:
+		return withConstraint(&node{typ: typeBool, eval: func(env *Env) interface{} {
+			value := left.eval(env).(float64)
+			for _, item := range right.eval(env).([]float64) {
+				if item == value {
+					return true
+				}
+			}
+			return false
+		}}, "in", left, right), nil
+	}
+	return nil, fmt.Errorf("位置%d: 无法判断%s是否在%s中", op.pos, left.typ, right.typ)
+}
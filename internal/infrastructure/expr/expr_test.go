@@ -0,0 +1,519 @@
+package expr
+
+import (
+	"ai-translate/internal/model"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestConditionMatch(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	task := &model.Task{
+		ID:         "t1",
+		Type:       model.TaskTypeTranslation,
+		Status:     model.TaskStatusPending,
+		Priority:   model.TaskPriorityNormal,
+		RetryCount: 2,
+		Error:      "rate limited by provider",
+		Tags:       []string{"vip"},
+		CreatedAt:  now.Add(-45 * time.Minute),
+		UpdatedAt:  now.Add(-5 * time.Minute),
+	}
+
+	cases := []struct {
+		name string
+		src  string
+		want bool
+	}{
+		{"空表达式总是满足", "", true},
+		{"数值比较与逻辑与", `age_minutes > 30 && retry_count >= 2`, true},
+		{"逻辑或", `idle_minutes > 10 || priority == 1`, true},
+		{"逻辑非与括号", `!(type == "translation" && status == "pending")`, false},
+		{"字符串列表包含", `status in ["pending", "paused"]`, true},
+		{"数值列表包含负数", `priority in [-1, 3]`, false},
+		{"标签包含", `"vip" in tags`, true},
+		{"标签不包含", `"slow" in tags`, false},
+		{"内置函数", `contains(error, "rate limited") && starts_with(id, "t")`, true},
+		{"布尔字面量", `false || ends_with(error, "provider")`, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			condition, err := CompileCondition(c.src)
+			if err != nil {
+				t.Fatalf("编译失败: %v", err)
+			}
+			if got := condition.Match(&Env{Task: task, Now: now}); got != c.want {
+				t.Errorf("Match(%q) = %v, 期望 %v", c.src, got, c.want)
+			}
+		})
+	}
+}
+
+func TestCompileConditionError(t *testing.T) {
+	cases := []struct {
+		name string
+		src  string
+	}{
+		{"未知字段", `unknown > 1`},
+		{"未知函数", `matches(error, "x")`},
+		{"结果不是布尔值", `retry_count`},
+		{"类型不匹配", `priority == "high"`},
+		{"字符串比较大小", `type > "a"`},
+		{"函数参数类型错误", `contains(priority, "1")`},
+		{"空列表", `type in []`},
+		{"列表元素类型不一致", `type in ["a", 1]`},
+		{"表达式不完整", `retry_count >`},
+		{"多余的内容", `retry_count > 1 2`},
+		{"括号未闭合", `(retry_count > 1`},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if _, err := CompileCondition(c.src); err == nil {
+				t.Errorf("CompileCondition(%q) 期望失败", c.src)
+			}
+		})
+	}
+}
+
+func TestParseActions(t *testing.T) {
+	cases := []struct {
+		name    string
+		src     string
+		want    []Action
+		wantErr bool
+	}{
+		{"空动作", "", nil, false},
+		{
+			name: "多个动作与省略的参数",
+			src:  `raise_priority(); tag("slow"); reroute("openai");`,
+			want: []Action{
+				{Type: ActionRaisePriority, Number: 1},
+				{Type: ActionTag, Text: "slow"},
+				{Type: ActionReroute, Text: "openai"},
+			},
+		},
+		{"设置优先级", `set_priority(3)`, []Action{{Type: ActionSetPriority, Number: 3}}, false},
+		{"设置的优先级超出范围", `set_priority(4)`, nil, true},
+		{"缺少必填参数", `set_priority()`, nil, true},
+		{"无参数动作带参数", `pause(1)`, nil, true},
+		{"参数类型错误", `tag(1)`, nil, true},
+		{"参数为空字符串", `reroute(" ")`, nil, true},
+		{"参数不是整数", `raise_priority(1.5)`, nil, true},
+		{"未知动作", `delete()`, nil, true},
+		{"缺少分号", `pause() resume()`, nil, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			actions, err := ParseActions(c.src)
+			if c.wantErr {
+				if err == nil {
+					t.Fatalf("ParseActions(%q) 期望失败，实际得到 %+v", c.src, actions)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("解析失败: %v", err)
+			}
+			if !reflect.DeepEqual(actions, c.want) {
+				t.Errorf("动作 = %+v, 期望 %+v", actions, c.want)
+			}
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	cases := []struct {
+		name     string
+		task     model.Task
+		actions  string
+		effect   Effect
+		changed  bool
+		describe string
+	}{
+		{
+			name:     "提升优先级不超过最高",
+			task:     model.Task{Priority: model.TaskPriorityHigh},
+			actions:  `raise_priority(5)`,
+			effect:   Effect{Priority: model.TaskPriorityUrgent},
+			changed:  true,
+			describe: "set_priority(3)",
+		},
+		{
+			name:    "降低优先级不低于最低",
+			task:    model.Task{Priority: model.TaskPriorityLow},
+			actions: `lower_priority(2)`,
+			effect:  Effect{Priority: model.TaskPriorityLow},
+		},
+		{
+			name:     "暂停与恢复以最后一个动作为准",
+			task:     model.Task{Status: model.TaskStatusPaused},
+			actions:  `pause(); resume()`,
+			effect:   Effect{Resume: true},
+			changed:  true,
+			describe: "resume()",
+		},
+		{
+			name:    "暂停已暂停的任务没有影响",
+			task:    model.Task{Status: model.TaskStatusPaused},
+			actions: `pause()`,
+			effect:  Effect{Pause: true},
+		},
+		{
+			name:     "已有的标签与相同驱动不重复",
+			task:     model.Task{Driver: "openai", Tags: []string{"vip"}},
+			actions:  `reroute("openai"); tag("vip"); tag("slow"); tag("slow")`,
+			effect:   Effect{Tags: []string{"slow"}},
+			changed:  true,
+			describe: `tag("slow")`,
+		},
+		{
+			name:     "切换驱动",
+			task:     model.Task{Driver: "openai", Status: model.TaskStatusPending},
+			actions:  `reroute("anthropic"); pause()`,
+			effect:   Effect{Pause: true, Driver: "anthropic"},
+			changed:  true,
+			describe: `pause(); reroute("anthropic")`,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			actions, err := ParseActions(c.actions)
+			if err != nil {
+				t.Fatalf("解析失败: %v", err)
+			}
+			effect := Apply(actions, &c.task)
+			if !reflect.DeepEqual(effect, c.effect) {
+				t.Errorf("影响 = %+v, 期望 %+v", effect, c.effect)
+			}
+			if got := effect.Changed(&c.task); got != c.changed {
+				t.Errorf("Changed = %v, 期望 %v", got, c.changed)
+			}
+			if got := effect.Describe(&c.task); got != c.describe {
+				t.Errorf("Describe = %q, 期望 %q", got, c.describe)
+			}
+		})
+	}
+}
+
+func TestCompile(t *testing.T) {
+	cases := []struct {
+		name     string
+		rule     model.PriorityAdjustRule
+		actions  []Action
+		relative bool
+		wantErr  bool
+	}{
+		{
+			name:    "动作为空时按规则优先级设置",
+			rule:    model.PriorityAdjustRule{Condition: `age_minutes > 30`, Priority: model.TaskPriorityHigh},
+			actions: []Action{{Type: ActionSetPriority, Number: int(model.TaskPriorityHigh)}},
+		},
+		{
+			name:     "相对调整优先级",
+			rule:     model.PriorityAdjustRule{Action: `tag("aged"); raise_priority()`},
+			actions:  []Action{{Type: ActionTag, Text: "aged"}, {Type: ActionRaisePriority, Number: 1}},
+			relative: true,
+		},
+		{
+			name:    "动作为空且优先级无效",
+			rule:    model.PriorityAdjustRule{Priority: model.TaskPriority(9)},
+			wantErr: true,
+		},
+		{
+			name:    "条件无效",
+			rule:    model.PriorityAdjustRule{Condition: `age_minutes >`, Action: `pause()`},
+			wantErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			rule, err := Compile(&c.rule)
+			if c.wantErr {
+				if err == nil {
+					t.Fatal("期望编译失败")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("编译失败: %v", err)
+			}
+			if !reflect.DeepEqual(rule.Actions, c.actions) {
+				t.Errorf("动作 = %+v, 期望 %+v", rule.Actions, c.actions)
+			}
+			if got := rule.Relative(); got != c.relative {
+				t.Errorf("Relative = %v, 期望 %v", got, c.relative)
+			}
+		})
+	}
+}
+
+func TestCompileCache(t *testing.T) {
+	rule := &model.PriorityAdjustRule{ID: "cache-test", Action: `raise_priority(1)`}
+	defer Evict(rule.ID)
+
+	first, err := Compile(rule)
+	if err != nil {
+		t.Fatalf("编译失败: %v", err)
+	}
+	if second, _ := Compile(rule); second != first {
+		t.Error("规则未修改时期望复用编译结果")
+	}
+
+	rule.Action = `lower_priority(1)`
+	updated, err := Compile(rule)
+	if err != nil {
+		t.Fatalf("编译失败: %v", err)
+	}
+	if updated == first || updated.Actions[0].Type != ActionLowerPriority {
+		t.Errorf("规则修改后期望重新编译，实际动作 %+v", updated.Actions)
+	}
+}
+
+func TestInScope(t *testing.T) {
+	task := &model.Task{WorkID: "w1", BatchID: "b1", Type: model.TaskTypeTranslation, Status: model.TaskStatusPending}
+
+	cases := []struct {
+		name string
+		rule model.PriorityAdjustRule
+		want bool
+	}{
+		{"不限定范围", model.PriorityAdjustRule{}, true},
+		{"范围全部匹配", model.PriorityAdjustRule{WorkID: "w1", BatchID: "b1", TaskType: model.TaskTypeTranslation, Status: model.TaskStatusPending}, true},
+		{"工作不匹配", model.PriorityAdjustRule{WorkID: "w2"}, false},
+		{"任务类型不匹配", model.PriorityAdjustRule{TaskType: model.TaskTypeContentGeneration}, false},
+		{"状态不匹配", model.PriorityAdjustRule{Status: model.TaskStatusPaused}, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := InScope(&c.rule, task); got != c.want {
+				t.Errorf("InScope = %v, 期望 %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestFindConflicts(t *testing.T) {
+	cases := []struct {
+		name  string
+		rule  model.PriorityAdjustRule
+		other model.PriorityAdjustRule
+		kind  ConflictKind
+	}{
+		{
+			name:  "条件重叠且设置不同的优先级",
+			rule:  model.PriorityAdjustRule{ID: "a", Condition: `age_minutes > 30`, Action: `set_priority(3)`},
+			other: model.PriorityAdjustRule{ID: "b", Condition: `age_minutes > 60`, Action: `set_priority(0)`},
+			kind:  ConflictContradiction,
+		},
+		{
+			name:  "一条提升一条降低",
+			rule:  model.PriorityAdjustRule{ID: "a", Condition: `retry_count >= 2`, Action: `raise_priority()`},
+			other: model.PriorityAdjustRule{ID: "b", Condition: `retry_count < 5`, Action: `lower_priority()`},
+			kind:  ConflictContradiction,
+		},
+		{
+			name:  "均调整优先级",
+			rule:  model.PriorityAdjustRule{ID: "a", Action: `raise_priority()`},
+			other: model.PriorityAdjustRule{ID: "b", Action: `set_priority(2)`},
+			kind:  ConflictOverlap,
+		},
+		{
+			name:  "切换到不同驱动",
+			rule:  model.PriorityAdjustRule{ID: "a", Action: `reroute("openai")`},
+			other: model.PriorityAdjustRule{ID: "b", Condition: `type == "translation"`, Action: `reroute("anthropic")`},
+			kind:  ConflictContradiction,
+		},
+		{
+			name:  "数值范围不相交",
+			rule:  model.PriorityAdjustRule{ID: "a", Condition: `age_minutes > 30`, Action: `set_priority(3)`},
+			other: model.PriorityAdjustRule{ID: "b", Condition: `age_minutes <= 30`, Action: `set_priority(0)`},
+		},
+		{
+			name:  "整数字段不存在满足两个条件的取值",
+			rule:  model.PriorityAdjustRule{ID: "a", Condition: `retry_count > 1`, Action: `raise_priority()`},
+			other: model.PriorityAdjustRule{ID: "b", Condition: `retry_count < 2`, Action: `lower_priority()`},
+		},
+		{
+			name:  "作用范围不相交",
+			rule:  model.PriorityAdjustRule{ID: "a", TaskType: model.TaskTypeTranslation, Action: `set_priority(3)`},
+			other: model.PriorityAdjustRule{ID: "b", Condition: `type == "content_generation"`, Action: `set_priority(0)`},
+		},
+		{
+			name:  "动作调整不同属性",
+			rule:  model.PriorityAdjustRule{ID: "a", Action: `tag("slow")`},
+			other: model.PriorityAdjustRule{ID: "b", Action: `pause()`},
+		},
+		{
+			name:  "跳过自身",
+			rule:  model.PriorityAdjustRule{ID: "a", Action: `set_priority(3)`},
+			other: model.PriorityAdjustRule{ID: "a", Action: `set_priority(0)`},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			conflicts := FindConflicts(&c.rule, []*model.PriorityAdjustRule{&c.other})
+			Evict(c.rule.ID)
+			Evict(c.other.ID)
+
+			if c.kind == "" {
+				if len(conflicts) != 0 {
+					t.Errorf("期望没有冲突，实际 %+v", conflicts)
+				}
+				return
+			}
+			if len(conflicts) != 1 || conflicts[0].Kind != c.kind {
+				t.Fatalf("冲突 = %+v, 期望一个 %s", conflicts, c.kind)
+			}
+			if conflicts[0].RuleID != c.rule.ID || conflicts[0].OtherRuleID != c.other.ID {
+				t.Errorf("冲突规则 = %s/%s, 期望 %s/%s", conflicts[0].RuleID, conflicts[0].OtherRuleID, c.rule.ID, c.other.ID)
+			}
+		})
+	}
+}
+
+func TestInstantiate(t *testing.T) {
+	template := &model.RuleTemplate{
+		ID:      "tpl",
+		Name:    "等待过久",
+		Version: 2,
+		Params: []model.TemplateParam{
+			{Name: "work_id", Type: model.TemplateParamString},
+			{Name: "threshold", Type: model.TemplateParamNumber, Default: "30"},
+			{Name: "tag", Type: model.TemplateParamString, Default: "aged"},
+			{Name: "step", Type: model.TemplateParamInteger, Default: "1"},
+		},
+		WorkID:    "{work_id}",
+		TaskType:  model.TaskTypeTranslation,
+		Condition: `age_minutes > {threshold} && work_id == {work_id}`,
+		Action:    `raise_priority({step}); tag({tag})`,
+	}
+
+	cases := []struct {
+		name      string
+		params    map[string]string
+		condition string
+		action    string
+		workID    string
+		wantErr   bool
+	}{
+		{
+			name:      "使用默认值",
+			params:    map[string]string{"work_id": "w1"},
+			condition: `age_minutes > 30 && work_id == "w1"`,
+			action:    `raise_priority(1); tag("aged")`,
+			workID:    "w1",
+		},
+		{
+			name:      "字符串参数无法改变表达式结构",
+			params:    map[string]string{"work_id": `w1" || true || "`, "threshold": " 1.50 ", "tag": `a\b`},
+			condition: `age_minutes > 1.5 && work_id == "w1\" || true || \""`,
+			action:    `raise_priority(1); tag("a\\b")`,
+			workID:    `w1" || true || "`,
+		},
+		{"缺少必填参数", map[string]string{}, "", "", "", true},
+		{"数值参数无效", map[string]string{"work_id": "w1", "threshold": "1 || true"}, "", "", "", true},
+		{"整数参数无效", map[string]string{"work_id": "w1", "step": "1.5"}, "", "", "", true},
+		{"未定义的参数", map[string]string{"work_id": "w1", "other": "x"}, "", "", "", true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			rule, err := Instantiate(template, c.params)
+			if c.wantErr {
+				if err == nil {
+					t.Fatalf("期望实例化失败，实际得到 %+v", rule)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("实例化失败: %v", err)
+			}
+			if rule.Condition != c.condition {
+				t.Errorf("条件 = %s, 期望 %s", rule.Condition, c.condition)
+			}
+			if rule.Action != c.action {
+				t.Errorf("动作 = %s, 期望 %s", rule.Action, c.action)
+			}
+			if rule.WorkID != c.workID {
+				t.Errorf("工作ID = %s, 期望 %s", rule.WorkID, c.workID)
+			}
+			if rule.TemplateID != template.ID || rule.TemplateVersion != template.Version || rule.TaskType != template.TaskType {
+				t.Errorf("模板信息 = %s/%d/%s, 期望 %s/%d/%s", rule.TemplateID, rule.TemplateVersion, rule.TaskType,
+					template.ID, template.Version, template.TaskType)
+			}
+		})
+	}
+}
+
+func TestValidateTemplate(t *testing.T) {
+	cases := []struct {
+		name     string
+		template model.RuleTemplate
+		wantErr  bool
+	}{
+		{
+			name: "合法模板",
+			template: model.RuleTemplate{
+				Params:    []model.TemplateParam{{Name: "n", Type: model.TemplateParamInteger, Default: "2"}},
+				Condition: `retry_count >= {n}`,
+				Action:    `raise_priority({n})`,
+			},
+		},
+		{
+			name:     "参数名无效",
+			template: model.RuleTemplate{Params: []model.TemplateParam{{Name: "1n", Type: model.TemplateParamInteger}}},
+			wantErr:  true,
+		},
+		{
+			name: "参数重复",
+			template: model.RuleTemplate{Params: []model.TemplateParam{
+				{Name: "n", Type: model.TemplateParamInteger},
+				{Name: "n", Type: model.TemplateParamNumber},
+			}},
+			wantErr: true,
+		},
+		{
+			name:     "参数类型无效",
+			template: model.RuleTemplate{Params: []model.TemplateParam{{Name: "n", Type: "bool"}}},
+			wantErr:  true,
+		},
+		{
+			name:     "默认值无效",
+			template: model.RuleTemplate{Params: []model.TemplateParam{{Name: "n", Type: model.TemplateParamInteger, Default: "x"}}},
+			wantErr:  true,
+		},
+		{
+			name:     "引用未定义的参数",
+			template: model.RuleTemplate{Condition: `retry_count >= {n}`},
+			wantErr:  true,
+		},
+		{
+			name: "字符串参数用在数值位置",
+			template: model.RuleTemplate{
+				Params:    []model.TemplateParam{{Name: "n", Type: model.TemplateParamString}},
+				Condition: `retry_count >= {n}`,
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := ValidateTemplate(&c.template)
+			if c.wantErr && err == nil {
+				t.Error("期望校验失败")
+			}
+			if !c.wantErr && err != nil {
+				t.Errorf("校验失败: %v", err)
+			}
+		})
+	}
+}
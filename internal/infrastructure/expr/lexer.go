@@ -0,0 +1,104 @@
+package expr
+
+import (
+	"fmt"
+	"strings"
+)
+
+// tokenKind 词法单元类型
+type tokenKind int
+
+const (
+	tokenEOF    tokenKind = iota
+	tokenIdent            // 标识符
+	tokenNumber           // 数字
+	tokenString           // 字符串
+	tokenOp               // 运算符与分隔符
+)
+
+// token 词法单元
+type token struct {
+	kind tokenKind
+	text string // 标识符、运算符原文或字符串内容
+	pos  int    // 在表达式中的位置，用于错误提示
+}
+
+// operators 支持的运算符与分隔符，长的在前以便优先匹配
+var operators = []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "(", ")", "[", "]", ",", ";", "-"}
+
+// tokenize 将表达式拆分为词法单元
+func tokenize(src string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case isIdentStart(src[i]):
+			start := i
+			for i < len(src) && (isIdentStart(src[i]) || isDigit(src[i])) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: src[start:i], pos: start})
+		case isDigit(src[i]):
+			start := i
+			for i < len(src) && (isDigit(src[i]) || src[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: src[start:i], pos: start})
+		case c == '"' || c == '\'':
+			start := i
+			text, n, err := scanString(src[i:])
+			if err != nil {
+				return nil, fmt.Errorf("位置%d: %v", start, err)
+			}
+			i += n
+			tokens = append(tokens, token{kind: tokenString, text: text, pos: start})
+		default:
+			matched := false
+			for _, op := range operators {
+				if strings.HasPrefix(src[i:], op) {
+					tokens = append(tokens, token{kind: tokenOp, text: op, pos: i})
+					i += len(op)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("位置%d: 无法识别的字符 %q", i, src[i])
+			}
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, pos: len(src)}), nil
+}
+
+// scanString 读取引号包围的字符串，支持 \" \' \\ 转义，返回内容与消耗的字节数
+func scanString(src string) (string, int, error) {
+	quote := src[0]
+	var b strings.Builder
+	for i := 1; i < len(src); i++ {
+		switch src[i] {
+		case quote:
+			return b.String(), i + 1, nil
+		case '\\':
+			if i+1 >= len(src) {
+				return "", 0, fmt.Errorf("字符串未结束")
+			}
+			i++
+			b.WriteByte(src[i])
+		default:
+			b.WriteByte(src[i])
+		}
+	}
+	return "", 0, fmt.Errorf("字符串未结束")
+}
+
+// isIdentStart 是否可作为标识符字符，标识符仅支持ASCII字母与下划线
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// isDigit 是否为数字
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
@@ -0,0 +1,97 @@
+package expr
+
+import (
+	"ai-translate/internal/model"
+	"fmt"
+	"strconv"
+	"sync"
+)
+
+// Rule 编译后的优先级调整规则
+type Rule struct {
+	Condition *Condition
+	Actions   []Action
+}
+
+// cachedRule 缓存的编译结果及编译时的规则内容
+type cachedRule struct {
+	source string
+	rule   *Rule
+}
+
+// compiled 已编译规则缓存，以规则ID为键，每条规则只保留最新内容的编译结果，规则修改后重新编译并替换
+var compiled sync.Map
+
+// Compile 编译规则的条件与动作，动作为空时等同于 set_priority(rule.Priority)
+// 没有ID的草稿规则不缓存
+func Compile(r *model.PriorityAdjustRule) (*Rule, error) {
+	source := r.Condition + "\x00" + r.Action + "\x00" + strconv.Itoa(int(r.Priority))
+	if r.ID != "" {
+		if cached, ok := compiled.Load(r.ID); ok && cached.(*cachedRule).source == source {
+			return cached.(*cachedRule).rule, nil
+		}
+	}
+
+	condition, err := CompileCondition(r.Condition)
+	if err != nil {
+		return nil, fmt.Errorf("规则条件无效: %v", err)
+	}
+	actions, err := ParseActions(r.Action)
+	if err != nil {
+		return nil, fmt.Errorf("规则动作无效: %v", err)
+	}
+	if len(actions) == 0 {
+		if r.Priority < model.TaskPriorityLow || r.Priority > model.TaskPriorityUrgent {
+			return nil, fmt.Errorf("规则动作无效: 优先级必须在%d到%d之间", model.TaskPriorityLow, model.TaskPriorityUrgent)
+		}
+		actions = []Action{{Type: ActionSetPriority, Number: int(r.Priority)}}
+	}
+
+	compiledRule := &Rule{Condition: condition, Actions: actions}
+	if r.ID != "" {
+		compiled.Store(r.ID, &cachedRule{source: source, rule: compiledRule})
+	}
+	return compiledRule, nil
+}
+
+// Evict 删除规则的编译缓存，规则删除后调用
+func Evict(ruleID string) {
+	compiled.Delete(ruleID)
+}
+
+// InScope 判断任务是否在规则限定的工作、批次、任务类型与状态范围内
+func InScope(r *model.PriorityAdjustRule, task *model.Task) bool {
+	if r.WorkID != "" && r.WorkID != task.WorkID {
+		return false
+	}
+	if r.BatchID != "" && r.BatchID != task.BatchID {
+		return false
+	}
+	if r.TaskType != "" && r.TaskType != task.Type {
+		return false
+	}
+	if r.Status != "" && r.Status != task.Status {
+		return false
+	}
+	return true
+}
+
+// Match 判断任务是否满足规则条件
+func (r *Rule) Match(env *Env) bool {
+	return r.Condition.Match(env)
+}
+
+// Apply 计算规则动作对任务的影响
+func (r *Rule) Apply(task *model.Task) Effect {
+	return Apply(r.Actions, task)
+}
+
+// Relative 是否包含相对调整优先级的动作，这类规则重复执行会持续改变优先级
+func (r *Rule) Relative() bool {
+	for _, action := range r.Actions {
+		if action.Type == ActionRaisePriority || action.Type == ActionLowerPriority {
+			return true
+		}
+	}
+	return false
+}
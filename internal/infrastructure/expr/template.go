@@ -0,0 +1,173 @@
+package expr
+
+import (
+	"ai-translate/internal/model"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// placeholderPattern 模板占位符 {name}
+var placeholderPattern = regexp.MustCompile(`\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// Instantiate 按参数实例化模板，返回的规则只包含模板决定的字段
+// 条件与动作中的字符串参数替换为带引号的字符串字面量，数值参数校验后原样替换，参数值无法改变表达式结构；
+// 作用范围字段中的参数按原文替换
+func Instantiate(template *model.RuleTemplate, params map[string]string) (*model.PriorityAdjustRule, error) {
+	values, err := resolveParams(template.Params, params)
+	if err != nil {
+		return nil, err
+	}
+
+	rule := &model.PriorityAdjustRule{
+		Name:            template.Name,
+		Description:     template.Description,
+		Priority:        template.Priority,
+		TemplateID:      template.ID,
+		TemplateVersion: template.Version,
+		TemplateParams:  values,
+	}
+
+	types := make(map[string]model.TemplateParamType, len(template.Params))
+	for _, param := range template.Params {
+		types[param.Name] = param.Type
+	}
+	literal := func(name string) string {
+		if types[name] == model.TemplateParamString {
+			return quoteString(values[name])
+		}
+		return values[name]
+	}
+	raw := func(name string) string {
+		return values[name]
+	}
+
+	fields := []struct {
+		dst   *string
+		src   string
+		value func(name string) string
+	}{
+		{&rule.Condition, template.Condition, literal},
+		{&rule.Action, template.Action, literal},
+		{&rule.WorkID, template.WorkID, raw},
+		{&rule.BatchID, template.BatchID, raw},
+	}
+	for _, f := range fields {
+		if *f.dst, err = substitute(f.src, types, f.value); err != nil {
+			return nil, err
+		}
+	}
+	rule.TaskType = template.TaskType
+	rule.Status = template.Status
+
+	if _, err := Compile(rule); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+// ValidateTemplate 校验模板参数定义，并以默认值或示例值实例化一次，确保模板能生成合法的规则
+func ValidateTemplate(template *model.RuleTemplate) error {
+	sample := make(map[string]string, len(template.Params))
+	seen := make(map[string]bool, len(template.Params))
+	for _, param := range template.Params {
+		if !placeholderPattern.MatchString("{" + param.Name + "}") {
+			return fmt.Errorf("模板参数名无效: %q", param.Name)
+		}
+		if seen[param.Name] {
+			return fmt.Errorf("模板参数重复: %s", param.Name)
+		}
+		seen[param.Name] = true
+
+		switch param.Type {
+		case model.TemplateParamString:
+			sample[param.Name] = "sample"
+		case model.TemplateParamNumber, model.TemplateParamInteger:
+			sample[param.Name] = "1"
+		default:
+			return fmt.Errorf("模板参数 %s 的类型无效: %q", param.Name, param.Type)
+		}
+		if param.Default != "" {
+			if _, err := convertParam(param, param.Default); err != nil {
+				return fmt.Errorf("模板参数 %s 的默认值无效: %v", param.Name, err)
+			}
+		}
+	}
+
+	_, err := Instantiate(template, sample)
+	return err
+}
+
+// resolveParams 校验参数并补全默认值
+func resolveParams(defs []model.TemplateParam, params map[string]string) (map[string]string, error) {
+	declared := make(map[string]bool, len(defs))
+	values := make(map[string]string, len(defs))
+	for _, param := range defs {
+		declared[param.Name] = true
+
+		value, ok := params[param.Name]
+		if !ok || value == "" {
+			if param.Default == "" {
+				return nil, fmt.Errorf("缺少模板参数: %s", param.Name)
+			}
+			value = param.Default
+		}
+
+		converted, err := convertParam(param, value)
+		if err != nil {
+			return nil, fmt.Errorf("模板参数 %s 无效: %v", param.Name, err)
+		}
+		values[param.Name] = converted
+	}
+
+	for name := range params {
+		if !declared[name] {
+			return nil, fmt.Errorf("未定义的模板参数: %s", name)
+		}
+	}
+	return values, nil
+}
+
+// convertParam 按类型校验参数值，返回规范化后的值
+func convertParam(param model.TemplateParam, value string) (string, error) {
+	switch param.Type {
+	case model.TemplateParamNumber:
+		n, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || math.IsNaN(n) || math.IsInf(n, 0) {
+			return "", fmt.Errorf("%q 不是数值", value)
+		}
+		return strconv.FormatFloat(n, 'f', -1, 64), nil
+	case model.TemplateParamInteger:
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return "", fmt.Errorf("%q 不是整数", value)
+		}
+		return strconv.Itoa(n), nil
+	}
+	return value, nil
+}
+
+// quoteString 生成表达式中的字符串字面量，只转义引号与反斜杠，与scanString对应
+func quoteString(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `"`, `\"`)
+	return `"` + value + `"`
+}
+
+// substitute 替换占位符，引用未定义的参数时报错
+func substitute(src string, types map[string]model.TemplateParamType, value func(name string) string) (string, error) {
+	var err error
+	result := placeholderPattern.ReplaceAllStringFunc(src, func(match string) string {
+		name := match[1 : len(match)-1]
+		if _, ok := types[name]; !ok {
+			if err == nil {
+				err = fmt.Errorf("模板引用了未定义的参数: %s", name)
+			}
+			return match
+		}
+		return value(name)
+	})
+	return result, err
+}
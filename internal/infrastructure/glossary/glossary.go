@@ -0,0 +1,200 @@
+package glossary
+
+import (
+	"context"
+	"fmt"
+	"github.com/gogf/gf/v2/frame/g"
+	"path"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Format 术语表格式
+type Format string
+
+const (
+	FormatCSV Format = "csv"
+	FormatTSV Format = "tsv"
+	FormatTBX Format = "tbx"
+)
+
+// Term 术语条目
+type Term struct {
+	Source   string `json:"source"`   // 原文术语
+	Target   string `json:"target"`   // 译文术语
+	Required bool   `json:"required"` // 是否强制使用译文术语
+}
+
+// Violation 术语违规记录
+type Violation struct {
+	CueIndex int   `json:"cue_index"` // 字幕条目编号
+	Term     *Term `json:"term"`      // 未遵守的术语
+}
+
+// Glossary 术语表
+type Glossary struct {
+	Terms []*Term `json:"terms"`
+}
+
+// Load 从存储下载并解析术语表，targetLang用于TBX选择目标语言
+func Load(ctx context.Context, url string, targetLang string) (*Glossary, error) {
+	resp, err := g.Client().Get(ctx, url)
+	if err != nil {
+		return nil, fmt.Errorf("下载术语表失败: %v", err)
+	}
+	defer resp.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("下载术语表失败，状态码：%d", resp.StatusCode)
+	}
+
+	data := resp.ReadAll()
+	glossary, err := Parse(data, DetectFormat(url, data), targetLang)
+	if err != nil {
+		return nil, fmt.Errorf("解析术语表失败(%s): %v", path.Base(url), err)
+	}
+	return glossary, nil
+}
+
+// DetectFormat 根据文件扩展名或内容判断术语表格式
+func DetectFormat(url string, data []byte) Format {
+	name := url
+	if i := strings.IndexAny(name, "?#"); i >= 0 {
+		name = name[:i]
+	}
+	switch strings.ToLower(path.Ext(name)) {
+	case ".tbx", ".xml":
+		return FormatTBX
+	case ".tsv", ".tab":
+		return FormatTSV
+	case ".csv":
+		return FormatCSV
+	}
+
+	content := strings.TrimSpace(string(data))
+	if strings.HasPrefix(content, "<") {
+		return FormatTBX
+	}
+	firstLine := content
+	if i := strings.IndexByte(content, '\n'); i >= 0 {
+		firstLine = content[:i]
+	}
+	if strings.Contains(firstLine, "\t") {
+		return FormatTSV
+	}
+	return FormatCSV
+}
+
+// Parse 按格式解析术语表
+func Parse(data []byte, format Format, targetLang string) (*Glossary, error) {
+	var (
+		terms []*Term
+		err   error
+	)
+	switch format {
+	case FormatCSV:
+		terms, err = parseDelimited(data, ',')
+	case FormatTSV:
+		terms, err = parseDelimited(data, '\t')
+	case FormatTBX:
+		terms, err = parseTBX(data, targetLang)
+	default:
+		return nil, fmt.Errorf("不支持的术语表格式: %s", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// 长术语优先，避免短术语抢先匹配
+	sort.SliceStable(terms, func(i, j int) bool {
+		return len(terms[i].Source) > len(terms[j].Source)
+	})
+
+	return &Glossary{Terms: terms}, nil
+}
+
+// Match 获取文本中出现的术语
+func (gl *Glossary) Match(texts ...string) []*Term {
+	if gl == nil || len(gl.Terms) == 0 {
+		return nil
+	}
+
+	content := strings.ToLower(strings.Join(texts, "\n"))
+	var matched []*Term
+	for _, term := range gl.Terms {
+		if containsTerm(content, strings.ToLower(term.Source)) {
+			matched = append(matched, term)
+		}
+	}
+	return matched
+}
+
+// Check 检查译文是否使用了原文中出现的强制术语，返回未遵守的术语
+func (gl *Glossary) Check(source, translation string) []*Term {
+	var broken []*Term
+	target := strings.ToLower(translation)
+	for _, term := range gl.Match(source) {
+		if term.Required && !containsTerm(target, strings.ToLower(term.Target)) {
+			broken = append(broken, term)
+		}
+	}
+	return broken
+}
+
+// containsTerm 判断文本是否包含术语，术语首尾为拉丁字母或数字时要求该处为词边界，
+// 避免 AI 匹配到 said 之类的单词；中日韩等不以空格分词的文字仍按子串匹配
+func containsTerm(content, term string) bool {
+	if term == "" {
+		return false
+	}
+	first, _ := utf8.DecodeRuneInString(term)
+	last, _ := utf8.DecodeLastRuneInString(term)
+
+	for start := 0; start <= len(content); {
+		i := strings.Index(content[start:], term)
+		if i < 0 {
+			return false
+		}
+		i += start
+		end := i + len(term)
+
+		before, _ := utf8.DecodeLastRuneInString(content[:i])
+		after, _ := utf8.DecodeRuneInString(content[end:])
+		if (!isLatinWordRune(first) || !isLatinWordRune(before)) &&
+			(!isLatinWordRune(last) || !isLatinWordRune(after)) {
+			return true
+		}
+
+		_, size := utf8.DecodeRuneInString(content[i:])
+		start = i + size
+	}
+	return false
+}
+
+// isLatinWordRune 是否为拉丁字母或数字，空字符串解码得到的 utf8.RuneError 视为边界
+func isLatinWordRune(r rune) bool {
+	if r < utf8.RuneSelf {
+		return unicode.IsLetter(r) || unicode.IsDigit(r)
+	}
+	return unicode.Is(unicode.Latin, r)
+}
+
+// PromptSection 生成注入提示词的术语说明
+func PromptSection(terms []*Term) string {
+	if len(terms) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("术语表（原文 => 译文，标*的必须使用指定译文）：\n")
+	for _, term := range terms {
+		mark := ""
+		if term.Required {
+			mark = "*"
+		}
+		fmt.Fprintf(&b, "%s%s => %s\n", mark, term.Source, term.Target)
+	}
+	return b.String()
+}
@@ -0,0 +1,191 @@
+package glossary
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDetectFormat(t *testing.T) {
+	cases := []struct {
+		name string
+		url  string
+		data string
+		want Format
+	}{
+		{"CSV扩展名", "https://oss/terms.csv?sign=1", "a\tb", FormatCSV},
+		{"TSV扩展名", "https://oss/terms.TSV", "a,b", FormatTSV},
+		{"TBX扩展名", "https://oss/terms.tbx#v1", "", FormatTBX},
+		{"无扩展名时按XML内容识别", "https://oss/terms", "  <martif></martif>", FormatTBX},
+		{"无扩展名时首行含制表符", "https://oss/terms", "source\ttarget\nAI,人工智能", FormatTSV},
+		{"无扩展名时默认CSV", "https://oss/terms", "source,target", FormatCSV},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := DetectFormat(c.url, []byte(c.data)); got != c.want {
+				t.Errorf("DetectFormat(%q) = %s, 期望 %s", c.url, got, c.want)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		name       string
+		data       string
+		format     Format
+		targetLang string
+		want       []Term
+		wantErr    bool
+	}{
+		{
+			name:   "CSV带表头、注释与是否强制列，长术语在前",
+			data:   "\xef\xbb\xbf原文,译文,强制\n# 注释\nAI,人工智能\nmachine learning,机器学习,否\n\nGPU, 图形处理器 ,是\n",
+			format: FormatCSV,
+			want: []Term{
+				{Source: "machine learning", Target: "机器学习", Required: false},
+				{Source: "GPU", Target: "图形处理器", Required: true},
+				{Source: "AI", Target: "人工智能", Required: true},
+			},
+		},
+		{
+			name:   "TSV跳过译文为空的行",
+			data:   "cloud\t云\nedge\t\n",
+			format: FormatTSV,
+			want: []Term{
+				{Source: "cloud", Target: "云", Required: true},
+			},
+		},
+		{
+			name:    "CSV缺少译文列",
+			data:    "AI,人工智能\nGPU\n",
+			format:  FormatCSV,
+			wantErr: true,
+		},
+		{
+			name: "TBX按目标语言选择首选术语，弃用术语不强制",
+			data: `<martif><text><body>
+<termEntry>
+  <langSet xml:lang="en"><tig><term>server</term></tig></langSet>
+  <langSet xml:lang="ja"><tig><term>サーバー</term></tig></langSet>
+  <langSet xml:lang="zh-CN">
+    <tig><term>伺服器</term><termNote type="administrativeStatus">admittedTerm-admn-sts</termNote></tig>
+    <tig><term>服务器</term><termNote type="administrativeStatus">preferredTerm-admn-sts</termNote></tig>
+  </langSet>
+</termEntry>
+<termEntry>
+  <langSet xml:lang="en"><ntig><termGrp><term>router</term></termGrp></ntig></langSet>
+  <langSet xml:lang="zh"><ntig><termGrp><term>路由器</term><termNote type="administrativeStatus">deprecatedTerm-admn-sts</termNote></termGrp></ntig></langSet>
+</termEntry>
+<termEntry>
+  <langSet xml:lang="en"><tig><term>only source</term></tig></langSet>
+</termEntry>
+</body></text></martif>`,
+			format:     FormatTBX,
+			targetLang: "zh",
+			want: []Term{
+				{Source: "server", Target: "服务器", Required: true},
+				{Source: "router", Target: "路由器", Required: false},
+			},
+		},
+		{
+			name:    "TBX格式无效",
+			data:    "<martif><text>",
+			format:  FormatTBX,
+			wantErr: true,
+		},
+		{
+			name:    "不支持的格式",
+			data:    "AI,人工智能",
+			format:  Format("xlsx"),
+			wantErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			gl, err := Parse([]byte(c.data), c.format, c.targetLang)
+			if c.wantErr {
+				if err == nil {
+					t.Fatalf("期望解析失败，实际得到 %d 条术语", len(gl.Terms))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("解析失败: %v", err)
+			}
+			got := make([]Term, len(gl.Terms))
+			for i, term := range gl.Terms {
+				got[i] = *term
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("术语 = %+v, 期望 %+v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestMatchAndCheck(t *testing.T) {
+	gl := &Glossary{Terms: []*Term{
+		{Source: "AI", Target: "人工智能", Required: true},
+		{Source: "cloud", Target: "云端", Required: true},
+		{Source: "GPU", Target: "显卡", Required: false},
+		{Source: "東京", Target: "东京", Required: true},
+	}}
+
+	cases := []struct {
+		name        string
+		source      string
+		translation string
+		matched     []string
+		broken      []string
+	}{
+		{"遵守强制术语", "AI runs in the cloud", "人工智能在云端运行", []string{"AI", "cloud"}, nil},
+		{"未使用强制术语", "AI runs in the cloud", "AI在云上运行", []string{"AI", "cloud"}, []string{"AI", "cloud"}},
+		{"大小写不敏感", "ai is here", "人工智能来了", []string{"AI"}, nil},
+		{"拉丁术语要求词边界", "He said the clouds are GPU-bound", "他说云很多", []string{"GPU"}, nil},
+		{"非强制术语不检查", "Buy a GPU", "买一块图形卡", []string{"GPU"}, nil},
+		{"中日韩术语按子串匹配", "東京タワー", "东京塔", []string{"東京"}, nil},
+		{"没有出现术语", "hello", "你好", nil, nil},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := sources(gl.Match(c.source)); !reflect.DeepEqual(got, c.matched) {
+				t.Errorf("Match(%q) = %v, 期望 %v", c.source, got, c.matched)
+			}
+			if got := sources(gl.Check(c.source, c.translation)); !reflect.DeepEqual(got, c.broken) {
+				t.Errorf("Check(%q, %q) = %v, 期望 %v", c.source, c.translation, got, c.broken)
+			}
+		})
+	}
+
+	var empty *Glossary
+	if terms := empty.Match("AI"); terms != nil {
+		t.Errorf("空术语表 Match = %v, 期望 nil", terms)
+	}
+}
+
+func TestPromptSection(t *testing.T) {
+	if got := PromptSection(nil); got != "" {
+		t.Errorf("没有术语时 = %q, 期望空", got)
+	}
+
+	got := PromptSection([]*Term{
+		{Source: "AI", Target: "人工智能", Required: true},
+		{Source: "GPU", Target: "显卡"},
+	})
+	want := "术语表（原文 => 译文，标*的必须使用指定译文）：\n*AI => 人工智能\nGPU => 显卡\n"
+	if got != want {
+		t.Errorf("PromptSection = %q, 期望 %q", got, want)
+	}
+}
+
+// sources 获取术语原文列表
+func sources(terms []*Term) []string {
+	var result []string
+	for _, term := range terms {
+		result = append(result, term.Source)
+	}
+	return result
+}
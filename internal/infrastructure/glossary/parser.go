@@ -0,0 +1,189 @@
+package glossary
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// parseDelimited 解析CSV/TSV术语表
+// 列顺序为：原文,译文[,是否强制]，首行为表头时自动跳过，未指定是否强制时默认强制
+func parseDelimited(data []byte, sep rune) ([]*Term, error) {
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	reader.Comma = sep
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	reader.TrimLeadingSpace = true
+
+	var terms []*Term
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("第%d行解析失败: %v", line, err)
+		}
+
+		if line == 1 && isHeader(record) {
+			continue
+		}
+		if len(record) < 2 {
+			if len(record) == 1 && strings.TrimSpace(record[0]) == "" {
+				continue
+			}
+			return nil, fmt.Errorf("第%d行缺少译文列", line)
+		}
+
+		source := strings.TrimSpace(record[0])
+		target := strings.TrimSpace(record[1])
+		if source == "" || target == "" {
+			continue
+		}
+
+		required := true
+		if len(record) > 2 && strings.TrimSpace(record[2]) != "" {
+			required = parseBool(record[2])
+		}
+
+		terms = append(terms, &Term{
+			Source:   source,
+			Target:   target,
+			Required: required,
+		})
+	}
+
+	return terms, nil
+}
+
+// isHeader 判断是否为表头行
+func isHeader(record []string) bool {
+	if len(record) == 0 {
+		return false
+	}
+	switch strings.ToLower(strings.TrimSpace(record[0])) {
+	case "source", "src", "term", "原文", "源语言", "术语":
+		return true
+	}
+	return false
+}
+
+// parseBool 解析是否强制列
+func parseBool(s string) bool {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "1", "true", "yes", "y", "required", "是", "强制":
+		return true
+	}
+	return false
+}
+
+// tbxDocument TBX文档结构
+type tbxDocument struct {
+	Entries []tbxEntry `xml:"text>body>termEntry"`
+}
+
+// tbxEntry TBX术语条目
+type tbxEntry struct {
+	LangSets []tbxLangSet `xml:"langSet"`
+}
+
+// tbxLangSet TBX语言集合
+type tbxLangSet struct {
+	Lang  string    `xml:"http://www.w3.org/XML/1998/namespace lang,attr"`
+	Terms []tbxTerm `xml:"tig"`
+	NTigs []tbxTerm `xml:"ntig>termGrp"`
+}
+
+// tbxTerm TBX术语
+type tbxTerm struct {
+	Term  string        `xml:"term"`
+	Notes []tbxTermNote `xml:"termNote"`
+}
+
+// tbxTermNote TBX术语备注
+type tbxTermNote struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+// parseTBX 解析TBX术语表，第一个语言集合为原文，与targetLang匹配的语言集合为译文
+func parseTBX(data []byte, targetLang string) ([]*Term, error) {
+	var doc tbxDocument
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	var terms []*Term
+	for _, entry := range doc.Entries {
+		if len(entry.LangSets) < 2 {
+			continue
+		}
+
+		source := firstTerm(entry.LangSets[0])
+		target, deprecated := "", false
+		for _, langSet := range entry.LangSets[1:] {
+			if targetLang == "" || matchLang(langSet.Lang, targetLang) {
+				target, deprecated = preferredTerm(langSet)
+				break
+			}
+		}
+		if source == "" || target == "" {
+			continue
+		}
+
+		terms = append(terms, &Term{
+			Source:   source,
+			Target:   target,
+			Required: !deprecated,
+		})
+	}
+
+	return terms, nil
+}
+
+// firstTerm 获取语言集合中的第一个术语
+func firstTerm(langSet tbxLangSet) string {
+	for _, t := range append(langSet.Terms, langSet.NTigs...) {
+		if term := strings.TrimSpace(t.Term); term != "" {
+			return term
+		}
+	}
+	return ""
+}
+
+// preferredTerm 获取语言集合中的首选术语，并返回其是否为已弃用术语
+func preferredTerm(langSet tbxLangSet) (string, bool) {
+	candidates := append(langSet.Terms, langSet.NTigs...)
+	for _, t := range candidates {
+		for _, note := range t.Notes {
+			if note.Type == "administrativeStatus" && strings.HasPrefix(note.Value, "preferredTerm") {
+				return strings.TrimSpace(t.Term), false
+			}
+		}
+	}
+	for _, t := range candidates {
+		term := strings.TrimSpace(t.Term)
+		if term == "" {
+			continue
+		}
+		deprecated := false
+		for _, note := range t.Notes {
+			if note.Type == "administrativeStatus" && strings.HasPrefix(note.Value, "deprecatedTerm") {
+				deprecated = true
+			}
+		}
+		return term, deprecated
+	}
+	return "", false
+}
+
+// matchLang 判断语言代码是否匹配，如 zh-CN 匹配 zh
+func matchLang(lang, target string) bool {
+	lang = strings.ToLower(strings.ReplaceAll(lang, "_", "-"))
+	target = strings.ToLower(strings.ReplaceAll(target, "_", "-"))
+	return lang == target || strings.HasPrefix(lang, target+"-") || strings.HasPrefix(target, lang+"-")
+}
@@ -2,12 +2,17 @@ package persistence
 
 import (
 	"ai-translate/internal/domain/task"
+	"context"
+	"encoding/json"
+	"errors"
 	"github.com/gogf/gf/v2/database/gdb"
+	"github.com/gogf/gf/v2/database/gredis"
 	"github.com/gogf/gf/v2/frame/g"
+	"time"
 )
 
 type taskRepository struct {
-	db gdb.DB
+	db modeler
 }
 
 // NewTaskRepository 创建任务仓储实例
@@ -44,9 +49,32 @@ func (r *taskRepository) FindByStatus(status int) ([]*task.Task, error) {
 	return tasks, nil
 }
 
+func (r *taskRepository) FindByWorkID(workID uint64) ([]*task.Task, error) {
+	var tasks []*task.Task
+	err := r.db.Model("tasks").Where("work_id", workID).OrderAsc("id").Scan(&tasks)
+	if err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+func (r *taskRepository) FindByReference(taskType int, referenceID uint64) ([]*task.Task, error) {
+	var tasks []*task.Task
+	err := r.db.Model("tasks").Where("type", taskType).Where("reference_id", referenceID).OrderAsc("id").Scan(&tasks)
+	if err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
 func (r *taskRepository) Save(task *task.Task) error {
-	_, err := r.db.Model("tasks").Insert(task)
-	return err
+	// 回填自增ID，依赖关系与队列消息都以ID引用任务
+	id, err := r.db.Model("tasks").InsertAndGetId(task)
+	if err != nil {
+		return err
+	}
+	task.ID = uint64(id)
+	return nil
 }
 
 func (r *taskRepository) Update(task *task.Task) error {
@@ -54,13 +82,129 @@ func (r *taskRepository) Update(task *task.Task) error {
 	return err
 }
 
+func (r *taskRepository) UpdateStatusFrom(id uint64, from, to int, errMsg string) (bool, error) {
+	result, err := r.db.Model("tasks").Where("id", id).Where("status", from).Data(g.Map{
+		"status":     to,
+		"error":      errMsg,
+		"updated_at": time.Now(),
+	}).Update()
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func (r *taskRepository) UpdatePartialResult(id uint64, partial string) error {
+	_, err := r.db.Model("tasks").Where("id", id).Data(g.Map{
+		"partial_result": partial,
+		"updated_at":     time.Now(),
+	}).Update()
+	return err
+}
+
 func (r *taskRepository) Delete(id uint64) error {
 	_, err := r.db.Model("tasks").Where("id", id).Delete()
 	return err
 }
 
+type taskDependencyRepository struct {
+	db modeler
+}
+
+// NewTaskDependencyRepository 创建任务依赖仓储实例
+func NewTaskDependencyRepository() task.TaskDependencyRepository {
+	return &taskDependencyRepository{
+		db: g.DB(),
+	}
+}
+
+func (r *taskDependencyRepository) FindByTaskID(taskID uint64) ([]*task.TaskDependency, error) {
+	var dependencies []*task.TaskDependency
+	err := r.db.Model("task_dependencies").Where("task_id", taskID).Scan(&dependencies)
+	if err != nil {
+		return nil, err
+	}
+	return dependencies, nil
+}
+
+func (r *taskDependencyRepository) FindByDependsOnID(dependsOnID uint64) ([]*task.TaskDependency, error) {
+	var dependencies []*task.TaskDependency
+	err := r.db.Model("task_dependencies").Where("depends_on_id", dependsOnID).Scan(&dependencies)
+	if err != nil {
+		return nil, err
+	}
+	return dependencies, nil
+}
+
+func (r *taskDependencyRepository) FindByWorkID(workID uint64) ([]*task.TaskDependency, error) {
+	var dependencies []*task.TaskDependency
+	err := r.db.Model("task_dependencies").Where("work_id", workID).OrderAsc("id").Scan(&dependencies)
+	if err != nil {
+		return nil, err
+	}
+	return dependencies, nil
+}
+
+func (r *taskDependencyRepository) Save(dependency *task.TaskDependency) error {
+	id, err := r.db.Model("task_dependencies").InsertAndGetId(dependency)
+	if err != nil {
+		return err
+	}
+	dependency.ID = uint64(id)
+	return nil
+}
+
+type outboxRepository struct {
+	db modeler
+}
+
+// NewOutboxRepository 创建发件箱仓储实例
+func NewOutboxRepository() task.OutboxRepository {
+	return &outboxRepository{
+		db: g.DB(),
+	}
+}
+
+func (r *outboxRepository) FindPending(limit int) ([]*task.OutboxMessage, error) {
+	var messages []*task.OutboxMessage
+	err := r.db.Model("task_outbox").Where("status", task.OutboxPending).OrderAsc("id").Limit(limit).Scan(&messages)
+	if err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+func (r *outboxRepository) Save(message *task.OutboxMessage) error {
+	id, err := r.db.Model("task_outbox").InsertAndGetId(message)
+	if err != nil {
+		return err
+	}
+	message.ID = uint64(id)
+	return nil
+}
+
+func (r *outboxRepository) UpdateStatus(id uint64, status int) error {
+	_, err := r.db.Model("task_outbox").Where("id", id).Data(g.Map{
+		"status":       status,
+		"published_at": time.Now(),
+	}).Update()
+	return err
+}
+
+func (r *outboxRepository) RecordFailure(id uint64, errMsg string) error {
+	_, err := r.db.Model("task_outbox").Where("id", id).Data(g.Map{
+		"attempts":   gdb.Raw("attempts + 1"),
+		"last_error": errMsg,
+	}).Update()
+	return err
+}
+
 type taskQueue struct {
-	redis *g.Redis
+	redis *gredis.Redis
 }
 
 // NewTaskQueue 创建任务队列实例
@@ -72,35 +216,69 @@ func NewTaskQueue() task.TaskQueue {
 
 func (q *taskQueue) Push(t *task.Task) error {
 	// 使用Redis的List数据结构实现队列
-	_, err := q.redis.LPush("task_queue", t)
+	payload, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	_, err = q.redis.LPush(context.Background(), "task_queue", string(payload))
 	return err
 }
 
+// pushUniqueScript 去重键不存在时写入去重键并入队，两步在Redis中原子执行
+const pushUniqueScript = `
+if redis.call('SET', KEYS[2], 1, 'NX', 'EX', ARGV[2]) then
+	redis.call('LPUSH', KEYS[1], ARGV[1])
+	return 1
+end
+return 0`
+
+func (q *taskQueue) PushUnique(key string, t *task.Task) (bool, error) {
+	// 中继重复投递同一消息时去重键已存在，任务不会重复入队
+	payload, err := json.Marshal(t)
+	if err != nil {
+		return false, err
+	}
+	ctx := context.Background()
+	ttl := g.Cfg().MustGet(ctx, "outbox.dedupTTL", 86400).Int()
+	result, err := q.redis.Do(ctx, "EVAL", pushUniqueScript, 2, "task_queue", "task_queue:dedup:"+key, string(payload), ttl)
+	if err != nil {
+		return false, err
+	}
+	return result.Int() == 1, nil
+}
+
 func (q *taskQueue) Pop() (*task.Task, error) {
 	// 从队列右侧弹出任务
-	var t task.Task
-	err := q.redis.RPop("task_queue").Scan(&t)
+	result, err := q.redis.RPop(context.Background(), "task_queue")
 	if err != nil {
 		return nil, err
 	}
+	if result.IsNil() {
+		return nil, errors.New("任务队列为空")
+	}
+	var t task.Task
+	if err := json.Unmarshal(result.Bytes(), &t); err != nil {
+		return nil, err
+	}
 	return &t, nil
 }
 
 func (q *taskQueue) Remove(id uint64) error {
 	// 从队列中移除指定ID的任务
 	// 这里需要遍历队列找到对应ID的任务并删除
-	tasks, err := q.redis.LRange("task_queue", 0, -1).Result()
+	ctx := context.Background()
+	tasks, err := q.redis.LRange(ctx, "task_queue", 0, -1)
 	if err != nil {
 		return err
 	}
-	
-	for i, taskStr := range tasks {
+
+	for _, item := range tasks {
 		var t task.Task
-		if err := g.Json().DecodeTo([]byte(taskStr), &t); err != nil {
+		if err := json.Unmarshal(item.Bytes(), &t); err != nil {
 			continue
 		}
 		if t.ID == id {
-			_, err := q.redis.LRem("task_queue", i, taskStr)
+			_, err := q.redis.LRem(ctx, "task_queue", 0, item.String())
 			return err
 		}
 	}
@@ -108,9 +286,10 @@ func (q *taskQueue) Remove(id uint64) error {
 }
 
 func (q *taskQueue) GetLength() (int64, error) {
-	return q.redis.LLen("task_queue").Result()
+	return q.redis.LLen(context.Background(), "task_queue")
 }
 
 func (q *taskQueue) Clear() error {
-	return q.redis.Del("task_queue").Err()
-} 
\ No newline at end of file
+	_, err := q.redis.Del(context.Background(), "task_queue")
+	return err
+}
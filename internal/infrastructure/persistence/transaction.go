@@ -0,0 +1,51 @@
+package persistence
+
+import (
+	"ai-translate/internal/domain/task"
+	"ai-translate/internal/domain/work"
+	"context"
+	"github.com/gogf/gf/v2/database/gdb"
+	"github.com/gogf/gf/v2/frame/g"
+)
+
+// modeler 仓储使用的数据库访问接口，gdb.DB与gdb.TX均满足，仓储可绑定到事务
+type modeler interface {
+	Model(tableNameQueryOrStruct ...interface{}) *gdb.Model
+}
+
+// Tx 数据库事务，通过它获取的仓储共享同一事务
+type Tx struct {
+	tx gdb.TX
+}
+
+// Transaction 在一个数据库事务中执行fn，fn返回错误或panic时回滚
+func Transaction(fn func(tx *Tx) error) error {
+	return g.DB().Transaction(context.Background(), func(ctx context.Context, tx gdb.TX) error {
+		return fn(&Tx{tx: tx})
+	})
+}
+
+// Works 获取绑定到事务的作品仓储
+func (t *Tx) Works() work.WorkRepository {
+	return &workRepository{db: t.tx}
+}
+
+// TranslationBatches 获取绑定到事务的翻译批次仓储
+func (t *Tx) TranslationBatches() work.TranslationBatchRepository {
+	return &translationBatchRepository{db: t.tx}
+}
+
+// Tasks 获取绑定到事务的任务仓储
+func (t *Tx) Tasks() task.TaskRepository {
+	return &taskRepository{db: t.tx}
+}
+
+// TaskDependencies 获取绑定到事务的任务依赖仓储
+func (t *Tx) TaskDependencies() task.TaskDependencyRepository {
+	return &taskDependencyRepository{db: t.tx}
+}
+
+// Outbox 获取绑定到事务的发件箱仓储
+func (t *Tx) Outbox() task.OutboxRepository {
+	return &outboxRepository{db: t.tx}
+}
@@ -1,7 +1,6 @@
 package persistence
 
 import (
-	"context"
 	"ai-translate/internal/domain/user"
 	"github.com/gogf/gf/v2/database/gdb"
 	"github.com/gogf/gf/v2/frame/g"
@@ -7,7 +7,7 @@ import (
 )
 
 type workRepository struct {
-	db gdb.DB
+	db modeler
 }
 
 // NewWorkRepository 创建作品仓储实例
@@ -36,8 +36,12 @@ func (r *workRepository) FindByUserID(userID uint64) ([]*work.Work, error) {
 }
 
 func (r *workRepository) Save(work *work.Work) error {
-	_, err := r.db.Model("works").Insert(work)
-	return err
+	id, err := r.db.Model("works").InsertAndGetId(work)
+	if err != nil {
+		return err
+	}
+	work.ID = uint64(id)
+	return nil
 }
 
 func (r *workRepository) Update(work *work.Work) error {
@@ -81,7 +85,7 @@ func (r *contentSummaryRepository) Update(summary *work.ContentSummary) error {
 }
 
 type translationBatchRepository struct {
-	db gdb.DB
+	db modeler
 }
 
 // NewTranslationBatchRepository 创建翻译批次仓储实例
@@ -110,8 +114,12 @@ func (r *translationBatchRepository) FindByWorkID(workID uint64) ([]*work.Transl
 }
 
 func (r *translationBatchRepository) Save(batch *work.TranslationBatch) error {
-	_, err := r.db.Model("translation_batches").Insert(batch)
-	return err
+	id, err := r.db.Model("translation_batches").InsertAndGetId(batch)
+	if err != nil {
+		return err
+	}
+	batch.ID = uint64(id)
+	return nil
 }
 
 func (r *translationBatchRepository) Update(batch *work.TranslationBatch) error {
@@ -139,8 +147,12 @@ func (r *translationResultRepository) FindByBatchID(batchID uint64) (*work.Trans
 	return &result, nil
 }
 
+// Save 按task_id插入或覆盖翻译结果，同一任务重试或重复投递时只保留最新一次的结果
 func (r *translationResultRepository) Save(result *work.TranslationResult) error {
-	_, err := r.db.Model("translation_results").Insert(result)
+	_, err := r.db.Model("translation_results").
+		Data(result).
+		OnDuplicate("srt_url", "status", "violations", "created_at").
+		Save()
 	return err
 }
 
@@ -1,6 +1,8 @@
 package processor
 
 import (
+	"ai-translate/internal/infrastructure/ai"
+	"ai-translate/internal/infrastructure/queue"
 	"context"
 	"fmt"
 	"github.com/gogf/gf/v2/frame/g"
@@ -10,23 +12,36 @@ import (
 
 // TaskProcessor 任务处理器
 type TaskProcessor struct {
-	queue       Queue
+	queue       queue.Queue
 	workers     int
+	typeWorkers map[string]int
 	maxRetries  int
+	retryPolicy ai.RetryPolicy
 	wg          sync.WaitGroup
 	stopChan    chan struct{}
 	taskHandlers map[string]TaskHandler
+	onFailure   FailureHandler
+	filter      MessageFilter
 }
 
 // TaskHandler 任务处理函数类型
 type TaskHandler func(ctx context.Context, taskID string, data []byte) error
 
+// FailureHandler 任务失败回调，retryAt非零表示任务将在该时间重试，否则为最终失败
+type FailureHandler func(ctx context.Context, taskID string, err error, retryAt time.Time)
+
+// MessageFilter 消息过滤函数，返回false时确认并丢弃消息，不调用任务处理函数
+// 用于丢弃已被新消息取代的过期消息，如任务调整优先级后重新投递，原消息即过期
+type MessageFilter func(ctx context.Context, msg *queue.Message) bool
+
 // NewTaskProcessor 创建任务处理器
-func NewTaskProcessor(queue Queue, workers, maxRetries int) *TaskProcessor {
+func NewTaskProcessor(q queue.Queue, workers, maxRetries int) *TaskProcessor {
 	return &TaskProcessor{
-		queue:       queue,
+		queue:       q,
 		workers:     workers,
+		typeWorkers: make(map[string]int),
 		maxRetries:  maxRetries,
+		retryPolicy: ai.NewRetryPolicy(context.Background()),
 		stopChan:    make(chan struct{}),
 		taskHandlers: make(map[string]TaskHandler),
 	}
@@ -37,12 +52,33 @@ func (p *TaskProcessor) RegisterHandler(taskType string, handler TaskHandler) {
 	p.taskHandlers[taskType] = handler
 }
 
+// SetWorkers 设置指定任务类型的工作协程数，未设置时使用默认工作协程数
+func (p *TaskProcessor) SetWorkers(taskType string, workers int) {
+	p.typeWorkers[taskType] = workers
+}
+
+// SetFilter 设置消息过滤函数
+func (p *TaskProcessor) SetFilter(filter MessageFilter) {
+	p.filter = filter
+}
+
+// OnFailure 设置任务失败回调
+func (p *TaskProcessor) OnFailure(handler FailureHandler) {
+	p.onFailure = handler
+}
+
 // Start 启动任务处理器
 func (p *TaskProcessor) Start(ctx context.Context) error {
-	// 启动工作协程
-	for i := 0; i < p.workers; i++ {
-		p.wg.Add(1)
-		go p.worker(ctx, i)
+	// 按任务类型启动工作协程，各自消费对应队列
+	for taskType := range p.taskHandlers {
+		workers, ok := p.typeWorkers[taskType]
+		if !ok {
+			workers = p.workers
+		}
+		for i := 0; i < workers; i++ {
+			p.wg.Add(1)
+			go p.worker(ctx, taskType, i)
+		}
 	}
 
 	// 等待停止信号
@@ -60,20 +96,20 @@ func (p *TaskProcessor) Stop() {
 }
 
 // worker 工作协程
-func (p *TaskProcessor) worker(ctx context.Context, id int) {
+func (p *TaskProcessor) worker(ctx context.Context, taskType string, id int) {
 	defer p.wg.Done()
 
-	g.Log().Infof(ctx, "Worker %d started", id)
+	g.Log().Infof(ctx, "Worker %s-%d started", taskType, id)
 
 	for {
 		select {
 		case <-ctx.Done():
-			g.Log().Infof(ctx, "Worker %d stopped", id)
+			g.Log().Infof(ctx, "Worker %s-%d stopped", taskType, id)
 			return
 		default:
 			// 处理任务
-			if err := p.processTask(ctx); err != nil {
-				g.Log().Errorf(ctx, "Worker %d error: %v", id, err)
+			if err := p.processTask(ctx, taskType); err != nil {
+				g.Log().Errorf(ctx, "Worker %s-%d error: %v", taskType, id, err)
 				time.Sleep(time.Second) // 错误后等待一秒
 			}
 		}
@@ -81,33 +117,73 @@ func (p *TaskProcessor) worker(ctx context.Context, id int) {
 }
 
 // processTask 处理单个任务
-func (p *TaskProcessor) processTask(ctx context.Context) error {
-	// 从队列获取任务
-	msg, err := p.queue.Consume(ctx)
+// 消息在处理完成后才确认：成功确认；可重试失败投递到延迟队列后确认；最终失败转入死信队列
+func (p *TaskProcessor) processTask(ctx context.Context, taskType string) error {
+	// 从任务类型对应的队列获取任务
+	d, err := p.queue.Consume(ctx, taskType)
 	if err != nil {
 		return fmt.Errorf("consume task failed: %v", err)
 	}
 
-	// 解析任务类型
-	taskType := msg.Type
-	handler, ok := p.taskHandlers[taskType]
+	// 按消息中的任务类型获取处理函数
+	handler, ok := p.taskHandlers[d.Type]
 	if !ok {
-		return fmt.Errorf("unknown task type: %s", taskType)
+		err := fmt.Errorf("unknown task type: %s", d.Type)
+		if dlErr := p.queue.DeadLetter(ctx, d, err.Error()); dlErr != nil {
+			return fmt.Errorf("dead letter task failed: %v", dlErr)
+		}
+		return err
+	}
+
+	// 丢弃过期消息
+	if p.filter != nil && !p.filter(ctx, d.Message) {
+		if err := d.Ack(); err != nil {
+			return fmt.Errorf("ack task failed: %v", err)
+		}
+		return nil
 	}
 
 	// 处理任务
-	err = handler(ctx, msg.ID, msg.Data)
-	if err != nil {
-		// 处理失败，重试
-		if msg.Retries < p.maxRetries {
-			msg.Retries++
-			// 重新入队
-			if err := p.queue.Publish(ctx, msg); err != nil {
-				return fmt.Errorf("retry task failed: %v", err)
-			}
+	err = handler(ctx, d.ID, d.Data)
+	if err == nil {
+		if err := d.Ack(); err != nil {
+			return fmt.Errorf("ack task failed: %v", err)
+		}
+		return nil
+	}
+
+	// 不可重试错误或重试次数用尽，任务直接失败并转入死信队列
+	if !ai.ShouldRetry(err, d.Retries, p.maxRetries) {
+		p.fail(ctx, d.ID, err, time.Time{})
+		if dlErr := p.queue.DeadLetter(ctx, d, err.Error()); dlErr != nil {
+			return fmt.Errorf("dead letter task failed: %v", dlErr)
 		}
 		return fmt.Errorf("process task failed: %v", err)
 	}
 
-	return nil
-} 
\ No newline at end of file
+	// 按退避策略投递到延迟队列，到期后重新消费
+	delay := p.retryPolicy.Delay(d.Retries, err)
+	p.fail(ctx, d.ID, err, time.Now().Add(delay))
+	retry := *d.Message
+	retry.Retries++
+	if err := p.queue.PublishDelayed(ctx, &retry, delay); err != nil {
+		// 延迟投递失败时转入死信队列，不放回任务队列，避免消息被立即重新消费而反复失败
+		// 任务按最终失败处理，可从死信队列重新投递
+		p.fail(ctx, d.ID, err, time.Time{})
+		if dlErr := p.queue.DeadLetter(ctx, d, fmt.Sprintf("延迟重试投递失败: %v", err)); dlErr != nil {
+			return fmt.Errorf("dead letter task failed: %v", dlErr)
+		}
+		return fmt.Errorf("retry task failed: %v", err)
+	}
+	if err := d.Ack(); err != nil {
+		return fmt.Errorf("ack task failed: %v", err)
+	}
+	return fmt.Errorf("process task failed, retry %d after %s: %v", retry.Retries, delay, err)
+}
+
+// fail 调用任务失败回调
+func (p *TaskProcessor) fail(ctx context.Context, taskID string, err error, retryAt time.Time) {
+	if p.onFailure != nil {
+		p.onFailure(ctx, taskID, err, retryAt)
+	}
+}
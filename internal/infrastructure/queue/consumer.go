@@ -0,0 +1,151 @@
+package queue
+
+import (
+	"context"
+	"github.com/gogf/gf/v2/frame/g"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"sync"
+	"time"
+)
+
+// reconnectBackoff 重连退避策略，等待时间按次数指数增长，不超过上限
+type reconnectBackoff struct {
+	BaseDelay time.Duration // 首次重连等待时间
+	MaxDelay  time.Duration // 单次等待时间上限
+}
+
+// newReconnectBackoff 从配置文件 rabbitmq.reconnect 读取重连退避策略
+func newReconnectBackoff(ctx context.Context) reconnectBackoff {
+	return reconnectBackoff{
+		BaseDelay: time.Duration(g.Cfg().MustGet(ctx, "rabbitmq.reconnect.baseDelay", 1).Int()) * time.Second,
+		MaxDelay:  time.Duration(g.Cfg().MustGet(ctx, "rabbitmq.reconnect.maxDelay", 30).Int()) * time.Second,
+	}
+}
+
+// delay 计算第attempt次重连前的等待时间（attempt从0开始）
+func (b reconnectBackoff) delay(attempt int) time.Duration {
+	delay := b.BaseDelay
+	for i := 0; i < attempt && delay < b.MaxDelay; i++ {
+		delay *= 2
+	}
+	if b.MaxDelay > 0 && delay > b.MaxDelay {
+		delay = b.MaxDelay
+	}
+	return delay
+}
+
+// consumer 单个任务队列的长期消费者
+// 在独立通道上按预取数量注册一次消费，通道或连接关闭后按退避策略重新订阅
+type consumer struct {
+	mq         *RabbitMQ
+	config     ConsumerConfig
+	once       sync.Once
+	deliveries chan amqp.Delivery
+}
+
+// newConsumer 创建任务队列消费者
+func newConsumer(mq *RabbitMQ, config ConsumerConfig) *consumer {
+	return &consumer{
+		mq:         mq,
+		config:     config,
+		deliveries: make(chan amqp.Delivery),
+	}
+}
+
+// start 启动消费者，重复调用无副作用
+func (c *consumer) start() {
+	c.once.Do(func() {
+		go c.run()
+	})
+}
+
+// run 订阅队列并转发消息，直到客户端关闭
+func (c *consumer) run() {
+	ctx := context.Background()
+
+	for attempt := 0; ; {
+		channel, deliveries, err := c.subscribe()
+		if err != nil {
+			delay := c.mq.backoff.delay(attempt)
+			attempt++
+			g.Log().Warningf(ctx, "订阅队列失败: %s, %v, %s后重试", c.config.Queue, err, delay)
+			if !c.sleep(delay) {
+				return
+			}
+			continue
+		}
+
+		attempt = 0
+		g.Log().Infof(ctx, "开始消费队列: %s, 预取数量: %d", c.config.Queue, c.config.Prefetch)
+		closed := c.forward(deliveries)
+		channel.Close()
+		if !closed {
+			return
+		}
+		g.Log().Warningf(ctx, "队列消费通道已关闭，准备重新订阅: %s", c.config.Queue)
+	}
+}
+
+// subscribe 创建消费通道并注册消费者
+func (c *consumer) subscribe() (*amqp.Channel, <-chan amqp.Delivery, error) {
+	channel, err := c.mq.openChannel()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// 设置预取数量
+	if err := channel.Qos(c.config.Prefetch, 0, false); err != nil {
+		channel.Close()
+		return nil, nil, err
+	}
+
+	// 开始消费
+	deliveries, err := channel.Consume(
+		taskQueueName(c.config.Queue), // 队列名称
+		"",                            // 消费者标签
+		false,                         // 自动确认
+		false,                         // 独占
+		false,                         // 不等待
+		false,                         // 不阻塞
+		nil,                           // 参数
+	)
+	if err != nil {
+		channel.Close()
+		return nil, nil, err
+	}
+
+	return channel, deliveries, nil
+}
+
+// forward 将投递的消息转发给工作协程，投递通道关闭时返回true，客户端关闭时返回false
+// 通道关闭后尚未确认的消息由RabbitMQ重新投递
+func (c *consumer) forward(deliveries <-chan amqp.Delivery) bool {
+	for {
+		select {
+		case d, ok := <-deliveries:
+			if !ok {
+				return true
+			}
+			select {
+			case c.deliveries <- d:
+			case <-c.mq.done:
+				return false
+			}
+		case <-c.mq.done:
+			return false
+		}
+	}
+}
+
+// sleep 等待重连，客户端关闭时返回false
+func (c *consumer) sleep(delay time.Duration) bool {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-c.mq.done:
+		return false
+	}
+}
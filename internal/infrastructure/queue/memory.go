@@ -0,0 +1,331 @@
+package queue
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// MemoryQueue 进程内队列，用于测试与单机部署
+// 每种任务类型一个按优先级排序的就绪队列和一个按可消费时间排序的延迟队列，延迟消息到期后移入就绪队列；进程退出后消息丢失
+type MemoryQueue struct {
+	mu     sync.Mutex
+	topics map[string]*memoryTopic
+	seq    uint64
+	done   chan struct{}
+	closed bool
+}
+
+// memoryTopic 单个任务类型的待消费消息与死信消息
+type memoryTopic struct {
+	ready   memoryHeap
+	delayed memoryHeap
+	dead    []*Message
+	notify  chan struct{}
+}
+
+// memoryItem 待消费消息
+type memoryItem struct {
+	msg     *Message
+	readyAt time.Time // 可消费时间
+	seq     uint64    // 入队序号，可消费时间相同时先入先出
+}
+
+// memoryHeap 待消费消息堆，排序规则由less决定
+type memoryHeap struct {
+	items []*memoryItem
+	less  func(a, b *memoryItem) bool
+}
+
+// readyBefore 就绪队列排序：优先级高的在前，同优先级先入先出
+func readyBefore(a, b *memoryItem) bool {
+	if a.msg.Priority != b.msg.Priority {
+		return a.msg.Priority > b.msg.Priority
+	}
+	return a.seq < b.seq
+}
+
+// delayedBefore 延迟队列排序：先到期的在前
+func delayedBefore(a, b *memoryItem) bool {
+	if !a.readyAt.Equal(b.readyAt) {
+		return a.readyAt.Before(b.readyAt)
+	}
+	return a.seq < b.seq
+}
+
+func (h *memoryHeap) Len() int { return len(h.items) }
+
+func (h *memoryHeap) Less(i, j int) bool { return h.less(h.items[i], h.items[j]) }
+
+func (h *memoryHeap) Swap(i, j int) { h.items[i], h.items[j] = h.items[j], h.items[i] }
+
+func (h *memoryHeap) Push(x interface{}) { h.items = append(h.items, x.(*memoryItem)) }
+
+func (h *memoryHeap) Pop() interface{} {
+	old := h.items
+	item := old[len(old)-1]
+	old[len(old)-1] = nil
+	h.items = old[:len(old)-1]
+	return item
+}
+
+// peek 获取堆顶元素
+func (h *memoryHeap) peek() *memoryItem {
+	return h.items[0]
+}
+
+// NewMemoryQueue 创建进程内队列
+func NewMemoryQueue() *MemoryQueue {
+	return &MemoryQueue{
+		topics: make(map[string]*memoryTopic),
+		done:   make(chan struct{}),
+	}
+}
+
+// topic 获取任务类型对应的队列，调用方需持有q.mu
+func (q *MemoryQueue) topic(taskType string) *memoryTopic {
+	t, ok := q.topics[taskType]
+	if !ok {
+		t = &memoryTopic{
+			ready:   memoryHeap{less: readyBefore},
+			delayed: memoryHeap{less: delayedBefore},
+			notify:  make(chan struct{}, 1),
+		}
+		q.topics[taskType] = t
+	}
+	return t
+}
+
+// push 将消息加入队列并唤醒等待的消费者
+func (q *MemoryQueue) push(msg *Message, readyAt time.Time) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return fmt.Errorf("内存队列已关闭")
+	}
+
+	// 复制消息，避免发布方后续修改影响队列中的消息
+	copied := *msg
+	copied.Priority = clampPriority(copied.Priority)
+	q.seq++
+	t := q.topic(msg.Type)
+	item := &memoryItem{msg: &copied, readyAt: readyAt, seq: q.seq}
+	if readyAt.After(time.Now()) {
+		heap.Push(&t.delayed, item)
+	} else {
+		heap.Push(&t.ready, item)
+	}
+	t.wake()
+	return nil
+}
+
+// promote 将到期的延迟消息移入就绪队列，返回下一条延迟消息的剩余等待时间，没有延迟消息时返回-1
+func (t *memoryTopic) promote(now time.Time) time.Duration {
+	for t.delayed.Len() > 0 {
+		item := t.delayed.peek()
+		if item.readyAt.After(now) {
+			return item.readyAt.Sub(now)
+		}
+		heap.Push(&t.ready, heap.Pop(&t.delayed))
+	}
+	return -1
+}
+
+// wake 唤醒一个等待的消费者
+func (t *memoryTopic) wake() {
+	select {
+	case t.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Publish 发布消息
+func (q *MemoryQueue) Publish(ctx context.Context, msg *Message) error {
+	return q.push(msg, time.Now())
+}
+
+// PublishDelayed 延迟发布消息
+func (q *MemoryQueue) PublishDelayed(ctx context.Context, msg *Message, delay time.Duration) error {
+	return q.push(msg, time.Now().Add(delay))
+}
+
+// Consume 消费指定任务类型的消息，没有可消费消息时阻塞等待
+func (q *MemoryQueue) Consume(ctx context.Context, taskType string) (*Delivery, error) {
+	for {
+		q.mu.Lock()
+		if q.closed {
+			q.mu.Unlock()
+			return nil, fmt.Errorf("内存队列已关闭")
+		}
+		t := q.topic(taskType)
+
+		// 取出优先级最高的就绪消息，仍有剩余消息时继续唤醒其他消费者
+		next := t.promote(time.Now())
+		if t.ready.Len() > 0 {
+			item := heap.Pop(&t.ready).(*memoryItem)
+			if t.ready.Len() > 0 {
+				t.wake()
+			}
+			q.mu.Unlock()
+			return q.delivery(item.msg), nil
+		}
+
+		// 没有就绪消息时等待新消息或最近一条延迟消息到期
+		var timer *time.Timer
+		var wait <-chan time.Time
+		if next >= 0 {
+			timer = time.NewTimer(next)
+			wait = timer.C
+		}
+		notify := t.notify
+		q.mu.Unlock()
+
+		var err error
+		select {
+		case <-notify:
+		case <-wait:
+		case <-q.done:
+			err = fmt.Errorf("内存队列已关闭")
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// delivery 创建待确认消息，确认或拒绝只生效一次
+func (q *MemoryQueue) delivery(msg *Message) *Delivery {
+	var once sync.Once
+	return &Delivery{
+		Message: msg,
+		ack: func() error {
+			once.Do(func() {})
+			return nil
+		},
+		nack: func(requeue bool) error {
+			var err error
+			once.Do(func() {
+				if requeue {
+					err = q.push(msg, time.Now())
+					return
+				}
+				dead := *msg
+				dead.Error = "消息被拒绝"
+				dead.FailedAt = time.Now()
+				q.bury(&dead)
+			})
+			return err
+		},
+	}
+}
+
+// bury 将消息加入死信队列
+func (q *MemoryQueue) bury(msg *Message) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	t := q.topic(msg.Type)
+	t.dead = append(t.dead, msg)
+}
+
+// DeadLetter 记录失败原因后将消息转入死信队列，并确认原消息
+func (q *MemoryQueue) DeadLetter(ctx context.Context, delivery *Delivery, reason string) error {
+	msg := *delivery.Message
+	msg.Error = reason
+	msg.FailedAt = time.Now()
+	q.bury(&msg)
+	return delivery.Ack()
+}
+
+// Close 关闭队列，阻塞中的消费者立即返回
+func (q *MemoryQueue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if !q.closed {
+		q.closed = true
+		close(q.done)
+	}
+	return nil
+}
+
+// ListDeadLetters 列出死信消息
+func (q *MemoryQueue) ListDeadLetters(ctx context.Context, taskType string, limit int) ([]*Message, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var messages []*Message
+	for _, msg := range q.topic(taskType).dead {
+		if limit > 0 && len(messages) >= limit {
+			break
+		}
+		copied := *msg
+		messages = append(messages, &copied)
+	}
+	return messages, nil
+}
+
+// GetDeadLetter 获取指定ID的死信消息
+func (q *MemoryQueue) GetDeadLetter(ctx context.Context, taskType string, id string) (*Message, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, msg := range q.topic(taskType).dead {
+		if msg.ID == id {
+			copied := *msg
+			return &copied, nil
+		}
+	}
+	return nil, ErrDeadLetterNotFound
+}
+
+// RequeueDeadLetters 将死信消息重置重试次数后重新投递
+func (q *MemoryQueue) RequeueDeadLetters(ctx context.Context, taskType string, id string) ([]*Message, error) {
+	removed := q.removeDeadLetters(taskType, id)
+	if id != "" && len(removed) == 0 {
+		return nil, ErrDeadLetterNotFound
+	}
+
+	for i, msg := range removed {
+		msg.Retries = 0
+		msg.Error = ""
+		msg.FailedAt = time.Time{}
+		if err := q.Publish(ctx, msg); err != nil {
+			return removed[:i], err
+		}
+	}
+	return removed, nil
+}
+
+// PurgeDeadLetters 删除死信消息，id为空时清空死信队列
+func (q *MemoryQueue) PurgeDeadLetters(ctx context.Context, taskType string, id string) (int, error) {
+	removed := q.removeDeadLetters(taskType, id)
+	if id != "" && len(removed) == 0 {
+		return 0, ErrDeadLetterNotFound
+	}
+	return len(removed), nil
+}
+
+// removeDeadLetters 移除死信消息，id为空时移除全部
+func (q *MemoryQueue) removeDeadLetters(taskType string, id string) []*Message {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	t := q.topic(taskType)
+	var removed, kept []*Message
+	for _, msg := range t.dead {
+		if id == "" || msg.ID == id {
+			removed = append(removed, msg)
+		} else {
+			kept = append(kept, msg)
+		}
+	}
+	t.dead = kept
+	return removed
+}
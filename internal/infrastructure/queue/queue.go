@@ -1,13 +1,93 @@
 package queue
 
-import "context"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gogf/gf/v2/frame/g"
+	"sort"
+	"time"
+)
+
+// ErrDeadLetterNotFound 死信消息不存在
+var ErrDeadLetterNotFound = errors.New("死信消息不存在")
+
+// ConsumerConfig 任务队列消费配置
+type ConsumerConfig struct {
+	TaskType string // 任务类型，同时作为路由键
+	Queue    string // 队列名称
+	Workers  int    // 工作协程数
+	Prefetch int    // 预取数量，即未确认消息上限
+}
+
+// LoadConsumerConfigs 从配置文件 rabbitmq.queues 读取各任务队列的消费配置
+// 未配置工作协程数时使用defaultWorkers，未配置预取数量时与工作协程数相同
+func LoadConsumerConfigs(ctx context.Context, defaultWorkers int) []ConsumerConfig {
+	var configs []ConsumerConfig
+	for taskType := range g.Cfg().MustGet(ctx, "rabbitmq.queues").Map() {
+		prefix := "rabbitmq.queues." + taskType
+		config := ConsumerConfig{
+			TaskType: taskType,
+			Queue:    g.Cfg().MustGet(ctx, prefix+".name", taskType).String(),
+			Workers:  g.Cfg().MustGet(ctx, prefix+".workers", defaultWorkers).Int(),
+			Prefetch: g.Cfg().MustGet(ctx, prefix+".prefetch").Int(),
+		}
+		if config.Workers <= 0 {
+			config.Workers = 1
+		}
+		if config.Prefetch <= 0 {
+			config.Prefetch = config.Workers
+		}
+		configs = append(configs, config)
+	}
+	sort.Slice(configs, func(i, j int) bool {
+		return configs[i].TaskType < configs[j].TaskType
+	})
+	return configs
+}
+
+// MaxPriority 消息优先级上限，与任务优先级 model.TaskPriorityUrgent 一致
+const MaxPriority = 3
+
+// clampPriority 将优先级限制在 [0, MaxPriority]
+func clampPriority(priority int) int {
+	if priority < 0 {
+		return 0
+	}
+	if priority > MaxPriority {
+		return MaxPriority
+	}
+	return priority
+}
 
 // Message 消息结构
 type Message struct {
-	ID      string          // 消息ID
-	Type    string          // 消息类型
-	Data    []byte          // 消息数据
-	Retries int             // 重试次数
+	ID       string    // 消息ID
+	Type     string    // 消息类型
+	Data     []byte    // 消息数据
+	Priority int       // 优先级，数值越大越先消费
+	Seq      int64     // 任务投递序号，与任务当前序号不一致的消息已被后续投递取代
+	Retries  int       // 重试次数
+	Error    string    // 最近一次失败原因，转入死信队列时记录
+	FailedAt time.Time // 转入死信队列的时间
+}
+
+// Delivery 已投递、待确认的消息
+// 处理完成后必须调用Ack、Nack或Queue.DeadLetter之一，否则消息不会从队列移除
+type Delivery struct {
+	*Message
+	ack  func() error
+	nack func(requeue bool) error
+}
+
+// Ack 确认消息已处理
+func (d *Delivery) Ack() error {
+	return d.ack()
+}
+
+// Nack 拒绝消息，requeue为true时放回队列，否则转入死信队列
+func (d *Delivery) Nack(requeue bool) error {
+	return d.nack(requeue)
 }
 
 // Queue 队列接口
@@ -15,9 +95,57 @@ type Queue interface {
 	// Publish 发布消息
 	Publish(ctx context.Context, msg *Message) error
 
-	// Consume 消费消息
-	Consume(ctx context.Context) (*Message, error)
+	// PublishDelayed 延迟发布消息，用于失败重试的退避等待
+	PublishDelayed(ctx context.Context, msg *Message, delay time.Duration) error
+
+	// Consume 从指定任务类型的队列消费消息，处理完成后需确认
+	Consume(ctx context.Context, taskType string) (*Delivery, error)
+
+	// DeadLetter 记录失败原因后将消息转入死信队列，并确认原消息
+	DeadLetter(ctx context.Context, delivery *Delivery, reason string) error
 
 	// Close 关闭连接
 	Close() error
-} 
\ No newline at end of file
+}
+
+// DeadLetterQueue 死信队列管理接口
+type DeadLetterQueue interface {
+	// ListDeadLetters 列出死信消息，不会移除消息
+	ListDeadLetters(ctx context.Context, taskType string, limit int) ([]*Message, error)
+
+	// GetDeadLetter 获取指定ID的死信消息
+	GetDeadLetter(ctx context.Context, taskType string, id string) (*Message, error)
+
+	// RequeueDeadLetters 将死信消息重置重试次数后重新投递，id为空时重新投递全部
+	RequeueDeadLetters(ctx context.Context, taskType string, id string) ([]*Message, error)
+
+	// PurgeDeadLetters 删除死信消息，id为空时清空死信队列，返回删除数量
+	PurgeDeadLetters(ctx context.Context, taskType string, id string) (int, error)
+}
+
+// Backend 队列后端，同时支持死信管理
+type Backend interface {
+	Queue
+	DeadLetterQueue
+}
+
+// 队列后端类型
+const (
+	BackendRabbitMQ = "rabbitmq" // RabbitMQ，默认
+	BackendRedis    = "redis"    // Redis Streams
+	BackendMemory   = "memory"   // 进程内队列，仅用于测试与单机部署
+)
+
+// New 按配置文件 queue.backend 创建队列后端
+func New(ctx context.Context, consumers []ConsumerConfig) (Backend, error) {
+	backend := g.Cfg().MustGet(ctx, "queue.backend", BackendRabbitMQ).String()
+	switch backend {
+	case BackendRabbitMQ:
+		return NewRabbitMQ(consumers)
+	case BackendRedis:
+		return NewRedisQueue(ctx, consumers)
+	case BackendMemory:
+		return NewMemoryQueue(), nil
+	}
+	return nil, fmt.Errorf("不支持的队列后端: %s", backend)
+}
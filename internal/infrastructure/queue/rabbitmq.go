@@ -3,103 +3,350 @@ package queue
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/gogf/gf/v2/frame/g"
 	amqp "github.com/rabbitmq/amqp091-go"
+	"sync"
 	"time"
 )
 
+const (
+	// taskExchange 任务交换机，按任务类型路由到任务队列
+	taskExchange = "task_exchange"
+	// deadLetterExchange 死信交换机，按任务类型路由到死信队列
+	deadLetterExchange = "task_dlx"
+)
+
+// delayLevels 延迟队列的等待档位
+// 同一队列内消息按入队顺序过期，为避免长延迟阻塞短延迟，按档位拆分队列，延迟时间向上取整到档位
+var delayLevels = []time.Duration{
+	time.Second,
+	5 * time.Second,
+	15 * time.Second,
+	time.Minute,
+	5 * time.Minute,
+	15 * time.Minute,
+	time.Hour,
+}
+
+// topologyVersion 任务队列参数的版本，修改任务队列参数时递增
+// RabbitMQ不允许以不同参数重新声明已存在的队列，参数变化后声明新版本的队列，并将旧队列中的消息迁移过去
+const topologyVersion = 2
+
+// taskQueueName 当前版本的任务队列名称
+func taskQueueName(queue string) string {
+	return fmt.Sprintf("%s.v%d", queue, topologyVersion)
+}
+
+// deadLetterQueueName 死信队列名称
+func deadLetterQueueName(taskType string) string {
+	return taskType + ".dlq"
+}
+
+// delayQueueName 延迟队列名称
+func delayQueueName(taskType string, level time.Duration) string {
+	return fmt.Sprintf("%s.delay.%ds", taskType, int(level/time.Second))
+}
+
+// delayLevel 获取不小于delay的最小档位，超过最大档位时取最大档位
+func delayLevel(delay time.Duration) time.Duration {
+	for _, level := range delayLevels {
+		if delay <= level {
+			return level
+		}
+	}
+	return delayLevels[len(delayLevels)-1]
+}
+
 // RabbitMQ RabbitMQ客户端
+// 连接或通道关闭后，发布与管理操作在下次调用时重新建立连接，消费者按退避策略自动重连
 type RabbitMQ struct {
+	url       string
+	consumers map[string]*consumer
+	backoff   reconnectBackoff
+
+	mu      sync.Mutex
 	conn    *amqp.Connection
-	channel *amqp.Channel
-	queues  map[string]amqp.Queue
+	channel *amqp.Channel // 发布通道
+
+	done      chan struct{}
+	closeOnce sync.Once
 }
 
-// NewRabbitMQ 创建RabbitMQ客户端
-func NewRabbitMQ() (*RabbitMQ, error) {
+// NewRabbitMQ 创建RabbitMQ客户端，consumers为各任务队列的消费配置
+func NewRabbitMQ(consumers []ConsumerConfig) (*RabbitMQ, error) {
+	ctx := context.Background()
+
 	// 获取RabbitMQ配置
-	host := g.Cfg().MustGet("rabbitmq.host").String()
-	port := g.Cfg().MustGet("rabbitmq.port").Int()
-	username := g.Cfg().MustGet("rabbitmq.username").String()
-	password := g.Cfg().MustGet("rabbitmq.password").String()
-	vhost := g.Cfg().MustGet("rabbitmq.vhost").String()
+	host := g.Cfg().MustGet(ctx, "rabbitmq.host").String()
+	port := g.Cfg().MustGet(ctx, "rabbitmq.port").Int()
+	username := g.Cfg().MustGet(ctx, "rabbitmq.username").String()
+	password := g.Cfg().MustGet(ctx, "rabbitmq.password").String()
+	vhost := g.Cfg().MustGet(ctx, "rabbitmq.vhost").String()
 
-	// 连接RabbitMQ
 	url := fmt.Sprintf("amqp://%s:%s@%s:%d/%s", username, password, host, port, vhost)
-	conn, err := amqp.Dial(url)
+	return newRabbitMQ(url, newReconnectBackoff(ctx), consumers)
+}
+
+// newRabbitMQ 按连接地址与重连退避策略创建RabbitMQ客户端
+func newRabbitMQ(url string, backoff reconnectBackoff, consumers []ConsumerConfig) (*RabbitMQ, error) {
+	r := &RabbitMQ{
+		url:       url,
+		consumers: make(map[string]*consumer),
+		backoff:   backoff,
+		done:      make(chan struct{}),
+	}
+	for _, config := range consumers {
+		r.consumers[config.TaskType] = newConsumer(r, config)
+	}
+
+	// 建立连接并声明队列
+	if _, err := r.connection(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// connection 获取当前连接，连接已关闭时重新连接并声明队列
+func (r *RabbitMQ) connection() (*amqp.Connection, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.connectionLocked()
+}
+
+// connectionLocked 获取当前连接，调用方需持有r.mu
+func (r *RabbitMQ) connectionLocked() (*amqp.Connection, error) {
+	select {
+	case <-r.done:
+		return nil, fmt.Errorf("RabbitMQ客户端已关闭")
+	default:
+	}
+
+	if r.conn != nil && !r.conn.IsClosed() {
+		return r.conn, nil
+	}
+
+	// 连接RabbitMQ
+	conn, err := amqp.Dial(r.url)
 	if err != nil {
 		return nil, fmt.Errorf("连接RabbitMQ失败: %v", err)
 	}
 
-	// 创建通道
+	// 声明交换机与队列
 	channel, err := conn.Channel()
 	if err != nil {
 		conn.Close()
 		return nil, fmt.Errorf("创建通道失败: %v", err)
 	}
-
-	// 创建交换机
-	err = channel.ExchangeDeclare(
-		"task_exchange", // 交换机名称
-		"direct",        // 交换机类型
-		true,           // 持久化
-		false,          // 自动删除
-		false,          // 内部使用
-		false,          // 不等待
-		nil,            // 参数
-	)
-	if err != nil {
+	configs := make([]ConsumerConfig, 0, len(r.consumers))
+	for _, c := range r.consumers {
+		configs = append(configs, c.config)
+	}
+	if err := declareTopology(channel, configs); err != nil {
 		channel.Close()
 		conn.Close()
-		return nil, fmt.Errorf("创建交换机失败: %v", err)
+		return nil, err
+	}
+	migrateLegacyQueues(conn, configs)
+
+	r.conn = conn
+	r.channel = channel
+	return conn, nil
+}
+
+// publishChannel 获取发布通道，通道已关闭时重新创建
+func (r *RabbitMQ) publishChannel() (*amqp.Channel, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	conn, err := r.connectionLocked()
+	if err != nil {
+		return nil, err
 	}
+	if r.channel == nil || r.channel.IsClosed() {
+		channel, err := conn.Channel()
+		if err != nil {
+			return nil, fmt.Errorf("创建通道失败: %v", err)
+		}
+		r.channel = channel
+	}
+	return r.channel, nil
+}
 
-	// 创建队列
-	queues := make(map[string]amqp.Queue)
-	queueNames := []string{"content_generation", "translation"}
+// openChannel 在当前连接上创建独立通道
+func (r *RabbitMQ) openChannel() (*amqp.Channel, error) {
+	conn, err := r.connection()
+	if err != nil {
+		return nil, err
+	}
+	channel, err := conn.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("创建通道失败: %v", err)
+	}
+	return channel, nil
+}
 
-	for _, name := range queueNames {
-		queue, err := channel.QueueDeclare(
-			name,  // 队列名称
-			true,  // 持久化
-			false, // 自动删除
-			false, // 独占
-			false, // 不等待
-			nil,   // 参数
+// declareTopology 声明交换机与队列
+// 每种任务类型包含：任务队列（优先级队列，拒绝的消息转入死信交换机）、死信队列、各档位延迟队列（过期后转回任务交换机）
+// 任务队列名称带版本号，参数变化时递增 topologyVersion，旧队列由 migrateLegacyQueues 迁移
+func declareTopology(channel *amqp.Channel, configs []ConsumerConfig) error {
+	// 创建交换机
+	for _, exchange := range []string{taskExchange, deadLetterExchange} {
+		err := channel.ExchangeDeclare(
+			exchange, // 交换机名称
+			"direct", // 交换机类型
+			true,     // 持久化
+			false,    // 自动删除
+			false,    // 内部使用
+			false,    // 不等待
+			nil,      // 参数
 		)
 		if err != nil {
-			channel.Close()
-			conn.Close()
-			return nil, fmt.Errorf("创建队列失败: %v", err)
-		}
-
-		// 绑定队列到交换机
-		err = channel.QueueBind(
-			queue.Name,      // 队列名称
-			name,           // 路由键
-			"task_exchange", // 交换机名称
-			false,          // 不等待
-			nil,            // 参数
+			return fmt.Errorf("创建交换机失败(%s): %v", exchange, err)
+		}
+	}
+
+	for _, config := range configs {
+		name := config.TaskType
+
+		// 创建任务队列
+		queue, err := channel.QueueDeclare(
+			taskQueueName(config.Queue), // 队列名称
+			true,                        // 持久化
+			false,                       // 自动删除
+			false,                       // 独占
+			false,                       // 不等待
+			amqp.Table{
+				"x-max-priority":            int32(MaxPriority),
+				"x-dead-letter-exchange":    deadLetterExchange,
+				"x-dead-letter-routing-key": name,
+			},
 		)
 		if err != nil {
-			channel.Close()
-			conn.Close()
-			return nil, fmt.Errorf("绑定队列失败: %v", err)
+			return fmt.Errorf("创建队列失败(%s): %v", config.Queue, err)
+		}
+		if err := channel.QueueBind(queue.Name, name, taskExchange, false, nil); err != nil {
+			return fmt.Errorf("绑定队列失败(%s): %v", config.Queue, err)
 		}
 
-		queues[name] = queue
+		// 创建死信队列
+		dlq, err := channel.QueueDeclare(deadLetterQueueName(name), true, false, false, false, nil)
+		if err != nil {
+			return fmt.Errorf("创建死信队列失败(%s): %v", name, err)
+		}
+		if err := channel.QueueBind(dlq.Name, name, deadLetterExchange, false, nil); err != nil {
+			return fmt.Errorf("绑定死信队列失败(%s): %v", name, err)
+		}
+
+		// 创建延迟队列，无消费者，消息过期后经任务交换机回到任务队列
+		for _, level := range delayLevels {
+			_, err := channel.QueueDeclare(
+				delayQueueName(name, level),
+				true,
+				false,
+				false,
+				false,
+				amqp.Table{
+					"x-message-ttl":             int64(level / time.Millisecond),
+					"x-dead-letter-exchange":    taskExchange,
+					"x-dead-letter-routing-key": name,
+				},
+			)
+			if err != nil {
+				return fmt.Errorf("创建延迟队列失败(%s): %v", name, err)
+			}
+		}
 	}
 
-	return &RabbitMQ{
-		conn:    conn,
-		channel: channel,
-		queues:  queues,
-	}, nil
+	return nil
 }
 
-// Close 关闭连接
+// migrateLegacyQueues 迁移未带版本号的旧任务队列：解除与任务交换机的绑定，将剩余消息重新发布到任务交换机后删除旧队列
+// 旧队列仍有消费者（滚动升级中的旧实例）时暂不删除，下次连接时继续迁移；迁移失败只记录日志，不影响新队列的使用
+func migrateLegacyQueues(conn *amqp.Connection, configs []ConsumerConfig) {
+	ctx := context.Background()
+	for _, config := range configs {
+		if err := migrateLegacyQueue(ctx, conn, config); err != nil {
+			g.Log().Warningf(ctx, "迁移旧任务队列失败: %s, %v", config.Queue, err)
+		}
+	}
+}
+
+// migrateLegacyQueue 迁移单个旧任务队列，旧队列不存在时直接返回
+// 队列不存在等通道级错误会关闭通道，因此在独立通道上执行
+func migrateLegacyQueue(ctx context.Context, conn *amqp.Connection, config ConsumerConfig) error {
+	channel, err := conn.Channel()
+	if err != nil {
+		return fmt.Errorf("创建通道失败: %v", err)
+	}
+	defer channel.Close()
+
+	if _, err := channel.QueueDeclarePassive(config.Queue, true, false, false, false, nil); err != nil {
+		var amqpErr *amqp.Error
+		if errors.As(err, &amqpErr) && amqpErr.Code == amqp.NotFound {
+			return nil
+		}
+		return err
+	}
+
+	// 解除绑定后新消息只进入新版本队列
+	if err := channel.QueueUnbind(config.Queue, config.TaskType, taskExchange, nil); err != nil {
+		return fmt.Errorf("解除旧队列绑定失败: %v", err)
+	}
+
+	// 逐条转发旧队列中的消息，发布确认后才确认原消息，迁移中断时未确认的消息留在旧队列
+	if err := channel.Confirm(false); err != nil {
+		return fmt.Errorf("开启发布确认失败: %v", err)
+	}
+	moved := 0
+	for {
+		d, ok, err := channel.Get(config.Queue, false)
+		if err != nil {
+			return fmt.Errorf("读取旧队列消息失败: %v", err)
+		}
+		if !ok {
+			break
+		}
+
+		confirm, err := channel.PublishWithDeferredConfirmWithContext(ctx, taskExchange, config.TaskType, false, false, amqp.Publishing{
+			Headers:      d.Headers,
+			ContentType:  d.ContentType,
+			Body:         d.Body,
+			DeliveryMode: amqp.Persistent,
+			Timestamp:    d.Timestamp,
+			MessageId:    d.MessageId,
+			Priority:     d.Priority,
+		})
+		if err != nil {
+			return fmt.Errorf("转发旧队列消息失败: %v", err)
+		}
+		if !confirm.Wait() {
+			return fmt.Errorf("转发旧队列消息未被确认: %s", d.MessageId)
+		}
+		if err := d.Ack(false); err != nil {
+			return fmt.Errorf("确认旧队列消息失败: %v", err)
+		}
+		moved++
+	}
+
+	// 仍有消费者或新消息时删除失败，保留旧队列
+	if _, err := channel.QueueDelete(config.Queue, true, true, false); err != nil {
+		return fmt.Errorf("删除旧队列失败(已迁移%d条消息): %v", moved, err)
+	}
+	g.Log().Infof(ctx, "旧任务队列已迁移: %s -> %s, 消息数: %d", config.Queue, taskQueueName(config.Queue), moved)
+	return nil
+}
+
+// Close 停止消费并关闭连接
 func (r *RabbitMQ) Close() error {
+	r.closeOnce.Do(func() {
+		close(r.done)
+	})
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	if r.channel != nil {
 		r.channel.Close()
 	}
@@ -111,6 +358,28 @@ func (r *RabbitMQ) Close() error {
 
 // Publish 发布消息
 func (r *RabbitMQ) Publish(ctx context.Context, msg *Message) error {
+	channel, err := r.publishChannel()
+	if err != nil {
+		return err
+	}
+	return r.publish(ctx, channel, taskExchange, msg.Type, msg)
+}
+
+// PublishDelayed 发布消息到延迟队列，过期后回到任务队列
+func (r *RabbitMQ) PublishDelayed(ctx context.Context, msg *Message, delay time.Duration) error {
+	if delay <= 0 {
+		return r.Publish(ctx, msg)
+	}
+	channel, err := r.publishChannel()
+	if err != nil {
+		return err
+	}
+	// 通过默认交换机直接投递到对应档位的延迟队列
+	return r.publish(ctx, channel, "", delayQueueName(msg.Type, delayLevel(delay)), msg)
+}
+
+// publish 序列化并发布消息
+func (r *RabbitMQ) publish(ctx context.Context, channel *amqp.Channel, exchange, routingKey string, msg *Message) error {
 	// 序列化消息
 	body, err := json.Marshal(msg)
 	if err != nil {
@@ -118,17 +387,18 @@ func (r *RabbitMQ) Publish(ctx context.Context, msg *Message) error {
 	}
 
 	// 发布消息
-	err = r.channel.PublishWithContext(ctx,
-		"task_exchange", // 交换机名称
-		msg.Type,       // 路由键
-		false,         // 强制
-		false,         // 立即
+	err = channel.PublishWithContext(ctx,
+		exchange,   // 交换机名称
+		routingKey, // 路由键
+		false,      // 强制
+		false,      // 立即
 		amqp.Publishing{
 			ContentType:  "application/json",
 			Body:         body,
 			DeliveryMode: amqp.Persistent, // 持久化消息
 			Timestamp:    time.Now(),
 			MessageId:    msg.ID,
+			Priority:     uint8(clampPriority(msg.Priority)), // 经延迟队列转回时保留
 		},
 	)
 	if err != nil {
@@ -138,47 +408,190 @@ func (r *RabbitMQ) Publish(ctx context.Context, msg *Message) error {
 	return nil
 }
 
-// Consume 消费消息
-func (r *RabbitMQ) Consume(ctx context.Context) (*Message, error) {
-	// 设置预取数量
-	err := r.channel.Qos(
-		1,     // 预取数量
-		0,     // 预取大小
-		false, // 全局
-	)
-	if err != nil {
-		return nil, fmt.Errorf("设置预取数量失败: %v", err)
-	}
-
-	// 开始消费
-	msgs, err := r.channel.Consume(
-		"content_generation", // 队列名称
-		"",                   // 消费者标签
-		false,               // 自动确认
-		false,               // 独占
-		false,               // 不等待
-		false,               // 不阻塞
-		nil,                 // 参数
-	)
-	if err != nil {
-		return nil, fmt.Errorf("开始消费失败: %v", err)
+// Consume 从指定任务类型的队列消费消息，消息在处理完成后由调用方确认
+// 首次调用时启动该队列的消费者，之后所有调用共享同一投递通道
+func (r *RabbitMQ) Consume(ctx context.Context, taskType string) (*Delivery, error) {
+	c, ok := r.consumers[taskType]
+	if !ok {
+		return nil, fmt.Errorf("未配置的任务队列: %s", taskType)
 	}
+	c.start()
 
 	// 等待消息
 	select {
-	case msg := <-msgs:
-		// 解析消息
+	case msg := <-c.deliveries:
+		// 解析消息，格式无效的消息直接转入死信队列
 		var message Message
 		if err := json.Unmarshal(msg.Body, &message); err != nil {
-			msg.Nack(false, true)
+			msg.Nack(false, false)
 			return nil, fmt.Errorf("解析消息失败: %v", err)
 		}
 
-		// 确认消息
-		msg.Ack(false)
-
-		return &message, nil
+		return &Delivery{
+			Message: &message,
+			ack: func() error {
+				return msg.Ack(false)
+			},
+			nack: func(requeue bool) error {
+				return msg.Nack(false, requeue)
+			},
+		}, nil
+	case <-r.done:
+		return nil, fmt.Errorf("RabbitMQ客户端已关闭")
 	case <-ctx.Done():
 		return nil, ctx.Err()
 	}
-} 
\ No newline at end of file
+}
+
+// DeadLetter 记录失败原因后将消息发布到死信交换机，并确认原消息
+func (r *RabbitMQ) DeadLetter(ctx context.Context, delivery *Delivery, reason string) error {
+	msg := *delivery.Message
+	msg.Error = reason
+	msg.FailedAt = time.Now()
+
+	channel, err := r.publishChannel()
+	if err == nil {
+		err = r.publish(ctx, channel, deadLetterExchange, msg.Type, &msg)
+	}
+	if err != nil {
+		// 发布失败时拒绝原消息，由队列的死信配置转入死信队列，只是不带失败原因
+		delivery.Nack(false)
+		return err
+	}
+	return delivery.Ack()
+}
+
+// ListDeadLetters 列出死信消息
+func (r *RabbitMQ) ListDeadLetters(ctx context.Context, taskType string, limit int) ([]*Message, error) {
+	var messages []*Message
+	err := r.scanDeadLetters(taskType, func(_ *amqp.Channel, _ amqp.Delivery, msg *Message) (bool, error) {
+		messages = append(messages, msg)
+		return limit > 0 && len(messages) >= limit, nil
+	})
+	return messages, err
+}
+
+// GetDeadLetter 获取指定ID的死信消息
+func (r *RabbitMQ) GetDeadLetter(ctx context.Context, taskType string, id string) (*Message, error) {
+	var found *Message
+	err := r.scanDeadLetters(taskType, func(_ *amqp.Channel, _ amqp.Delivery, msg *Message) (bool, error) {
+		if msg.ID == id {
+			found = msg
+			return true, nil
+		}
+		return false, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if found == nil {
+		return nil, ErrDeadLetterNotFound
+	}
+	return found, nil
+}
+
+// RequeueDeadLetters 将死信消息重置重试次数后重新投递到任务队列
+func (r *RabbitMQ) RequeueDeadLetters(ctx context.Context, taskType string, id string) ([]*Message, error) {
+	var requeued []*Message
+	err := r.scanDeadLetters(taskType, func(channel *amqp.Channel, d amqp.Delivery, msg *Message) (bool, error) {
+		if id != "" && msg.ID != id {
+			return false, nil
+		}
+
+		msg.Retries = 0
+		msg.Error = ""
+		msg.FailedAt = time.Time{}
+		if err := r.publish(ctx, channel, taskExchange, taskType, msg); err != nil {
+			return true, err
+		}
+		if err := d.Ack(false); err != nil {
+			return true, fmt.Errorf("确认死信消息失败: %v", err)
+		}
+		requeued = append(requeued, msg)
+		return id != "", nil
+	})
+	if err == nil && id != "" && len(requeued) == 0 {
+		err = ErrDeadLetterNotFound
+	}
+	return requeued, err
+}
+
+// PurgeDeadLetters 删除死信消息，id为空时清空死信队列
+func (r *RabbitMQ) PurgeDeadLetters(ctx context.Context, taskType string, id string) (int, error) {
+	if _, ok := r.consumers[taskType]; !ok {
+		return 0, fmt.Errorf("未知的任务类型: %s", taskType)
+	}
+
+	if id == "" {
+		channel, err := r.openChannel()
+		if err != nil {
+			return 0, err
+		}
+		defer channel.Close()
+
+		count, err := channel.QueuePurge(deadLetterQueueName(taskType), false)
+		if err != nil {
+			return 0, fmt.Errorf("清空死信队列失败: %v", err)
+		}
+		return count, nil
+	}
+
+	purged := 0
+	err := r.scanDeadLetters(taskType, func(_ *amqp.Channel, d amqp.Delivery, msg *Message) (bool, error) {
+		if msg.ID != id {
+			return false, nil
+		}
+		if err := d.Ack(false); err != nil {
+			return true, fmt.Errorf("确认死信消息失败: %v", err)
+		}
+		purged++
+		return true, nil
+	})
+	if err == nil && purged == 0 {
+		err = ErrDeadLetterNotFound
+	}
+	return purged, err
+}
+
+// scanDeadLetters 在独立通道上逐条取出死信消息，visit返回true时停止
+// visit中确认的消息从死信队列移除；未确认的消息在通道关闭时自动放回队列并保持原有顺序
+func (r *RabbitMQ) scanDeadLetters(taskType string, visit func(channel *amqp.Channel, d amqp.Delivery, msg *Message) (bool, error)) error {
+	if _, ok := r.consumers[taskType]; !ok {
+		return fmt.Errorf("未知的任务类型: %s", taskType)
+	}
+
+	channel, err := r.openChannel()
+	if err != nil {
+		return err
+	}
+	defer channel.Close()
+
+	for {
+		d, ok, err := channel.Get(deadLetterQueueName(taskType), false)
+		if err != nil {
+			return fmt.Errorf("读取死信队列失败: %v", err)
+		}
+		if !ok {
+			return nil
+		}
+
+		// 格式无效或被直接拒绝的消息没有失败原因，按原始内容展示
+		var msg Message
+		if err := json.Unmarshal(d.Body, &msg); err != nil {
+			msg = Message{
+				ID:    d.MessageId,
+				Type:  taskType,
+				Data:  d.Body,
+				Error: "消息格式无效",
+			}
+		}
+		if msg.Error == "" {
+			msg.Error = "消息被拒绝"
+		}
+
+		stop, err := visit(channel, d, &msg)
+		if err != nil || stop {
+			return err
+		}
+	}
+}
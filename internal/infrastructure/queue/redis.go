@@ -0,0 +1,597 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"github.com/gogf/gf/v2/frame/g"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// redisConsumerGroup 任务流的消费者组
+	redisConsumerGroup = "task_workers"
+	// redisMessageField 流条目中保存消息的字段
+	redisMessageField = "message"
+)
+
+// promoteDelayedScript 将到期的延迟消息按优先级原子地移入任务流
+// KEYS[1] 延迟消息有序集合，KEYS[2..] 优先级0至MaxPriority的任务流；ARGV[1] 当前时间（毫秒），ARGV[2] 单次移动数量
+var promoteDelayedScript = redis.NewScript(`
+local due = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, ARGV[2])
+for _, message in ipairs(due) do
+	local priority = tonumber(cjson.decode(message)['Priority']) or 0
+	priority = math.max(0, math.min(priority, #KEYS - 2))
+	redis.call('XADD', KEYS[priority + 2], '*', 'message', message)
+	redis.call('ZREM', KEYS[1], message)
+end
+return #due
+`)
+
+// redisStreamKey 任务流键，每个优先级一个流
+func redisStreamKey(taskType string, priority int) string {
+	return fmt.Sprintf("task:stream:%s:p%d", taskType, priority)
+}
+
+// redisStreamKeys 按优先级从高到低排列的任务流键
+func redisStreamKeys(taskType string) []string {
+	keys := make([]string, 0, MaxPriority+1)
+	for priority := MaxPriority; priority >= 0; priority-- {
+		keys = append(keys, redisStreamKey(taskType, priority))
+	}
+	return keys
+}
+
+// redisDelayedKey 延迟消息有序集合键，分数为可消费时间（毫秒）
+func redisDelayedKey(taskType string) string {
+	return "task:delayed:" + taskType
+}
+
+// redisDeadLetterKey 死信流键
+func redisDeadLetterKey(taskType string) string {
+	return "task:dlq:" + taskType
+}
+
+// RedisQueue 基于Redis Streams的队列
+// 每种任务类型按优先级分为多个流，消费时从高优先级流开始读取，所有实例共享同一消费者组；消息处理完成后XACK确认，
+// 实例崩溃遗留的未确认消息在空闲超过claimIdle后由其他实例认领；延迟消息暂存在有序集合中，到期后移入对应优先级的流
+// 处理中的消息由持有者每隔claimIdle/3重新认领以刷新空闲时间，处理时间超过claimIdle也不会被其他实例认领
+type RedisQueue struct {
+	client    *redis.Client
+	consumer  string
+	claimIdle time.Duration
+	block     time.Duration
+
+	mu       sync.Mutex
+	promoted map[string]bool
+	buffered map[string][]redisEntry        // 阻塞读取时一次取到的多条消息，已归属当前消费者，按优先级排列
+	inflight map[string]map[string]struct{} // 已读取、尚未确认的条目ID，按流分组
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewRedisQueue 创建Redis队列，从配置文件 queue.redis 读取连接信息，并为consumers中的任务类型创建消费者组
+func NewRedisQueue(ctx context.Context, consumers []ConsumerConfig) (*RedisQueue, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:     g.Cfg().MustGet(ctx, "queue.redis.address").String(),
+		Password: g.Cfg().MustGet(ctx, "queue.redis.password").String(),
+		DB:       g.Cfg().MustGet(ctx, "queue.redis.db").Int(),
+	})
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("连接Redis失败: %v", err)
+	}
+
+	claimIdle := time.Duration(g.Cfg().MustGet(ctx, "queue.redis.claimIdle", 300).Int()) * time.Second
+	return newRedisQueue(ctx, client, claimIdle, consumers)
+}
+
+// newRedisQueue 在已连接的客户端上创建Redis队列，创建失败时关闭客户端
+func newRedisQueue(ctx context.Context, client *redis.Client, claimIdle time.Duration, consumers []ConsumerConfig) (*RedisQueue, error) {
+	if claimIdle <= 0 {
+		client.Close()
+		return nil, fmt.Errorf("queue.redis.claimIdle必须大于0")
+	}
+
+	hostname, _ := os.Hostname()
+	q := &RedisQueue{
+		client:    client,
+		consumer:  fmt.Sprintf("%s-%d", hostname, os.Getpid()),
+		claimIdle: claimIdle,
+		block:     2 * time.Second,
+		promoted:  make(map[string]bool),
+		buffered:  make(map[string][]redisEntry),
+		inflight:  make(map[string]map[string]struct{}),
+		done:      make(chan struct{}),
+	}
+
+	for _, config := range consumers {
+		if err := q.createGroup(ctx, config.TaskType); err != nil {
+			client.Close()
+			return nil, err
+		}
+	}
+
+	go q.keepAlive()
+	return q, nil
+}
+
+// redisEntry 从任务流读取的条目
+type redisEntry struct {
+	stream string
+	entry  redis.XMessage
+}
+
+// createGroup 为各优先级的任务流创建消费者组，流不存在时一并创建
+func (q *RedisQueue) createGroup(ctx context.Context, taskType string) error {
+	for _, stream := range redisStreamKeys(taskType) {
+		err := q.client.XGroupCreateMkStream(ctx, stream, redisConsumerGroup, "0").Err()
+		if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+			return fmt.Errorf("创建消费者组失败(%s): %v", stream, err)
+		}
+	}
+	return nil
+}
+
+// Publish 发布消息到对应优先级的任务流
+func (q *RedisQueue) Publish(ctx context.Context, msg *Message) error {
+	return q.add(ctx, redisStreamKey(msg.Type, clampPriority(msg.Priority)), msg)
+}
+
+// add 序列化消息并追加到流
+func (q *RedisQueue) add(ctx context.Context, stream string, msg *Message) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("序列化消息失败: %v", err)
+	}
+	err = q.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: stream,
+		Values: map[string]interface{}{redisMessageField: body},
+	}).Err()
+	if err != nil {
+		return fmt.Errorf("发布消息失败: %v", err)
+	}
+	return nil
+}
+
+// PublishDelayed 延迟发布消息，到期前暂存在有序集合中
+func (q *RedisQueue) PublishDelayed(ctx context.Context, msg *Message, delay time.Duration) error {
+	if delay <= 0 {
+		return q.Publish(ctx, msg)
+	}
+
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("序列化消息失败: %v", err)
+	}
+	err = q.client.ZAdd(ctx, redisDelayedKey(msg.Type), &redis.Z{
+		Score:  float64(time.Now().Add(delay).UnixMilli()),
+		Member: body,
+	}).Err()
+	if err != nil {
+		return fmt.Errorf("发布延迟消息失败: %v", err)
+	}
+	return nil
+}
+
+// Consume 消费指定任务类型的消息
+// 依次尝试：本地暂存的消息、空闲超时的未确认消息、新消息，后两者均从高优先级流开始
+func (q *RedisQueue) Consume(ctx context.Context, taskType string) (*Delivery, error) {
+	q.startPromoter(taskType)
+	streams := redisStreamKeys(taskType)
+
+	for {
+		select {
+		case <-q.done:
+			return nil, fmt.Errorf("Redis队列已关闭")
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+
+		if entry, ok := q.popBuffered(taskType); ok {
+			return q.delivery(ctx, taskType, entry)
+		}
+
+		// 认领其他消费者遗留的未确认消息
+		for _, stream := range streams {
+			claimed, _, err := q.client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+				Stream:   stream,
+				Group:    redisConsumerGroup,
+				Consumer: q.consumer,
+				MinIdle:  q.claimIdle,
+				Start:    "0-0",
+				Count:    1,
+			}).Result()
+			if err != nil && !errors.Is(err, redis.Nil) {
+				return nil, fmt.Errorf("认领未确认消息失败: %v", err)
+			}
+			if len(claimed) > 0 {
+				entry := redisEntry{stream: stream, entry: claimed[0]}
+				q.track(entry)
+				return q.delivery(ctx, taskType, entry)
+			}
+		}
+
+		// 按优先级从高到低非阻塞读取新消息
+		for _, stream := range streams {
+			entries, err := q.read(ctx, []string{stream}, -1)
+			if err != nil {
+				return nil, err
+			}
+			if len(entries) > 0 {
+				return q.delivery(ctx, taskType, entries[0])
+			}
+		}
+
+		// 所有流均为空时阻塞等待任一流的新消息，同时取到的多条消息暂存到本地
+		entries, err := q.read(ctx, streams, q.block)
+		if err != nil {
+			return nil, err
+		}
+		if len(entries) > 0 {
+			q.pushBuffered(taskType, entries[1:])
+			return q.delivery(ctx, taskType, entries[0])
+		}
+	}
+}
+
+// read 从任务流读取新消息，每个流最多一条，结果按streams的顺序排列；block小于0时不阻塞
+func (q *RedisQueue) read(ctx context.Context, streams []string, block time.Duration) ([]redisEntry, error) {
+	args := make([]string, 0, len(streams)*2)
+	args = append(args, streams...)
+	for range streams {
+		args = append(args, ">")
+	}
+
+	result, err := q.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    redisConsumerGroup,
+		Consumer: q.consumer,
+		Streams:  args,
+		Count:    1,
+		Block:    block,
+	}).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("读取消息失败: %v", err)
+	}
+
+	var entries []redisEntry
+	for _, stream := range streams {
+		for _, s := range result {
+			if s.Stream == stream && len(s.Messages) > 0 {
+				entry := redisEntry{stream: stream, entry: s.Messages[0]}
+				q.track(entry)
+				entries = append(entries, entry)
+			}
+		}
+	}
+	return entries, nil
+}
+
+// track 记录已归属当前消费者、尚未确认的条目
+func (q *RedisQueue) track(e redisEntry) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	ids, ok := q.inflight[e.stream]
+	if !ok {
+		ids = make(map[string]struct{})
+		q.inflight[e.stream] = ids
+	}
+	ids[e.entry.ID] = struct{}{}
+}
+
+// untrack 移除已确认或放弃的条目
+func (q *RedisQueue) untrack(e redisEntry) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if ids, ok := q.inflight[e.stream]; ok {
+		delete(ids, e.entry.ID)
+		if len(ids) == 0 {
+			delete(q.inflight, e.stream)
+		}
+	}
+}
+
+// keepAlive 每隔claimIdle/3重新认领处理中与本地暂存的条目，刷新其空闲时间，直到队列关闭
+func (q *RedisQueue) keepAlive() {
+	ctx := context.Background()
+	interval := q.claimIdle / 3
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-q.done:
+			return
+		case <-ticker.C:
+			q.touch(ctx)
+		}
+	}
+}
+
+// touch 以XCLAIM JUSTID将条目重新认领给当前消费者，只重置空闲时间，不增加投递次数
+// 已确认的条目不在待确认列表中，认领时会被忽略
+func (q *RedisQueue) touch(ctx context.Context) {
+	q.mu.Lock()
+	pending := make(map[string][]string, len(q.inflight))
+	for stream, ids := range q.inflight {
+		for id := range ids {
+			pending[stream] = append(pending[stream], id)
+		}
+	}
+	q.mu.Unlock()
+
+	for stream, ids := range pending {
+		err := q.client.XClaimJustID(ctx, &redis.XClaimArgs{
+			Stream:   stream,
+			Group:    redisConsumerGroup,
+			Consumer: q.consumer,
+			MinIdle:  0,
+			Messages: ids,
+		}).Err()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			g.Log().Warningf(ctx, "刷新未确认消息空闲时间失败: %s, %v", stream, err)
+		}
+	}
+}
+
+// popBuffered 取出本地暂存的消息
+func (q *RedisQueue) popBuffered(taskType string) (redisEntry, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	entries := q.buffered[taskType]
+	if len(entries) == 0 {
+		return redisEntry{}, false
+	}
+	q.buffered[taskType] = entries[1:]
+	return entries[0], true
+}
+
+// pushBuffered 暂存消息
+func (q *RedisQueue) pushBuffered(taskType string, entries []redisEntry) {
+	if len(entries) == 0 {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.buffered[taskType] = append(q.buffered[taskType], entries...)
+}
+
+// delivery 解析流条目并创建待确认消息
+// 格式无效的消息直接转入死信流
+func (q *RedisQueue) delivery(ctx context.Context, taskType string, e redisEntry) (*Delivery, error) {
+	entry := e.entry
+	settle := func() error {
+		pipe := q.client.TxPipeline()
+		pipe.XAck(ctx, e.stream, redisConsumerGroup, entry.ID)
+		pipe.XDel(ctx, e.stream, entry.ID)
+		if _, err := pipe.Exec(ctx); err != nil {
+			return fmt.Errorf("确认消息失败: %v", err)
+		}
+		q.untrack(e)
+		return nil
+	}
+
+	body, _ := entry.Values[redisMessageField].(string)
+	var message Message
+	if err := json.Unmarshal([]byte(body), &message); err != nil {
+		dead := &Message{ID: entry.ID, Type: taskType, Data: []byte(body), Error: "消息格式无效", FailedAt: time.Now()}
+		if err := q.add(ctx, redisDeadLetterKey(taskType), dead); err == nil {
+			settle()
+		} else {
+			// 转入死信流失败时不再刷新，由其他实例在空闲超时后认领重试
+			q.untrack(e)
+		}
+		return nil, fmt.Errorf("解析消息失败: %v", err)
+	}
+
+	return &Delivery{
+		Message: &message,
+		ack:     settle,
+		nack: func(requeue bool) error {
+			msg := message
+			if requeue {
+				if err := q.Publish(ctx, &msg); err != nil {
+					return err
+				}
+				return settle()
+			}
+			msg.Error = "消息被拒绝"
+			msg.FailedAt = time.Now()
+			if err := q.add(ctx, redisDeadLetterKey(taskType), &msg); err != nil {
+				return err
+			}
+			return settle()
+		},
+	}, nil
+}
+
+// startPromoter 启动延迟消息搬运协程，每种任务类型只启动一次
+func (q *RedisQueue) startPromoter(taskType string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.promoted[taskType] {
+		return
+	}
+	q.promoted[taskType] = true
+	go q.promote(taskType)
+}
+
+// promote 每秒将到期的延迟消息移入任务流，直到队列关闭
+func (q *RedisQueue) promote(taskType string) {
+	ctx := context.Background()
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-q.done:
+			return
+		case <-ticker.C:
+			now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+			keys := []string{redisDelayedKey(taskType)}
+			for priority := 0; priority <= MaxPriority; priority++ {
+				keys = append(keys, redisStreamKey(taskType, priority))
+			}
+			err := promoteDelayedScript.Run(ctx, q.client, keys, now, 100).Err()
+			if err != nil && !errors.Is(err, redis.Nil) {
+				g.Log().Warningf(ctx, "移动延迟消息失败: %s, %v", taskType, err)
+			}
+		}
+	}
+}
+
+// DeadLetter 记录失败原因后将消息转入死信流，并确认原消息
+func (q *RedisQueue) DeadLetter(ctx context.Context, delivery *Delivery, reason string) error {
+	msg := *delivery.Message
+	msg.Error = reason
+	msg.FailedAt = time.Now()
+	if err := q.add(ctx, redisDeadLetterKey(msg.Type), &msg); err != nil {
+		return err
+	}
+	return delivery.Ack()
+}
+
+// Close 关闭连接
+func (q *RedisQueue) Close() error {
+	q.closeOnce.Do(func() {
+		close(q.done)
+	})
+	return q.client.Close()
+}
+
+// deadLetterEntry 死信流条目
+type deadLetterEntry struct {
+	id  string
+	msg *Message
+}
+
+// scanDeadLetters 按写入顺序读取死信流，limit不大于0时读取全部
+func (q *RedisQueue) scanDeadLetters(ctx context.Context, taskType string, limit int) ([]deadLetterEntry, error) {
+	var entries []redis.XMessage
+	var err error
+	if limit > 0 {
+		entries, err = q.client.XRangeN(ctx, redisDeadLetterKey(taskType), "-", "+", int64(limit)).Result()
+	} else {
+		entries, err = q.client.XRange(ctx, redisDeadLetterKey(taskType), "-", "+").Result()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取死信流失败: %v", err)
+	}
+
+	result := make([]deadLetterEntry, 0, len(entries))
+	for _, entry := range entries {
+		body, _ := entry.Values[redisMessageField].(string)
+		var msg Message
+		if err := json.Unmarshal([]byte(body), &msg); err != nil {
+			msg = Message{ID: entry.ID, Type: taskType, Data: []byte(body), Error: "消息格式无效"}
+		}
+		result = append(result, deadLetterEntry{id: entry.ID, msg: &msg})
+	}
+	return result, nil
+}
+
+// ListDeadLetters 列出死信消息
+func (q *RedisQueue) ListDeadLetters(ctx context.Context, taskType string, limit int) ([]*Message, error) {
+	entries, err := q.scanDeadLetters(ctx, taskType, limit)
+	if err != nil {
+		return nil, err
+	}
+	messages := make([]*Message, 0, len(entries))
+	for _, entry := range entries {
+		messages = append(messages, entry.msg)
+	}
+	return messages, nil
+}
+
+// GetDeadLetter 获取指定ID的死信消息
+func (q *RedisQueue) GetDeadLetter(ctx context.Context, taskType string, id string) (*Message, error) {
+	entries, err := q.scanDeadLetters(ctx, taskType, 0)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.msg.ID == id {
+			return entry.msg, nil
+		}
+	}
+	return nil, ErrDeadLetterNotFound
+}
+
+// RequeueDeadLetters 将死信消息重置重试次数后重新投递
+func (q *RedisQueue) RequeueDeadLetters(ctx context.Context, taskType string, id string) ([]*Message, error) {
+	entries, err := q.scanDeadLetters(ctx, taskType, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var requeued []*Message
+	for _, entry := range entries {
+		if id != "" && entry.msg.ID != id {
+			continue
+		}
+		msg := entry.msg
+		msg.Retries = 0
+		msg.Error = ""
+		msg.FailedAt = time.Time{}
+		if err := q.Publish(ctx, msg); err != nil {
+			return requeued, err
+		}
+		if err := q.client.XDel(ctx, redisDeadLetterKey(taskType), entry.id).Err(); err != nil {
+			return requeued, fmt.Errorf("删除死信消息失败: %v", err)
+		}
+		requeued = append(requeued, msg)
+	}
+	if id != "" && len(requeued) == 0 {
+		return nil, ErrDeadLetterNotFound
+	}
+	return requeued, nil
+}
+
+// PurgeDeadLetters 删除死信消息，id为空时清空死信流
+func (q *RedisQueue) PurgeDeadLetters(ctx context.Context, taskType string, id string) (int, error) {
+	if id == "" {
+		count, err := q.client.XLen(ctx, redisDeadLetterKey(taskType)).Result()
+		if err != nil {
+			return 0, fmt.Errorf("读取死信流失败: %v", err)
+		}
+		if err := q.client.Del(ctx, redisDeadLetterKey(taskType)).Err(); err != nil {
+			return 0, fmt.Errorf("清空死信流失败: %v", err)
+		}
+		return int(count), nil
+	}
+
+	entries, err := q.scanDeadLetters(ctx, taskType, 0)
+	if err != nil {
+		return 0, err
+	}
+	var ids []string
+	for _, entry := range entries {
+		if entry.msg.ID == id {
+			ids = append(ids, entry.id)
+		}
+	}
+	if len(ids) == 0 {
+		return 0, ErrDeadLetterNotFound
+	}
+	if err := q.client.XDel(ctx, redisDeadLetterKey(taskType), ids...).Err(); err != nil {
+		return 0, fmt.Errorf("删除死信消息失败: %v", err)
+	}
+	return len(ids), nil
+}
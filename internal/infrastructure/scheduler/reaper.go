@@ -0,0 +1,130 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"github.com/gogf/gf/v2/frame/g"
+	"ai-translate/internal/infrastructure/ai"
+	"ai-translate/internal/infrastructure/event"
+	"ai-translate/internal/model"
+)
+
+// reapBatchSize 每批回收的卡住任务数
+const reapBatchSize = 100
+
+// deadlineGrace 超过截止时间后等待处理协程自行处理超时的时间，之后才由回收协程处理
+const deadlineGrace = time.Minute
+
+// defaultTimeouts 各任务类型默认的执行时限（秒）
+var defaultTimeouts = map[model.TaskType]int{
+	model.TaskTypeContentGeneration: 600,
+	model.TaskTypeTranslation:       1800,
+}
+
+// ExecutionTimeout 获取任务类型的执行时限，配置为0时不限制
+func ExecutionTimeout(ctx context.Context, taskType model.TaskType) time.Duration {
+	seconds := g.Cfg().MustGet(ctx, "scheduler.timeout."+string(taskType), defaultTimeouts[taskType]).Int()
+	return time.Duration(seconds) * time.Second
+}
+
+// WithDeadline 按任务类型的执行时限记录本次执行的截止时间，返回在截止时间取消的上下文；未配置时限时不限制
+func WithDeadline(ctx context.Context, repository model.TaskRepository, task *model.Task) (context.Context, context.CancelFunc) {
+	timeout := ExecutionTimeout(ctx, task.Type)
+	if timeout <= 0 {
+		if err := repository.SetDeadline(ctx, task.ID, nil); err != nil {
+			g.Log().Warningf(ctx, "清除任务截止时间失败: %s, %v", task.ID, err)
+		}
+		return context.WithCancel(ctx)
+	}
+
+	deadline := time.Now().Add(timeout)
+	if err := repository.SetDeadline(ctx, task.ID, &deadline); err != nil {
+		g.Log().Warningf(ctx, "记录任务截止时间失败: %s, %v", task.ID, err)
+	}
+	task.DeadlineAt = &deadline
+	return context.WithDeadline(ctx, deadline)
+}
+
+// TimeoutError 任务因超过执行时限被取消时，返回带原因的错误，便于记录失败原因；其他错误原样返回
+func TimeoutError(ctx context.Context, task *model.Task, err error) error {
+	if err == nil || !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return err
+	}
+	return fmt.Errorf("任务执行超时（超过%s）: %w", ExecutionTimeout(ctx, task.Type), err)
+}
+
+// OnRequeued 注册卡住任务放回等待处理后的回调，队列模式下用于重新投递任务
+func (s *TaskScheduler) OnRequeued(fn func(ctx context.Context, task *model.Task)) {
+	s.onRequeued = fn
+}
+
+// reapStuckTasks 处理租约过期（工作协程崩溃或失联）或超过截止时间的处理中任务：
+// 仍有重试次数时放回等待处理并增加重试次数，否则标记失败，两者都记录原因
+// 按ID分页，放回或标记失败失败的任务留到下次回收，不会反复处理同一批任务
+func (s *TaskScheduler) reapStuckTasks(ctx context.Context) {
+	now := time.Now()
+	afterID := ""
+	for {
+		tasks, err := s.repository.ListStuckTasks(ctx, now, now.Add(-deadlineGrace), afterID, reapBatchSize)
+		if err != nil {
+			g.Log().Errorf(ctx, "获取卡住的任务失败: %v", err)
+			return
+		}
+		for _, task := range tasks {
+			s.reap(ctx, task, now)
+		}
+		if len(tasks) < reapBatchSize {
+			return
+		}
+		afterID = tasks[len(tasks)-1].ID
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.stopCh:
+			return
+		default:
+		}
+	}
+}
+
+// reap 放回或失败一个卡住的任务，任务在此之前已完成或已被其他实例处理时跳过
+func (s *TaskScheduler) reap(ctx context.Context, task *model.Task, now time.Time) {
+	deadlineBefore := now.Add(-deadlineGrace)
+	reason := "任务心跳超时"
+	if task.DeadlineAt != nil && task.DeadlineAt.Before(deadlineBefore) {
+		reason = "任务执行超时"
+	}
+
+	if ai.CanRetry(task.RetryCount, task.MaxRetries) {
+		requeued, err := s.repository.RequeueStuckTask(ctx, task.ID, now, deadlineBefore, reason)
+		if err != nil {
+			g.Log().Errorf(ctx, "放回卡住的任务失败: %s, %v", task.ID, err)
+			return
+		}
+		if !requeued {
+			return
+		}
+		g.Log().Warningf(ctx, "%s，放回等待处理: %s, 第%d次重试", reason, task.ID, task.RetryCount+1)
+		task.Status = model.TaskStatusPending
+		task.RetryCount++
+		task.Error = reason
+		event.TaskStatusChanged(task, model.TaskStatusPending, reason)
+		if s.onRequeued != nil {
+			s.onRequeued(ctx, task)
+		}
+		return
+	}
+
+	failed, err := s.repository.FailStuckTask(ctx, task.ID, now, deadlineBefore, reason)
+	if err != nil {
+		g.Log().Errorf(ctx, "标记卡住的任务失败失败: %s, %v", task.ID, err)
+		return
+	}
+	if failed {
+		g.Log().Warningf(ctx, "%s，重试次数已用尽: %s", reason, task.ID)
+		event.TaskStatusChanged(task, model.TaskStatusFailed, reason)
+	}
+}
@@ -0,0 +1,426 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/google/uuid"
+	"ai-translate/internal/infrastructure/ai"
+	"ai-translate/internal/infrastructure/event"
+	"ai-translate/internal/infrastructure/expr"
+	"ai-translate/internal/model"
+)
+
+// rulePageSize 加载规则与规则组时的分页大小
+const rulePageSize = 100
+
+// ruleSweepLock 规则巡检的命名锁，多副本部署时同一时刻只有一个实例巡检
+const ruleSweepLock = "ai-translate:rule-sweep"
+
+// sweepStatuses 规则巡检覆盖的任务状态
+var sweepStatuses = []model.TaskStatus{model.TaskStatusPending, model.TaskStatusPaused}
+
+// AgingPolicy 等待老化策略：待处理任务每等待Step未被调整，优先级提升一级，最高提升到MaxPriority，防止低优先级任务饿死
+type AgingPolicy struct {
+	Step        time.Duration      // 提升一级所需的等待时间，为0时不启用
+	MaxPriority model.TaskPriority // 老化可提升到的最高优先级
+}
+
+// RuleEngine 优先级规则引擎，定期对待处理与已暂停的任务评估启用的规则与规则组，并按等待时间老化提升优先级
+type RuleEngine struct {
+	repository model.TaskRepository
+	aging      AgingPolicy
+	batchSize  int
+	onRaised   func(ctx context.Context, task *model.Task, oldPriority, newPriority model.TaskPriority)
+	onResumed  func(ctx context.Context, task *model.Task)
+}
+
+// RuleChain 按顺序评估的一组规则，多条规则生效时按Strategy处理
+type RuleChain struct {
+	Strategy model.RuleGroupStrategy
+	Rules    []*model.PriorityAdjustRule
+}
+
+// plannedRule 将要执行的规则及其对任务的影响
+type plannedRule struct {
+	rule   *model.PriorityAdjustRule
+	effect expr.Effect
+}
+
+// ruleSet 一次巡检使用的规则快照：不属于任何启用规则组的规则按Order升序、Priority降序组成一组first_match，其后为启用的规则组
+type ruleSet struct {
+	chains []RuleChain
+}
+
+// NewRuleEngine 创建规则引擎，从配置文件 scheduler.rules 与 scheduler.aging 读取参数
+func NewRuleEngine(ctx context.Context, repository model.TaskRepository) *RuleEngine {
+	return &RuleEngine{
+		repository: repository,
+		aging: AgingPolicy{
+			Step:        time.Duration(g.Cfg().MustGet(ctx, "scheduler.aging.stepMinutes", 30).Int()) * time.Minute,
+			MaxPriority: model.TaskPriority(g.Cfg().MustGet(ctx, "scheduler.aging.maxPriority", int(model.TaskPriorityHigh)).Int()),
+		},
+		batchSize: g.Cfg().MustGet(ctx, "scheduler.rules.batchSize", 200).Int(),
+	}
+}
+
+// OnPriorityRaised 注册优先级提升回调，队列模式下用于按新优先级重新投递仍在排队的任务
+func (e *RuleEngine) OnPriorityRaised(fn func(ctx context.Context, task *model.Task, oldPriority, newPriority model.TaskPriority)) {
+	e.onRaised = fn
+}
+
+// OnResumed 注册任务恢复回调，暂停时排队消息已被丢弃，队列模式下用于重新投递任务
+func (e *RuleEngine) OnResumed(fn func(ctx context.Context, task *model.Task)) {
+	e.onResumed = fn
+}
+
+// Sweep 巡检一遍待处理与已暂停的任务，其他实例正在巡检时跳过本轮
+func (e *RuleEngine) Sweep(ctx context.Context) error {
+	acquired, err := e.repository.TryLock(ctx, ruleSweepLock, func() error {
+		return e.sweep(ctx)
+	})
+	if err != nil {
+		return err
+	}
+	if !acquired {
+		g.Log().Debug(ctx, "其他实例正在巡检规则，跳过本轮")
+	}
+	return nil
+}
+
+// sweep 分批评估待处理与已暂停的任务
+func (e *RuleEngine) sweep(ctx context.Context) error {
+	set, err := e.loadRules(ctx)
+	if err != nil {
+		return fmt.Errorf("加载规则失败: %v", err)
+	}
+
+	afterID := ""
+	for {
+		tasks, err := e.repository.ListTasksByStatus(ctx, sweepStatuses, afterID, e.batchSize)
+		if err != nil {
+			return fmt.Errorf("获取任务失败: %v", err)
+		}
+
+		for _, task := range tasks {
+			if err := e.evaluate(ctx, task, set); err != nil {
+				g.Log().Warningf(ctx, "评估任务规则失败: %s, %v", task.ID, err)
+			}
+		}
+
+		if len(tasks) < e.batchSize {
+			return nil
+		}
+		afterID = tasks[len(tasks)-1].ID
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+	}
+}
+
+// loadRules 加载启用的规则与规则组
+func (e *RuleEngine) loadRules(ctx context.Context) (*ruleSet, error) {
+	var rules []*model.PriorityAdjustRule
+	for page := 1; ; page++ {
+		list, total, err := e.repository.ListPriorityRules(ctx, "", "", "", true, page, rulePageSize)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, list...)
+		if len(list) == 0 || int64(len(rules)) >= total {
+			break
+		}
+	}
+
+	var groups []*model.RuleGroup
+	for page := 1; ; page++ {
+		list, total, err := e.repository.ListRuleGroups(ctx, true, page, rulePageSize)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, list...)
+		if len(list) == 0 || int64(len(groups)) >= total {
+			break
+		}
+	}
+
+	var chains []RuleChain
+	grouped := make(map[string]bool)
+	for _, group := range groups {
+		groupRules, err := e.repository.GetGroupRules(ctx, group.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range group.Rules {
+			grouped[id] = true
+		}
+
+		chain := RuleChain{Strategy: group.Strategy}
+		for _, rule := range groupRules {
+			if rule.Enabled {
+				chain.Rules = append(chain.Rules, rule)
+			}
+		}
+		if len(chain.Rules) > 0 {
+			chains = append(chains, chain)
+		}
+	}
+
+	standalone := RuleChain{Strategy: model.RuleGroupFirstMatch}
+	for _, rule := range rules {
+		if !grouped[rule.ID] {
+			standalone.Rules = append(standalone.Rules, rule)
+		}
+	}
+	sort.SliceStable(standalone.Rules, func(i, j int) bool {
+		a, b := standalone.Rules[i], standalone.Rules[j]
+		if a.Order != b.Order {
+			return a.Order < b.Order
+		}
+		return a.Priority > b.Priority
+	})
+	if len(standalone.Rules) > 0 {
+		chains = append([]RuleChain{standalone}, chains...)
+	}
+	return &ruleSet{chains: chains}, nil
+}
+
+// evaluate 对单个任务依次执行独立规则与每个规则组，均未生效时按等待时间老化
+func (e *RuleEngine) evaluate(ctx context.Context, task *model.Task, set *ruleSet) error {
+	applied := false
+
+	for _, chain := range set.chains {
+		planned, err := e.resolve(ctx, task, chain, expr.NewEnv(task), true)
+		if err != nil {
+			return err
+		}
+		for _, p := range planned {
+			if err := e.Apply(ctx, task, p.rule.ID, p.rule.Description, p.effect); err != nil {
+				return err
+			}
+			applied = true
+		}
+	}
+
+	if applied {
+		return nil
+	}
+	return e.age(ctx, task)
+}
+
+// resolve 按规则组的处理方式决定要执行的规则，不写入任何数据
+//   - first_match：按顺序第一个生效的规则
+//   - highest/lowest：所有生效的规则中调整后优先级最高/最低的一个，相同时取靠前的
+//   - cumulative：按顺序依次作用于任务的所有生效规则，后面的规则基于前面规则执行后的任务评估
+func (e *RuleEngine) resolve(ctx context.Context, task *model.Task, chain RuleChain, env *expr.Env, once bool) ([]plannedRule, error) {
+	preview := *task
+	local := *env
+	local.Task = &preview
+
+	var planned []plannedRule
+	for _, rule := range chain.Rules {
+		effect, err := e.plan(ctx, &preview, rule, &local, once)
+		if err != nil {
+			return nil, err
+		}
+		if effect == nil {
+			continue
+		}
+
+		switch chain.Strategy {
+		case model.RuleGroupHighest:
+			if len(planned) == 0 || effect.Priority > planned[0].effect.Priority {
+				planned = []plannedRule{{rule, *effect}}
+			}
+		case model.RuleGroupLowest:
+			if len(planned) == 0 || effect.Priority < planned[0].effect.Priority {
+				planned = []plannedRule{{rule, *effect}}
+			}
+		case model.RuleGroupCumulative:
+			planned = append(planned, plannedRule{rule, *effect})
+			applyPreview(&preview, *effect)
+		default:
+			return []plannedRule{{rule, *effect}}, nil
+		}
+	}
+	return planned, nil
+}
+
+// plan 计算规则对任务的影响，不写入任何数据；规则不匹配或对任务没有实际影响时返回nil
+// once为true时，含相对调整动作的规则对同一任务只执行一次，避免每次巡检重复提升或降低优先级
+func (e *RuleEngine) plan(ctx context.Context, task *model.Task, rule *model.PriorityAdjustRule, env *expr.Env, once bool) (*expr.Effect, error) {
+	if !expr.InScope(rule, task) {
+		return nil, nil
+	}
+
+	compiled, err := expr.Compile(rule)
+	if err != nil {
+		g.Log().Warningf(ctx, "编译规则失败，跳过: rule_id=%s, %v", rule.ID, err)
+		return nil, nil
+	}
+	if !compiled.Match(env) {
+		return nil, nil
+	}
+
+	effect := compiled.Apply(task)
+	// 老化提升过的任务不被绝对设置优先级的规则调回，否则规则与老化每次巡检反复调整并写入日志
+	if task.AgedAt != nil && !compiled.Relative() && effect.Priority < task.Priority {
+		effect.Priority = task.Priority
+	}
+	if !effect.Changed(task) {
+		return nil, nil
+	}
+
+	if once && compiled.Relative() {
+		done, err := e.repository.HasPriorityLog(ctx, task.ID, rule.ID)
+		if err != nil {
+			return nil, fmt.Errorf("查询调整日志失败: %v", err)
+		}
+		if done {
+			return nil, nil
+		}
+	}
+
+	return &effect, nil
+}
+
+// Apply 执行规则动作：切换AI驱动、添加标签、调整优先级、暂停或恢复任务，并写入调整日志
+// 所有写入在同一事务中完成，提交后才更新task并发布事件
+func (e *RuleEngine) Apply(ctx context.Context, task *model.Task, ruleID, reason string, effect expr.Effect) error {
+	_, err := e.apply(ctx, task, ruleID, reason, effect, nil)
+	return err
+}
+
+// apply 在事务中执行规则动作；guard不为nil时先在同一事务中执行，返回false表示调整已由其他巡检完成，不再写入
+func (e *RuleEngine) apply(ctx context.Context, task *model.Task, ruleID, reason string, effect expr.Effect, guard func(repository model.TaskRepository) (bool, error)) (bool, error) {
+	action := effect.Describe(task)
+	oldPriority := task.Priority
+	oldStatus := task.Status
+	updated := *task
+	applied := false
+
+	err := e.repository.Transaction(ctx, func(repository model.TaskRepository) error {
+		if guard != nil {
+			ok, err := guard(repository)
+			if err != nil || !ok {
+				return err
+			}
+		}
+
+		// 切换驱动与添加标签
+		if effect.Driver != "" || len(effect.Tags) > 0 {
+			driver := updated.Driver
+			if effect.Driver != "" {
+				driver = ai.DriverType(effect.Driver)
+			}
+			tags := append(append([]string(nil), updated.Tags...), effect.Tags...)
+			if err := repository.UpdateRouting(ctx, updated.ID, driver, tags); err != nil {
+				return fmt.Errorf("更新任务失败: %v", err)
+			}
+			updated.Driver = driver
+			updated.Tags = tags
+		}
+
+		// 调整优先级
+		if effect.Priority != oldPriority {
+			if err := repository.UpdatePriority(ctx, updated.ID, effect.Priority); err != nil {
+				return fmt.Errorf("更新任务优先级失败: %v", err)
+			}
+			updated.Priority = effect.Priority
+		}
+
+		// 暂停任务，排队中的消息在消费时被丢弃
+		if effect.Pause && updated.Status != model.TaskStatusPaused {
+			if err := repository.UpdateStatus(ctx, updated.ID, model.TaskStatusPaused); err != nil {
+				return fmt.Errorf("暂停任务失败: %v", err)
+			}
+			updated.Status = model.TaskStatusPaused
+		}
+
+		// 恢复任务，放回等待处理后重新投递
+		if effect.Resume && updated.Status == model.TaskStatusPaused {
+			if err := repository.UpdateStatus(ctx, updated.ID, model.TaskStatusPending); err != nil {
+				return fmt.Errorf("恢复任务失败: %v", err)
+			}
+			updated.Status = model.TaskStatusPending
+		}
+
+		// 创建调整日志
+		log := &model.PriorityAdjustLog{
+			ID:          uuid.New().String(),
+			TaskID:      updated.ID,
+			RuleID:      ruleID,
+			OldPriority: oldPriority,
+			NewPriority: updated.Priority,
+			Action:      action,
+			Reason:      reason,
+			CreatedAt:   time.Now(),
+		}
+		if err := repository.CreatePriorityLog(ctx, log); err != nil {
+			return fmt.Errorf("创建优先级调整日志失败: %v", err)
+		}
+		applied = true
+		return nil
+	})
+	if err != nil || !applied {
+		return false, err
+	}
+	*task = updated
+
+	if task.Priority != oldPriority {
+		event.TaskPriorityAdjusted(task, oldPriority, task.Priority, reason)
+		if e.onRaised != nil {
+			e.onRaised(ctx, task, oldPriority, task.Priority)
+		}
+	}
+	if task.Status != oldStatus {
+		event.TaskStatusChanged(task, task.Status, "")
+		if task.Status == model.TaskStatusPending && e.onResumed != nil {
+			e.onResumed(ctx, task)
+		}
+	}
+
+	g.Log().Infof(ctx, "规则已执行: task_id=%s, rule_id=%s, action=%s, reason=%s", task.ID, ruleID, action, reason)
+	return true, nil
+}
+
+// age 待处理任务自上次老化（未老化过时自创建）起等待超过老化步长时提升一级优先级
+// 不以更新时间起算，规则添加标签、切换驱动等更新不会重置等待时间
+func (e *RuleEngine) age(ctx context.Context, task *model.Task) error {
+	if e.aging.Step <= 0 || task.Status != model.TaskStatusPending || task.Priority >= e.aging.MaxPriority {
+		return nil
+	}
+
+	since := task.CreatedAt
+	if task.AgedAt != nil {
+		since = *task.AgedAt
+	}
+	waited := time.Since(since)
+	if waited < e.aging.Step {
+		return nil
+	}
+
+	reason := fmt.Sprintf("等待%d分钟未处理，老化提升优先级", int(waited.Minutes()))
+	prev := task.AgedAt
+	now := time.Now()
+	// 以老化时间未变为条件，同一任务的同一次老化只生效一次
+	applied, err := e.apply(ctx, task, model.AgingRuleID, reason, expr.Effect{Priority: task.Priority + 1}, func(repository model.TaskRepository) (bool, error) {
+		ok, err := repository.MarkAged(ctx, task.ID, prev, now)
+		if err != nil {
+			return false, fmt.Errorf("记录老化时间失败: %v", err)
+		}
+		return ok, nil
+	})
+	if err != nil {
+		return err
+	}
+	if applied {
+		task.AgedAt = &now
+	}
+	return nil
+}
@@ -0,0 +1,454 @@
+package scheduler
+
+import (
+	"ai-translate/internal/infrastructure/ai"
+	"ai-translate/internal/infrastructure/expr"
+	"ai-translate/internal/model"
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// fakeRepository 内存任务仓库，只实现规则引擎用到的方法，事务失败时回滚到事务开始前的任务与日志
+type fakeRepository struct {
+	model.TaskRepository
+
+	tasks   map[string]*model.Task
+	rules   []*model.PriorityAdjustRule
+	groups  []*model.RuleGroup
+	logs    []*model.PriorityAdjustLog
+	locked  bool  // 命名锁是否被其他实例持有
+	failErr error // 不为nil时写入调整日志失败
+}
+
+// newFakeRepository 创建内存仓库
+func newFakeRepository(tasks ...*model.Task) *fakeRepository {
+	r := &fakeRepository{tasks: make(map[string]*model.Task)}
+	for _, task := range tasks {
+		r.tasks[task.ID] = task
+	}
+	return r
+}
+
+// task 获取任务当前状态
+func (r *fakeRepository) task(id string) model.Task {
+	return *r.tasks[id]
+}
+
+// logRules 获取每条调整日志的规则ID
+func (r *fakeRepository) logRules() []string {
+	var result []string
+	for _, log := range r.logs {
+		result = append(result, log.TaskID+":"+log.RuleID)
+	}
+	return result
+}
+
+func (r *fakeRepository) Transaction(ctx context.Context, fn func(repository model.TaskRepository) error) error {
+	snapshot := make(map[string]model.Task, len(r.tasks))
+	for id, task := range r.tasks {
+		snapshot[id] = *task
+	}
+	logs := len(r.logs)
+
+	if err := fn(r); err != nil {
+		for id, task := range snapshot {
+			task := task
+			r.tasks[id] = &task
+		}
+		r.logs = r.logs[:logs]
+		return err
+	}
+	return nil
+}
+
+func (r *fakeRepository) TryLock(ctx context.Context, name string, fn func() error) (bool, error) {
+	if r.locked {
+		return false, nil
+	}
+	return true, fn()
+}
+
+// list 按ID升序返回满足条件且ID大于afterID的任务副本
+func (r *fakeRepository) list(afterID string, limit int, match func(task *model.Task) bool) []*model.Task {
+
+	var ids []string
+	for id, task := range r.tasks {
+		if id > afterID && match(task) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	if len(ids) > limit {
+		ids = ids[:limit]
+	}
+
+	result := make([]*model.Task, len(ids))
+	for i, id := range ids {
+		task := *r.tasks[id]
+		result[i] = &task
+	}
+	return result
+}
+
+func (r *fakeRepository) ListTasksByStatus(ctx context.Context, statuses []model.TaskStatus, afterID string, limit int) ([]*model.Task, error) {
+	return r.list(afterID, limit, func(task *model.Task) bool {
+		for _, status := range statuses {
+			if task.Status == status {
+				return true
+			}
+		}
+		return false
+	}), nil
+}
+
+func (r *fakeRepository) ListStartedTasks(ctx context.Context, since, until time.Time, afterID string, limit int) ([]*model.Task, error) {
+	return r.list(afterID, limit, func(task *model.Task) bool {
+		return task.StartedAt != nil && !task.CreatedAt.Before(since) && task.CreatedAt.Before(until)
+	}), nil
+}
+
+func (r *fakeRepository) ListPriorityRules(ctx context.Context, workID, batchID string, taskType model.TaskType, enabled bool, page, size int) ([]*model.PriorityAdjustRule, int64, error) {
+	var rules []*model.PriorityAdjustRule
+	for _, rule := range r.rules {
+		if rule.Enabled == enabled {
+			rules = append(rules, rule)
+		}
+	}
+	total := int64(len(rules))
+	start := (page - 1) * size
+	if start >= len(rules) {
+		return nil, total, nil
+	}
+	if end := start + size; end < len(rules) {
+		rules = rules[:end]
+	}
+	return rules[start:], total, nil
+}
+
+func (r *fakeRepository) ListRuleGroups(ctx context.Context, enabled bool, page, size int) ([]*model.RuleGroup, int64, error) {
+	var groups []*model.RuleGroup
+	for _, group := range r.groups {
+		if group.Enabled == enabled {
+			groups = append(groups, group)
+		}
+	}
+	if page > 1 {
+		return nil, int64(len(groups)), nil
+	}
+	return groups, int64(len(groups)), nil
+}
+
+func (r *fakeRepository) GetGroupRules(ctx context.Context, groupID string) ([]*model.PriorityAdjustRule, error) {
+	for _, group := range r.groups {
+		if group.ID != groupID {
+			continue
+		}
+		var rules []*model.PriorityAdjustRule
+		for _, id := range group.Rules {
+			for _, rule := range r.rules {
+				if rule.ID == id {
+					rules = append(rules, rule)
+				}
+			}
+		}
+		return rules, nil
+	}
+	return nil, errors.New("规则组不存在")
+}
+
+func (r *fakeRepository) HasPriorityLog(ctx context.Context, taskID, ruleID string) (bool, error) {
+	for _, log := range r.logs {
+		if log.TaskID == taskID && log.RuleID == ruleID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *fakeRepository) CreatePriorityLog(ctx context.Context, log *model.PriorityAdjustLog) error {
+	if r.failErr != nil {
+		return r.failErr
+	}
+	r.logs = append(r.logs, log)
+	return nil
+}
+
+func (r *fakeRepository) UpdateRouting(ctx context.Context, id string, driver ai.DriverType, tags []string) error {
+	r.tasks[id].Driver = driver
+	r.tasks[id].Tags = tags
+	return nil
+}
+
+func (r *fakeRepository) UpdatePriority(ctx context.Context, id string, priority model.TaskPriority) error {
+	r.tasks[id].Priority = priority
+	return nil
+}
+
+func (r *fakeRepository) UpdateStatus(ctx context.Context, id string, status model.TaskStatus) error {
+	r.tasks[id].Status = status
+	return nil
+}
+
+func (r *fakeRepository) MarkAged(ctx context.Context, id string, prev *time.Time, at time.Time) (bool, error) {
+	task := r.tasks[id]
+	if task.Status != model.TaskStatusPending {
+		return false, nil
+	}
+	if (task.AgedAt == nil) != (prev == nil) || (prev != nil && !task.AgedAt.Equal(*prev)) {
+		return false, nil
+	}
+	task.AgedAt = &at
+	return true, nil
+}
+
+// newTestEngine 创建使用内存仓库的规则引擎
+func newTestEngine(repository *fakeRepository, aging AgingPolicy) *RuleEngine {
+	return &RuleEngine{repository: repository, aging: aging, batchSize: 2}
+}
+
+func TestRuleEngineSweep(t *testing.T) {
+	now := time.Now()
+	pending := func(id string, priority model.TaskPriority, age time.Duration) *model.Task {
+		return &model.Task{
+			ID:        id,
+			Type:      model.TaskTypeTranslation,
+			Status:    model.TaskStatusPending,
+			Priority:  priority,
+			CreatedAt: now.Add(-age),
+			UpdatedAt: now.Add(-age),
+		}
+	}
+	rule := func(id, condition, action string, order int) *model.PriorityAdjustRule {
+		return &model.PriorityAdjustRule{ID: "sweep-" + id, Condition: condition, Action: action, Order: order, Enabled: true}
+	}
+
+	cases := []struct {
+		name       string
+		tasks      []*model.Task
+		rules      []*model.PriorityAdjustRule
+		groups     []*model.RuleGroup
+		logs       []*model.PriorityAdjustLog
+		aging      AgingPolicy
+		locked     bool
+		priorities map[string]model.TaskPriority
+		statuses   map[string]model.TaskStatus
+		logRules   []string
+	}{
+		{
+			name:  "独立规则按顺序只执行第一个生效的",
+			tasks: []*model.Task{pending("t1", model.TaskPriorityLow, time.Hour), pending("t2", model.TaskPriorityLow, time.Minute)},
+			rules: []*model.PriorityAdjustRule{
+				rule("b", `age_minutes > 30`, `set_priority(1)`, 2),
+				rule("a", `age_minutes > 30`, `set_priority(3)`, 1),
+			},
+			priorities: map[string]model.TaskPriority{"t1": model.TaskPriorityUrgent, "t2": model.TaskPriorityLow},
+			logRules:   []string{"t1:sweep-a"},
+		},
+		{
+			name:  "规则组取调整后优先级最高的规则",
+			tasks: []*model.Task{pending("t1", model.TaskPriorityLow, time.Hour)},
+			rules: []*model.PriorityAdjustRule{
+				rule("a", ``, `set_priority(1)`, 0),
+				rule("b", ``, `set_priority(2)`, 0),
+			},
+			groups: []*model.RuleGroup{
+				{ID: "g", Rules: []string{"sweep-a", "sweep-b"}, Strategy: model.RuleGroupHighest, Enabled: true},
+			},
+			priorities: map[string]model.TaskPriority{"t1": model.TaskPriorityHigh},
+			logRules:   []string{"t1:sweep-b"},
+		},
+		{
+			name:  "累计规则组依次执行所有生效的规则",
+			tasks: []*model.Task{pending("t1", model.TaskPriorityLow, time.Hour)},
+			rules: []*model.PriorityAdjustRule{
+				rule("a", ``, `raise_priority(); tag("slow")`, 0),
+				rule("b", `"slow" in tags`, `pause()`, 0),
+			},
+			groups: []*model.RuleGroup{
+				{ID: "g", Rules: []string{"sweep-a", "sweep-b"}, Strategy: model.RuleGroupCumulative, Enabled: true},
+			},
+			priorities: map[string]model.TaskPriority{"t1": model.TaskPriorityNormal},
+			statuses:   map[string]model.TaskStatus{"t1": model.TaskStatusPaused},
+			logRules:   []string{"t1:sweep-a", "t1:sweep-b"},
+		},
+		{
+			name:       "相对调整的规则对同一任务只执行一次",
+			tasks:      []*model.Task{pending("t1", model.TaskPriorityLow, time.Hour)},
+			rules:      []*model.PriorityAdjustRule{rule("a", ``, `raise_priority()`, 0)},
+			logs:       []*model.PriorityAdjustLog{{TaskID: "t1", RuleID: "sweep-a"}},
+			priorities: map[string]model.TaskPriority{"t1": model.TaskPriorityLow},
+			logRules:   []string{"t1:sweep-a"},
+		},
+		{
+			name: "没有规则生效时按等待时间老化，不超过最高老化优先级",
+			tasks: []*model.Task{
+				pending("t1", model.TaskPriorityLow, time.Hour),
+				pending("t2", model.TaskPriorityLow, time.Minute),
+				pending("t3", model.TaskPriorityHigh, time.Hour),
+			},
+			aging: AgingPolicy{Step: 30 * time.Minute, MaxPriority: model.TaskPriorityHigh},
+			priorities: map[string]model.TaskPriority{
+				"t1": model.TaskPriorityNormal,
+				"t2": model.TaskPriorityLow,
+				"t3": model.TaskPriorityHigh,
+			},
+			logRules: []string{"t1:" + model.AgingRuleID},
+		},
+		{
+			name:       "规则生效时不老化",
+			tasks:      []*model.Task{pending("t1", model.TaskPriorityLow, time.Hour)},
+			rules:      []*model.PriorityAdjustRule{rule("a", ``, `tag("seen")`, 0)},
+			aging:      AgingPolicy{Step: 30 * time.Minute, MaxPriority: model.TaskPriorityHigh},
+			priorities: map[string]model.TaskPriority{"t1": model.TaskPriorityLow},
+			logRules:   []string{"t1:sweep-a"},
+		},
+		{
+			name:       "其他实例正在巡检时跳过",
+			tasks:      []*model.Task{pending("t1", model.TaskPriorityLow, time.Hour)},
+			rules:      []*model.PriorityAdjustRule{rule("a", ``, `set_priority(3)`, 0)},
+			locked:     true,
+			priorities: map[string]model.TaskPriority{"t1": model.TaskPriorityLow},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			repository := newFakeRepository(c.tasks...)
+			repository.rules = c.rules
+			repository.groups = c.groups
+			repository.logs = c.logs
+			repository.locked = c.locked
+
+			if err := newTestEngine(repository, c.aging).Sweep(context.Background()); err != nil {
+				t.Fatalf("巡检失败: %v", err)
+			}
+			for id, priority := range c.priorities {
+				if got := repository.task(id).Priority; got != priority {
+					t.Errorf("任务 %s 优先级 = %d, 期望 %d", id, got, priority)
+				}
+			}
+			for id, status := range c.statuses {
+				if got := repository.task(id).Status; got != status {
+					t.Errorf("任务 %s 状态 = %s, 期望 %s", id, got, status)
+				}
+			}
+			if got := repository.logRules(); !reflect.DeepEqual(got, c.logRules) {
+				t.Errorf("调整日志 = %v, 期望 %v", got, c.logRules)
+			}
+		})
+	}
+}
+
+func TestRuleEngineApply(t *testing.T) {
+	cases := []struct {
+		name     string
+		status   model.TaskStatus
+		effect   string
+		failErr  error
+		priority model.TaskPriority
+		result   model.TaskStatus
+		raised   bool
+		resumed  bool
+		wantErr  bool
+	}{
+		{
+			name:     "提升优先级后回调",
+			status:   model.TaskStatusPending,
+			effect:   "raise",
+			priority: model.TaskPriorityHigh,
+			result:   model.TaskStatusPending,
+			raised:   true,
+		},
+		{
+			name:     "恢复已暂停的任务后回调",
+			status:   model.TaskStatusPaused,
+			effect:   "resume",
+			priority: model.TaskPriorityNormal,
+			result:   model.TaskStatusPending,
+			resumed:  true,
+		},
+		{
+			name:     "写入日志失败时回滚且不回调",
+			status:   model.TaskStatusPaused,
+			effect:   "resume",
+			failErr:  errors.New("数据库不可用"),
+			priority: model.TaskPriorityNormal,
+			result:   model.TaskStatusPaused,
+			wantErr:  true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			task := &model.Task{ID: "t1", Status: c.status, Priority: model.TaskPriorityNormal}
+			stored := *task
+			repository := newFakeRepository(&stored)
+			repository.failErr = c.failErr
+
+			engine := newTestEngine(repository, AgingPolicy{})
+			var raised, resumed bool
+			engine.OnPriorityRaised(func(ctx context.Context, task *model.Task, oldPriority, newPriority model.TaskPriority) {
+				raised = true
+			})
+			engine.OnResumed(func(ctx context.Context, task *model.Task) {
+				resumed = true
+			})
+
+			effect := expr.Effect{Priority: task.Priority}
+			switch c.effect {
+			case "raise":
+				effect.Priority = model.TaskPriorityHigh
+			case "resume":
+				effect.Resume = true
+			}
+
+			err := engine.Apply(context.Background(), task, "r1", "测试", effect)
+			if (err != nil) != c.wantErr {
+				t.Fatalf("错误 = %v, 期望失败 %v", err, c.wantErr)
+			}
+			if task.Priority != c.priority || task.Status != c.result {
+				t.Errorf("任务 = %d/%s, 期望 %d/%s", task.Priority, task.Status, c.priority, c.result)
+			}
+			if got := repository.task("t1"); got.Priority != c.priority || got.Status != c.result {
+				t.Errorf("已保存的任务 = %d/%s, 期望 %d/%s", got.Priority, got.Status, c.priority, c.result)
+			}
+			if raised != c.raised || resumed != c.resumed {
+				t.Errorf("回调 = %v/%v, 期望 %v/%v", raised, resumed, c.raised, c.resumed)
+			}
+		})
+	}
+}
+
+func TestRuleEngineAgeOnce(t *testing.T) {
+	created := time.Now().Add(-time.Hour)
+	task := &model.Task{ID: "t1", Status: model.TaskStatusPending, Priority: model.TaskPriorityLow, CreatedAt: created}
+	stored := *task
+	repository := newFakeRepository(&stored)
+	engine := newTestEngine(repository, AgingPolicy{Step: 30 * time.Minute, MaxPriority: model.TaskPriorityUrgent})
+
+	// 两次巡检读到的是同一份老化前的任务，只有第一次生效
+	stale := *task
+	if err := engine.age(context.Background(), task); err != nil {
+		t.Fatalf("老化失败: %v", err)
+	}
+	if err := engine.age(context.Background(), &stale); err != nil {
+		t.Fatalf("老化失败: %v", err)
+	}
+
+	if got := repository.task("t1").Priority; got != model.TaskPriorityNormal {
+		t.Errorf("优先级 = %d, 期望只提升一级", got)
+	}
+	if task.AgedAt == nil {
+		t.Error("期望记录老化时间")
+	}
+	if stale.Priority != model.TaskPriorityLow || stale.AgedAt != nil {
+		t.Errorf("未生效的老化不应修改任务，实际 %d/%v", stale.Priority, stale.AgedAt)
+	}
+	if got := repository.logRules(); !reflect.DeepEqual(got, []string{"t1:" + model.AgingRuleID}) {
+		t.Errorf("调整日志 = %v, 期望一条老化日志", got)
+	}
+}
@@ -0,0 +1,221 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"ai-translate/internal/infrastructure/ai"
+	"ai-translate/internal/infrastructure/expr"
+	"ai-translate/internal/model"
+)
+
+// SimulatedChange 模拟执行规则时单个任务的变化
+type SimulatedChange struct {
+	TaskID      string             `json:"task_id"`
+	RuleID      string             `json:"rule_id"`
+	OldPriority model.TaskPriority `json:"old_priority"`
+	NewPriority model.TaskPriority `json:"new_priority"`
+	Action      string             `json:"action"` // 将执行的动作
+}
+
+// ReplayChange 回放时单个任务的变化与估算的排队等待时长（秒）
+type ReplayChange struct {
+	SimulatedChange
+	WaitBefore float64 `json:"wait_before"` // 实际排队等待时长
+	WaitAfter  float64 `json:"wait_after"`  // 估算的排队等待时长
+}
+
+// ReplayResult 规则回放结果
+type ReplayResult struct {
+	Tasks         int             `json:"tasks"`           // 回放的任务数
+	Matched       int             `json:"matched"`         // 规则生效的任务数
+	AvgWaitBefore float64         `json:"avg_wait_before"` // 实际平均排队等待时长（秒）
+	AvgWaitAfter  float64         `json:"avg_wait_after"`  // 估算的平均排队等待时长（秒）
+	Changes       []*ReplayChange `json:"changes"`         // 规则生效的任务，最多limit条
+}
+
+// Simulate 对当前待处理与已暂停的任务模拟执行规则，不写入任何数据
+// chains中每组规则按其处理方式执行，与巡检时规则组的执行方式相同；最多返回limit条变化
+func (e *RuleEngine) Simulate(ctx context.Context, chains []RuleChain, limit int) ([]*SimulatedChange, error) {
+	var changes []*SimulatedChange
+	afterID := ""
+	for {
+		tasks, err := e.repository.ListTasksByStatus(ctx, sweepStatuses, afterID, e.batchSize)
+		if err != nil {
+			return nil, fmt.Errorf("获取任务失败: %v", err)
+		}
+
+		for _, task := range tasks {
+			change, err := e.simulate(ctx, task, chains, expr.NewEnv(task), true)
+			if err != nil {
+				return nil, err
+			}
+			if change == nil {
+				continue
+			}
+			changes = append(changes, change)
+			if limit > 0 && len(changes) >= limit {
+				return changes, nil
+			}
+		}
+
+		if len(tasks) < e.batchSize {
+			return changes, nil
+		}
+		afterID = tasks[len(tasks)-1].ID
+	}
+}
+
+// Replay 在指定时间段内创建并已开始处理的历史任务上回放规则，估算排队等待时长的变化，不写入任何数据
+// 任务按开始处理前的状态评估规则，条件中的等待时长以开始处理时间为准；
+// 优先级变化后的等待时长按同一时间段内同类型、同优先级任务的平均等待时长估算，提升优先级不会使等待变长，降低优先级不会使等待变短
+func (e *RuleEngine) Replay(ctx context.Context, chains []RuleChain, since, until time.Time, limit int) (*ReplayResult, error) {
+	// 第一遍统计各任务类型、各优先级的平均等待时长
+	type waitKey struct {
+		taskType model.TaskType
+		priority model.TaskPriority
+	}
+	type waitSum struct {
+		total float64
+		count int
+	}
+	sums := make(map[waitKey]*waitSum)
+	err := e.eachStarted(ctx, since, until, func(task *model.Task) error {
+		key := waitKey{task.Type, task.Priority}
+		if sums[key] == nil {
+			sums[key] = &waitSum{}
+		}
+		sums[key].total += queueWait(task)
+		sums[key].count++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 第二遍回放规则
+	result := &ReplayResult{}
+	var totalBefore, totalAfter float64
+	err = e.eachStarted(ctx, since, until, func(task *model.Task) error {
+		before := queueWait(task)
+		after := before
+
+		// 还原为开始处理前的状态
+		waiting := *task
+		waiting.Status = model.TaskStatusPending
+		waiting.Progress = 0
+		waiting.Result = ""
+		waiting.Error = ""
+		waiting.PartialResult = ""
+		waiting.StartedAt = nil
+		waiting.CompletedAt = nil
+
+		change, err := e.simulate(ctx, &waiting, chains, &expr.Env{Task: &waiting, Now: *task.StartedAt}, false)
+		if err != nil {
+			return err
+		}
+		if change != nil {
+			if sum := sums[waitKey{task.Type, change.NewPriority}]; sum != nil && change.NewPriority != change.OldPriority {
+				estimate := sum.total / float64(sum.count)
+				if (change.NewPriority > change.OldPriority && estimate < before) ||
+					(change.NewPriority < change.OldPriority && estimate > before) {
+					after = estimate
+				}
+			}
+			result.Matched++
+			if limit <= 0 || len(result.Changes) < limit {
+				result.Changes = append(result.Changes, &ReplayChange{SimulatedChange: *change, WaitBefore: before, WaitAfter: after})
+			}
+		}
+
+		result.Tasks++
+		totalBefore += before
+		totalAfter += after
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if result.Tasks > 0 {
+		result.AvgWaitBefore = totalBefore / float64(result.Tasks)
+		result.AvgWaitAfter = totalAfter / float64(result.Tasks)
+	}
+	return result, nil
+}
+
+// eachStarted 遍历指定时间段内创建且已开始处理的任务
+func (e *RuleEngine) eachStarted(ctx context.Context, since, until time.Time, fn func(task *model.Task) error) error {
+	afterID := ""
+	for {
+		tasks, err := e.repository.ListStartedTasks(ctx, since, until, afterID, e.batchSize)
+		if err != nil {
+			return fmt.Errorf("获取历史任务失败: %v", err)
+		}
+		for _, task := range tasks {
+			if err := fn(task); err != nil {
+				return err
+			}
+		}
+		if len(tasks) < e.batchSize {
+			return nil
+		}
+		afterID = tasks[len(tasks)-1].ID
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+	}
+}
+
+// simulate 在任务副本上依次执行每组规则，返回累计的变化，没有变化时返回nil
+func (e *RuleEngine) simulate(ctx context.Context, task *model.Task, chains []RuleChain, env *expr.Env, once bool) (*SimulatedChange, error) {
+	preview := *task
+	env.Task = &preview
+
+	var change *SimulatedChange
+	for _, chain := range chains {
+		planned, err := e.resolve(ctx, &preview, chain, env, once)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, p := range planned {
+			if change == nil {
+				change = &SimulatedChange{TaskID: task.ID, RuleID: p.rule.ID, OldPriority: task.Priority}
+			} else {
+				change.RuleID += "," + p.rule.ID
+				change.Action += "; "
+			}
+			change.Action += p.effect.Describe(&preview)
+			applyPreview(&preview, p.effect)
+		}
+	}
+
+	if change != nil {
+		change.NewPriority = preview.Priority
+	}
+	return change, nil
+}
+
+// applyPreview 在任务副本上应用规则动作
+func applyPreview(task *model.Task, effect expr.Effect) {
+	task.Priority = effect.Priority
+	if effect.Pause {
+		task.Status = model.TaskStatusPaused
+	}
+	if effect.Resume && task.Status == model.TaskStatusPaused {
+		task.Status = model.TaskStatusPending
+	}
+	if effect.Driver != "" {
+		task.Driver = ai.DriverType(effect.Driver)
+	}
+	task.Tags = append(append([]string(nil), task.Tags...), effect.Tags...)
+}
+
+// queueWait 任务从创建到开始处理的排队等待时长（秒）
+func queueWait(task *model.Task) float64 {
+	return task.StartedAt.Sub(task.CreatedAt).Seconds()
+}
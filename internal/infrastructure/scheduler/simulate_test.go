@@ -0,0 +1,183 @@
+package scheduler
+
+import (
+	"ai-translate/internal/model"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRuleEngineSimulate(t *testing.T) {
+	now := time.Now()
+	task := func(id string, status model.TaskStatus, priority model.TaskPriority) *model.Task {
+		return &model.Task{ID: id, Type: model.TaskTypeTranslation, Status: status, Priority: priority, CreatedAt: now.Add(-time.Hour)}
+	}
+	rule := func(id, condition, action string) *model.PriorityAdjustRule {
+		return &model.PriorityAdjustRule{ID: "simulate-" + id, Condition: condition, Action: action, Enabled: true}
+	}
+
+	cases := []struct {
+		name    string
+		chains  []RuleChain
+		logs    []*model.PriorityAdjustLog
+		limit   int
+		changes []SimulatedChange
+	}{
+		{
+			name: "多组规则的变化累计到同一任务",
+			chains: []RuleChain{
+				{Strategy: model.RuleGroupFirstMatch, Rules: []*model.PriorityAdjustRule{rule("a", `priority == 0`, `set_priority(1)`)}},
+				{Strategy: model.RuleGroupFirstMatch, Rules: []*model.PriorityAdjustRule{rule("b", `priority == 1`, `raise_priority(); tag("x")`)}},
+			},
+			changes: []SimulatedChange{
+				{TaskID: "t1", RuleID: "simulate-a,simulate-b", OldPriority: 0, NewPriority: 2, Action: `set_priority(1); set_priority(2); tag("x")`},
+				{TaskID: "t2", RuleID: "simulate-b", OldPriority: 1, NewPriority: 2, Action: `set_priority(2); tag("x")`},
+			},
+		},
+		{
+			name: "已执行过的相对调整规则不再模拟",
+			chains: []RuleChain{
+				{Strategy: model.RuleGroupFirstMatch, Rules: []*model.PriorityAdjustRule{rule("a", ``, `raise_priority()`)}},
+			},
+			logs: []*model.PriorityAdjustLog{{TaskID: "t1", RuleID: "simulate-a"}},
+			changes: []SimulatedChange{
+				{TaskID: "t2", RuleID: "simulate-a", OldPriority: 1, NewPriority: 2, Action: `set_priority(2)`},
+				{TaskID: "t3", RuleID: "simulate-a", OldPriority: 2, NewPriority: 3, Action: `set_priority(3)`},
+			},
+		},
+		{
+			name: "恢复已暂停的任务",
+			chains: []RuleChain{
+				{Strategy: model.RuleGroupFirstMatch, Rules: []*model.PriorityAdjustRule{rule("a", `status == "paused"`, `resume()`)}},
+			},
+			changes: []SimulatedChange{
+				{TaskID: "t3", RuleID: "simulate-a", OldPriority: 2, NewPriority: 2, Action: `resume()`},
+			},
+		},
+		{
+			name: "最多返回limit条变化",
+			chains: []RuleChain{
+				{Strategy: model.RuleGroupFirstMatch, Rules: []*model.PriorityAdjustRule{rule("a", ``, `tag("x")`)}},
+			},
+			limit: 1,
+			changes: []SimulatedChange{
+				{TaskID: "t1", RuleID: "simulate-a", Action: `tag("x")`},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			repository := newFakeRepository(
+				task("t1", model.TaskStatusPending, model.TaskPriorityLow),
+				task("t2", model.TaskStatusPending, model.TaskPriorityNormal),
+				task("t3", model.TaskStatusPaused, model.TaskPriorityHigh),
+				task("t4", model.TaskStatusRunning, model.TaskPriorityLow),
+			)
+			repository.logs = c.logs
+
+			changes, err := newTestEngine(repository, AgingPolicy{}).Simulate(context.Background(), c.chains, c.limit)
+			if err != nil {
+				t.Fatalf("模拟失败: %v", err)
+			}
+			if len(changes) != len(c.changes) {
+				t.Fatalf("变化数 = %d, 期望 %d: %+v", len(changes), len(c.changes), changes)
+			}
+			for i, change := range changes {
+				if *change != c.changes[i] {
+					t.Errorf("第%d条变化 = %+v, 期望 %+v", i+1, *change, c.changes[i])
+				}
+			}
+			if got := repository.task("t1"); got.Priority != model.TaskPriorityLow || len(got.Tags) != 0 {
+				t.Errorf("模拟不应修改任务，实际 %+v", got)
+			}
+		})
+	}
+}
+
+func TestRuleEngineReplay(t *testing.T) {
+	until := time.Now()
+	since := until.Add(-24 * time.Hour)
+	started := func(id string, priority model.TaskPriority, created time.Time, wait time.Duration) *model.Task {
+		startedAt := created.Add(wait)
+		return &model.Task{
+			ID:        id,
+			Type:      model.TaskTypeTranslation,
+			Status:    model.TaskStatusCompleted,
+			Priority:  priority,
+			CreatedAt: created,
+			StartedAt: &startedAt,
+		}
+	}
+
+	cases := []struct {
+		name       string
+		action     string
+		limit      int
+		matched    int
+		changes    int
+		avgBefore  float64
+		avgAfter   float64
+		waitsAfter map[string]float64
+	}{
+		{
+			name:       "提升优先级按同优先级任务的平均等待估算",
+			action:     `set_priority(2)`,
+			matched:    2,
+			changes:    2,
+			avgBefore:  (600 + 300 + 60) / 3.0,
+			avgAfter:   (60 + 60 + 60) / 3.0,
+			waitsAfter: map[string]float64{"t1": 60, "t2": 60},
+		},
+		{
+			name:       "降低优先级不会使等待变短",
+			action:     `set_priority(0)`,
+			matched:    1,
+			changes:    1,
+			avgBefore:  (600 + 300 + 60) / 3.0,
+			avgAfter:   (600 + 300 + 450) / 3.0,
+			waitsAfter: map[string]float64{"t3": 450},
+		},
+		{
+			name:      "只返回limit条变化但统计全部任务",
+			action:    `set_priority(2)`,
+			limit:     1,
+			matched:   2,
+			changes:   1,
+			avgBefore: (600 + 300 + 60) / 3.0,
+			avgAfter:  (60 + 60 + 60) / 3.0,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			repository := newFakeRepository(
+				started("t1", model.TaskPriorityLow, since.Add(time.Hour), 600*time.Second),
+				started("t2", model.TaskPriorityLow, since.Add(2*time.Hour), 300*time.Second),
+				started("t3", model.TaskPriorityHigh, since.Add(3*time.Hour), 60*time.Second),
+				started("t4", model.TaskPriorityLow, since.Add(-time.Hour), time.Hour),
+				&model.Task{ID: "t5", Status: model.TaskStatusPending, CreatedAt: since.Add(time.Hour)},
+			)
+			chains := []RuleChain{{
+				Strategy: model.RuleGroupFirstMatch,
+				Rules:    []*model.PriorityAdjustRule{{ID: "replay-a", Action: c.action, Enabled: true}},
+			}}
+
+			result, err := newTestEngine(repository, AgingPolicy{}).Replay(context.Background(), chains, since, until, c.limit)
+			if err != nil {
+				t.Fatalf("回放失败: %v", err)
+			}
+			if result.Tasks != 3 || result.Matched != c.matched || len(result.Changes) != c.changes {
+				t.Errorf("任务数/生效数/变化数 = %d/%d/%d, 期望 3/%d/%d", result.Tasks, result.Matched, len(result.Changes), c.matched, c.changes)
+			}
+			if result.AvgWaitBefore != c.avgBefore || result.AvgWaitAfter != c.avgAfter {
+				t.Errorf("平均等待 = %.1f/%.1f, 期望 %.1f/%.1f", result.AvgWaitBefore, result.AvgWaitAfter, c.avgBefore, c.avgAfter)
+			}
+			for _, change := range result.Changes {
+				if want, ok := c.waitsAfter[change.TaskID]; ok && change.WaitAfter != want {
+					t.Errorf("任务 %s 估算等待 = %.1f, 期望 %.1f", change.TaskID, change.WaitAfter, want)
+				}
+			}
+		})
+	}
+}
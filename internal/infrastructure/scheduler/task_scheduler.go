@@ -3,37 +3,63 @@ package scheduler
 import (
 	"context"
 	"fmt"
+	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 	"github.com/gogf/gf/v2/frame/g"
 	"ai-translate/internal/infrastructure/ai"
+	"ai-translate/internal/infrastructure/event"
 	"ai-translate/internal/infrastructure/queue"
+	"ai-translate/internal/infrastructure/translator"
 	"ai-translate/internal/model"
 )
 
 // TaskScheduler 任务调度器
 type TaskScheduler struct {
-	queue      queue.Queue
-	repository model.TaskRepository
-	aiDrivers  map[ai.DriverType]ai.AIService
-	workers    int
-	maxRetries int
-	stopCh     chan struct{}
-	wg         sync.WaitGroup
+	queue        queue.Queue
+	repository   model.TaskRepository
+	aiDrivers    *ai.Registry
+	workers      int
+	retryPolicy  ai.RetryPolicy
+	rules        *RuleEngine
+	ruleInterval time.Duration
+	instanceID   string        // 实例标识，与工作协程编号组成租约持有者
+	lease        time.Duration // 领取任务的租约时长
+	consumerSeq  int64         // 队列消费者领取任务的序号，与实例标识组成租约持有者
+	reapInterval time.Duration // 回收卡住任务的间隔
+	onRequeued   func(ctx context.Context, task *model.Task)
+	stopCh       chan struct{}
+	wg           sync.WaitGroup
 }
 
 // NewTaskScheduler 创建任务调度器
-func NewTaskScheduler(queue queue.Queue, repository model.TaskRepository, aiDrivers map[ai.DriverType]ai.AIService) (*TaskScheduler, error) {
-	workers := g.Cfg().MustGet("queue.worker.numWorkers").Int()
-	maxRetries := g.Cfg().MustGet("queue.worker.maxRetries").Int()
+func NewTaskScheduler(queue queue.Queue, repository model.TaskRepository, aiDrivers *ai.Registry) (*TaskScheduler, error) {
+	workers := g.Cfg().MustGet(context.Background(), "queue.worker.numWorkers").Int()
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+
+	// 租约时长不大于0时任务领取后立即过期，使用默认值
+	lease := time.Duration(g.Cfg().MustGet(context.Background(), "scheduler.lease.duration", 60).Int()) * time.Second
+	if lease <= 0 {
+		lease = time.Minute
+	}
 
 	return &TaskScheduler{
-		queue:      queue,
-		repository: repository,
-		aiDrivers:  aiDrivers,
-		workers:    workers,
-		maxRetries: maxRetries,
-		stopCh:     make(chan struct{}),
+		queue:        queue,
+		repository:   repository,
+		aiDrivers:    aiDrivers,
+		workers:      workers,
+		retryPolicy:  ai.NewRetryPolicy(context.Background()),
+		rules:        NewRuleEngine(context.Background(), repository),
+		ruleInterval: time.Duration(g.Cfg().MustGet(context.Background(), "scheduler.rules.interval", 60).Int()) * time.Second,
+		instanceID:   fmt.Sprintf("%s-%d", hostname, os.Getpid()),
+		lease:        lease,
+		reapInterval: time.Duration(g.Cfg().MustGet(context.Background(), "scheduler.reaper.interval", 30).Int()) * time.Second,
+		stopCh:       make(chan struct{}),
 	}, nil
 }
 
@@ -45,13 +71,22 @@ func (s *TaskScheduler) Start(ctx context.Context) error {
 		go s.worker(ctx, i)
 	}
 
-	// 启动监控协程
+	return s.StartMonitor(ctx)
+}
+
+// StartMonitor 只启动监控协程（任务统计与规则巡检），用于任务由队列消费者处理的部署方式
+func (s *TaskScheduler) StartMonitor(ctx context.Context) error {
 	s.wg.Add(1)
 	go s.monitor(ctx)
 
 	return nil
 }
 
+// Rules 获取规则引擎
+func (s *TaskScheduler) Rules() *RuleEngine {
+	return s.rules
+}
+
 // Stop 停止调度器
 func (s *TaskScheduler) Stop() {
 	close(s.stopCh)
@@ -62,83 +97,155 @@ func (s *TaskScheduler) Stop() {
 func (s *TaskScheduler) worker(ctx context.Context, id int) {
 	defer s.wg.Done()
 
+	owner := fmt.Sprintf("%s-%d", s.instanceID, id)
 	for {
 		select {
 		case <-s.stopCh:
 			return
 		default:
-			// 获取待处理任务
-			tasks, err := s.repository.GetPendingTasks(ctx, 1)
+			// 领取待处理任务，任务同时被置为处理中并持有租约
+			task, err := s.repository.ClaimTask(ctx, owner, s.lease)
 			if err != nil {
-				g.Log().Errorf(ctx, "获取待处理任务失败: %v", err)
+				g.Log().Errorf(ctx, "领取待处理任务失败: %v", err)
 				time.Sleep(time.Second)
 				continue
 			}
 
-			if len(tasks) == 0 {
+			if task == nil {
 				time.Sleep(time.Second)
 				continue
 			}
+			event.TaskStatusChanged(task, model.TaskStatusRunning, "")
 
-			task := tasks[0]
+			// 处理任务，超过任务类型的执行时限时取消；处理期间定期续租，租约失效时任务已被回收，不再写入处理结果
+			taskCtx, cancel := WithDeadline(ctx, s.repository, task)
+			stopHeartbeat := s.KeepLease(ctx, task.ID, owner, cancel)
+			result, err := s.processTask(taskCtx, task)
+			err = TimeoutError(taskCtx, task, err)
+			lost := stopHeartbeat()
+			cancel()
+			if lost {
+				g.Log().Warningf(ctx, "任务租约已失效，放弃处理结果: %s", task.ID)
+				continue
+			}
+			if err != nil {
+				g.Log().Errorf(ctx, "处理任务失败: %v", err)
+				s.handleFailure(ctx, task, err)
+				continue
+			}
 
-			// 更新任务状态为处理中
-			if err := s.repository.UpdateStatus(ctx, task.ID, model.TaskStatusRunning); err != nil {
-				g.Log().Errorf(ctx, "更新任务状态失败: %v", err)
+			// 保存结果并标记任务完成，以租约仍属于本工作协程为条件，停止续租后租约被回收的结果同样丢弃
+			saved, err := s.repository.SaveTaskResult(ctx, task, model.TaskOutput{Result: result, LeaseOwner: owner})
+			if err != nil {
+				g.Log().Errorf(ctx, "保存任务结果失败: %s, %v", task.ID, err)
+				continue
+			}
+			if !saved {
+				g.Log().Warningf(ctx, "任务租约已失效，放弃处理结果: %s", task.ID)
 				continue
 			}
+			event.TaskStatusChanged(task, model.TaskStatusCompleted, "")
+		}
+	}
+}
 
-			// 处理任务
-			if err := s.processTask(ctx, task); err != nil {
-				g.Log().Errorf(ctx, "处理任务失败: %v", err)
-				// 增加重试次数
-				if err := s.repository.IncrementRetryCount(ctx, task.ID); err != nil {
-					g.Log().Errorf(ctx, "增加重试次数失败: %v", err)
+// ClaimByID 队列消费者领取消息对应的任务并持有租约，任务已被其他工作协程持有或无需处理时返回nil
+// 返回的owner用于续租与保存结果
+func (s *TaskScheduler) ClaimByID(ctx context.Context, taskID string) (*model.Task, string, error) {
+	owner := fmt.Sprintf("%s-c%d", s.instanceID, atomic.AddInt64(&s.consumerSeq, 1))
+	task, err := s.repository.ClaimTaskByID(ctx, taskID, owner, s.lease)
+	if err != nil {
+		return nil, "", err
+	}
+	return task, owner, nil
+}
+
+// KeepLease 按租约时长的三分之一定期续租，租约已被回收时调用cancel中止任务处理
+// 返回的函数停止续租并报告租约是否已失效
+func (s *TaskScheduler) KeepLease(ctx context.Context, taskID, owner string, cancel context.CancelFunc) func() bool {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	lost := false
+
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(s.lease / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				renewed, err := s.repository.RenewLease(ctx, taskID, owner, s.lease)
+				if err != nil {
+					// 续租失败时继续尝试，租约在到期前仍然有效
+					g.Log().Warningf(ctx, "任务续租失败: %s, %v", taskID, err)
+					continue
 				}
-				// 更新任务状态为失败
-				if err := s.repository.UpdateStatus(ctx, task.ID, model.TaskStatusFailed); err != nil {
-					g.Log().Errorf(ctx, "更新任务状态失败: %v", err)
+				if !renewed {
+					lost = true
+					cancel()
+					return
 				}
-				continue
 			}
+		}
+	}()
 
-			// 更新任务状态为已完成
-			if err := s.repository.UpdateStatus(ctx, task.ID, model.TaskStatusCompleted); err != nil {
-				g.Log().Errorf(ctx, "更新任务状态失败: %v", err)
-			}
+	return func() bool {
+		close(done)
+		<-stopped
+		return lost
+	}
+}
+
+// handleFailure 处理任务失败：可重试错误按退避策略安排重试，不可重试错误或重试次数用尽时直接失败
+func (s *TaskScheduler) handleFailure(ctx context.Context, task *model.Task, err error) {
+	if ai.ShouldRetry(err, task.RetryCount, task.MaxRetries) {
+		delay := s.retryPolicy.Delay(task.RetryCount, err)
+		if err := s.repository.ScheduleRetry(ctx, task.ID, err.Error(), time.Now().Add(delay)); err != nil {
+			g.Log().Errorf(ctx, "安排任务重试失败: %v", err)
 		}
+		g.Log().Infof(ctx, "任务将在%s后重试: %s, 第%d次", delay, task.ID, task.RetryCount+1)
+		event.TaskStatusChanged(task, model.TaskStatusPending, err.Error())
+		return
 	}
+
+	if err := s.repository.MarkFailed(ctx, task.ID, err.Error()); err != nil {
+		g.Log().Errorf(ctx, "更新任务状态失败: %v", err)
+	}
+	event.TaskStatusChanged(task, model.TaskStatusFailed, err.Error())
 }
 
-// processTask 处理任务
-func (s *TaskScheduler) processTask(ctx context.Context, task *model.Task) error {
+// processTask 处理任务并返回结果，结果由调用方在确认租约后写入
+func (s *TaskScheduler) processTask(ctx context.Context, task *model.Task) (string, error) {
 	// 获取AI驱动
-	aiService, ok := s.aiDrivers[task.Driver]
-	if !ok {
-		return fmt.Errorf("不支持的AI驱动类型: %s", task.Driver)
+	aiService, err := s.aiDrivers.Get(task.Driver)
+	if err != nil {
+		return "", err
 	}
 
 	var result string
-	var err error
+
+	// 处理中的进度与部分结果按translator.ProgressInterval限频写入任务
+	update := func(progress translator.Progress) {
+		if err := s.repository.UpdateProgress(ctx, task.ID, progress.Percent(), progress.Partial); err != nil {
+			g.Log().Warningf(ctx, "更新任务进度失败: %s, %v", task.ID, err)
+		}
+		event.TaskProgressed(task, progress.Percent())
+	}
 
 	// 根据任务类型处理
 	switch task.Type {
 	case model.TaskTypeContentGeneration:
-		result, err = aiService.GenerateContent(ctx, task.Content, task.Language)
+		result, err = aiService.GenerateContentStream(ctx, task.Content, task.Language, translator.StreamProgress(update, translator.ProgressInterval))
 	case model.TaskTypeTranslation:
-		result, err = aiService.Translate(ctx, task.Content, task.SourceLang, task.TargetLang)
+		config := translator.NewConfig(ctx, string(s.aiDrivers.Resolve(task.Driver)))
+		report := translator.ThrottleProgress(update, translator.ProgressInterval)
+		result, err = translator.TranslateContent(ctx, aiService, config, task.Content, task.SourceLang, task.TargetLang, report)
 	default:
-		return fmt.Errorf("不支持的任务类型: %s", task.Type)
-	}
-
-	if err != nil {
-		return err
+		return "", fmt.Errorf("不支持的任务类型: %s", task.Type)
 	}
-
-	// 更新任务结果
-	task.Result = result
-	task.CompletedAt = &time.Time{}
-	return s.repository.Update(ctx, task)
+	return result, err
 }
 
 // monitor 监控协程
@@ -148,10 +255,32 @@ func (s *TaskScheduler) monitor(ctx context.Context) {
 	ticker := time.NewTicker(time.Minute)
 	defer ticker.Stop()
 
+	// 回收租约过期或超过截止时间的任务，间隔不大于0时不回收
+	var reapTick <-chan time.Time
+	if s.reapInterval > 0 {
+		reapTicker := time.NewTicker(s.reapInterval)
+		defer reapTicker.Stop()
+		reapTick = reapTicker.C
+	}
+
+	// 规则巡检，间隔不大于0时不巡检
+	var ruleTick <-chan time.Time
+	if s.ruleInterval > 0 {
+		ruleTicker := time.NewTicker(s.ruleInterval)
+		defer ruleTicker.Stop()
+		ruleTick = ruleTicker.C
+	}
+
 	for {
 		select {
 		case <-s.stopCh:
 			return
+		case <-reapTick:
+			s.reapStuckTasks(ctx)
+		case <-ruleTick:
+			if err := s.rules.Sweep(ctx); err != nil {
+				g.Log().Errorf(ctx, "规则巡检失败: %v", err)
+			}
 		case <-ticker.C:
 			// 获取任务统计
 			stats, err := s.repository.GetStats(ctx, "")
@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"bytes"
 	"context"
 	"github.com/aliyun/aliyun-oss-go-sdk/oss"
 	"github.com/gogf/gf/v2/frame/g"
@@ -8,14 +9,15 @@ import (
 	"time"
 )
 
-type ossService struct {
+// OSSService OSS存储服务
+type OSSService struct {
 	client     *oss.Client
 	bucket     *oss.Bucket
 	bucketName string
 }
 
 // NewOSSService 创建OSS存储服务实例
-func NewOSSService() (*ossService, error) {
+func NewOSSService() (*OSSService, error) {
 	endpoint := g.Cfg().MustGet(context.Background(), "oss.endpoint").String()
 	accessKeyID := g.Cfg().MustGet(context.Background(), "oss.accessKeyId").String()
 	accessKeySecret := g.Cfg().MustGet(context.Background(), "oss.accessKeySecret").String()
@@ -31,7 +33,7 @@ func NewOSSService() (*ossService, error) {
 		return nil, err
 	}
 
-	return &ossService{
+	return &OSSService{
 		client:     client,
 		bucket:     bucket,
 		bucketName: bucketName,
@@ -39,41 +41,39 @@ func NewOSSService() (*ossService, error) {
 }
 
 // UploadFile 上传文件到OSS
-func (s *ossService) UploadFile(objectKey string, filePath string) (string, error) {
+func (s *OSSService) UploadFile(objectKey string, filePath string) (string, error) {
 	err := s.bucket.PutObjectFromFile(objectKey, filePath)
 	if err != nil {
 		return "", err
 	}
 
 	// 生成文件访问URL
-	url := s.bucket.SignURL(objectKey, oss.HTTPGet, time.Hour*24*365)
-	return url, nil
+	return s.GetFileURL(objectKey)
 }
 
 // UploadContent 上传内容到OSS
-func (s *ossService) UploadContent(objectKey string, content []byte) (string, error) {
-	err := s.bucket.PutObject(objectKey, content)
+func (s *OSSService) UploadContent(objectKey string, content []byte) (string, error) {
+	err := s.bucket.PutObject(objectKey, bytes.NewReader(content))
 	if err != nil {
 		return "", err
 	}
 
 	// 生成文件访问URL
-	url := s.bucket.SignURL(objectKey, oss.HTTPGet, time.Hour*24*365)
-	return url, nil
+	return s.GetFileURL(objectKey)
 }
 
 // DeleteFile 从OSS删除文件
-func (s *ossService) DeleteFile(objectKey string) error {
+func (s *OSSService) DeleteFile(objectKey string) error {
 	return s.bucket.DeleteObject(objectKey)
 }
 
-// GetFileURL 获取文件访问URL
-func (s *ossService) GetFileURL(objectKey string) string {
-	return s.bucket.SignURL(objectKey, oss.HTTPGet, time.Hour*24*365)
+// GetFileURL 获取文件访问URL，签名有效期一年
+func (s *OSSService) GetFileURL(objectKey string) (string, error) {
+	return s.bucket.SignURL(objectKey, oss.HTTPGet, int64((time.Hour * 24 * 365).Seconds()))
 }
 
 // GenerateObjectKey 生成对象键
-func (s *ossService) GenerateObjectKey(prefix string, filename string) string {
+func (s *OSSService) GenerateObjectKey(prefix string, filename string) string {
 	ext := path.Ext(filename)
 	return prefix + "/" + time.Now().Format("2006/01/02") + "/" + filename + ext
 } 
\ No newline at end of file
@@ -0,0 +1,63 @@
+package subtitle
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParseSRT 解析SRT字幕
+func ParseSRT(data []byte) (*Document, error) {
+	doc := &Document{Format: FormatSRT}
+
+	for _, block := range splitBlocks(data) {
+		lines := block
+
+		// 序号行可省略，时间行必须存在
+		if !strings.Contains(lines[0], "-->") {
+			if len(lines) < 2 {
+				return nil, fmt.Errorf("第%d条字幕缺少时间行", len(doc.Cues)+1)
+			}
+			if _, err := strconv.Atoi(strings.TrimSpace(lines[0])); err != nil {
+				return nil, fmt.Errorf("第%d条字幕序号无效: %s", len(doc.Cues)+1, lines[0])
+			}
+			lines = lines[1:]
+		}
+
+		start, end, _, err := parseTiming(lines[0])
+		if err != nil {
+			return nil, fmt.Errorf("第%d条字幕解析失败: %v", len(doc.Cues)+1, err)
+		}
+
+		doc.Cues = append(doc.Cues, &Cue{
+			Index: len(doc.Cues) + 1,
+			Start: start,
+			End:   end,
+			Text:  strings.Join(lines[1:], "\n"),
+		})
+	}
+
+	if len(doc.Cues) == 0 {
+		return nil, fmt.Errorf("未解析到字幕条目")
+	}
+
+	return doc, nil
+}
+
+// WriteSRT 输出SRT字幕，序号按顺序重新编排
+func WriteSRT(doc *Document) []byte {
+	var buf bytes.Buffer
+	for i, cue := range doc.Cues {
+		if i > 0 {
+			buf.WriteString("\n")
+		}
+		fmt.Fprintf(&buf, "%d\n", i+1)
+		fmt.Fprintf(&buf, "%s --> %s\n", formatTimestamp(cue.Start, ","), formatTimestamp(cue.End, ","))
+		if text := normalizeText(cue.Text); text != "" {
+			buf.WriteString(text)
+			buf.WriteString("\n")
+		}
+	}
+	return buf.Bytes()
+}
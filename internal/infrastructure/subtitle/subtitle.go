@@ -0,0 +1,225 @@
+package subtitle
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// utf8BOM UTF-8字节顺序标记
+var utf8BOM = []byte("\xef\xbb\xbf")
+
+// Format 字幕格式
+type Format string
+
+const (
+	FormatSRT    Format = "srt"    // SubRip
+	FormatWebVTT Format = "webvtt" // WebVTT
+)
+
+// Cue 字幕条目
+type Cue struct {
+	Index    int           `json:"index"`    // 序号（从1开始）
+	ID       string        `json:"id"`       // WebVTT条目标识，SRT为空
+	Start    time.Duration `json:"start"`    // 开始时间
+	End      time.Duration `json:"end"`      // 结束时间
+	Settings string        `json:"settings"` // WebVTT条目设置，如 "align:start"
+	Text     string        `json:"text"`     // 字幕文本，多行以\n分隔
+}
+
+// Document 字幕文档
+type Document struct {
+	Format Format `json:"format"` // 源格式
+	Header string `json:"header"` // WebVTT文件头（WEBVTT之后的内容及NOTE/STYLE块）
+	Cues   []*Cue `json:"cues"`   // 字幕条目
+}
+
+// DetectFormat 根据内容判断字幕格式
+func DetectFormat(data []byte) Format {
+	data = bytes.TrimPrefix(data, utf8BOM)
+	data = bytes.TrimLeft(data, " \t\r\n")
+	if bytes.HasPrefix(data, []byte("WEBVTT")) {
+		return FormatWebVTT
+	}
+	return FormatSRT
+}
+
+// Parse 解析字幕内容，自动识别格式
+func Parse(data []byte) (*Document, error) {
+	switch DetectFormat(data) {
+	case FormatWebVTT:
+		return ParseWebVTT(data)
+	default:
+		return ParseSRT(data)
+	}
+}
+
+// Write 按指定格式输出字幕内容
+func Write(doc *Document, format Format) ([]byte, error) {
+	switch format {
+	case FormatSRT:
+		return WriteSRT(doc), nil
+	case FormatWebVTT:
+		return WriteWebVTT(doc), nil
+	default:
+		return nil, fmt.Errorf("不支持的字幕格式: %s", format)
+	}
+}
+
+// Texts 获取所有条目文本
+func (d *Document) Texts() []string {
+	texts := make([]string, len(d.Cues))
+	for i, cue := range d.Cues {
+		texts[i] = cue.Text
+	}
+	return texts
+}
+
+// WithTexts 保留时间轴，仅替换条目文本，返回新文档
+func (d *Document) WithTexts(texts []string) (*Document, error) {
+	if len(texts) != len(d.Cues) {
+		return nil, fmt.Errorf("条目数量不一致: 源%d条, 译文%d条", len(d.Cues), len(texts))
+	}
+
+	cues := make([]*Cue, len(d.Cues))
+	for i, cue := range d.Cues {
+		c := *cue
+		c.Text = normalizeText(texts[i])
+		cues[i] = &c
+	}
+
+	return &Document{
+		Format: d.Format,
+		Header: d.Header,
+		Cues:   cues,
+	}, nil
+}
+
+// splitBlocks 按空行切分字幕块
+func splitBlocks(data []byte) [][]string {
+	content := string(bytes.TrimPrefix(data, utf8BOM))
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	content = strings.ReplaceAll(content, "\r", "\n")
+
+	var blocks [][]string
+	var current []string
+	for _, line := range strings.Split(content, "\n") {
+		if strings.TrimSpace(line) == "" {
+			if len(current) > 0 {
+				blocks = append(blocks, current)
+				current = nil
+			}
+			continue
+		}
+		current = append(current, strings.TrimRight(line, " \t"))
+	}
+	if len(current) > 0 {
+		blocks = append(blocks, current)
+	}
+
+	return blocks
+}
+
+// normalizeText 规范化条目文本，去除空行以免破坏块结构
+func normalizeText(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	kept := lines[:0]
+	for _, line := range lines {
+		if line = strings.TrimSpace(line); line != "" {
+			kept = append(kept, line)
+		}
+	}
+	return strings.Join(kept, "\n")
+}
+
+// parseTimestamp 解析时间戳，支持 HH:MM:SS,mmm / HH:MM:SS.mmm / MM:SS.mmm
+func parseTimestamp(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	var h, m, sec, ms int
+
+	parts := strings.Split(strings.Replace(s, ",", ".", 1), ":")
+	var secPart string
+	switch len(parts) {
+	case 3:
+		if _, err := fmt.Sscanf(parts[0]+" "+parts[1], "%d %d", &h, &m); err != nil {
+			return 0, fmt.Errorf("无效的时间戳: %s", s)
+		}
+		secPart = parts[2]
+	case 2:
+		if _, err := fmt.Sscanf(parts[0], "%d", &m); err != nil {
+			return 0, fmt.Errorf("无效的时间戳: %s", s)
+		}
+		secPart = parts[1]
+	default:
+		return 0, fmt.Errorf("无效的时间戳: %s", s)
+	}
+
+	secFields := strings.SplitN(secPart, ".", 2)
+	if _, err := fmt.Sscanf(secFields[0], "%d", &sec); err != nil {
+		return 0, fmt.Errorf("无效的时间戳: %s", s)
+	}
+	if len(secFields) == 2 {
+		frac := secFields[1]
+		if len(frac) > 3 {
+			frac = frac[:3]
+		}
+		for len(frac) < 3 {
+			frac += "0"
+		}
+		if _, err := fmt.Sscanf(frac, "%d", &ms); err != nil {
+			return 0, fmt.Errorf("无效的时间戳: %s", s)
+		}
+	}
+	if m >= 60 || sec >= 60 || h < 0 || m < 0 || sec < 0 {
+		return 0, fmt.Errorf("无效的时间戳: %s", s)
+	}
+
+	return time.Duration(h)*time.Hour +
+		time.Duration(m)*time.Minute +
+		time.Duration(sec)*time.Second +
+		time.Duration(ms)*time.Millisecond, nil
+}
+
+// parseTiming 解析时间行 "start --> end [settings]"
+func parseTiming(line string) (start, end time.Duration, settings string, err error) {
+	arrow := strings.Index(line, "-->")
+	if arrow < 0 {
+		return 0, 0, "", fmt.Errorf("无效的时间行: %s", line)
+	}
+
+	start, err = parseTimestamp(line[:arrow])
+	if err != nil {
+		return 0, 0, "", err
+	}
+
+	rest := strings.Fields(line[arrow+3:])
+	if len(rest) == 0 {
+		return 0, 0, "", fmt.Errorf("无效的时间行: %s", line)
+	}
+	end, err = parseTimestamp(rest[0])
+	if err != nil {
+		return 0, 0, "", err
+	}
+	if end < start {
+		return 0, 0, "", fmt.Errorf("结束时间早于开始时间: %s", line)
+	}
+
+	return start, end, strings.Join(rest[1:], " "), nil
+}
+
+// formatTimestamp 格式化时间戳，sep为毫秒分隔符（SRT为","，WebVTT为"."）
+func formatTimestamp(d time.Duration, sep string) string {
+	if d < 0 {
+		d = 0
+	}
+	h := d / time.Hour
+	d -= h * time.Hour
+	m := d / time.Minute
+	d -= m * time.Minute
+	s := d / time.Second
+	d -= s * time.Second
+	ms := d / time.Millisecond
+	return fmt.Sprintf("%02d:%02d:%02d%s%03d", h, m, s, sep, ms)
+}
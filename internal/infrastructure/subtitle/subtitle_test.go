@@ -0,0 +1,201 @@
+package subtitle
+
+import (
+	"testing"
+	"time"
+)
+
+// ts 构造时间戳
+func ts(h, m, s, ms int) time.Duration {
+	return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute + time.Duration(s)*time.Second + time.Duration(ms)*time.Millisecond
+}
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		name    string
+		input   string
+		format  Format
+		header  string
+		cues    []Cue
+		wantErr bool
+	}{
+		{
+			name:   "标准SRT",
+			input:  "1\n00:00:01,000 --> 00:00:02,500\n你好\n\n2\n00:00:03,000 --> 00:00:04,000\n第一行\n第二行\n",
+			format: FormatSRT,
+			cues: []Cue{
+				{Index: 1, Start: ts(0, 0, 1, 0), End: ts(0, 0, 2, 500), Text: "你好"},
+				{Index: 2, Start: ts(0, 0, 3, 0), End: ts(0, 0, 4, 0), Text: "第一行\n第二行"},
+			},
+		},
+		{
+			name:   "带BOM与CRLF换行的SRT",
+			input:  "\xef\xbb\xbf1\r\n00:00:01,000 --> 00:00:02,000\r\nHello\r\n\r\n",
+			format: FormatSRT,
+			cues: []Cue{
+				{Index: 1, Start: ts(0, 0, 1, 0), End: ts(0, 0, 2, 0), Text: "Hello"},
+			},
+		},
+		{
+			name:   "省略序号行的SRT",
+			input:  "00:01:00,000 --> 00:01:01,000\nA\n\n00:01:02,000 --> 00:01:03,000\nB\n",
+			format: FormatSRT,
+			cues: []Cue{
+				{Index: 1, Start: ts(0, 1, 0, 0), End: ts(0, 1, 1, 0), Text: "A"},
+				{Index: 2, Start: ts(0, 1, 2, 0), End: ts(0, 1, 3, 0), Text: "B"},
+			},
+		},
+		{
+			name:   "WebVTT文件头、NOTE块、条目标识与设置",
+			input:  "WEBVTT - 示例\n\nNOTE 注释\n\nintro\n00:01.000 --> 00:02.000 align:start\n开始\n\n00:00:03.5 --> 00:00:04.25\n结束\n",
+			format: FormatWebVTT,
+			header: "WEBVTT - 示例\n\nNOTE 注释",
+			cues: []Cue{
+				{Index: 1, ID: "intro", Start: ts(0, 0, 1, 0), End: ts(0, 0, 2, 0), Settings: "align:start", Text: "开始"},
+				{Index: 2, Start: ts(0, 0, 3, 500), End: ts(0, 0, 4, 250), Text: "结束"},
+			},
+		},
+		{
+			name:    "缺少时间行",
+			input:   "1\n你好\n",
+			wantErr: true,
+		},
+		{
+			name:    "序号无效",
+			input:   "一\n00:00:01,000 --> 00:00:02,000\n你好\n",
+			wantErr: true,
+		},
+		{
+			name:    "结束时间早于开始时间",
+			input:   "1\n00:00:02,000 --> 00:00:01,000\n你好\n",
+			wantErr: true,
+		},
+		{
+			name:    "分钟超出范围",
+			input:   "1\n00:60:00,000 --> 00:61:00,000\n你好\n",
+			wantErr: true,
+		},
+		{
+			name:    "空内容",
+			input:   "\n\n",
+			wantErr: true,
+		},
+		{
+			name:    "WebVTT没有条目",
+			input:   "WEBVTT\n\nNOTE 只有注释\n",
+			wantErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			doc, err := Parse([]byte(c.input))
+			if c.wantErr {
+				if err == nil {
+					t.Fatalf("期望解析失败，实际成功: %+v", doc)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("解析失败: %v", err)
+			}
+			if doc.Format != c.format {
+				t.Errorf("格式 = %s, 期望 %s", doc.Format, c.format)
+			}
+			if doc.Header != c.header {
+				t.Errorf("文件头 = %q, 期望 %q", doc.Header, c.header)
+			}
+			if len(doc.Cues) != len(c.cues) {
+				t.Fatalf("条目数 = %d, 期望 %d", len(doc.Cues), len(c.cues))
+			}
+			for i, want := range c.cues {
+				if got := *doc.Cues[i]; got != want {
+					t.Errorf("第%d条 = %+v, 期望 %+v", i+1, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRebuild(t *testing.T) {
+	cases := []struct {
+		name   string
+		input  string
+		texts  []string
+		format Format
+		want   string
+	}{
+		{
+			name:   "SRT替换文本并重新编排序号",
+			input:  "3\n00:00:01,000 --> 00:00:02,000\nHello\n\n7\n00:00:03,000 --> 00:00:04,000\nWorld\n",
+			texts:  []string{"你好", "世界"},
+			format: FormatSRT,
+			want:   "1\n00:00:01,000 --> 00:00:02,000\n你好\n\n2\n00:00:03,000 --> 00:00:04,000\n世界\n",
+		},
+		{
+			name:   "译文中的空行被去除",
+			input:  "1\n00:00:01,000 --> 00:00:02,000\nHello\n",
+			texts:  []string{"\n第一行\n\n第二行\n"},
+			format: FormatSRT,
+			want:   "1\n00:00:01,000 --> 00:00:02,000\n第一行\n第二行\n",
+		},
+		{
+			name:   "WebVTT保留文件头、条目标识与设置",
+			input:  "WEBVTT\n\nSTYLE\n::cue { color: white }\n\nintro\n00:00:01.000 --> 00:00:02.000 line:0\nHello\n",
+			texts:  []string{"你好"},
+			format: FormatWebVTT,
+			want:   "WEBVTT\n\nSTYLE\n::cue { color: white }\n\nintro\n00:00:01.000 --> 00:00:02.000 line:0\n你好\n",
+		},
+		{
+			name:   "SRT转换为WebVTT",
+			input:  "1\n01:02:03,004 --> 01:02:05,000\nHello\n",
+			texts:  []string{"你好"},
+			format: FormatWebVTT,
+			want:   "WEBVTT\n\n01:02:03.004 --> 01:02:05.000\n你好\n",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			doc, err := Parse([]byte(c.input))
+			if err != nil {
+				t.Fatalf("解析失败: %v", err)
+			}
+			translated, err := doc.WithTexts(c.texts)
+			if err != nil {
+				t.Fatalf("替换文本失败: %v", err)
+			}
+			output, err := Write(translated, c.format)
+			if err != nil {
+				t.Fatalf("输出失败: %v", err)
+			}
+			if string(output) != c.want {
+				t.Errorf("输出 = %q, 期望 %q", output, c.want)
+			}
+
+			// 输出结果重新解析后时间轴不变
+			reparsed, err := Parse(output)
+			if err != nil {
+				t.Fatalf("重新解析失败: %v", err)
+			}
+			for i, cue := range reparsed.Cues {
+				if cue.Start != doc.Cues[i].Start || cue.End != doc.Cues[i].End {
+					t.Errorf("第%d条时间轴 = %s --> %s, 期望 %s --> %s", i+1, cue.Start, cue.End, doc.Cues[i].Start, doc.Cues[i].End)
+				}
+			}
+		})
+	}
+}
+
+func TestWithTextsCountMismatch(t *testing.T) {
+	doc, err := ParseSRT([]byte("1\n00:00:01,000 --> 00:00:02,000\nHello\n"))
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	if _, err := doc.WithTexts([]string{"你好", "多余"}); err == nil {
+		t.Error("条目数量不一致时期望返回错误")
+	}
+	if _, err := Write(doc, Format("ass")); err == nil {
+		t.Error("不支持的格式期望返回错误")
+	}
+}
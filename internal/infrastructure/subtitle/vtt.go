@@ -0,0 +1,100 @@
+package subtitle
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// ParseWebVTT 解析WebVTT字幕
+func ParseWebVTT(data []byte) (*Document, error) {
+	blocks := splitBlocks(data)
+	if len(blocks) == 0 || !strings.HasPrefix(blocks[0][0], "WEBVTT") {
+		return nil, fmt.Errorf("缺少WEBVTT文件头")
+	}
+
+	doc := &Document{Format: FormatWebVTT}
+	headers := []string{strings.Join(blocks[0], "\n")}
+
+	for _, lines := range blocks[1:] {
+		// NOTE/STYLE/REGION块不含时间行，作为文件头保留
+		if isVTTMetaBlock(lines[0]) {
+			if len(doc.Cues) == 0 {
+				headers = append(headers, strings.Join(lines, "\n"))
+			}
+			continue
+		}
+
+		var id string
+		if !strings.Contains(lines[0], "-->") {
+			if len(lines) < 2 {
+				return nil, fmt.Errorf("第%d条字幕缺少时间行", len(doc.Cues)+1)
+			}
+			id = lines[0]
+			lines = lines[1:]
+		}
+
+		start, end, settings, err := parseTiming(lines[0])
+		if err != nil {
+			return nil, fmt.Errorf("第%d条字幕解析失败: %v", len(doc.Cues)+1, err)
+		}
+
+		doc.Cues = append(doc.Cues, &Cue{
+			Index:    len(doc.Cues) + 1,
+			ID:       id,
+			Start:    start,
+			End:      end,
+			Settings: settings,
+			Text:     strings.Join(lines[1:], "\n"),
+		})
+	}
+
+	if len(doc.Cues) == 0 {
+		return nil, fmt.Errorf("未解析到字幕条目")
+	}
+
+	doc.Header = strings.Join(headers, "\n\n")
+	return doc, nil
+}
+
+// WriteWebVTT 输出WebVTT字幕
+func WriteWebVTT(doc *Document) []byte {
+	var buf bytes.Buffer
+
+	header := doc.Header
+	if !strings.HasPrefix(header, "WEBVTT") {
+		header = "WEBVTT"
+	}
+	buf.WriteString(header)
+	buf.WriteString("\n")
+
+	for _, cue := range doc.Cues {
+		buf.WriteString("\n")
+		if cue.ID != "" {
+			buf.WriteString(cue.ID)
+			buf.WriteString("\n")
+		}
+		fmt.Fprintf(&buf, "%s --> %s", formatTimestamp(cue.Start, "."), formatTimestamp(cue.End, "."))
+		if cue.Settings != "" {
+			buf.WriteString(" ")
+			buf.WriteString(cue.Settings)
+		}
+		buf.WriteString("\n")
+		if text := normalizeText(cue.Text); text != "" {
+			buf.WriteString(text)
+			buf.WriteString("\n")
+		}
+	}
+
+	return buf.Bytes()
+}
+
+// isVTTMetaBlock 判断是否为NOTE/STYLE/REGION块
+func isVTTMetaBlock(line string) bool {
+	for _, prefix := range []string{"NOTE", "STYLE", "REGION"} {
+		if line == prefix || strings.HasPrefix(line, prefix+" ") || strings.HasPrefix(line, prefix+"\t") {
+			return true
+		}
+	}
+	return false
+}
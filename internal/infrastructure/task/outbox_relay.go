@@ -0,0 +1,104 @@
+package task
+
+import (
+	"ai-translate/internal/domain/task"
+	"ai-translate/internal/infrastructure/persistence"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gogf/gf/v2/frame/g"
+	"strconv"
+	"time"
+)
+
+// errMalformedPayload 消息内容无法解析，重新投递也不会成功
+var errMalformedPayload = errors.New("解析发件箱消息失败")
+
+// OutboxRelay 发件箱中继，将与任务同一事务写入的入队消息投递到任务队列
+// 入队成功后才标记已投递，标记失败时下次重新投递，队列按消息ID去重，同一消息只入队一次
+type OutboxRelay struct {
+	outboxRepo  task.OutboxRepository
+	taskRepo    task.TaskRepository
+	taskQueue   task.TaskQueue
+	interval    time.Duration
+	batchSize   int
+	maxAttempts int // 投递失败达到该次数后标记为投递失败，0表示不限制
+}
+
+// NewOutboxRelay 创建发件箱中继实例
+func NewOutboxRelay() *OutboxRelay {
+	ctx := context.Background()
+	return &OutboxRelay{
+		outboxRepo:  persistence.NewOutboxRepository(),
+		taskRepo:    persistence.NewTaskRepository(),
+		taskQueue:   persistence.NewTaskQueue(),
+		interval:    time.Duration(g.Cfg().MustGet(ctx, "outbox.interval", 1).Int()) * time.Second,
+		batchSize:   g.Cfg().MustGet(ctx, "outbox.batchSize", 100).Int(),
+		maxAttempts: g.Cfg().MustGet(ctx, "outbox.maxAttempts", 10).Int(),
+	}
+}
+
+// Start 按间隔投递待投递消息，直到ctx结束
+func (r *OutboxRelay) Start(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		if err := r.RelayOnce(ctx); err != nil {
+			g.Log().Errorf(ctx, "读取发件箱失败: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RelayOnce 按写入顺序投递一批待投递消息，单条消息投递失败时记录原因，下次重新投递
+// 消息内容无法解析或失败次数达到上限时标记为投递失败，避免一直占据待投递消息的前列
+func (r *OutboxRelay) RelayOnce(ctx context.Context) error {
+	messages, err := r.outboxRepo.FindPending(r.batchSize)
+	if err != nil {
+		return err
+	}
+	for _, m := range messages {
+		err := r.relay(m)
+		if err == nil {
+			continue
+		}
+		g.Log().Warningf(ctx, "投递发件箱消息失败: %d, %v", m.ID, err)
+		if err := r.outboxRepo.RecordFailure(m.ID, err.Error()); err != nil {
+			g.Log().Errorf(ctx, "记录发件箱投递失败失败: %d, %v", m.ID, err)
+			continue
+		}
+		if errors.Is(err, errMalformedPayload) || (r.maxAttempts > 0 && m.Attempts+1 >= r.maxAttempts) {
+			g.Log().Errorf(ctx, "发件箱消息不再投递: %d, 失败%d次", m.ID, m.Attempts+1)
+			if err := r.outboxRepo.UpdateStatus(m.ID, task.OutboxFailed); err != nil {
+				g.Log().Errorf(ctx, "标记发件箱消息投递失败失败: %d, %v", m.ID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// relay 投递一条消息，任务已删除或已不在等待中时跳过，不再入队
+func (r *OutboxRelay) relay(m *task.OutboxMessage) error {
+	current, err := r.taskRepo.FindByID(m.TaskID)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && current.Status != task.StatusWaiting) {
+		return r.outboxRepo.UpdateStatus(m.ID, task.OutboxSkipped)
+	}
+	if err != nil {
+		return err
+	}
+
+	var t task.Task
+	if err := json.Unmarshal([]byte(m.Payload), &t); err != nil {
+		return fmt.Errorf("%w: %v", errMalformedPayload, err)
+	}
+	if _, err := r.taskQueue.PushUnique(strconv.FormatUint(m.ID, 10), &t); err != nil {
+		return err
+	}
+	return r.outboxRepo.UpdateStatus(m.ID, task.OutboxPublished)
+}
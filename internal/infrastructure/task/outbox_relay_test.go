@@ -0,0 +1,227 @@
+package task
+
+import (
+	"ai-translate/internal/domain/task"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// fakeTaskRepository 内存任务仓储，只实现中继用到的方法
+type fakeTaskRepository struct {
+	task.TaskRepository
+	tasks map[uint64]*task.Task
+	err   error // 不为nil时查询任务失败
+}
+
+func (r *fakeTaskRepository) FindByID(id uint64) (*task.Task, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	t, ok := r.tasks[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return t, nil
+}
+
+// fakeOutboxRepository 内存发件箱仓储
+type fakeOutboxRepository struct {
+	messages []*task.OutboxMessage
+}
+
+func (r *fakeOutboxRepository) FindPending(limit int) ([]*task.OutboxMessage, error) {
+	var result []*task.OutboxMessage
+	for _, m := range r.messages {
+		if m.Status == task.OutboxPending && len(result) < limit {
+			result = append(result, m)
+		}
+	}
+	return result, nil
+}
+
+func (r *fakeOutboxRepository) Save(message *task.OutboxMessage) error {
+	r.messages = append(r.messages, message)
+	return nil
+}
+
+func (r *fakeOutboxRepository) UpdateStatus(id uint64, status int) error {
+	for _, m := range r.messages {
+		if m.ID == id {
+			m.Status = status
+		}
+	}
+	return nil
+}
+
+func (r *fakeOutboxRepository) RecordFailure(id uint64, errMsg string) error {
+	for _, m := range r.messages {
+		if m.ID == id {
+			m.Attempts++
+			m.LastError = errMsg
+		}
+	}
+	return nil
+}
+
+// fakeTaskQueue 记录入队消息的任务队列，同一key只入队一次
+type fakeTaskQueue struct {
+	task.TaskQueue
+	keys   []string
+	pushed map[string]bool
+	err    error // 不为nil时入队失败
+}
+
+func (q *fakeTaskQueue) PushUnique(key string, t *task.Task) (bool, error) {
+	if q.err != nil {
+		return false, q.err
+	}
+	if q.pushed[key] {
+		return false, nil
+	}
+	q.pushed[key] = true
+	q.keys = append(q.keys, key)
+	return true, nil
+}
+
+func TestOutboxRelayOnce(t *testing.T) {
+	payload := func(id uint64) string {
+		data, _ := json.Marshal(&task.Task{ID: id})
+		return string(data)
+	}
+
+	cases := []struct {
+		name     string
+		status   int // 任务状态，-1表示任务已删除
+		payload  string
+		attempts int
+		findErr  error
+		pushErr  error
+		pushed   map[string]bool
+		keys     []string
+		result   int
+		failures int
+	}{
+		{
+			name:   "等待中的任务入队后标记已投递",
+			status: task.StatusWaiting,
+			keys:   []string{"1"},
+			result: task.OutboxPublished,
+		},
+		{
+			name:   "队列已去重时同样标记已投递",
+			status: task.StatusWaiting,
+			pushed: map[string]bool{"1": true},
+			result: task.OutboxPublished,
+		},
+		{
+			name:   "任务已删除时跳过",
+			status: -1,
+			result: task.OutboxSkipped,
+		},
+		{
+			name:   "任务已取消时跳过",
+			status: task.StatusCanceled,
+			result: task.OutboxSkipped,
+		},
+		{
+			name:     "查询任务失败时记录失败，下次重新投递",
+			status:   task.StatusWaiting,
+			findErr:  errors.New("数据库不可用"),
+			result:   task.OutboxPending,
+			failures: 1,
+		},
+		{
+			name:     "入队失败时记录失败，下次重新投递",
+			status:   task.StatusWaiting,
+			pushErr:  errors.New("队列不可用"),
+			result:   task.OutboxPending,
+			failures: 1,
+		},
+		{
+			name:     "失败次数达到上限时标记投递失败",
+			status:   task.StatusWaiting,
+			attempts: 2,
+			pushErr:  errors.New("队列不可用"),
+			result:   task.OutboxFailed,
+			failures: 3,
+		},
+		{
+			name:     "消息内容无法解析时直接标记投递失败",
+			status:   task.StatusWaiting,
+			payload:  "{",
+			result:   task.OutboxFailed,
+			failures: 1,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			taskRepo := &fakeTaskRepository{tasks: map[uint64]*task.Task{}, err: c.findErr}
+			if c.status >= 0 {
+				taskRepo.tasks[10] = &task.Task{ID: 10, Status: c.status}
+			}
+			message := &task.OutboxMessage{ID: 1, TaskID: 10, Payload: c.payload, Status: task.OutboxPending, Attempts: c.attempts}
+			if message.Payload == "" {
+				message.Payload = payload(10)
+			}
+			outboxRepo := &fakeOutboxRepository{messages: []*task.OutboxMessage{message}}
+			queue := &fakeTaskQueue{pushed: map[string]bool{}, err: c.pushErr}
+			for key := range c.pushed {
+				queue.pushed[key] = true
+			}
+
+			relay := &OutboxRelay{
+				outboxRepo:  outboxRepo,
+				taskRepo:    taskRepo,
+				taskQueue:   queue,
+				batchSize:   10,
+				maxAttempts: 3,
+			}
+			if err := relay.RelayOnce(context.Background()); err != nil {
+				t.Fatalf("投递失败: %v", err)
+			}
+
+			if !reflect.DeepEqual(queue.keys, c.keys) {
+				t.Errorf("入队 = %v, 期望 %v", queue.keys, c.keys)
+			}
+			if message.Status != c.result {
+				t.Errorf("消息状态 = %d, 期望 %d", message.Status, c.result)
+			}
+			if message.Attempts != c.failures {
+				t.Errorf("失败次数 = %d, 期望 %d", message.Attempts, c.failures)
+			}
+		})
+	}
+}
+
+func TestOutboxRelayOrder(t *testing.T) {
+	taskRepo := &fakeTaskRepository{tasks: map[uint64]*task.Task{
+		10: {ID: 10, Status: task.StatusWaiting},
+		11: {ID: 11, Status: task.StatusWaiting},
+	}}
+	outboxRepo := &fakeOutboxRepository{}
+	for i, id := range []uint64{10, 11, 10} {
+		data, _ := json.Marshal(&task.Task{ID: id})
+		outboxRepo.Save(&task.OutboxMessage{ID: uint64(i + 1), TaskID: id, Payload: string(data)})
+	}
+	queue := &fakeTaskQueue{pushed: map[string]bool{}}
+
+	relay := &OutboxRelay{outboxRepo: outboxRepo, taskRepo: taskRepo, taskQueue: queue, batchSize: 2}
+	for i := 0; i < 2; i++ {
+		if err := relay.RelayOnce(context.Background()); err != nil {
+			t.Fatalf("投递失败: %v", err)
+		}
+	}
+
+	// 每批最多投递batchSize条，按写入顺序投递；同一任务的多条消息各自入队
+	if want := []string{"1", "2", "3"}; !reflect.DeepEqual(queue.keys, want) {
+		t.Errorf("入队 = %v, 期望 %v", queue.keys, want)
+	}
+	if pending, _ := outboxRepo.FindPending(10); len(pending) != 0 {
+		t.Errorf("期望全部投递，剩余 %d 条", len(pending))
+	}
+}
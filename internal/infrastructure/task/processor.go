@@ -2,22 +2,37 @@ package task
 
 import (
 	"ai-translate/internal/application"
-	"ai-translate/internal/domain/ai"
+	"ai-translate/internal/domain/prompt"
 	"ai-translate/internal/domain/task"
 	"ai-translate/internal/domain/work"
 	"ai-translate/internal/infrastructure/ai"
+	"ai-translate/internal/infrastructure/event"
+	"ai-translate/internal/infrastructure/glossary"
+	"ai-translate/internal/infrastructure/persistence"
 	"ai-translate/internal/infrastructure/storage"
+	"ai-translate/internal/infrastructure/subtitle"
+	"ai-translate/internal/infrastructure/translator"
+	"ai-translate/internal/model"
 	"context"
-	"encoding/json"
+	"fmt"
 	"github.com/gogf/gf/v2/frame/g"
+	"path"
+	"strconv"
 	"time"
 )
 
 type Processor struct {
-	taskService    task.TaskService
-	workService    work.WorkService
-	aiService      ai.ModelService
-	storageService *storage.OSSService
+	taskService           task.TaskService
+	workService           work.WorkService
+	taskQueue             task.TaskQueue
+	contentSummaryRepo    work.ContentSummaryRepository
+	promptRepo            prompt.PromptRepository
+	translationResultRepo work.TranslationResultRepository
+	aiDrivers             *ai.Registry
+	storageService        *storage.OSSService
+	relay                 *OutboxRelay
+	checkInterval         time.Duration // 处理期间检查任务状态的间隔
+	pollInterval          time.Duration // 队列为空时再次读取的间隔
 }
 
 // NewProcessor 创建任务处理器实例
@@ -27,13 +42,7 @@ func NewProcessor() (*Processor, error) {
 		return nil, err
 	}
 
-	aiConfig := &ai.ModelConfig{
-		Type:      ai.ModelTypeGemini,
-		APIKey:    g.Cfg().MustGet(context.Background(), "gemini.apiKey").String(),
-		ModelName: g.Cfg().MustGet(context.Background(), "gemini.model").String(),
-	}
-
-	aiService, err := ai.NewGeminiService(aiConfig)
+	aiDrivers, err := ai.NewRegistry(context.Background())
 	if err != nil {
 		return nil, err
 	}
@@ -43,49 +52,151 @@ func NewProcessor() (*Processor, error) {
 		return nil, err
 	}
 
+	checkInterval := time.Duration(g.Cfg().MustGet(context.Background(), "processor.statusCheckInterval", 2).Int()) * time.Second
+	if checkInterval <= 0 {
+		checkInterval = 2 * time.Second
+	}
+	pollInterval := time.Duration(g.Cfg().MustGet(context.Background(), "processor.pollInterval", 1).Int()) * time.Second
+	if pollInterval <= 0 {
+		pollInterval = time.Second
+	}
+
 	return &Processor{
-		taskService:    application.NewTaskService(),
-		workService:    workService,
-		aiService:      aiService,
-		storageService: storageService,
+		taskService:           application.NewTaskService(),
+		workService:           workService,
+		taskQueue:             persistence.NewTaskQueue(),
+		contentSummaryRepo:    persistence.NewContentSummaryRepository(),
+		promptRepo:            persistence.NewPromptRepository(),
+		translationResultRepo: persistence.NewTranslationResultRepository(),
+		aiDrivers:             aiDrivers,
+		storageService:        storageService,
+		relay:                 NewOutboxRelay(),
+		checkInterval:         checkInterval,
+		pollInterval:          pollInterval,
 	}, nil
 }
 
-// Start 启动任务处理器
+// Start 启动任务处理器，阻塞直到ctx结束，返回前等待发件箱中继退出
 func (p *Processor) Start(ctx context.Context) error {
+	// 发件箱中继将新建与重新等待的任务投递到队列
+	relayDone := make(chan struct{})
+	go func() {
+		defer close(relayDone)
+		p.relay.Start(ctx)
+	}()
+	defer func() { <-relayDone }()
+
 	for {
 		select {
 		case <-ctx.Done():
 			return nil
 		default:
-			// 从队列中获取任务
-			t, err := p.taskService.(*application.TaskService).TaskQueue.Pop()
+			// 从队列中获取任务，队列为空或读取失败时等待后重试
+			t, err := p.taskQueue.Pop()
 			if err != nil {
+				select {
+				case <-ctx.Done():
+					return nil
+				case <-time.After(p.pollInterval):
+				}
 				continue
 			}
 
-			// 处理任务
-			err = p.processTask(ctx, t)
+			// 队列中是入队时的任务快照，已取消或暂停的任务不再处理
+			t, err = p.taskService.GetTask(t.ID)
+			if err != nil {
+				g.Log().Errorf(ctx, "获取任务信息失败: %v", err)
+				continue
+			}
+			if t.Status != task.StatusWaiting {
+				g.Log().Infof(ctx, "任务已不在等待中，跳过处理: %d, status=%d", t.ID, t.Status)
+				continue
+			}
+
+			// 处理任务，任务被取消或暂停时中断处理，不再重试或更新状态
+			event.WorkTaskStatusChanged(t, model.TaskStatusRunning, "")
+			taskCtx, stop := p.watch(ctx, t.ID)
+			err = p.processTask(taskCtx, t)
+			if stop() {
+				g.Log().Infof(ctx, "任务已取消或暂停，中断处理: %d", t.ID)
+				continue
+			}
+			if ctx.Err() != nil {
+				// 服务关闭中断处理时放回队列，重启后从已保存的部分结果继续处理
+				if err := p.taskQueue.Push(t); err != nil {
+					g.Log().Errorf(context.Background(), "服务关闭时放回任务失败: %d, %v", t.ID, err)
+				}
+				return nil
+			}
 			if err != nil {
-				// 更新任务状态为失败
-				t.Status = 3 // 3:失败
-				t.UpdatedAt = time.Now()
-				p.taskService.UpdateTask(t)
-
-				// 如果未超过最大重试次数，则重试
-				if t.RetryCount < t.MaxRetry {
-					p.taskService.RetryTask(t.ID)
+				// 认证失败、内容被拦截等不可重试错误直接失败，否则未超过最大重试次数时重试
+				if ai.ShouldRetry(err, t.RetryCount, t.MaxRetry) {
+					if retryErr := p.taskService.RetryTask(t.ID); retryErr == nil {
+						event.WorkTaskStatusChanged(t, model.TaskStatusPending, err.Error())
+						continue
+					}
+				} else if !ai.IsRetryable(err) {
+					g.Log().Errorf(ctx, "任务失败且不可重试: %d, %v", t.ID, err)
+				}
+
+				// 最终失败，等待该任务的下游任务一并失败
+				if failErr := p.taskService.FailTask(t.ID, err.Error()); failErr != nil {
+					g.Log().Errorf(ctx, "更新任务失败状态失败: %d, %v", t.ID, failErr)
+				} else {
+					event.WorkTaskStatusChanged(t, model.TaskStatusFailed, err.Error())
 				}
 			} else {
-				// 更新任务状态为完成
-				t.Status = 1 // 1:完成
-				t.UpdatedAt = time.Now()
-				p.taskService.UpdateTask(t)
+				// 更新任务状态为完成，放行依赖该任务的下游任务
+				if err := p.taskService.CompleteTask(t.ID); err != nil {
+					g.Log().Errorf(ctx, "更新任务完成状态失败: %d, %v", t.ID, err)
+				} else {
+					event.WorkTaskStatusChanged(t, model.TaskStatusCompleted, "")
+				}
 			}
 		}
 	}
 }
 
+// watch 处理期间按间隔检查任务状态，任务被取消或暂停时取消处理上下文，中断进行中的AI请求
+// 返回的函数停止检查并报告处理是否因此被中断
+func (p *Processor) watch(ctx context.Context, id uint64) (context.Context, func() bool) {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	interrupted := false
+
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(p.checkInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				t, err := p.taskService.GetTask(id)
+				if err != nil {
+					// 查询失败时继续处理，下次再检查
+					g.Log().Warningf(ctx, "检查任务状态失败: %d, %v", id, err)
+					continue
+				}
+				if t.Status != task.StatusWaiting {
+					interrupted = true
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+
+	return ctx, func() bool {
+		close(done)
+		<-stopped
+		cancel()
+		return interrupted
+	}
+}
+
 // processTask 处理任务
 func (p *Processor) processTask(ctx context.Context, t *task.Task) error {
 	switch t.Type {
@@ -107,7 +218,7 @@ func (p *Processor) processContentGenerationTask(ctx context.Context, t *task.Ta
 	}
 
 	// 生成内容简介
-	return p.workService.GenerateContentSummary(w.ID)
+	return p.workService.GenerateContentSummary(ctx, w.ID, t.Driver)
 }
 
 // processTranslationTask 处理翻译任务
@@ -125,13 +236,13 @@ func (p *Processor) processTranslationTask(ctx context.Context, t *task.Task) er
 	}
 
 	// 获取内容简介
-	summary, err := p.workService.(*application.WorkService).ContentSummaryRepo.FindByWorkID(w.ID)
+	summary, err := p.contentSummaryRepo.FindByWorkID(w.ID)
 	if err != nil {
 		return err
 	}
 
 	// 获取翻译提示词
-	prompts, err := p.workService.(*application.WorkService).PromptRepo.FindByType(2) // 2:翻译
+	prompts, err := p.promptRepo.FindByType(2) // 2:翻译
 	if err != nil {
 		return err
 	}
@@ -139,42 +250,115 @@ func (p *Processor) processTranslationTask(ctx context.Context, t *task.Task) er
 		return nil
 	}
 
-	// 调用AI进行翻译
-	req := &ai.TranslationRequest{
-		Content:        summary.Content,
-		TargetLanguage: batch.TargetLanguage,
-		Terminology:    batch.TerminologyURL,
-		Prompt:         prompts[0].Content,
+	// 获取源字幕，译文沿用源字幕的时间轴
+	source, err := p.fetchSubtitle(ctx, w.SubtitleURL)
+	if err != nil {
+		return err
+	}
+
+	// 加载术语表
+	var terms *glossary.Glossary
+	if batch.TerminologyURL != "" {
+		terms, err = glossary.Load(ctx, batch.TerminologyURL, batch.TargetLanguage)
+		if err != nil {
+			return err
+		}
 	}
 
-	resp, err := p.aiService.Translate(ctx, req)
+	// 按任务指定的驱动获取AI服务
+	driver := p.aiDrivers.Resolve(ai.DriverType(t.Driver))
+	aiService, err := p.aiDrivers.Get(driver)
 	if err != nil {
 		return err
 	}
 
+	// 按条目编号分块调用AI翻译，内容简介作为背景信息，术语表按块注入
+	prompt := fmt.Sprintf("%s\n目标语言: %s\n\n%s\n\n内容简介（仅供参考）：\n%s",
+		prompts[0].Content, batch.TargetLanguage, translator.FormatInstruction, summary.Content)
+	cueTranslator := translator.NewCueTranslator(func(ctx context.Context, content string) (string, error) {
+		return aiService.Generate(ctx, prompt+"\n\n"+content)
+	}, translator.NewConfig(ctx, string(driver))).WithGlossary(terms)
+
+	// 每完成一个分块保存已完成条目的译文，任务取消或暂停后恢复时跳过这些分块
+	cueTranslator.WithResume(translator.ResumeTexts(source.Cues, t.PartialResult)).
+		WithProgress(func(progress translator.Progress) {
+			if err := p.taskService.SavePartialResult(t.ID, progress.Partial); err != nil {
+				g.Log().Warningf(ctx, "保存部分译文失败: %d, %v", t.ID, err)
+			}
+			event.WorkTaskProgressed(t, progress.Percent())
+		}, func(texts map[int]string) string {
+			return translator.RenderPartial(source, texts)
+		})
+
+	translated, err := cueTranslator.Translate(ctx, source.Cues)
+	if err != nil {
+		return err
+	}
+	// 未遵守术语表的条目随翻译结果保存，供用户查看
+	violations := make([]*work.TermViolation, 0, len(translated.Violations))
+	for _, v := range translated.Violations {
+		violations = append(violations, &work.TermViolation{
+			CueIndex: v.CueIndex,
+			Source:   v.Term.Source,
+			Target:   v.Term.Target,
+		})
+	}
+	if len(violations) > 0 {
+		g.Log().Warningf(ctx, "译文未遵守术语表: batch_id=%d, %d处", batch.ID, len(violations))
+	}
+
 	// 生成SRT文件
-	srtContent := generateSRT(resp.TranslatedContent)
+	srtContent, err := generateSRT(source, translated.Texts)
+	if err != nil {
+		return err
+	}
 
 	// 上传SRT文件到OSS
 	objectKey := p.storageService.GenerateObjectKey("translations", w.Title)
-	srtURL, err := p.storageService.UploadContent(objectKey, []byte(srtContent))
+	srtURL, err := p.storageService.UploadContent(objectKey, srtContent)
 	if err != nil {
 		return err
 	}
 
 	// 保存翻译结果
 	result := &work.TranslationResult{
-		BatchID:   batch.ID,
-		SrtURL:    srtURL,
-		Status:    1,
-		CreatedAt: time.Now(),
+		BatchID:    batch.ID,
+		TaskID:     strconv.FormatUint(t.ID, 10),
+		SrtURL:     srtURL,
+		Status:     1,
+		Violations: violations,
+		CreatedAt:  time.Now(),
 	}
 
-	return p.workService.(*application.WorkService).TranslationResultRepo.Save(result)
+	return p.translationResultRepo.Save(result)
+}
+
+// fetchSubtitle 下载并解析源字幕文件
+func (p *Processor) fetchSubtitle(ctx context.Context, url string) (*subtitle.Document, error) {
+	resp, err := g.Client().Get(ctx, url)
+	if err != nil {
+		return nil, fmt.Errorf("下载源字幕失败: %v", err)
+	}
+	defer resp.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("下载源字幕失败，状态码：%d", resp.StatusCode)
+	}
+
+	doc, err := subtitle.Parse(resp.ReadAll())
+	if err != nil {
+		return nil, fmt.Errorf("解析源字幕失败(%s): %v", path.Base(url), err)
+	}
+	return doc, nil
 }
 
 // generateSRT 生成SRT文件内容
-func generateSRT(content string) string {
-	// TODO: 实现SRT文件生成逻辑
-	return content
-} 
\ No newline at end of file
+// 只替换源字幕文本，条目数量和时间轴以源字幕为准
+func generateSRT(source *subtitle.Document, texts []string) ([]byte, error) {
+	doc, err := source.WithTexts(texts)
+	if err != nil {
+		return nil, err
+	}
+
+	return subtitle.WriteSRT(doc), nil
+}
@@ -0,0 +1,84 @@
+package translator
+
+import (
+	"ai-translate/internal/infrastructure/subtitle"
+	"unicode"
+	"unicode/utf8"
+)
+
+// cueOverheadTokens 每个条目编号及换行的额外token估算
+const cueOverheadTokens = 4
+
+// Chunk 翻译分块
+type Chunk struct {
+	Context []*subtitle.Cue // 上文条目，只读，仅供参考
+	Cues    []*subtitle.Cue // 需要翻译的条目
+}
+
+// Chunker 按token预算切分字幕条目
+type Chunker struct {
+	maxTokens   int
+	contextCues int
+}
+
+// NewChunker 创建分块器，maxTokens为单块译文条目的token预算，contextCues为携带的上文条目数
+func NewChunker(maxTokens, contextCues int) *Chunker {
+	if maxTokens <= 0 {
+		maxTokens = 1500
+	}
+	if contextCues < 0 {
+		contextCues = 0
+	}
+	return &Chunker{
+		maxTokens:   maxTokens,
+		contextCues: contextCues,
+	}
+}
+
+// Split 切分条目，单个条目超出预算时独占一块
+func (c *Chunker) Split(cues []*subtitle.Cue) []*Chunk {
+	var chunks []*Chunk
+	start := 0
+	tokens := 0
+
+	for i, cue := range cues {
+		cost := EstimateTokens(cue.Text) + cueOverheadTokens
+		if i > start && tokens+cost > c.maxTokens {
+			chunks = append(chunks, c.newChunk(cues, start, i))
+			start = i
+			tokens = 0
+		}
+		tokens += cost
+	}
+	if start < len(cues) {
+		chunks = append(chunks, c.newChunk(cues, start, len(cues)))
+	}
+
+	return chunks
+}
+
+// newChunk 创建分块，并附带start之前的若干条目作为上文
+func (c *Chunker) newChunk(cues []*subtitle.Cue, start, end int) *Chunk {
+	from := start - c.contextCues
+	if from < 0 {
+		from = 0
+	}
+	return &Chunk{
+		Context: cues[from:start],
+		Cues:    cues[start:end],
+	}
+}
+
+// EstimateTokens 粗略估算文本token数：CJK字符按1个计，其余按约4个字符1个计
+func EstimateTokens(text string) int {
+	cjk := 0
+	other := 0
+	for _, r := range text {
+		if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+			cjk++
+		} else {
+			other += utf8.RuneLen(r)
+		}
+	}
+	return cjk + (other+3)/4
+}
@@ -0,0 +1,92 @@
+package translator
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEstimateTokens(t *testing.T) {
+	cases := []struct {
+		name string
+		text string
+		want int
+	}{
+		{"空文本", "", 0},
+		{"拉丁字符按4个计1个", "abcdefgh", 2},
+		{"不足4个字符向上取整", "abcde", 2},
+		{"中日韩字符各计1个", "你好こんにちは안녕", 9},
+		{"混合文本", "AI是什么", 4},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := EstimateTokens(c.text); got != c.want {
+				t.Errorf("EstimateTokens(%q) = %d, 期望 %d", c.text, got, c.want)
+			}
+		})
+	}
+}
+
+func TestChunkerSplit(t *testing.T) {
+	// 每条"你好"计2个token，加上编号开销共6个
+	short := newCues("你好", "你好", "你好", "你好", "你好")
+
+	cases := []struct {
+		name        string
+		maxTokens   int
+		contextCues int
+		texts       []string
+		cues        [][]int // 每块需要翻译的条目序号
+		context     [][]int // 每块的上文条目序号
+	}{
+		{
+			name:      "预算足够时只有一块",
+			maxTokens: 100,
+			cues:      [][]int{{1, 2, 3, 4, 5}},
+			context:   [][]int{{}},
+		},
+		{
+			name:        "按预算切分并携带上文",
+			maxTokens:   12,
+			contextCues: 1,
+			cues:        [][]int{{1, 2}, {3, 4}, {5}},
+			context:     [][]int{{}, {2}, {4}},
+		},
+		{
+			name:        "上文条目数超出前面的条目数",
+			maxTokens:   18,
+			contextCues: 5,
+			cues:        [][]int{{1, 2, 3}, {4, 5}},
+			context:     [][]int{{}, {1, 2, 3}},
+		},
+		{
+			name:      "单个条目超出预算时独占一块",
+			maxTokens: 10,
+			texts:     []string{"你好", strings.Repeat("长", 20), "你好"},
+			cues:      [][]int{{1}, {2}, {3}},
+			context:   [][]int{{}, {}, {}},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cues := short
+			if c.texts != nil {
+				cues = newCues(c.texts...)
+			}
+			chunks := NewChunker(c.maxTokens, c.contextCues).Split(cues)
+			var gotCues, gotContext [][]int
+			for _, chunk := range chunks {
+				gotCues = append(gotCues, indexes(chunk.Cues))
+				gotContext = append(gotContext, indexes(chunk.Context))
+			}
+			if !reflect.DeepEqual(gotCues, c.cues) {
+				t.Errorf("分块条目 = %v, 期望 %v", gotCues, c.cues)
+			}
+			if !reflect.DeepEqual(gotContext, c.context) {
+				t.Errorf("分块上文 = %v, 期望 %v", gotContext, c.context)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package translator
+
+import (
+	"ai-translate/internal/infrastructure/ai"
+	"ai-translate/internal/infrastructure/subtitle"
+	"context"
+	"fmt"
+	"strings"
+)
+
+// TranslateContent 翻译任务内容并上报进度
+// 内容为SRT/WebVTT字幕时按条目分块翻译，进度为已完成条目数/条目总数；
+// 其余内容整体流式翻译，部分结果为已收到的译文
+func TranslateContent(ctx context.Context, service ai.AIService, config Config, content, sourceLang, targetLang string, report ProgressFunc) (string, error) {
+	doc, err := subtitle.Parse([]byte(content))
+	if err != nil || len(doc.Cues) == 0 {
+		return translateText(ctx, service, content, sourceLang, targetLang, report)
+	}
+
+	prompt := fmt.Sprintf("你是一个专业的字幕翻译助手。请将以下%s字幕翻译成%s。\n%s", sourceLang, targetLang, FormatInstruction)
+	cueTranslator := NewCueTranslator(func(ctx context.Context, content string) (string, error) {
+		// 流式请求不受整体超时限制，避免长分块超时
+		return service.GenerateStream(ctx, prompt+"\n\n"+content, nil)
+	}, config)
+	if report != nil {
+		cueTranslator.WithProgress(report, func(texts map[int]string) string {
+			return RenderPartial(doc, texts)
+		})
+	}
+
+	result, err := cueTranslator.Translate(ctx, doc.Cues)
+	if err != nil {
+		return "", err
+	}
+
+	translated, err := doc.WithTexts(result.Texts)
+	if err != nil {
+		return "", err
+	}
+	output, err := subtitle.Write(translated, doc.Format)
+	if err != nil {
+		return "", err
+	}
+	return string(output), nil
+}
+
+// translateText 流式翻译普通文本，完成前进度为0
+func translateText(ctx context.Context, service ai.AIService, content, sourceLang, targetLang string, report ProgressFunc) (string, error) {
+	var partial strings.Builder
+	var onDelta func(delta string)
+	if report != nil {
+		onDelta = func(delta string) {
+			partial.WriteString(delta)
+			report(Progress{
+				Total:   1,
+				Partial: partial.String(),
+			})
+		}
+	}
+
+	result, err := service.TranslateStream(ctx, content, sourceLang, targetLang, onDelta)
+	if err != nil {
+		return "", err
+	}
+	if report != nil {
+		report(Progress{
+			Done:    1,
+			Total:   1,
+			Partial: result,
+		})
+	}
+	return result, nil
+}
+
+// RenderPartial 将已完成条目渲染为字幕，未完成条目不输出
+func RenderPartial(doc *subtitle.Document, texts map[int]string) string {
+	partial := &subtitle.Document{
+		Format: doc.Format,
+		Header: doc.Header,
+	}
+	for _, cue := range doc.Cues {
+		text, ok := texts[cue.Index]
+		if !ok {
+			continue
+		}
+		done := *cue
+		done.Text = text
+		partial.Cues = append(partial.Cues, &done)
+	}
+
+	output, err := subtitle.Write(partial, doc.Format)
+	if err != nil {
+		return ""
+	}
+	return string(output)
+}
+
+// ResumeTexts 从RenderPartial输出的部分结果中恢复已完成条目的译文（条目序号 => 译文）
+// 部分结果解析时重新编号，按时间轴依次对应到源条目，无法解析时返回nil
+func ResumeTexts(cues []*subtitle.Cue, partial string) map[int]string {
+	if strings.TrimSpace(partial) == "" {
+		return nil
+	}
+	doc, err := subtitle.Parse([]byte(partial))
+	if err != nil {
+		return nil
+	}
+
+	texts := make(map[int]string, len(doc.Cues))
+	i := 0
+	for _, done := range doc.Cues {
+		for i < len(cues) && (cues[i].Start != done.Start || cues[i].End != done.End) {
+			i++
+		}
+		if i == len(cues) {
+			break
+		}
+		texts[cues[i].Index] = done.Text
+		i++
+	}
+	return texts
+}
@@ -0,0 +1,139 @@
+package translator
+
+import (
+	"ai-translate/internal/infrastructure/subtitle"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// lineBreak 条目内换行的占位符，保证一行对应一个条目
+const lineBreak = " <br> "
+
+// FormatInstruction 条目格式说明，需拼接到翻译提示词中
+const FormatInstruction = `输入的每一行格式为"[编号] 文本"，文本中的<br>表示换行。
+请逐行翻译，输出同样格式的行：保留每行开头的[编号]且不得修改、合并、拆分或调整顺序，保留<br>，不要输出其他内容。`
+
+// cueLinePattern 匹配"[编号] 文本"格式的行
+var cueLinePattern = regexp.MustCompile(`^\s*\[(\d+)\]\s?(.*)$`)
+
+// contextCue 上文条目，译文为空表示尚未翻译
+type contextCue struct {
+	Index       int
+	Source      string
+	Translation string
+}
+
+// encodeCues 将条目编码为带编号的文本，上文条目单独成段且不要求翻译
+func encodeCues(contextCues []contextCue, cues []*subtitle.Cue) string {
+	var b strings.Builder
+	if len(contextCues) > 0 {
+		b.WriteString("上文（仅供参考，不要翻译或输出）：\n")
+		for _, c := range contextCues {
+			if c.Translation != "" {
+				fmt.Fprintf(&b, "[%d] %s => %s\n", c.Index, encodeText(c.Source), encodeText(c.Translation))
+			} else {
+				fmt.Fprintf(&b, "[%d] %s\n", c.Index, encodeText(c.Source))
+			}
+		}
+		b.WriteString("\n需要翻译的内容：\n")
+	}
+	for _, cue := range cues {
+		fmt.Fprintf(&b, "[%d] %s\n", cue.Index, encodeText(cue.Text))
+	}
+	return b.String()
+}
+
+// encodeText 将条目内换行替换为占位符
+func encodeText(text string) string {
+	return strings.ReplaceAll(strings.TrimSpace(text), "\n", lineBreak)
+}
+
+// decodeResult 编号对齐的解析结果
+type decodeResult struct {
+	Texts      map[int]string // 编号 -> 译文
+	Missing    []int          // 缺失的编号（被丢弃或被合并）
+	Duplicated []int          // 重复出现的编号
+	Unknown    []int          // 不属于本次请求的编号
+	Reordered  bool           // 顺序是否被打乱
+}
+
+// decodeCues 解析模型输出，按编号与请求条目对齐
+func decodeCues(output string, cues []*subtitle.Cue) *decodeResult {
+	expected := make(map[int]bool, len(cues))
+	for _, cue := range cues {
+		expected[cue.Index] = true
+	}
+
+	result := &decodeResult{Texts: make(map[int]string)}
+	seen := make(map[int]bool)
+	last := -1
+	current := -1
+
+	output = strings.ReplaceAll(output, "\r\n", "\n")
+	for _, line := range strings.Split(output, "\n") {
+		m := cueLinePattern.FindStringSubmatch(line)
+		if m == nil {
+			// 无编号的非空行视为上一条的续行
+			if current >= 0 && strings.TrimSpace(line) != "" && strings.TrimSpace(line) != "```" {
+				result.Texts[current] += lineBreak + strings.TrimSpace(line)
+			}
+			continue
+		}
+
+		id, _ := strconv.Atoi(m[1])
+		current = -1
+		if !expected[id] {
+			result.Unknown = append(result.Unknown, id)
+			continue
+		}
+		if seen[id] {
+			result.Duplicated = append(result.Duplicated, id)
+			continue
+		}
+		if id < last {
+			result.Reordered = true
+		}
+
+		seen[id] = true
+		last = id
+		current = id
+		result.Texts[id] = strings.TrimSpace(m[2])
+	}
+
+	for _, cue := range cues {
+		text, ok := result.Texts[cue.Index]
+		// 源文本非空而译文为空，多为被合并到相邻条目
+		if !ok || (text == "" && strings.TrimSpace(cue.Text) != "") {
+			delete(result.Texts, cue.Index)
+			result.Missing = append(result.Missing, cue.Index)
+		}
+	}
+	sort.Ints(result.Missing)
+
+	for id, text := range result.Texts {
+		result.Texts[id] = decodeText(text)
+	}
+
+	return result
+}
+
+// decodeText 还原条目内换行
+func decodeText(text string) string {
+	parts := strings.Split(text, "<br>")
+	for i, part := range parts {
+		parts[i] = strings.TrimSpace(part)
+	}
+	return strings.Join(parts, "\n")
+}
+
+// formatIDs 格式化编号列表用于日志和错误信息
+func formatIDs(ids []int) string {
+	s := make([]string, len(ids))
+	for i, id := range ids {
+		s[i] = strconv.Itoa(id)
+	}
+	return strings.Join(s, ",")
+}
@@ -0,0 +1,124 @@
+package translator
+
+import (
+	"ai-translate/internal/infrastructure/subtitle"
+	"reflect"
+	"testing"
+)
+
+// newCues 按序号构造条目，序号从1开始
+func newCues(texts ...string) []*subtitle.Cue {
+	cues := make([]*subtitle.Cue, len(texts))
+	for i, text := range texts {
+		cues[i] = &subtitle.Cue{Index: i + 1, Text: text}
+	}
+	return cues
+}
+
+func TestEncodeCues(t *testing.T) {
+	cases := []struct {
+		name    string
+		context []contextCue
+		cues    []*subtitle.Cue
+		want    string
+	}{
+		{
+			name: "条目内换行替换为占位符",
+			cues: newCues("Hello", " first\nsecond "),
+			want: "[1] Hello\n[2] first <br> second\n",
+		},
+		{
+			name: "上文条目单独成段，已翻译的带上译文",
+			context: []contextCue{
+				{Index: 1, Source: "Hi", Translation: "你好"},
+				{Index: 2, Source: "Bye"},
+			},
+			cues: []*subtitle.Cue{{Index: 3, Text: "Thanks"}},
+			want: "上文（仅供参考，不要翻译或输出）：\n[1] Hi => 你好\n[2] Bye\n\n需要翻译的内容：\n[3] Thanks\n",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := encodeCues(c.context, c.cues); got != c.want {
+				t.Errorf("encodeCues = %q, 期望 %q", got, c.want)
+			}
+		})
+	}
+}
+
+func TestDecodeCues(t *testing.T) {
+	cues := newCues("one", "two\nlines", "three")
+
+	cases := []struct {
+		name       string
+		output     string
+		texts      map[int]string
+		missing    []int
+		duplicated []int
+		unknown    []int
+		reordered  bool
+	}{
+		{
+			name:   "逐行对齐并还原换行",
+			output: "[1] 一\n[2] 二 <br> 行\n[3] 三\n",
+			texts:  map[int]string{1: "一", 2: "二\n行", 3: "三"},
+		},
+		{
+			name:   "忽略代码块标记与CRLF，无编号行作为上一条的续行",
+			output: "```\r\n[1] 一\r\n[2] 二\r\n行\r\n[3] 三\r\n```",
+			texts:  map[int]string{1: "一", 2: "二\n行", 3: "三"},
+		},
+		{
+			name:    "缺失条目",
+			output:  "[1] 一\n[3] 三",
+			texts:   map[int]string{1: "一", 3: "三"},
+			missing: []int{2},
+		},
+		{
+			name:    "被合并到相邻条目的空译文视为缺失",
+			output:  "[1] 一 二\n[2]\n[3] 三",
+			texts:   map[int]string{1: "一 二", 3: "三"},
+			missing: []int{2},
+		},
+		{
+			name:       "重复编号保留第一次出现",
+			output:     "[1] 一\n[2] 二\n[2] 重复\n[3] 三",
+			texts:      map[int]string{1: "一", 2: "二", 3: "三"},
+			duplicated: []int{2},
+		},
+		{
+			name:    "不属于本次请求的编号",
+			output:  "[1] 一\n[2] 二\n[3] 三\n[4] 多余",
+			texts:   map[int]string{1: "一", 2: "二", 3: "三"},
+			unknown: []int{4},
+		},
+		{
+			name:      "顺序被打乱",
+			output:    "[2] 二\n[1] 一\n[3] 三",
+			texts:     map[int]string{1: "一", 2: "二", 3: "三"},
+			reordered: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			result := decodeCues(c.output, cues)
+			if !reflect.DeepEqual(result.Texts, c.texts) {
+				t.Errorf("译文 = %q, 期望 %q", result.Texts, c.texts)
+			}
+			if !reflect.DeepEqual(result.Missing, c.missing) {
+				t.Errorf("缺失 = %v, 期望 %v", result.Missing, c.missing)
+			}
+			if !reflect.DeepEqual(result.Duplicated, c.duplicated) {
+				t.Errorf("重复 = %v, 期望 %v", result.Duplicated, c.duplicated)
+			}
+			if !reflect.DeepEqual(result.Unknown, c.unknown) {
+				t.Errorf("多余 = %v, 期望 %v", result.Unknown, c.unknown)
+			}
+			if result.Reordered != c.reordered {
+				t.Errorf("乱序 = %v, 期望 %v", result.Reordered, c.reordered)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package translator
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// Progress 翻译进度
+type Progress struct {
+	Done    int    // 已完成条目数
+	Total   int    // 条目总数
+	Partial string // 已完成部分的译文
+}
+
+// Percent 完成百分比
+func (p Progress) Percent() int {
+	if p.Total <= 0 {
+		return 0
+	}
+	return p.Done * 100 / p.Total
+}
+
+// Finished 是否已全部完成
+func (p Progress) Finished() bool {
+	return p.Total > 0 && p.Done >= p.Total
+}
+
+// ProgressFunc 进度回调
+type ProgressFunc func(progress Progress)
+
+// ProgressInterval 处理中的进度与部分结果写入任务的最小间隔
+const ProgressInterval = time.Second
+
+// progressGate 进度回调限频，interval内只放行一次，完成时总会放行
+type progressGate struct {
+	mu       sync.Mutex
+	last     time.Time
+	interval time.Duration
+}
+
+// allow 是否放行本次回调
+func (g *progressGate) allow(finished bool) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	now := time.Now()
+	if !finished && now.Sub(g.last) < g.interval {
+		return false
+	}
+	g.last = now
+	return true
+}
+
+// ThrottleProgress 限制进度回调频率，interval内只回调一次，完成时的进度总会回调
+func ThrottleProgress(fn ProgressFunc, interval time.Duration) ProgressFunc {
+	gate := &progressGate{interval: interval}
+	return func(progress Progress) {
+		if gate.allow(progress.Finished()) {
+			fn(progress)
+		}
+	}
+}
+
+// StreamProgress 将流式输出的增量累积为部分结果，按interval限频回调，只在回调时生成部分结果字符串
+// 流式输出完成前Done为0，完成后的进度由调用方上报
+func StreamProgress(fn ProgressFunc, interval time.Duration) func(delta string) {
+	gate := &progressGate{interval: interval}
+	var partial strings.Builder
+	return func(delta string) {
+		partial.WriteString(delta)
+		if gate.allow(false) {
+			fn(Progress{Total: 1, Partial: partial.String()})
+		}
+	}
+}
+
+// cueProgress 按条目统计的翻译进度，并行分块完成时并发调用
+type cueProgress struct {
+	mu      sync.Mutex
+	total   int
+	texts   map[int]string
+	partial func(texts map[int]string) string
+	report  ProgressFunc
+}
+
+// add 记录一个分块的译文并上报进度
+func (p *cueProgress) add(texts map[int]string) {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	for id, text := range texts {
+		p.texts[id] = text
+	}
+	progress := Progress{
+		Done:  len(p.texts),
+		Total: p.total,
+	}
+	if p.partial != nil {
+		progress.Partial = p.partial(p.texts)
+	}
+	p.mu.Unlock()
+
+	p.report(progress)
+}
@@ -0,0 +1,391 @@
+package translator
+
+import (
+	"ai-translate/internal/infrastructure/glossary"
+	"ai-translate/internal/infrastructure/subtitle"
+	"context"
+	"fmt"
+	"github.com/gogf/gf/v2/frame/g"
+	"sync"
+	"time"
+)
+
+// TranslateFunc 调用模型翻译一段已编码的条目文本
+type TranslateFunc func(ctx context.Context, content string) (string, error)
+
+// Config 翻译器配置
+type Config struct {
+	ChunkTokens       int // 单块译文条目的token预算
+	ContextCues       int // 每块携带的上文条目数
+	Concurrency       int // 并行翻译的分块数，1为顺序翻译
+	RequestsPerMinute int // 服务商每分钟请求上限，0为不限制
+	MaxAttempts       int // 条目对齐失败时的最大尝试次数
+}
+
+// NewConfig 从配置文件读取指定AI驱动的翻译器配置
+func NewConfig(ctx context.Context, driver string) Config {
+	return Config{
+		ChunkTokens:       g.Cfg().MustGet(ctx, "translator.chunkTokens", 1500).Int(),
+		ContextCues:       g.Cfg().MustGet(ctx, "translator.contextCues", 3).Int(),
+		Concurrency:       g.Cfg().MustGet(ctx, "ai."+driver+".concurrency", 1).Int(),
+		RequestsPerMinute: g.Cfg().MustGet(ctx, "ai."+driver+".rpm", 0).Int(),
+		MaxAttempts:       g.Cfg().MustGet(ctx, "translator.maxAttempts", 3).Int(),
+	}
+}
+
+// Result 翻译结果
+type Result struct {
+	Texts      []string              // 与源条目一一对应的译文
+	Violations []*glossary.Violation // 重试后仍未遵守术语表的条目
+}
+
+// CueTranslator 按条目对齐的字幕翻译器
+type CueTranslator struct {
+	translate TranslateFunc
+	chunker   *Chunker
+	config    Config
+	limiter   *rateLimiter
+	glossary  *glossary.Glossary
+	progress  ProgressFunc
+	render    func(texts map[int]string) string
+	resume    map[int]string
+}
+
+// NewCueTranslator 创建字幕翻译器
+func NewCueTranslator(translate TranslateFunc, config Config) *CueTranslator {
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = 3
+	}
+	if config.Concurrency <= 0 {
+		config.Concurrency = 1
+	}
+	return &CueTranslator{
+		translate: translate,
+		chunker:   NewChunker(config.ChunkTokens, config.ContextCues),
+		config:    config,
+		limiter:   newRateLimiter(config.RequestsPerMinute),
+	}
+}
+
+// WithGlossary 设置术语表，每块只注入该块中出现的术语
+func (t *CueTranslator) WithGlossary(gl *glossary.Glossary) *CueTranslator {
+	t.glossary = gl
+	return t
+}
+
+// WithProgress 设置进度回调，每完成一个分块回调一次
+// render用于将已完成条目的译文渲染为部分结果，可为nil
+func (t *CueTranslator) WithProgress(progress ProgressFunc, render func(texts map[int]string) string) *CueTranslator {
+	t.progress = progress
+	t.render = render
+	return t
+}
+
+// WithResume 设置上次处理已完成条目的译文（条目序号 => 译文），条目均已完成的分块不再翻译
+func (t *CueTranslator) WithResume(texts map[int]string) *CueTranslator {
+	t.resume = texts
+	return t
+}
+
+// Translate 分块翻译字幕条目
+// 顺序翻译时上文携带已完成的译文；并行翻译时上文仅携带原文
+func (t *CueTranslator) Translate(ctx context.Context, cues []*subtitle.Cue) (*Result, error) {
+	chunks := t.chunker.Split(cues)
+	results := make([]*chunkResult, len(chunks))
+	progress := t.newProgress(len(cues))
+
+	if t.config.Concurrency == 1 || len(chunks) == 1 {
+		translated := make(map[int]string, len(cues))
+		for i, chunk := range chunks {
+			result := t.resumed(chunk)
+			if result == nil {
+				var err error
+				result, err = t.translateChunk(ctx, buildContext(chunk.Context, translated), chunk.Cues)
+				if err != nil {
+					return nil, fmt.Errorf("第%d/%d块翻译失败: %w", i+1, len(chunks), err)
+				}
+			}
+			for id, text := range result.texts {
+				translated[id] = text
+			}
+			results[i] = result
+			progress.add(result.texts)
+		}
+	} else if err := t.translateParallel(ctx, chunks, results, progress); err != nil {
+		return nil, err
+	}
+
+	// 按源条目顺序拼接各块译文
+	merged := make(map[int]string, len(cues))
+	var violations []*glossary.Violation
+	for _, result := range results {
+		for id, text := range result.texts {
+			merged[id] = text
+		}
+		violations = append(violations, result.violations...)
+	}
+
+	translated := make([]string, len(cues))
+	var missing []int
+	for i, cue := range cues {
+		text, ok := merged[cue.Index]
+		if !ok {
+			missing = append(missing, cue.Index)
+			continue
+		}
+		translated[i] = text
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("译文条目对齐失败，缺失条目: %s", formatIDs(missing))
+	}
+
+	return &Result{
+		Texts:      translated,
+		Violations: violations,
+	}, nil
+}
+
+// translateParallel 并行翻译各分块，任一分块失败即取消其余分块
+func (t *CueTranslator) translateParallel(ctx context.Context, chunks []*Chunk, results []*chunkResult, progress *cueProgress) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	sem := make(chan struct{}, t.config.Concurrency)
+
+	for i, chunk := range chunks {
+		if result := t.resumed(chunk); result != nil {
+			results[i] = result
+			progress.add(result.texts)
+			continue
+		}
+
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(i int, chunk *Chunk) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			result, err := t.translateChunk(ctx, buildContext(chunk.Context, nil), chunk.Cues)
+			if err != nil {
+				once.Do(func() {
+					firstErr = fmt.Errorf("第%d/%d块翻译失败: %w", i+1, len(chunks), err)
+					cancel()
+				})
+				return
+			}
+			results[i] = result
+			progress.add(result.texts)
+		}(i, chunk)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
+
+// newProgress 创建进度统计，未设置进度回调时返回nil
+func (t *CueTranslator) newProgress(total int) *cueProgress {
+	if t.progress == nil {
+		return nil
+	}
+	return &cueProgress{
+		total:   total,
+		texts:   make(map[int]string, total),
+		partial: t.render,
+		report:  t.progress,
+	}
+}
+
+// resumed 分块条目均有上次处理的译文时直接返回这些译文，否则返回nil
+func (t *CueTranslator) resumed(chunk *Chunk) *chunkResult {
+	if len(t.resume) == 0 {
+		return nil
+	}
+	texts := make(map[int]string, len(chunk.Cues))
+	for _, cue := range chunk.Cues {
+		text, ok := t.resume[cue.Index]
+		if !ok {
+			return nil
+		}
+		texts[cue.Index] = text
+	}
+	return &chunkResult{texts: texts}
+}
+
+// chunkResult 分块翻译结果
+type chunkResult struct {
+	texts      map[int]string
+	violations []*glossary.Violation
+}
+
+// translateChunk 翻译单个分块，缺失或未遵守术语表的条目单独重试
+func (t *CueTranslator) translateChunk(ctx context.Context, contextCues []contextCue, cues []*subtitle.Cue) (*chunkResult, error) {
+	texts := make(map[int]string, len(cues))
+	broken := make(map[int][]*glossary.Term)
+	pending := cues
+
+	for attempt := 1; attempt <= t.config.MaxAttempts && len(pending) > 0; attempt++ {
+		if err := t.limiter.wait(ctx); err != nil {
+			return nil, err
+		}
+
+		output, err := t.translate(ctx, t.buildPrompt(contextCues, pending))
+		if err != nil {
+			return nil, err
+		}
+
+		result := decodeCues(output, pending)
+		for id, text := range result.Texts {
+			texts[id] = text
+		}
+
+		if result.Reordered || len(result.Duplicated) > 0 || len(result.Unknown) > 0 {
+			g.Log().Warningf(ctx, "译文条目未对齐: 乱序=%v, 重复=[%s], 多余=[%s]",
+				result.Reordered, formatIDs(result.Duplicated), formatIDs(result.Unknown))
+		}
+
+		// 检查术语表，未遵守的条目与缺失条目一起重试
+		retry := result.Missing
+		var violated []int
+		for _, cue := range pending {
+			text, ok := result.Texts[cue.Index]
+			if !ok || t.glossary == nil {
+				continue
+			}
+			if terms := t.glossary.Check(cue.Text, text); len(terms) > 0 {
+				broken[cue.Index] = terms
+				violated = append(violated, cue.Index)
+			} else {
+				delete(broken, cue.Index)
+			}
+		}
+		retry = append(retry, violated...)
+		if len(retry) == 0 {
+			break
+		}
+
+		g.Log().Warningf(ctx, "译文缺失条目[%s]，未遵守术语表条目[%s]，第%d次重试",
+			formatIDs(result.Missing), formatIDs(violated), attempt)
+		pending = filterCues(pending, retry)
+	}
+
+	violations := make([]*glossary.Violation, 0, len(broken))
+	for _, cue := range cues {
+		for _, term := range broken[cue.Index] {
+			violations = append(violations, &glossary.Violation{
+				CueIndex: cue.Index,
+				Term:     term,
+			})
+		}
+	}
+
+	return &chunkResult{
+		texts:      texts,
+		violations: violations,
+	}, nil
+}
+
+// buildPrompt 构建分块请求内容，只注入本块条目中出现的术语
+func (t *CueTranslator) buildPrompt(contextCues []contextCue, cues []*subtitle.Cue) string {
+	content := encodeCues(contextCues, cues)
+	if t.glossary == nil {
+		return content
+	}
+
+	texts := make([]string, len(cues))
+	for i, cue := range cues {
+		texts[i] = cue.Text
+	}
+	section := glossary.PromptSection(t.glossary.Match(texts...))
+	if section == "" {
+		return content
+	}
+	return section + "\n" + content
+}
+
+// buildContext 构建上文条目，translated中已有的译文一并带上
+func buildContext(cues []*subtitle.Cue, translated map[int]string) []contextCue {
+	result := make([]contextCue, 0, len(cues))
+	for _, cue := range cues {
+		result = append(result, contextCue{
+			Index:       cue.Index,
+			Source:      cue.Text,
+			Translation: translated[cue.Index],
+		})
+	}
+	return result
+}
+
+// filterCues 按编号筛选条目
+func filterCues(cues []*subtitle.Cue, ids []int) []*subtitle.Cue {
+	wanted := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+
+	filtered := make([]*subtitle.Cue, 0, len(ids))
+	for _, cue := range cues {
+		if wanted[cue.Index] {
+			filtered = append(filtered, cue)
+		}
+	}
+	return filtered
+}
+
+// rateLimiter 按固定间隔放行请求的限流器
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+// newRateLimiter 创建限流器，rpm<=0时不限流
+func newRateLimiter(rpm int) *rateLimiter {
+	if rpm <= 0 {
+		return &rateLimiter{}
+	}
+	return &rateLimiter{interval: time.Minute / time.Duration(rpm)}
+}
+
+// wait 等待下一个可用的请求时间点
+func (l *rateLimiter) wait(ctx context.Context) error {
+	if l.interval == 0 {
+		return nil
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	delay := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+	l.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
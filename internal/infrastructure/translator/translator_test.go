@@ -0,0 +1,203 @@
+package translator
+
+import (
+	"ai-translate/internal/infrastructure/glossary"
+	"ai-translate/internal/infrastructure/subtitle"
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// indexes 获取条目序号列表
+func indexes(cues []*subtitle.Cue) []int {
+	result := make([]int, 0, len(cues))
+	for _, cue := range cues {
+		result = append(result, cue.Index)
+	}
+	return result
+}
+
+// fakeModel 按脚本应答的模型，reply根据第几次请求与请求中的条目生成输出
+type fakeModel struct {
+	mu       sync.Mutex
+	requests [][]int
+	reply    func(call int, ids []int, texts map[int]string) string
+}
+
+// translate 实现TranslateFunc，只解析"需要翻译的内容"中的条目
+func (m *fakeModel) translate(ctx context.Context, content string) (string, error) {
+	if i := strings.Index(content, "需要翻译的内容：\n"); i >= 0 {
+		content = content[i:]
+	}
+	var ids []int
+	texts := make(map[int]string)
+	for _, line := range strings.Split(content, "\n") {
+		if match := cueLinePattern.FindStringSubmatch(line); match != nil {
+			var id int
+			fmt.Sscanf(match[1], "%d", &id)
+			ids = append(ids, id)
+			texts[id] = match[2]
+		}
+	}
+
+	m.mu.Lock()
+	m.requests = append(m.requests, ids)
+	call := len(m.requests)
+	m.mu.Unlock()
+	return m.reply(call, ids, texts), nil
+}
+
+// echo 将每个条目译为"译文:原文"
+func echo(ids []int, texts map[int]string, skip ...int) string {
+	var b strings.Builder
+	for _, id := range ids {
+		skipped := false
+		for _, s := range skip {
+			skipped = skipped || s == id
+		}
+		if !skipped {
+			fmt.Fprintf(&b, "[%d] 译文:%s\n", id, texts[id])
+		}
+	}
+	return b.String()
+}
+
+func TestCueTranslatorRepair(t *testing.T) {
+	gl := &glossary.Glossary{Terms: []*glossary.Term{
+		{Source: "AI", Target: "人工智能", Required: true},
+	}}
+
+	cases := []struct {
+		name        string
+		glossary    *glossary.Glossary
+		maxAttempts int
+		reply       func(call int, ids []int, texts map[int]string) string
+		requests    [][]int
+		texts       []string
+		violations  []int
+		wantErr     bool
+	}{
+		{
+			name:        "一次对齐成功",
+			maxAttempts: 3,
+			reply: func(call int, ids []int, texts map[int]string) string {
+				return echo(ids, texts)
+			},
+			requests: [][]int{{1, 2, 3}},
+			texts:    []string{"译文:one", "译文:AI two", "译文:three"},
+		},
+		{
+			name:        "缺失条目单独重试",
+			maxAttempts: 3,
+			reply: func(call int, ids []int, texts map[int]string) string {
+				if call == 1 {
+					return echo(ids, texts, 2)
+				}
+				return echo(ids, texts)
+			},
+			requests: [][]int{{1, 2, 3}, {2}},
+			texts:    []string{"译文:one", "译文:AI two", "译文:three"},
+		},
+		{
+			name:        "重试次数用尽仍缺失时失败",
+			maxAttempts: 2,
+			reply: func(call int, ids []int, texts map[int]string) string {
+				return echo(ids, texts, 3)
+			},
+			requests: [][]int{{1, 2, 3}, {3}},
+			wantErr:  true,
+		},
+		{
+			name:        "未遵守术语表的条目重试",
+			glossary:    gl,
+			maxAttempts: 3,
+			reply: func(call int, ids []int, texts map[int]string) string {
+				if call == 1 {
+					return echo(ids, texts)
+				}
+				return "[2] 人工智能 二\n"
+			},
+			requests: [][]int{{1, 2, 3}, {2}},
+			texts:    []string{"译文:one", "人工智能 二", "译文:three"},
+		},
+		{
+			name:        "重试后仍未遵守术语表时记录违规",
+			glossary:    gl,
+			maxAttempts: 2,
+			reply: func(call int, ids []int, texts map[int]string) string {
+				return echo(ids, texts)
+			},
+			requests:   [][]int{{1, 2, 3}, {2}},
+			texts:      []string{"译文:one", "译文:AI two", "译文:three"},
+			violations: []int{2},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			model := &fakeModel{reply: c.reply}
+			translator := NewCueTranslator(model.translate, Config{ChunkTokens: 1000, MaxAttempts: c.maxAttempts}).
+				WithGlossary(c.glossary)
+
+			result, err := translator.Translate(context.Background(), newCues("one", "AI two", "three"))
+			if !reflect.DeepEqual(model.requests, c.requests) {
+				t.Errorf("请求条目 = %v, 期望 %v", model.requests, c.requests)
+			}
+			if c.wantErr {
+				if err == nil {
+					t.Fatalf("期望翻译失败，实际得到 %q", result.Texts)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("翻译失败: %v", err)
+			}
+			if !reflect.DeepEqual(result.Texts, c.texts) {
+				t.Errorf("译文 = %q, 期望 %q", result.Texts, c.texts)
+			}
+			var violations []int
+			for _, v := range result.Violations {
+				violations = append(violations, v.CueIndex)
+			}
+			if !reflect.DeepEqual(violations, c.violations) {
+				t.Errorf("违规条目 = %v, 期望 %v", violations, c.violations)
+			}
+		})
+	}
+}
+
+func TestCueTranslatorResume(t *testing.T) {
+	cases := []struct {
+		name        string
+		concurrency int
+	}{
+		{"顺序翻译", 1},
+		{"并行翻译", 2},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			model := &fakeModel{reply: func(call int, ids []int, texts map[int]string) string {
+				return echo(ids, texts)
+			}}
+			// 每块两条，第一块已在上次处理中完成
+			translator := NewCueTranslator(model.translate, Config{ChunkTokens: 12, Concurrency: c.concurrency}).
+				WithResume(map[int]string{1: "上次1", 2: "上次2", 3: "上次3"})
+
+			result, err := translator.Translate(context.Background(), newCues("一", "二", "三", "四"))
+			if err != nil {
+				t.Fatalf("翻译失败: %v", err)
+			}
+			if !reflect.DeepEqual(model.requests, [][]int{{3, 4}}) {
+				t.Errorf("请求条目 = %v, 期望只翻译未完成的分块", model.requests)
+			}
+			want := []string{"上次1", "上次2", "译文:三", "译文:四"}
+			if !reflect.DeepEqual(result.Texts, want) {
+				t.Errorf("译文 = %q, 期望 %q", result.Texts, want)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package utils
 
 import (
 	"bytes"
+	"context"
 	"crypto/aes"
 	"crypto/cipher"
 	"encoding/base64"
@@ -10,7 +11,7 @@ import (
 
 // AESEncrypt AES加密
 func AESEncrypt(plaintext []byte) (string, error) {
-	key := []byte(g.Cfg().MustGet("aes.key").String())
+	key := []byte(g.Cfg().MustGet(context.Background(), "aes.key").String())
 	block, err := aes.NewCipher(key)
 	if err != nil {
 		return "", err
@@ -32,7 +33,7 @@ func AESEncrypt(plaintext []byte) (string, error) {
 
 // AESDecrypt AES解密
 func AESDecrypt(ciphertext string) ([]byte, error) {
-	key := []byte(g.Cfg().MustGet("aes.key").String())
+	key := []byte(g.Cfg().MustGet(context.Background(), "aes.key").String())
 	block, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, err
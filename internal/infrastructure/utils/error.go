@@ -35,16 +35,28 @@ const (
 type ErrorResponse struct {
 	Code    int    `json:"code"`
 	Message string `json:"message"`
+	err     error
 }
 
-// NewError 创建错误响应
-func NewError(code int, message string) *ErrorResponse {
+// NewError 根据错误类型创建错误响应
+func NewError(err error, message string) *ErrorResponse {
 	return &ErrorResponse{
-		Code:    code,
+		Code:    GetErrorCode(err),
 		Message: message,
+		err:     err,
 	}
 }
 
+// Error 实现error接口
+func (e *ErrorResponse) Error() string {
+	return e.Message
+}
+
+// Unwrap 返回错误类型
+func (e *ErrorResponse) Unwrap() error {
+	return e.err
+}
+
 // IsError 判断是否为错误响应
 func IsError(err error) bool {
 	return err != nil
@@ -52,24 +64,24 @@ func IsError(err error) bool {
 
 // GetErrorCode 获取错误码
 func GetErrorCode(err error) int {
-	switch err {
-	case ErrInvalidParams:
+	switch {
+	case errors.Is(err, ErrInvalidParams):
 		return CodeInvalidParams
-	case ErrUnauthorized:
+	case errors.Is(err, ErrUnauthorized):
 		return CodeUnauthorized
-	case ErrForbidden:
+	case errors.Is(err, ErrForbidden):
 		return CodeForbidden
-	case ErrNotFound:
+	case errors.Is(err, ErrNotFound):
 		return CodeNotFound
-	case ErrDatabaseOperation:
+	case errors.Is(err, ErrDatabaseOperation):
 		return CodeDatabaseError
-	case ErrRedisOperation:
+	case errors.Is(err, ErrRedisOperation):
 		return CodeRedisError
-	case ErrOSSOperation:
+	case errors.Is(err, ErrOSSOperation):
 		return CodeOSSError
-	case ErrAIOperation:
+	case errors.Is(err, ErrAIOperation):
 		return CodeAIError
-	case ErrTaskOperation:
+	case errors.Is(err, ErrTaskOperation):
 		return CodeTaskError
 	default:
 		return CodeInternalServer
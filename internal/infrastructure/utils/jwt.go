@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"context"
 	"errors"
 	"github.com/gogf/gf/v2/frame/g"
 	"github.com/golang-jwt/jwt/v4"
@@ -18,8 +19,8 @@ type Claims struct {
 // GenerateToken 生成JWT token
 func GenerateToken(userID uint64, username string, roleType int) (string, error) {
 	// 获取JWT配置
-	secret := g.Cfg().MustGet("jwt.secret").String()
-	expire := g.Cfg().MustGet("jwt.expire").Int()
+	secret := g.Cfg().MustGet(context.Background(), "jwt.secret").String()
+	expire := g.Cfg().MustGet(context.Background(), "jwt.expire").Int()
 
 	// 创建声明
 	claims := Claims{
@@ -41,7 +42,7 @@ func GenerateToken(userID uint64, username string, roleType int) (string, error)
 // ParseToken 解析JWT token
 func ParseToken(tokenString string) (*Claims, error) {
 	// 获取JWT配置
-	secret := g.Cfg().MustGet("jwt.secret").String()
+	secret := g.Cfg().MustGet(context.Background(), "jwt.secret").String()
 
 	// 解析token
 	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
@@ -21,8 +21,8 @@ func Success(r *ghttp.Request, data interface{}) {
 	})
 }
 
-// Error 错误响应
-func Error(r *ghttp.Request, code int, msg string) {
+// Fail 错误响应
+func Fail(r *ghttp.Request, code int, msg string) {
 	r.Response.WriteJsonExit(Response{
 		Code: code,
 		Msg:  msg,
@@ -2,6 +2,7 @@ package utils
 
 import (
 	"errors"
+	"path/filepath"
 	"regexp"
 	"strings"
 )
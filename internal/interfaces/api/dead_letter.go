@@ -0,0 +1,164 @@
+package api
+
+import (
+	"ai-translate/internal/infrastructure/queue"
+	"ai-translate/internal/infrastructure/utils"
+	"ai-translate/internal/model"
+	"context"
+	"errors"
+	"time"
+)
+
+// DeadLetterInfo 死信消息信息
+type DeadLetterInfo struct {
+	TaskID   string    `json:"task_id"`
+	Type     string    `json:"type"`
+	Data     string    `json:"data"`
+	Retries  int       `json:"retries"`
+	Error    string    `json:"error"`
+	FailedAt time.Time `json:"failed_at"`
+}
+
+// newDeadLetterInfo 转换死信消息
+func newDeadLetterInfo(msg *queue.Message) DeadLetterInfo {
+	return DeadLetterInfo{
+		TaskID:   msg.ID,
+		Type:     msg.Type,
+		Data:     string(msg.Data),
+		Retries:  msg.Retries,
+		Error:    msg.Error,
+		FailedAt: msg.FailedAt,
+	}
+}
+
+// checkQueueType 检查任务类型是否为 rabbitmq.queues 中配置的任务队列
+func checkQueueType(ctx context.Context, taskType string) error {
+	for _, config := range queue.LoadConsumerConfigs(ctx, 1) {
+		if config.TaskType == taskType {
+			return nil
+		}
+	}
+	return utils.NewError(utils.ErrInvalidParams, "无效的任务类型")
+}
+
+// ListDeadLettersReq 获取死信消息列表请求
+type ListDeadLettersReq struct {
+	Type  string `json:"type" v:"required#任务类型不能为空"`
+	Limit int    `json:"limit" d:"50" v:"min:1,max:500#数量必须在1-500之间"`
+}
+
+// ListDeadLettersRes 获取死信消息列表响应
+type ListDeadLettersRes struct {
+	List []DeadLetterInfo `json:"list"`
+}
+
+// ListDeadLetters 获取死信消息列表
+func (c *TaskController) ListDeadLetters(ctx context.Context, req *ListDeadLettersReq) (*ListDeadLettersRes, error) {
+	if err := checkQueueType(ctx, req.Type); err != nil {
+		return nil, err
+	}
+
+	messages, err := c.taskService.ListDeadLetters(ctx, model.TaskType(req.Type), req.Limit)
+	if err != nil {
+		return nil, utils.NewError(utils.ErrInternalServer, "获取死信消息列表失败")
+	}
+
+	list := make([]DeadLetterInfo, 0, len(messages))
+	for _, msg := range messages {
+		list = append(list, newDeadLetterInfo(msg))
+	}
+
+	return &ListDeadLettersRes{
+		List: list,
+	}, nil
+}
+
+// GetDeadLetterReq 获取死信消息请求
+type GetDeadLetterReq struct {
+	Type   string `json:"type" v:"required#任务类型不能为空"`
+	TaskID string `json:"id" v:"required#任务ID不能为空"`
+}
+
+// GetDeadLetterRes 获取死信消息响应
+type GetDeadLetterRes struct {
+	DeadLetterInfo
+}
+
+// GetDeadLetter 获取死信消息
+func (c *TaskController) GetDeadLetter(ctx context.Context, req *GetDeadLetterReq) (*GetDeadLetterRes, error) {
+	if err := checkQueueType(ctx, req.Type); err != nil {
+		return nil, err
+	}
+
+	msg, err := c.taskService.GetDeadLetter(ctx, model.TaskType(req.Type), req.TaskID)
+	if err != nil {
+		if errors.Is(err, queue.ErrDeadLetterNotFound) {
+			return nil, utils.NewError(utils.ErrNotFound, "死信消息不存在")
+		}
+		return nil, utils.NewError(utils.ErrInternalServer, "获取死信消息失败")
+	}
+
+	return &GetDeadLetterRes{
+		DeadLetterInfo: newDeadLetterInfo(msg),
+	}, nil
+}
+
+// RequeueDeadLettersReq 重新投递死信消息请求
+type RequeueDeadLettersReq struct {
+	Type   string `json:"type" v:"required#任务类型不能为空"`
+	TaskID string `json:"task_id"` // 为空时重新投递全部
+}
+
+// RequeueDeadLettersRes 重新投递死信消息响应
+type RequeueDeadLettersRes struct {
+	Count int `json:"count"`
+}
+
+// RequeueDeadLetters 重新投递死信消息
+func (c *TaskController) RequeueDeadLetters(ctx context.Context, req *RequeueDeadLettersReq) (*RequeueDeadLettersRes, error) {
+	if err := checkQueueType(ctx, req.Type); err != nil {
+		return nil, err
+	}
+
+	count, err := c.taskService.RequeueDeadLetters(ctx, model.TaskType(req.Type), req.TaskID)
+	if err != nil {
+		if errors.Is(err, queue.ErrDeadLetterNotFound) {
+			return nil, utils.NewError(utils.ErrNotFound, "死信消息不存在")
+		}
+		return nil, utils.NewError(utils.ErrInternalServer, "重新投递死信消息失败")
+	}
+
+	return &RequeueDeadLettersRes{
+		Count: count,
+	}, nil
+}
+
+// PurgeDeadLettersReq 删除死信消息请求
+type PurgeDeadLettersReq struct {
+	Type   string `json:"type" v:"required#任务类型不能为空"`
+	TaskID string `json:"task_id"` // 为空时清空死信队列
+}
+
+// PurgeDeadLettersRes 删除死信消息响应
+type PurgeDeadLettersRes struct {
+	Count int `json:"count"`
+}
+
+// PurgeDeadLetters 删除死信消息
+func (c *TaskController) PurgeDeadLetters(ctx context.Context, req *PurgeDeadLettersReq) (*PurgeDeadLettersRes, error) {
+	if err := checkQueueType(ctx, req.Type); err != nil {
+		return nil, err
+	}
+
+	count, err := c.taskService.PurgeDeadLetters(ctx, model.TaskType(req.Type), req.TaskID)
+	if err != nil {
+		if errors.Is(err, queue.ErrDeadLetterNotFound) {
+			return nil, utils.NewError(utils.ErrNotFound, "死信消息不存在")
+		}
+		return nil, utils.NewError(utils.ErrInternalServer, "删除死信消息失败")
+	}
+
+	return &PurgeDeadLettersRes{
+		Count: count,
+	}, nil
+}
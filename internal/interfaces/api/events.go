@@ -0,0 +1,88 @@
+package api
+
+import (
+	"ai-translate/internal/infrastructure/event"
+	"ai-translate/internal/infrastructure/utils"
+	"encoding/json"
+	"fmt"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/net/ghttp"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// eventHeartbeatInterval SSE心跳间隔，防止代理断开空闲连接
+const eventHeartbeatInterval = 15 * time.Second
+
+// Events 通过SSE推送任务状态、进度与优先级调整事件
+// 可按 task_id、batch_id、work_id 查询参数订阅，均为空时订阅调用者的全部任务；管理员可订阅全部用户的任务
+func (c *TaskController) Events(r *ghttp.Request) {
+	filter := event.Filter{
+		TaskID:  r.Get("task_id").String(),
+		BatchID: r.Get("batch_id").String(),
+		WorkID:  r.Get("work_id").String(),
+	}
+
+	// 普通用户只能订阅自己作品下的任务
+	if r.GetCtxVar("user_role").Int() != utils.RoleTypeAdmin {
+		workIDs, err := c.userWorkIDs(r.GetCtxVar("user_id").Uint64())
+		if err != nil {
+			g.Log().Errorf(r.Context(), "获取用户作品失败: %v", err)
+			r.Response.WriteStatusExit(http.StatusInternalServerError)
+		}
+		if _, ok := workIDs[filter.WorkID]; filter.WorkID != "" && !ok {
+			r.Response.WriteStatusExit(http.StatusForbidden)
+		}
+		filter.WorkIDs = workIDs
+	}
+
+	sub := event.Subscribe(filter)
+	defer sub.Close()
+
+	r.Response.Header().Set("Content-Type", "text/event-stream")
+	r.Response.Header().Set("Cache-Control", "no-cache")
+	r.Response.Header().Set("Connection", "keep-alive")
+	r.Response.Header().Set("X-Accel-Buffering", "no")
+	r.Response.WriteHeader(http.StatusOK)
+	r.Response.Write(": connected\n\n")
+	r.Response.Flush()
+
+	heartbeat := time.NewTicker(eventHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	ctx := r.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-heartbeat.C:
+			r.Response.Write(": ping\n\n")
+			r.Response.Flush()
+		case e, ok := <-sub.Events():
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(e)
+			if err != nil {
+				g.Log().Warningf(ctx, "序列化任务事件失败: %v", err)
+				continue
+			}
+			r.Response.Write(fmt.Sprintf("event: %s\ndata: %s\n\n", e.Type, data))
+			r.Response.Flush()
+		}
+	}
+}
+
+// userWorkIDs 获取用户作品ID集合，用于限定事件订阅范围
+func (c *TaskController) userWorkIDs(userID uint64) (map[string]struct{}, error) {
+	works, err := c.workRepo.FindByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	workIDs := make(map[string]struct{}, len(works))
+	for _, w := range works {
+		workIDs[strconv.FormatUint(w.ID, 10)] = struct{}{}
+	}
+	return workIDs, nil
+}
@@ -5,14 +5,15 @@ import (
 	"compress/gzip"
 	"encoding/json"
 	"fmt"
+	"github.com/gogf/gf/v2/database/gredis"
+	"github.com/gogf/gf/v2/debug/gdebug"
 	"github.com/gogf/gf/v2/frame/g"
 	"github.com/gogf/gf/v2/net/ghttp"
 	"github.com/gogf/gf/v2/os/gtime"
-	"github.com/gogf/gf/v2/util/gconv"
-	"github.com/go-redis/redis/v8"
-	"ai-translate/internal/utils"
+	"github.com/google/uuid"
+	"ai-translate/internal/infrastructure/utils"
+	"ai-translate/internal/model"
 	"context"
-	"io/ioutil"
 	"strings"
 	"time"
 )
@@ -23,7 +24,7 @@ func Auth(r *ghttp.Request) {
 	token := r.Header.Get("Authorization")
 	if token == "" {
 		r.Response.WriteJson(ghttp.DefaultHandlerResponse{
-			Code:    utils.ErrUnauthorized,
+			Code:    utils.CodeUnauthorized,
 			Message: "未授权访问",
 		})
 		r.Exit()
@@ -33,7 +34,7 @@ func Auth(r *ghttp.Request) {
 	// 验证令牌格式
 	if !strings.HasPrefix(token, "Bearer ") {
 		r.Response.WriteJson(ghttp.DefaultHandlerResponse{
-			Code:    utils.ErrUnauthorized,
+			Code:    utils.CodeUnauthorized,
 			Message: "无效的令牌格式",
 		})
 		r.Exit()
@@ -44,10 +45,10 @@ func Auth(r *ghttp.Request) {
 	token = strings.TrimPrefix(token, "Bearer ")
 
 	// 验证令牌
-	claims, err := utils.ValidateToken(token)
+	claims, err := utils.ParseToken(token)
 	if err != nil {
 		r.Response.WriteJson(ghttp.DefaultHandlerResponse{
-			Code:    utils.ErrUnauthorized,
+			Code:    utils.CodeUnauthorized,
 			Message: "令牌验证失败: " + err.Error(),
 		})
 		r.Exit()
@@ -56,7 +57,21 @@ func Auth(r *ghttp.Request) {
 
 	// 将用户信息存储到上下文
 	r.SetCtxVar("user_id", claims.UserID)
-	r.SetCtxVar("user_role", claims.Role)
+	r.SetCtxVar("user_role", claims.RoleType)
+
+	r.Middleware.Next()
+}
+
+// AdminOnly 管理员权限中间件，需在Auth之后执行
+func AdminOnly(r *ghttp.Request) {
+	if r.GetCtxVar("user_role").Int() != utils.RoleTypeAdmin {
+		r.Response.WriteJson(ghttp.DefaultHandlerResponse{
+			Code:    utils.CodeForbidden,
+			Message: "需要管理员权限",
+		})
+		r.Exit()
+		return
+	}
 
 	r.Middleware.Next()
 }
@@ -67,7 +82,7 @@ func Logger(r *ghttp.Request) {
 	requestID := r.GetCtxVar("request_id").String()
 	
 	// 记录请求信息
-	requestBody, _ := r.GetBody()
+	requestBody := r.GetBody()
 	requestHeaders := r.Header.Clone()
 	requestHeaders.Del("Authorization") // 移除敏感信息
 	
@@ -83,14 +98,7 @@ func Logger(r *ghttp.Request) {
 		"start_time":    startTime,
 	}
 	
-	g.Log().Infof(r.Context(), "请求开始: %s", utils.JsonEncode(logData))
-	
-	// 创建响应写入器
-	responseWriter := &responseBodyWriter{
-		ResponseWriter: r.Response.Writer,
-		body:          &bytes.Buffer{},
-	}
-	r.Response.Writer = responseWriter
+	g.Log().Infof(r.Context(), "请求开始: %s", jsonEncode(logData))
 	
 	r.Middleware.Next()
 	
@@ -104,12 +112,21 @@ func Logger(r *ghttp.Request) {
 		"path":          r.URL.Path,
 		"status":        r.Response.Status,
 		"headers":       responseHeaders,
-		"body":          responseWriter.body.String(),
+		"body":          r.Response.BufferString(),
 		"duration":      endTime - startTime,
 		"end_time":      endTime,
 	}
 	
-	g.Log().Infof(r.Context(), "请求结束: %s", utils.JsonEncode(logData))
+	g.Log().Infof(r.Context(), "请求结束: %s", jsonEncode(logData))
+}
+
+// jsonEncode 将日志数据编码为JSON字符串
+func jsonEncode(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(data)
 }
 
 // ErrorHandler 错误处理中间件
@@ -173,7 +190,7 @@ func CORS(r *ghttp.Request) {
 // RateLimit 限流中间件
 func RateLimit(r *ghttp.Request) {
 	// 获取Redis客户端
-	redisClient := g.Redis().GetClient()
+	redisClient := g.Redis()
 	
 	// 获取客户端IP
 	clientIP := r.GetClientIp()
@@ -182,18 +199,18 @@ func RateLimit(r *ghttp.Request) {
 	key := fmt.Sprintf("rate_limit:%s:%s", clientIP, r.URL.Path)
 	
 	// 获取限流配置
-	limit := g.Cfg().MustGet("rate_limit.limit").Int()     // 限制次数
-	window := g.Cfg().MustGet("rate_limit.window").Int()   // 时间窗口(秒)
+	ctx := context.Background()
+	limit := g.Cfg().MustGet(ctx, "rate_limit.limit").Int()     // 限制次数
+	window := g.Cfg().MustGet(ctx, "rate_limit.window").Int()   // 时间窗口(秒)
 	
 	// 使用Redis实现滑动窗口限流
-	ctx := context.Background()
 	now := time.Now().Unix()
 	
 	// 清理过期的请求记录
 	redisClient.ZRemRangeByScore(ctx, key, "0", fmt.Sprintf("%d", now-int64(window)))
 	
 	// 获取当前时间窗口内的请求数
-	count, err := redisClient.ZCard(ctx, key).Result()
+	count, err := redisClient.ZCard(ctx, key)
 	if err != nil {
 		g.Log().Errorf(r.Context(), "限流检查失败: %v", err)
 		r.Middleware.Next()
@@ -211,13 +228,13 @@ func RateLimit(r *ghttp.Request) {
 	}
 	
 	// 记录本次请求
-	redisClient.ZAdd(ctx, key, &redis.Z{
+	redisClient.ZAdd(ctx, key, nil, gredis.ZAddMember{
 		Score:  float64(now),
 		Member: now,
 	})
 	
 	// 设置过期时间
-	redisClient.Expire(ctx, key, time.Duration(window)*time.Second)
+	redisClient.Expire(ctx, key, int64(window))
 	
 	r.Middleware.Next()
 }
@@ -225,7 +242,7 @@ func RateLimit(r *ghttp.Request) {
 // RequestID 请求ID中间件
 func RequestID(r *ghttp.Request) {
 	// 生成请求ID
-	requestID := utils.GenerateUUID()
+	requestID := uuid.New().String()
 	
 	// 设置请求ID到上下文
 	r.SetCtxVar("request_id", requestID)
@@ -241,11 +258,11 @@ func Recovery(r *ghttp.Request) {
 	defer func() {
 		if err := recover(); err != nil {
 			// 记录错误日志
-			g.Log().Errorf(r.Context(), "请求处理异常: %v\n堆栈信息: %s", err, utils.GetStack())
+			g.Log().Errorf(r.Context(), "请求处理异常: %v\n堆栈信息: %s", err, gdebug.Stack())
 			
 			// 返回错误响应
 			r.Response.WriteJson(ghttp.DefaultHandlerResponse{
-				Code:    utils.ErrInternalServer,
+				Code:    utils.CodeInternalServer,
 				Message: "服务器内部错误",
 			})
 		}
@@ -282,7 +299,7 @@ func ValidateRequest(r *ghttp.Request) {
 	
 	// 验证参数类型
 	if taskType, ok := params["type"].(string); ok {
-		if !utils.IsValidTaskType(taskType) {
+		if t := model.TaskType(taskType); t != model.TaskTypeContentGeneration && t != model.TaskTypeTranslation {
 			r.Response.WriteJson(ghttp.DefaultHandlerResponse{
 				Code:    400,
 				Message: "无效的任务类型",
@@ -309,8 +326,9 @@ func ValidateRequest(r *ghttp.Request) {
 
 // Compress 响应压缩中间件
 func Compress(r *ghttp.Request) {
-	// 检查是否支持压缩
-	if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+	// 检查是否支持压缩，SSE事件流需逐条推送，不压缩
+	if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") ||
+		strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
 		r.Middleware.Next()
 		return
 	}
@@ -319,58 +337,33 @@ func Compress(r *ghttp.Request) {
 	r.Response.Header().Set("Content-Encoding", "gzip")
 	r.Response.Header().Set("Vary", "Accept-Encoding")
 	
-	// 创建gzip写入器
-	gzipWriter := gzip.NewWriter(r.Response.Writer)
-	defer gzipWriter.Close()
-	
-	// 替换响应写入器
-	responseWriter := &responseBodyWriter{
-		ResponseWriter: r.Response.Writer,
-		body:          &bytes.Buffer{},
-	}
-	r.Response.Writer = responseWriter
-	
 	r.Middleware.Next()
 	
-	// 压缩响应内容
-	gzipWriter.Write(responseWriter.body.Bytes())
-}
-
-// responseBodyWriter 响应体写入器
-type responseBodyWriter struct {
-	ghttp.ResponseWriter
-	body *bytes.Buffer
-}
-
-func (w *responseBodyWriter) Write(b []byte) (int, error) {
-	w.body.Write(b)
-	return w.ResponseWriter.Write(b)
+	// 压缩缓冲区中的响应内容
+	var buf bytes.Buffer
+	gzipWriter := gzip.NewWriter(&buf)
+	if _, err := gzipWriter.Write(r.Response.Buffer()); err != nil {
+		g.Log().Errorf(r.Context(), "压缩响应失败: %v", err)
+		return
+	}
+	if err := gzipWriter.Close(); err != nil {
+		g.Log().Errorf(r.Context(), "压缩响应失败: %v", err)
+		return
+	}
+	r.Response.SetBuffer(buf.Bytes())
 }
 
-// RegisterMiddleware 注册中间件
+// RegisterMiddleware 注册任务接口中间件，日志、跨域与限流等全局中间件由router.Register注册
+// 认证中间件由各路由组注册
 func RegisterMiddleware(s *ghttp.Server) {
-	// 全局中间件
-	s.Use(
+	// 任务接口中间件
+	s.BindMiddleware("/api/v1/*",
 		Recovery,     // 恢复中间件
 		RequestID,    // 请求ID中间件
-		Logger,       // 日志中间件
-		CORS,         // 跨域中间件
 		Compress,     // 响应压缩中间件
 		ErrorHandler, // 错误处理中间件
 	)
 
-	// 需要认证的路由组中间件
-	s.Group("/api/v1").Bind([]ghttp.GroupItem{
-		{"ALL", "/*", Auth}, // 认证中间件
-	})
-
-	// 需要限流的路由组中间件
-	s.Group("/api/v1").Bind([]ghttp.GroupItem{
-		{"ALL", "/*", RateLimit}, // 限流中间件
-	})
-
-	// 需要参数验证的路由组中间件
-	s.Group("/api/v1/tasks").Bind([]ghttp.GroupItem{
-		{"POST", "/*", ValidateRequest}, // 参数验证中间件
-	})
-} 
\ No newline at end of file
+	// 创建任务的参数验证中间件
+	s.BindMiddleware("POST:/api/v1/tasks", ValidateRequest)
+}
@@ -1,22 +1,21 @@
 package api
 
 import (
+	"ai-translate/internal/service"
 	"github.com/gogf/gf/v2/net/ghttp"
 )
 
-// RegisterRoutes 注册路由
-func RegisterRoutes(s *ghttp.Server) {
+// RegisterRoutes 注册任务管理路由
+func RegisterRoutes(s *ghttp.Server, taskService *service.TaskService) {
 	// 注册中间件
 	RegisterMiddleware(s)
 
 	// 创建任务控制器
-	taskController, err := NewTaskController()
-	if err != nil {
-		panic(err)
-	}
+	taskController := NewTaskController(taskService)
 
-	// 任务管理路由组
+	// 任务管理路由组，事件订阅、规则模拟回放与模板实例化等接口均需认证
 	taskGroup := s.Group("/api/v1/tasks")
+	taskGroup.Middleware(Auth)
 	{
 		// 基础任务管理
 		taskGroup.POST("/", taskController.Create)                    // 创建任务
@@ -25,7 +24,9 @@ func RegisterRoutes(s *ghttp.Server) {
 		taskGroup.PUT("/:id/status", taskController.UpdateStatus)     // 更新任务状态
 		taskGroup.DELETE("/:id", taskController.Delete)               // 删除任务
 		taskGroup.POST("/:id/retry", taskController.Retry)            // 重试任务
+		taskGroup.POST("/:id/resume", taskController.Resume)          // 恢复已暂停的任务
 		taskGroup.GET("/stats", taskController.GetStats)              // 获取任务统计
+		taskGroup.GET("/events", taskController.Events)               // 订阅任务事件（SSE）
 
 		// 任务优先级管理
 		taskGroup.PUT("/:id/priority", taskController.UpdatePriority) // 更新任务优先级
@@ -38,6 +39,8 @@ func RegisterRoutes(s *ghttp.Server) {
 		taskGroup.PUT("/rules/:id", taskController.UpdatePriorityRule) // 更新优先级规则
 		taskGroup.DELETE("/rules/:id", taskController.DeletePriorityRule) // 删除优先级规则
 		taskGroup.GET("/rules", taskController.ListPriorityRules)     // 获取优先级规则列表
+		taskGroup.POST("/rules/simulate", taskController.SimulatePriorityRule) // 模拟执行草稿规则
+		taskGroup.POST("/rules/replay", taskController.ReplayPriorityRule) // 在历史任务上回放草稿规则
 		taskGroup.GET("/:id/priority-logs", taskController.GetPriorityLogs) // 获取优先级调整日志
 
 		// 规则模板管理
@@ -46,6 +49,8 @@ func RegisterRoutes(s *ghttp.Server) {
 		taskGroup.PUT("/templates/:id", taskController.UpdateRuleTemplate) // 更新规则模板
 		taskGroup.DELETE("/templates/:id", taskController.DeleteRuleTemplate) // 删除规则模板
 		taskGroup.GET("/templates", taskController.ListRuleTemplates) // 获取规则模板列表
+		taskGroup.POST("/templates/:id/instantiate", taskController.InstantiateRuleTemplate) // 实例化规则模板
+		taskGroup.POST("/templates/:id/reapply", taskController.ReapplyRuleTemplate) // 重新应用规则模板
 
 		// 规则组管理
 		taskGroup.POST("/groups", taskController.CreateRuleGroup)     // 创建规则组
@@ -56,5 +61,19 @@ func RegisterRoutes(s *ghttp.Server) {
 		taskGroup.POST("/groups/:id/rules", taskController.AddRuleToGroup) // 添加规则到组
 		taskGroup.DELETE("/groups/:id/rules/:rule_id", taskController.RemoveRuleFromGroup) // 从组中移除规则
 		taskGroup.GET("/groups/:id/rules", taskController.GetGroupRules) // 获取组中的规则
+		taskGroup.PUT("/groups/:id/rules/order", taskController.ReorderGroupRules) // 调整组内规则顺序
+		taskGroup.POST("/groups/:id/simulate", taskController.SimulateRuleGroup) // 模拟执行规则组
+		taskGroup.POST("/groups/:id/replay", taskController.ReplayRuleGroup) // 在历史任务上回放规则组
+	}
+
+	// 管理员路由组
+	adminGroup := s.Group("/api/v1/admin")
+	adminGroup.Middleware(Auth, AdminOnly)
+	{
+		// 死信队列管理
+		adminGroup.GET("/dead-letters", taskController.ListDeadLetters)             // 获取死信消息列表
+		adminGroup.GET("/dead-letters/:type/:id", taskController.GetDeadLetter)     // 获取死信消息
+		adminGroup.POST("/dead-letters/requeue", taskController.RequeueDeadLetters) // 重新投递死信消息
+		adminGroup.DELETE("/dead-letters", taskController.PurgeDeadLetters)         // 删除死信消息
 	}
 } 
\ No newline at end of file
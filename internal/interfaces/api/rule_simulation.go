@@ -0,0 +1,141 @@
+package api
+
+import (
+	"ai-translate/internal/infrastructure/scheduler"
+	"ai-translate/internal/infrastructure/utils"
+	"ai-translate/internal/model"
+	"context"
+	"time"
+)
+
+// RuleDraft 待模拟的草稿规则，字段含义与优先级规则相同
+type RuleDraft struct {
+	WorkID      string           `json:"work_id"`     // 工作ID
+	BatchID     string           `json:"batch_id"`    // 批次ID
+	TaskType    model.TaskType   `json:"task_type"`   // 任务类型
+	Status      model.TaskStatus `json:"status"`      // 任务状态
+	Condition   string           `json:"condition"`   // 触发条件表达式
+	Action      string           `json:"action"`      // 执行动作
+	Priority    int              `json:"priority"`    // 优先级
+	Description string           `json:"description"` // 规则描述
+}
+
+// rule 转换为优先级规则
+func (d RuleDraft) rule() *model.PriorityAdjustRule {
+	return &model.PriorityAdjustRule{
+		ID:          "draft",
+		WorkID:      d.WorkID,
+		BatchID:     d.BatchID,
+		TaskType:    d.TaskType,
+		Status:      d.Status,
+		Condition:   d.Condition,
+		Action:      d.Action,
+		Priority:    model.TaskPriority(d.Priority),
+		Description: d.Description,
+		Enabled:     true,
+	}
+}
+
+// SimulatePriorityRuleReq 模拟执行草稿规则请求
+type SimulatePriorityRuleReq struct {
+	RuleDraft
+	Limit int `json:"limit" d:"100" v:"min:1,max:1000#数量必须在1-1000之间"`
+}
+
+// SimulateRuleRes 模拟执行规则响应
+type SimulateRuleRes struct {
+	Changes []*scheduler.SimulatedChange `json:"changes"` // 将被调整的任务
+}
+
+// SimulatePriorityRule 对当前待处理与已暂停的任务模拟执行草稿规则，不写入任何数据
+func (c *TaskController) SimulatePriorityRule(ctx context.Context, req *SimulatePriorityRuleReq) (*SimulateRuleRes, error) {
+	rule := req.rule()
+	if err := c.taskService.ValidatePriorityRule(rule); err != nil {
+		return nil, utils.NewError(utils.ErrInvalidParams, err.Error())
+	}
+
+	changes, err := c.taskService.SimulatePriorityRule(ctx, rule, req.Limit)
+	if err != nil {
+		return nil, utils.NewError(utils.ErrInternalServer, "模拟执行规则失败")
+	}
+
+	return &SimulateRuleRes{
+		Changes: changes,
+	}, nil
+}
+
+// SimulateRuleGroupReq 模拟执行规则组请求
+type SimulateRuleGroupReq struct {
+	ID    string `json:"id" v:"required#规则组ID不能为空"`
+	Limit int    `json:"limit" d:"100" v:"min:1,max:1000#数量必须在1-1000之间"`
+}
+
+// SimulateRuleGroup 对当前待处理与已暂停的任务模拟执行规则组，不写入任何数据
+func (c *TaskController) SimulateRuleGroup(ctx context.Context, req *SimulateRuleGroupReq) (*SimulateRuleRes, error) {
+	if _, err := c.taskService.GetRuleGroup(ctx, req.ID); err != nil {
+		return nil, utils.NewError(utils.ErrNotFound, "规则组不存在")
+	}
+
+	changes, err := c.taskService.SimulateRuleGroup(ctx, req.ID, req.Limit)
+	if err != nil {
+		return nil, utils.NewError(utils.ErrInternalServer, "模拟执行规则组失败")
+	}
+
+	return &SimulateRuleRes{
+		Changes: changes,
+	}, nil
+}
+
+// ReplayPriorityRuleReq 回放草稿规则请求
+type ReplayPriorityRuleReq struct {
+	RuleDraft
+	Days  int `json:"days" d:"7" v:"min:1,max:30#回放天数必须在1-30之间"` // 回放最近几天创建的任务
+	Limit int `json:"limit" d:"100" v:"min:1,max:1000#数量必须在1-1000之间"`
+}
+
+// ReplayRuleRes 回放规则响应
+type ReplayRuleRes struct {
+	*scheduler.ReplayResult
+}
+
+// ReplayPriorityRule 在历史任务上回放草稿规则，估算排队等待时长的变化，不写入任何数据
+func (c *TaskController) ReplayPriorityRule(ctx context.Context, req *ReplayPriorityRuleReq) (*ReplayRuleRes, error) {
+	rule := req.rule()
+	if err := c.taskService.ValidatePriorityRule(rule); err != nil {
+		return nil, utils.NewError(utils.ErrInvalidParams, err.Error())
+	}
+
+	until := time.Now()
+	result, err := c.taskService.ReplayPriorityRule(ctx, rule, until.AddDate(0, 0, -req.Days), until, req.Limit)
+	if err != nil {
+		return nil, utils.NewError(utils.ErrInternalServer, "回放规则失败")
+	}
+
+	return &ReplayRuleRes{
+		ReplayResult: result,
+	}, nil
+}
+
+// ReplayRuleGroupReq 回放规则组请求
+type ReplayRuleGroupReq struct {
+	ID    string `json:"id" v:"required#规则组ID不能为空"`
+	Days  int    `json:"days" d:"7" v:"min:1,max:30#回放天数必须在1-30之间"` // 回放最近几天创建的任务
+	Limit int    `json:"limit" d:"100" v:"min:1,max:1000#数量必须在1-1000之间"`
+}
+
+// ReplayRuleGroup 在历史任务上回放规则组，估算排队等待时长的变化，不写入任何数据
+func (c *TaskController) ReplayRuleGroup(ctx context.Context, req *ReplayRuleGroupReq) (*ReplayRuleRes, error) {
+	if _, err := c.taskService.GetRuleGroup(ctx, req.ID); err != nil {
+		return nil, utils.NewError(utils.ErrNotFound, "规则组不存在")
+	}
+
+	until := time.Now()
+	result, err := c.taskService.ReplayRuleGroup(ctx, req.ID, until.AddDate(0, 0, -req.Days), until, req.Limit)
+	if err != nil {
+		return nil, utils.NewError(utils.ErrInternalServer, "回放规则组失败")
+	}
+
+	return &ReplayRuleRes{
+		ReplayResult: result,
+	}, nil
+}
@@ -2,8 +2,11 @@ package api
 
 import (
 	"context"
-	"github.com/gogf/gf/v2/frame/g"
+	"github.com/google/uuid"
+	"ai-translate/internal/domain/work"
 	"ai-translate/internal/infrastructure/ai"
+	"ai-translate/internal/infrastructure/expr"
+	"ai-translate/internal/infrastructure/persistence"
 	"ai-translate/internal/infrastructure/utils"
 	"ai-translate/internal/model"
 	"ai-translate/internal/service"
@@ -31,17 +34,15 @@ type CreateTaskRes struct {
 // TaskController 任务控制器
 type TaskController struct {
 	taskService *service.TaskService
+	workRepo    work.WorkRepository // 查询用户作品，限定事件订阅范围
 }
 
 // NewTaskController 创建任务控制器
-func NewTaskController() (*TaskController, error) {
-	taskService, err := service.NewTaskService()
-	if err != nil {
-		return nil, err
-	}
+func NewTaskController(taskService *service.TaskService) *TaskController {
 	return &TaskController{
 		taskService: taskService,
-	}, nil
+		workRepo:    persistence.NewWorkRepository(),
+	}
 }
 
 // Create 创建任务
@@ -73,6 +74,8 @@ type GetTaskRes struct {
 	Content    string       `json:"content"`
 	Result     string       `json:"result"`
 	Error      string       `json:"error"`
+	Progress   int          `json:"progress"`       // 完成百分比（0-100）
+	PartialResult string    `json:"partial_result"` // 处理中的部分结果
 	Driver     ai.DriverType `json:"driver"`
 	RetryCount int          `json:"retry_count"`
 	MaxRetries int          `json:"max_retries"`
@@ -99,6 +102,8 @@ func (c *TaskController) Get(ctx context.Context, req *GetTaskReq) (*GetTaskRes,
 		Content:    task.Content,
 		Result:     task.Result,
 		Error:      task.Error,
+		Progress:   task.Progress,
+		PartialResult: task.PartialResult,
 		Driver:     task.Driver,
 		RetryCount: task.RetryCount,
 		MaxRetries: task.MaxRetries,
@@ -144,6 +149,7 @@ func (c *TaskController) List(ctx context.Context, req *ListTasksReq) (*ListTask
 			Content:    task.Content,
 			Result:     task.Result,
 			Error:      task.Error,
+			Progress:   task.Progress,
 			Driver:     task.Driver,
 			RetryCount: task.RetryCount,
 			MaxRetries: task.MaxRetries,
@@ -260,6 +266,27 @@ func (c *TaskController) Retry(ctx context.Context, req *RetryTaskReq) (*RetryTa
 	}, nil
 }
 
+// ResumeTaskReq 恢复任务请求
+type ResumeTaskReq struct {
+	TaskID string `json:"task_id" v:"required#任务ID不能为空"`
+}
+
+// ResumeTaskRes 恢复任务响应
+type ResumeTaskRes struct {
+	Success bool `json:"success"`
+}
+
+// Resume 恢复已暂停的任务
+func (c *TaskController) Resume(ctx context.Context, req *ResumeTaskReq) (*ResumeTaskRes, error) {
+	if err := c.taskService.ResumeTask(ctx, req.TaskID); err != nil {
+		return nil, utils.NewError(utils.ErrInternalServer, "恢复任务失败")
+	}
+
+	return &ResumeTaskRes{
+		Success: true,
+	}, nil
+}
+
 // UpdateTaskPriorityReq 更新任务优先级请求
 type UpdateTaskPriorityReq struct {
 	TaskID   string `json:"task_id" v:"required#任务ID不能为空"`
@@ -335,14 +362,19 @@ type CreatePriorityRuleReq struct {
 	BatchID     string         `json:"batch_id"`                  // 批次ID
 	TaskType    model.TaskType `json:"task_type"`                 // 任务类型
 	Status      model.TaskStatus `json:"status"`                  // 任务状态
+	Name        string         `json:"name"`                      // 规则名称
+	Condition   string         `json:"condition"`                 // 触发条件表达式，如 age_minutes > 30 && type == "translation"
+	Action      string         `json:"action"`                    // 执行动作，如 raise_priority(1); tag("slow")，为空时设置为Priority
 	Priority    int            `json:"priority" v:"required"`     // 优先级
 	Description string         `json:"description" v:"required"`  // 规则描述
 	Enabled     bool           `json:"enabled"`                   // 是否启用
+	Order       int            `json:"order"`                     // 评估顺序，数值小的先评估
 }
 
 // CreatePriorityRuleRes 创建优先级规则响应
 type CreatePriorityRuleRes struct {
-	ID string `json:"id"` // 规则ID
+	ID        string          `json:"id"`                  // 规则ID
+	Conflicts []expr.Conflict `json:"conflicts,omitempty"` // 与已启用规则的重叠与矛盾，仅作提示
 }
 
 // GetPriorityRuleReq 获取优先级规则请求
@@ -362,14 +394,19 @@ type UpdatePriorityRuleReq struct {
 	BatchID     string         `json:"batch_id"`                 // 批次ID
 	TaskType    model.TaskType `json:"task_type"`                // 任务类型
 	Status      model.TaskStatus `json:"status"`                 // 任务状态
+	Name        string         `json:"name"`                     // 规则名称
+	Condition   string         `json:"condition"`                // 触发条件表达式
+	Action      string         `json:"action"`                   // 执行动作
 	Priority    int            `json:"priority"`                 // 优先级
 	Description string         `json:"description"`              // 规则描述
 	Enabled     bool           `json:"enabled"`                  // 是否启用
+	Order       int            `json:"order"`                    // 评估顺序，数值小的先评估
 }
 
 // UpdatePriorityRuleRes 更新优先级规则响应
 type UpdatePriorityRuleRes struct {
-	Success bool `json:"success"` // 是否成功
+	Success   bool            `json:"success"`             // 是否成功
+	Conflicts []expr.Conflict `json:"conflicts,omitempty"` // 与已启用规则的重叠与矛盾，仅作提示
 }
 
 // DeletePriorityRuleReq 删除优先级规则请求
@@ -414,24 +451,30 @@ type GetPriorityLogsRes struct {
 // CreatePriorityRule 创建优先级规则
 func (c *TaskController) CreatePriorityRule(ctx context.Context, req *CreatePriorityRuleReq) (*CreatePriorityRuleRes, error) {
 	rule := &model.PriorityAdjustRule{
-		ID:          utils.GenerateUUID(),
+		ID:          uuid.New().String(),
 		WorkID:      req.WorkID,
 		BatchID:     req.BatchID,
 		TaskType:    req.TaskType,
 		Status:      req.Status,
-		Priority:    req.Priority,
+		Name:        req.Name,
+		Condition:   req.Condition,
+		Action:      req.Action,
+		Priority:    model.TaskPriority(req.Priority),
 		Description: req.Description,
 		Enabled:     req.Enabled,
+		Order:       req.Order,
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
 	}
 
-	if err := c.taskService.CreatePriorityRule(ctx, rule); err != nil {
+	conflicts, err := c.taskService.CreatePriorityRule(ctx, rule)
+	if err != nil {
 		return nil, err
 	}
 
 	return &CreatePriorityRuleRes{
-		ID: rule.ID,
+		ID:        rule.ID,
+		Conflicts: conflicts,
 	}, nil
 }
 
@@ -455,18 +498,24 @@ func (c *TaskController) UpdatePriorityRule(ctx context.Context, req *UpdatePrio
 		BatchID:     req.BatchID,
 		TaskType:    req.TaskType,
 		Status:      req.Status,
-		Priority:    req.Priority,
+		Name:        req.Name,
+		Condition:   req.Condition,
+		Action:      req.Action,
+		Priority:    model.TaskPriority(req.Priority),
 		Description: req.Description,
 		Enabled:     req.Enabled,
+		Order:       req.Order,
 		UpdatedAt:   time.Now(),
 	}
 
-	if err := c.taskService.UpdatePriorityRule(ctx, rule); err != nil {
+	conflicts, err := c.taskService.UpdatePriorityRule(ctx, rule)
+	if err != nil {
 		return nil, err
 	}
 
 	return &UpdatePriorityRuleRes{
-		Success: true,
+		Success:   true,
+		Conflicts: conflicts,
 	}, nil
 }
 
@@ -511,8 +560,14 @@ func (c *TaskController) GetPriorityLogs(ctx context.Context, req *GetPriorityLo
 type CreateRuleTemplateReq struct {
 	Name        string            `json:"name" v:"required"`        // 模板名称
 	Description string            `json:"description" v:"required"` // 模板描述
-	Conditions  []model.RuleCondition `json:"conditions"`           // 条件列表
-	Actions     []model.RuleAction    `json:"actions"`              // 动作列表
+	Params      []model.TemplateParam `json:"params"`               // 参数定义
+	WorkID      string            `json:"work_id"`                  // 工作ID，可使用占位符，如 {work_id}
+	BatchID     string            `json:"batch_id"`                 // 批次ID，可使用占位符
+	TaskType    model.TaskType    `json:"task_type"`                // 任务类型
+	Status      model.TaskStatus  `json:"status"`                   // 任务状态
+	Condition   string            `json:"condition"`                // 触发条件表达式，如 age_minutes > {threshold_minutes}
+	Action      string            `json:"action"`                   // 执行动作
+	Priority    int               `json:"priority"`                 // 优先级，Action为空时使用
 }
 
 // CreateRuleTemplateRes 创建规则模板响应
@@ -535,8 +590,14 @@ type UpdateRuleTemplateReq struct {
 	ID          string            `json:"id" v:"required"`          // 模板ID
 	Name        string            `json:"name"`                     // 模板名称
 	Description string            `json:"description"`              // 模板描述
-	Conditions  []model.RuleCondition `json:"conditions"`           // 条件列表
-	Actions     []model.RuleAction    `json:"actions"`              // 动作列表
+	Params      []model.TemplateParam `json:"params"`               // 参数定义
+	WorkID      string            `json:"work_id"`                  // 工作ID
+	BatchID     string            `json:"batch_id"`                 // 批次ID
+	TaskType    model.TaskType    `json:"task_type"`                // 任务类型
+	Status      model.TaskStatus  `json:"status"`                   // 任务状态
+	Condition   string            `json:"condition"`                // 触发条件表达式
+	Action      string            `json:"action"`                   // 执行动作
+	Priority    int               `json:"priority"`                 // 优先级
 }
 
 // UpdateRuleTemplateRes 更新规则模板响应
@@ -554,6 +615,29 @@ type DeleteRuleTemplateRes struct {
 	Success bool `json:"success"` // 是否成功
 }
 
+// InstantiateRuleTemplateReq 实例化规则模板请求
+type InstantiateRuleTemplateReq struct {
+	ID      string              `json:"id" v:"required"`                    // 模板ID
+	Params  []map[string]string `json:"params" v:"required#模板参数不能为空"` // 每组参数生成一条规则
+	Enabled bool                `json:"enabled"`                            // 生成的规则是否启用
+}
+
+// InstantiateRuleTemplateRes 实例化规则模板响应
+type InstantiateRuleTemplateRes struct {
+	Rules []*model.PriorityAdjustRule `json:"rules"` // 生成的规则
+}
+
+// ReapplyRuleTemplateReq 重新应用规则模板请求
+type ReapplyRuleTemplateReq struct {
+	ID string `json:"id" v:"required"` // 模板ID
+}
+
+// ReapplyRuleTemplateRes 重新应用规则模板响应
+type ReapplyRuleTemplateRes struct {
+	Updated []string          `json:"updated"` // 已更新的规则ID
+	Failed  map[string]string `json:"failed"`  // 无法更新的规则ID及原因
+}
+
 // ListRuleTemplatesReq 获取规则模板列表请求
 type ListRuleTemplatesReq struct {
 	Page int `json:"page"` // 页码
@@ -568,15 +652,17 @@ type ListRuleTemplatesRes struct {
 
 // CreateRuleGroupReq 创建规则组请求
 type CreateRuleGroupReq struct {
-	Name        string   `json:"name" v:"required"`        // 组名称
-	Description string   `json:"description" v:"required"` // 组描述
-	Rules       []string `json:"rules"`                    // 规则ID列表
-	Enabled     bool     `json:"enabled"`                  // 是否启用
+	Name        string                  `json:"name" v:"required"`        // 组名称
+	Description string                  `json:"description" v:"required"` // 组描述
+	Rules       []string                `json:"rules"`                    // 规则ID列表，顺序即评估顺序
+	Strategy    model.RuleGroupStrategy `json:"strategy"`                 // 多条规则匹配时的处理方式，默认first_match
+	Enabled     bool                    `json:"enabled"`                  // 是否启用
 }
 
 // CreateRuleGroupRes 创建规则组响应
 type CreateRuleGroupRes struct {
-	ID string `json:"id"` // 组ID
+	ID        string          `json:"id"`                  // 组ID
+	Conflicts []expr.Conflict `json:"conflicts,omitempty"` // 组内规则之间的重叠与矛盾，仅作提示
 }
 
 // GetRuleGroupReq 获取规则组请求
@@ -591,16 +677,18 @@ type GetRuleGroupRes struct {
 
 // UpdateRuleGroupReq 更新规则组请求
 type UpdateRuleGroupReq struct {
-	ID          string   `json:"id" v:"required"`          // 组ID
-	Name        string   `json:"name"`                     // 组名称
-	Description string   `json:"description"`              // 组描述
-	Rules       []string `json:"rules"`                    // 规则ID列表
-	Enabled     bool     `json:"enabled"`                  // 是否启用
+	ID          string                  `json:"id" v:"required"` // 组ID
+	Name        string                  `json:"name"`            // 组名称
+	Description string                  `json:"description"`     // 组描述
+	Rules       []string                `json:"rules"`           // 规则ID列表，顺序即评估顺序
+	Strategy    model.RuleGroupStrategy `json:"strategy"`        // 多条规则匹配时的处理方式，默认first_match
+	Enabled     bool                    `json:"enabled"`         // 是否启用
 }
 
 // UpdateRuleGroupRes 更新规则组响应
 type UpdateRuleGroupRes struct {
-	Success bool `json:"success"` // 是否成功
+	Success   bool            `json:"success"`             // 是否成功
+	Conflicts []expr.Conflict `json:"conflicts,omitempty"` // 组内规则之间的重叠与矛盾，仅作提示
 }
 
 // DeleteRuleGroupReq 删除规则组请求
@@ -634,7 +722,8 @@ type AddRuleToGroupReq struct {
 
 // AddRuleToGroupRes 添加规则到组响应
 type AddRuleToGroupRes struct {
-	Success bool `json:"success"` // 是否成功
+	Success   bool            `json:"success"`             // 是否成功
+	Conflicts []expr.Conflict `json:"conflicts,omitempty"` // 新规则与组内规则之间的重叠与矛盾，仅作提示
 }
 
 // RemoveRuleFromGroupReq 从组中移除规则请求
@@ -658,14 +747,31 @@ type GetGroupRulesRes struct {
 	Rules []*model.PriorityAdjustRule `json:"rules"` // 规则列表
 }
 
+// ReorderGroupRulesReq 调整组内规则顺序请求
+type ReorderGroupRulesReq struct {
+	GroupID string   `json:"group_id" v:"required"` // 组ID
+	Rules   []string `json:"rules" v:"required"`    // 组内全部规则ID，按新的评估顺序排列
+}
+
+// ReorderGroupRulesRes 调整组内规则顺序响应
+type ReorderGroupRulesRes struct {
+	Success bool `json:"success"` // 是否成功
+}
+
 // CreateRuleTemplate 创建规则模板
 func (c *TaskController) CreateRuleTemplate(ctx context.Context, req *CreateRuleTemplateReq) (*CreateRuleTemplateRes, error) {
 	template := &model.RuleTemplate{
-		ID:          utils.GenerateUUID(),
+		ID:          uuid.New().String(),
 		Name:        req.Name,
 		Description: req.Description,
-		Conditions:  req.Conditions,
-		Actions:     req.Actions,
+		Params:      req.Params,
+		WorkID:      req.WorkID,
+		BatchID:     req.BatchID,
+		TaskType:    req.TaskType,
+		Status:      req.Status,
+		Condition:   req.Condition,
+		Action:      req.Action,
+		Priority:    model.TaskPriority(req.Priority),
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
 	}
@@ -697,8 +803,14 @@ func (c *TaskController) UpdateRuleTemplate(ctx context.Context, req *UpdateRule
 		ID:          req.ID,
 		Name:        req.Name,
 		Description: req.Description,
-		Conditions:  req.Conditions,
-		Actions:     req.Actions,
+		Params:      req.Params,
+		WorkID:      req.WorkID,
+		BatchID:     req.BatchID,
+		TaskType:    req.TaskType,
+		Status:      req.Status,
+		Condition:   req.Condition,
+		Action:      req.Action,
+		Priority:    model.TaskPriority(req.Priority),
 		UpdatedAt:   time.Now(),
 	}
 
@@ -711,6 +823,39 @@ func (c *TaskController) UpdateRuleTemplate(ctx context.Context, req *UpdateRule
 	}, nil
 }
 
+// InstantiateRuleTemplate 按参数实例化规则模板，每组参数生成一条规则
+func (c *TaskController) InstantiateRuleTemplate(ctx context.Context, req *InstantiateRuleTemplateReq) (*InstantiateRuleTemplateRes, error) {
+	if _, err := c.taskService.GetRuleTemplate(ctx, req.ID); err != nil {
+		return nil, utils.NewError(utils.ErrNotFound, "规则模板不存在")
+	}
+
+	rules, err := c.taskService.InstantiateRuleTemplate(ctx, req.ID, req.Params, req.Enabled)
+	if err != nil {
+		return nil, utils.NewError(utils.ErrInvalidParams, err.Error())
+	}
+
+	return &InstantiateRuleTemplateRes{
+		Rules: rules,
+	}, nil
+}
+
+// ReapplyRuleTemplate 将模板的最新版本重新应用到由旧版本实例化的规则
+func (c *TaskController) ReapplyRuleTemplate(ctx context.Context, req *ReapplyRuleTemplateReq) (*ReapplyRuleTemplateRes, error) {
+	if _, err := c.taskService.GetRuleTemplate(ctx, req.ID); err != nil {
+		return nil, utils.NewError(utils.ErrNotFound, "规则模板不存在")
+	}
+
+	updated, failed, err := c.taskService.ReapplyRuleTemplate(ctx, req.ID)
+	if err != nil {
+		return nil, utils.NewError(utils.ErrInternalServer, "重新应用规则模板失败")
+	}
+
+	return &ReapplyRuleTemplateRes{
+		Updated: updated,
+		Failed:  failed,
+	}, nil
+}
+
 // DeleteRuleTemplate 删除规则模板
 func (c *TaskController) DeleteRuleTemplate(ctx context.Context, req *DeleteRuleTemplateReq) (*DeleteRuleTemplateRes, error) {
 	if err := c.taskService.DeleteRuleTemplate(ctx, req.ID); err != nil {
@@ -738,21 +883,24 @@ func (c *TaskController) ListRuleTemplates(ctx context.Context, req *ListRuleTem
 // CreateRuleGroup 创建规则组
 func (c *TaskController) CreateRuleGroup(ctx context.Context, req *CreateRuleGroupReq) (*CreateRuleGroupRes, error) {
 	group := &model.RuleGroup{
-		ID:          utils.GenerateUUID(),
+		ID:          uuid.New().String(),
 		Name:        req.Name,
 		Description: req.Description,
 		Rules:       req.Rules,
+		Strategy:    req.Strategy,
 		Enabled:     req.Enabled,
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
 	}
 
-	if err := c.taskService.CreateRuleGroup(ctx, group); err != nil {
-		return nil, err
+	conflicts, err := c.taskService.CreateRuleGroup(ctx, group)
+	if err != nil {
+		return nil, utils.NewError(utils.ErrInvalidParams, err.Error())
 	}
 
 	return &CreateRuleGroupRes{
-		ID: group.ID,
+		ID:        group.ID,
+		Conflicts: conflicts,
 	}, nil
 }
 
@@ -775,16 +923,19 @@ func (c *TaskController) UpdateRuleGroup(ctx context.Context, req *UpdateRuleGro
 		Name:        req.Name,
 		Description: req.Description,
 		Rules:       req.Rules,
+		Strategy:    req.Strategy,
 		Enabled:     req.Enabled,
 		UpdatedAt:   time.Now(),
 	}
 
-	if err := c.taskService.UpdateRuleGroup(ctx, group); err != nil {
-		return nil, err
+	conflicts, err := c.taskService.UpdateRuleGroup(ctx, group)
+	if err != nil {
+		return nil, utils.NewError(utils.ErrInvalidParams, err.Error())
 	}
 
 	return &UpdateRuleGroupRes{
-		Success: true,
+		Success:   true,
+		Conflicts: conflicts,
 	}, nil
 }
 
@@ -814,12 +965,14 @@ func (c *TaskController) ListRuleGroups(ctx context.Context, req *ListRuleGroups
 
 // AddRuleToGroup 添加规则到组
 func (c *TaskController) AddRuleToGroup(ctx context.Context, req *AddRuleToGroupReq) (*AddRuleToGroupRes, error) {
-	if err := c.taskService.AddRuleToGroup(ctx, req.GroupID, req.RuleID); err != nil {
+	conflicts, err := c.taskService.AddRuleToGroup(ctx, req.GroupID, req.RuleID)
+	if err != nil {
 		return nil, err
 	}
 
 	return &AddRuleToGroupRes{
-		Success: true,
+		Success:   true,
+		Conflicts: conflicts,
 	}, nil
 }
 
@@ -844,4 +997,19 @@ func (c *TaskController) GetGroupRules(ctx context.Context, req *GetGroupRulesRe
 	return &GetGroupRulesRes{
 		Rules: rules,
 	}, nil
+}
+
+// ReorderGroupRules 调整组内规则的评估顺序
+func (c *TaskController) ReorderGroupRules(ctx context.Context, req *ReorderGroupRulesReq) (*ReorderGroupRulesRes, error) {
+	if _, err := c.taskService.GetRuleGroup(ctx, req.GroupID); err != nil {
+		return nil, utils.NewError(utils.ErrNotFound, "规则组不存在")
+	}
+
+	if err := c.taskService.ReorderGroupRules(ctx, req.GroupID, req.Rules); err != nil {
+		return nil, utils.NewError(utils.ErrInvalidParams, err.Error())
+	}
+
+	return &ReorderGroupRulesRes{
+		Success: true,
+	}, nil
 } 
\ No newline at end of file
@@ -3,7 +3,6 @@ package api
 import (
 	"ai-translate/internal/application"
 	"ai-translate/internal/domain/user"
-	"context"
 	"github.com/gogf/gf/v2/frame/g"
 	"github.com/gogf/gf/v2/net/ghttp"
 )
@@ -3,6 +3,9 @@ package api
 import (
 	"ai-translate/internal/application"
 	"ai-translate/internal/domain/work"
+	"ai-translate/internal/infrastructure/utils"
+	"database/sql"
+	"errors"
 	"github.com/gogf/gf/v2/frame/g"
 	"github.com/gogf/gf/v2/net/ghttp"
 )
@@ -164,6 +167,23 @@ func (c *WorkController) DeleteWork(r *ghttp.Request) {
 	})
 }
 
+// CancelWork 取消作品，作品下未完成的翻译批次与处理中的任务一并取消
+func (c *WorkController) CancelWork(r *ghttp.Request) {
+	id := r.Get("id").Uint64()
+	err := c.workService.CancelWork(id)
+	if err != nil {
+		r.Response.WriteJsonExit(g.Map{
+			"code": 500,
+			"msg":  err.Error(),
+		})
+	}
+
+	r.Response.WriteJsonExit(g.Map{
+		"code": 200,
+		"msg":  "取消成功",
+	})
+}
+
 // CreateTranslationBatch 创建翻译批次
 func (c *WorkController) CreateTranslationBatch(r *ghttp.Request) {
 	var req struct {
@@ -235,4 +255,112 @@ func (c *WorkController) GetWorkTranslationBatches(r *ghttp.Request) {
 		"msg":  "获取成功",
 		"data": batches,
 	})
-} 
\ No newline at end of file
+} 
+
+// GetTranslationResult 获取翻译结果，包含未遵守术语表的条目
+// 批次须属于路径中的作品，作品须属于调用者，管理员除外
+func (c *WorkController) GetTranslationResult(r *ghttp.Request) {
+	workID := r.Get("id").Uint64()
+	batchID := r.Get("batchId").Uint64()
+
+	w, err := c.workService.GetWork(workID)
+	if errors.Is(err, sql.ErrNoRows) {
+		r.Response.WriteJsonExit(g.Map{
+			"code": 404,
+			"msg":  "作品不存在",
+		})
+	}
+	if err != nil {
+		r.Response.WriteJsonExit(g.Map{
+			"code": 500,
+			"msg":  err.Error(),
+		})
+	}
+	if w.UserID != r.GetCtxVar("user_id").Uint64() && r.GetCtxVar("user_role").Int() != utils.RoleTypeAdmin {
+		r.Response.WriteJsonExit(g.Map{
+			"code": 403,
+			"msg":  "无权访问该作品",
+		})
+	}
+
+	batch, err := c.workService.GetTranslationBatch(batchID)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && batch.WorkID != workID) {
+		r.Response.WriteJsonExit(g.Map{
+			"code": 404,
+			"msg":  "翻译批次不存在",
+		})
+	}
+	if err != nil {
+		r.Response.WriteJsonExit(g.Map{
+			"code": 500,
+			"msg":  err.Error(),
+		})
+	}
+
+	result, err := c.workService.GetTranslationResult(batchID)
+	if err != nil {
+		r.Response.WriteJsonExit(g.Map{
+			"code": 500,
+			"msg":  err.Error(),
+		})
+	}
+
+	r.Response.WriteJsonExit(g.Map{
+		"code": 200,
+		"msg":  "获取成功",
+		"data": result,
+	})
+}
+
+// CancelTranslationBatch 取消翻译批次
+func (c *WorkController) CancelTranslationBatch(r *ghttp.Request) {
+	id := r.Get("batchId").Uint64()
+	err := c.workService.CancelTranslationBatch(id)
+	if err != nil {
+		r.Response.WriteJsonExit(g.Map{
+			"code": 500,
+			"msg":  err.Error(),
+		})
+	}
+
+	r.Response.WriteJsonExit(g.Map{
+		"code": 200,
+		"msg":  "取消成功",
+	})
+}
+
+// GetWorkTaskGraph 获取作品的任务依赖图
+func (c *WorkController) GetWorkTaskGraph(r *ghttp.Request) {
+	workID := r.Get("id").Uint64()
+	graph, err := c.workService.GetWorkTaskGraph(workID)
+	if err != nil {
+		r.Response.WriteJsonExit(g.Map{
+			"code": 500,
+			"msg":  err.Error(),
+		})
+	}
+
+	r.Response.WriteJsonExit(g.Map{
+		"code": 200,
+		"msg":  "获取成功",
+		"data": graph,
+	})
+}
+
+// CancelWorkTask 取消作品下的任务，处理中的任务中断进行中的AI请求，已完成分块的译文保留用于恢复
+func (c *WorkController) CancelWorkTask(r *ghttp.Request) {
+	workID := r.Get("id").Uint64()
+	taskID := r.Get("taskId").Uint64()
+	err := c.workService.CancelWorkTask(workID, taskID)
+	if err != nil {
+		r.Response.WriteJsonExit(g.Map{
+			"code": 500,
+			"msg":  err.Error(),
+		})
+	}
+
+	r.Response.WriteJsonExit(g.Map{
+		"code": 200,
+		"msg":  "取消成功",
+	})
+}
@@ -1,13 +1,14 @@
 package middleware
 
 import (
-	"ai-translate/internal/application"
+	"bytes"
 	"context"
 	"errors"
 	"github.com/gogf/gf/v2/crypto/gaes"
 	"github.com/gogf/gf/v2/frame/g"
 	"github.com/gogf/gf/v2/net/ghttp"
 	"github.com/golang-jwt/jwt/v5"
+	"io"
 	"strings"
 )
 
@@ -68,16 +69,24 @@ func Encrypt(r *ghttp.Request) {
 	key := []byte(g.Cfg().MustGet(context.Background(), "aes.key").String())
 
 	// 加密请求体
-	if r.Body.Len() > 0 {
-		encrypted, err := gaes.Encrypt(r.Body.Bytes(), key)
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		r.Response.WriteJsonExit(g.Map{
+			"code": 400,
+			"msg":  "读取请求体失败",
+		})
+	}
+	if len(body) > 0 {
+		encrypted, err := gaes.Encrypt(body, key)
 		if err != nil {
 			r.Response.WriteJsonExit(g.Map{
 				"code": 500,
 				"msg":  "加密失败",
 			})
 		}
-		r.Body = ghttp.NewRequestBody(encrypted)
+		body = encrypted
 	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
 
 	r.Middleware.Next()
 
@@ -101,16 +110,24 @@ func Decrypt(r *ghttp.Request) {
 	key := []byte(g.Cfg().MustGet(context.Background(), "aes.key").String())
 
 	// 解密请求体
-	if r.Body.Len() > 0 {
-		decrypted, err := gaes.Decrypt(r.Body.Bytes(), key)
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		r.Response.WriteJsonExit(g.Map{
+			"code": 400,
+			"msg":  "读取请求体失败",
+		})
+	}
+	if len(body) > 0 {
+		decrypted, err := gaes.Decrypt(body, key)
 		if err != nil {
 			r.Response.WriteJsonExit(g.Map{
 				"code": 500,
 				"msg":  "解密失败",
 			})
 		}
-		r.Body = ghttp.NewRequestBody(decrypted)
+		body = decrypted
 	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
 
 	r.Middleware.Next()
 
@@ -23,7 +23,7 @@ func CORS(r *ghttp.Request) {
 
 	// 处理预检请求
 	if r.Method == "OPTIONS" {
-		r.Response.WriteJsonExit(200, "ok")
+		r.Response.WriteStatusExit(200)
 	}
 
 	r.Middleware.Next()
@@ -1 +1,24 @@
- 
\ No newline at end of file
+package middleware
+
+import (
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/net/ghttp"
+	"time"
+)
+
+// Logger 日志中间件
+func Logger(r *ghttp.Request) {
+	// 记录开始时间
+	start := time.Now()
+
+	r.Middleware.Next()
+
+	// 记录请求信息
+	g.Log().Infof(r.Context(), "%s %s %d %s %s",
+		r.Method,
+		r.URL.Path,
+		r.Response.Status,
+		time.Since(start),
+		r.GetClientIp(),
+	)
+}
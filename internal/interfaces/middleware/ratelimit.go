@@ -1 +1,46 @@
- 
\ No newline at end of file
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/net/ghttp"
+)
+
+// rateLimitWindow 限流时间窗口（秒）
+const rateLimitWindow = 60
+
+// RateLimit 限流中间件，按客户端IP限制每个时间窗口内的请求次数
+func RateLimit(r *ghttp.Request) {
+	// 获取限流配置
+	if !g.Cfg().MustGet(context.Background(), "ratelimit.enabled").Bool() {
+		r.Middleware.Next()
+		return
+	}
+	rate := g.Cfg().MustGet(context.Background(), "ratelimit.rate", 100).Int64()
+
+	// 累加当前时间窗口内的请求次数
+	key := fmt.Sprintf("rate_limit:%s", r.GetClientIp())
+	count, err := g.Redis().Incr(r.Context(), key)
+	if err != nil {
+		// 限流检查失败时放行
+		g.Log().Errorf(r.Context(), "限流检查失败: %v", err)
+		r.Middleware.Next()
+		return
+	}
+	if count == 1 {
+		if _, err := g.Redis().Expire(r.Context(), key, rateLimitWindow); err != nil {
+			g.Log().Errorf(r.Context(), "设置限流窗口失败: %v", err)
+		}
+	}
+
+	// 检查是否超过限制
+	if count > rate {
+		r.Response.WriteJsonExit(g.Map{
+			"code": 429,
+			"msg":  "请求过于频繁",
+		})
+	}
+
+	r.Middleware.Next()
+}
@@ -3,12 +3,19 @@ package router
 import (
 	"ai-translate/internal/interfaces/api"
 	"ai-translate/internal/interfaces/middleware"
-	"github.com/gogf/gf/v2/frame/g"
+	"ai-translate/internal/service"
 	"github.com/gogf/gf/v2/net/ghttp"
 )
 
-// Register 注册路由
-func Register(s *ghttp.Server) {
+// Register 注册路由，任务接口共用传入的任务服务
+func Register(s *ghttp.Server, taskService *service.TaskService) error {
+	userController := api.NewUserController()
+	workController, err := api.NewWorkController()
+	if err != nil {
+		return err
+	}
+	promptController := api.NewPromptController()
+
 	// 全局中间件
 	s.Use(
 		middleware.Logger,    // 日志中间件
@@ -21,8 +28,8 @@ func Register(s *ghttp.Server) {
 	// 公开API
 	s.Group("/api", func(group *ghttp.RouterGroup) {
 		// 用户认证
-		group.POST("/register", api.NewUserController().Register)
-		group.POST("/login", api.NewUserController().Login)
+		group.POST("/register", userController.Register)
+		group.POST("/login", userController.Login)
 	})
 
 	// 需要认证的API
@@ -31,37 +38,38 @@ func Register(s *ghttp.Server) {
 		group.Middleware(middleware.Auth)
 
 		// 用户管理
-		group.GET("/user/info", api.NewUserController().GetUserInfo)
-		group.PUT("/user/info", api.NewUserController().UpdateUser)
-		group.DELETE("/user", api.NewUserController().DeleteUser)
+		group.GET("/user/info", userController.GetUserInfo)
+		group.PUT("/user/info", userController.UpdateUser)
+		group.DELETE("/user", userController.DeleteUser)
 
 		// 作品管理
-		group.POST("/works", api.NewWorkController().CreateWork)
-		group.GET("/works/:id", api.NewWorkController().GetWork)
-		group.GET("/works", api.NewWorkController().GetUserWorks)
-		group.PUT("/works/:id", api.NewWorkController().UpdateWork)
-		group.DELETE("/works/:id", api.NewWorkController().DeleteWork)
+		group.POST("/works", workController.CreateWork)
+		group.GET("/works/:id", workController.GetWork)
+		group.GET("/works", workController.GetUserWorks)
+		group.PUT("/works/:id", workController.UpdateWork)
+		group.DELETE("/works/:id", workController.DeleteWork)
+		group.POST("/works/:id/cancel", workController.CancelWork)
 
 		// 翻译批次管理
-		group.POST("/works/:id/batches", api.NewWorkController().CreateTranslationBatch)
-		group.GET("/works/:id/batches/:batchId", api.NewWorkController().GetTranslationBatch)
-		group.GET("/works/:id/batches", api.NewWorkController().GetWorkTranslationBatches)
+		group.POST("/works/:id/batches", workController.CreateTranslationBatch)
+		group.GET("/works/:id/batches/:batchId", workController.GetTranslationBatch)
+		group.GET("/works/:id/batches", workController.GetWorkTranslationBatches)
+		group.GET("/works/:id/batches/:batchId/result", workController.GetTranslationResult)
+		group.POST("/works/:id/batches/:batchId/cancel", workController.CancelTranslationBatch)
 
-		// 提示词管理
-		group.POST("/prompts", api.NewPromptController().CreatePrompt)
-		group.GET("/prompts/:id", api.NewPromptController().GetPrompt)
-		group.GET("/prompts", api.NewPromptController().GetPromptsByType)
-		group.PUT("/prompts/:id", api.NewPromptController().UpdatePrompt)
-		group.DELETE("/prompts/:id", api.NewPromptController().DeletePrompt)
+		// 作品任务依赖图
+		group.GET("/works/:id/tasks", workController.GetWorkTaskGraph)
+		group.POST("/works/:id/tasks/:taskId/cancel", workController.CancelWorkTask)
 
-		// 任务管理
-		group.POST("/tasks", api.NewTaskController().CreateTask)
-		group.GET("/tasks/:id", api.NewTaskController().GetTask)
-		group.GET("/tasks", api.NewTaskController().GetTasksByType)
-		group.PUT("/tasks/:id", api.NewTaskController().UpdateTask)
-		group.DELETE("/tasks/:id", api.NewTaskController().DeleteTask)
-		group.POST("/tasks/:id/pause", api.NewTaskController().PauseTask)
-		group.POST("/tasks/:id/resume", api.NewTaskController().ResumeTask)
-		group.POST("/tasks/:id/retry", api.NewTaskController().RetryTask)
+		// 提示词管理
+		group.POST("/prompts", promptController.CreatePrompt)
+		group.GET("/prompts/:id", promptController.GetPrompt)
+		group.GET("/prompts", promptController.GetPromptsByType)
+		group.PUT("/prompts/:id", promptController.UpdatePrompt)
+		group.DELETE("/prompts/:id", promptController.DeletePrompt)
 	})
-} 
\ No newline at end of file
+
+	// 任务管理
+	api.RegisterRoutes(s, taskService)
+	return nil
+}
@@ -1,6 +1,7 @@
 package model
 
 import (
+	"context"
 	"time"
 	"ai-translate/internal/infrastructure/ai"
 )
@@ -43,17 +44,34 @@ type Task struct {
 	Status      TaskStatus   `json:"status" gorm:"index"`
 	Priority    TaskPriority `json:"priority" gorm:"index"` // 任务优先级
 	Content     string       `json:"content"`
+	Language    string       `json:"language"`    // 内容生成的目标语言
+	SourceLang  string       `json:"source_lang"` // 翻译源语言
+	TargetLang  string       `json:"target_lang"` // 翻译目标语言
 	Result      string       `json:"result"`
 	Error       string       `json:"error"`
+	Progress    int          `json:"progress"`                            // 完成百分比（0-100）
+	PartialResult string     `json:"partial_result" gorm:"type:longtext"` // 处理中的部分结果
 	Driver      ai.DriverType `json:"driver"`
+	Tags        []string     `json:"tags" gorm:"serializer:json"` // 规则动作添加的标签
+	EnqueueSeq  int64        `json:"enqueue_seq"`                 // 最近一次投递到队列的序号，序号不一致的消息已被后续投递取代
 	RetryCount  int          `json:"retry_count"`
 	MaxRetries  int          `json:"max_retries"`
+	NextRetryAt *time.Time   `json:"next_retry_at" gorm:"index"` // 下次重试时间，退避期间不会被领取
+	LeaseOwner  string       `json:"lease_owner"`                // 持有租约的工作协程，为空表示未被领取
+	LeaseExpiresAt *time.Time `json:"lease_expires_at" gorm:"index"` // 租约到期时间，到期未续租的任务由回收协程处理
+	DeadlineAt  *time.Time   `json:"deadline_at" gorm:"index"`     // 本次执行的截止时间，按任务类型的执行时限计算，为空表示不限制
+	AgedAt      *time.Time   `json:"aged_at"`                      // 最近一次老化提升优先级的时间，为空表示未老化过，老化等待时间从此时或创建时间起算
 	StartedAt   *time.Time   `json:"started_at"`
 	CompletedAt *time.Time   `json:"completed_at"`
 	CreatedAt   time.Time    `json:"created_at"`
 	UpdatedAt   time.Time    `json:"updated_at"`
 }
 
+// TableName 队列任务表名，与工作流任务的tasks表区分
+func (Task) TableName() string {
+	return "queue_tasks"
+}
+
 // TaskStats 任务统计
 type TaskStats struct {
 	TotalTasks      int64 `json:"total_tasks"`
@@ -71,18 +89,82 @@ type PriorityAdjustRule struct {
 	ID          string       `json:"id" gorm:"primaryKey"`
 	Name        string       `json:"name"`                    // 规则名称
 	Description string       `json:"description"`             // 规则描述
-	Condition   string       `json:"condition"`               // 触发条件
-	Action      string       `json:"action"`                  // 执行动作
-	Priority    TaskPriority `json:"priority"`                // 调整后的优先级
+	Condition   string       `json:"condition"`               // 触发条件表达式，如 age_minutes > 30 && retry_count >= 2，为空表示总是触发
+	Action      string       `json:"action"`                  // 执行动作，如 raise_priority(1); tag("slow")，多个动作以分号分隔
+	Priority    TaskPriority `json:"priority"`                // 调整后的优先级，Action为空时等同于 set_priority(Priority)
 	WorkID      string       `json:"work_id" gorm:"index"`    // 工作ID
 	BatchID     string       `json:"batch_id" gorm:"index"`   // 批次ID
 	TaskType    TaskType     `json:"task_type" gorm:"index"`  // 任务类型
 	Status      TaskStatus   `json:"status" gorm:"index"`     // 任务状态
 	Enabled     bool         `json:"enabled"`                 // 是否启用
+	Order       int          `json:"order" gorm:"column:sort_order;index"` // 评估顺序，不属于规则组的规则按Order升序评估，相同时按Priority降序
+	TemplateID  string       `json:"template_id" gorm:"index"` // 来源模板ID，为空表示手动创建
+	TemplateVersion int      `json:"template_version"`        // 实例化时的模板版本
+	TemplateParams map[string]string `json:"template_params" gorm:"serializer:json"` // 实例化时的模板参数，重新应用模板时使用
 	CreatedAt   time.Time    `json:"created_at"`
 	UpdatedAt   time.Time    `json:"updated_at"`
 }
 
+// TemplateParamType 模板参数类型
+type TemplateParamType string
+
+const (
+	TemplateParamString  TemplateParamType = "string"  // 字符串，在条件与动作中替换为带引号的字符串字面量
+	TemplateParamNumber  TemplateParamType = "number"  // 数值
+	TemplateParamInteger TemplateParamType = "integer" // 整数
+)
+
+// TemplateParam 模板参数
+type TemplateParam struct {
+	Name        string            `json:"name"`        // 参数名，模板中以 {name} 引用
+	Type        TemplateParamType `json:"type"`        // 参数类型
+	Default     string            `json:"default"`     // 默认值，为空表示必填
+	Description string            `json:"description"` // 参数说明
+}
+
+// RuleTemplate 规则模板，作用范围、条件与动作中可使用 {name} 形式的占位符，实例化时按参数类型替换
+type RuleTemplate struct {
+	ID          string          `json:"id" gorm:"primaryKey"`
+	Name        string          `json:"name"`                         // 模板名称
+	Description string          `json:"description"`                  // 模板描述
+	Params      []TemplateParam `json:"params" gorm:"serializer:json"` // 参数定义
+	WorkID      string          `json:"work_id"`                      // 工作ID，如 {work_id}
+	BatchID     string          `json:"batch_id"`                     // 批次ID
+	TaskType    TaskType        `json:"task_type"`                    // 任务类型
+	Status      TaskStatus      `json:"status"`                       // 任务状态
+	Condition   string          `json:"condition"`                    // 触发条件表达式，如 age_minutes > {threshold_minutes}
+	Action      string          `json:"action"`                       // 执行动作
+	Priority    TaskPriority    `json:"priority"`                     // 调整后的优先级，Action为空时使用
+	Version     int             `json:"version"`                      // 模板版本，每次修改加一
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
+
+// RuleGroupStrategy 规则组内多条规则同时匹配时的处理方式
+type RuleGroupStrategy string
+
+const (
+	RuleGroupFirstMatch RuleGroupStrategy = "first_match" // 按顺序执行第一个生效的规则，为空时的默认值
+	RuleGroupHighest    RuleGroupStrategy = "highest"     // 执行调整后优先级最高的规则，相同时取靠前的
+	RuleGroupLowest     RuleGroupStrategy = "lowest"      // 执行调整后优先级最低的规则，相同时取靠前的
+	RuleGroupCumulative RuleGroupStrategy = "cumulative"  // 按顺序依次执行所有生效的规则，优先级累计调整并限制在合法范围内
+)
+
+// RuleGroup 规则组，组内规则按Rules中的顺序评估，多条规则匹配时按Strategy处理
+type RuleGroup struct {
+	ID          string            `json:"id" gorm:"primaryKey"`
+	Name        string            `json:"name"`                        // 规则组名称
+	Description string            `json:"description"`                 // 规则组描述
+	Rules       []string          `json:"rules" gorm:"serializer:json"` // 规则ID列表，顺序即评估顺序
+	Strategy    RuleGroupStrategy `json:"strategy"`                    // 冲突处理方式
+	Enabled     bool              `json:"enabled" gorm:"index"`        // 是否启用
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// AgingRuleID 等待老化提升优先级时写入调整日志的规则ID
+const AgingRuleID = "aging"
+
 // PriorityAdjustLog 优先级调整日志
 type PriorityAdjustLog struct {
 	ID        string       `json:"id" gorm:"primaryKey"`
@@ -90,12 +172,55 @@ type PriorityAdjustLog struct {
 	RuleID    string       `json:"rule_id" gorm:"index"`
 	OldPriority TaskPriority `json:"old_priority"`
 	NewPriority TaskPriority `json:"new_priority"`
+	Action    string       `json:"action"` // 实际执行的动作，如 pause(); reroute("openai")
 	Reason    string       `json:"reason"`
 	CreatedAt time.Time    `json:"created_at"`
 }
 
+// TaskOutput 任务产出，由SaveTaskResult与任务完成状态在同一事务中写入
+type TaskOutput struct {
+	Result      string // 任务结果
+	ArtifactURL string // 上传到对象存储的产物地址
+	LeaseOwner  string // 非空时仅当任务仍在处理中且租约属于该工作协程时写入，租约已被回收的结果被丢弃
+}
+
+// ContentSummaryRecord 内容生成任务写入的内容简介，TaskID唯一，重复投递的消息不会产生重复记录
+type ContentSummaryRecord struct {
+	ID        uint64    `gorm:"primaryKey"`
+	WorkID    uint64    `gorm:"column:work_id"`
+	TaskID    string    `gorm:"column:task_id;uniqueIndex"`
+	Content   string    `gorm:"column:content"`
+	OssURL    string    `gorm:"column:oss_url"`
+	Status    int       `gorm:"column:status"` // 1:已生成
+	CreatedAt time.Time `gorm:"column:created_at"`
+}
+
+// TableName 内容简介表名
+func (ContentSummaryRecord) TableName() string {
+	return "content_summaries"
+}
+
+// TranslationResultRecord 翻译任务写入的翻译结果，TaskID唯一，重复投递的消息不会产生重复记录
+type TranslationResultRecord struct {
+	ID        uint64    `gorm:"primaryKey"`
+	BatchID   uint64    `gorm:"column:batch_id"`
+	TaskID    string    `gorm:"column:task_id;uniqueIndex"`
+	SrtURL    string    `gorm:"column:srt_url"`
+	Status    int       `gorm:"column:status"` // 1:已完成
+	CreatedAt time.Time `gorm:"column:created_at"`
+}
+
+// TableName 翻译结果表名
+func (TranslationResultRecord) TableName() string {
+	return "translation_results"
+}
+
 // TaskRepository 任务仓储接口
 type TaskRepository interface {
+	// 在同一事务中执行fn，fn通过传入的仓储读写，返回错误时回滚
+	Transaction(ctx context.Context, fn func(repository TaskRepository) error) error
+	// 以数据库命名锁保证多个实例中同一时刻只有一个执行fn，锁已被其他实例持有时不执行并返回false
+	TryLock(ctx context.Context, name string, fn func() error) (bool, error)
 	// 创建任务
 	Create(ctx context.Context, task *Task) error
 	// 获取任务
@@ -112,10 +237,42 @@ type TaskRepository interface {
 	UpdateStatus(ctx context.Context, id string, status TaskStatus) error
 	// 增加重试次数
 	IncrementRetryCount(ctx context.Context, id string) error
+	// 更新任务进度
+	UpdateProgress(ctx context.Context, id string, progress int, partialResult string) error
+	// 安排任务重试：增加重试次数、记录错误并在nextRetryAt前不再领取
+	ScheduleRetry(ctx context.Context, id string, errMsg string, nextRetryAt time.Time) error
+	// 保存任务结果并标记任务完成，同一事务内写入内容简介或翻译结果并更新所属批次与作品；任务已完成或租约已不属于output.LeaseOwner时不做修改并返回false
+	SaveTaskResult(ctx context.Context, task *Task, output TaskOutput) (bool, error)
+	// 标记任务失败并记录失败原因
+	MarkFailed(ctx context.Context, id string, errMsg string) error
+	// 领取优先级最高的待处理任务并设置租约，多个工作协程并发领取时同一任务只会被领取一次，没有待处理任务时返回nil
+	ClaimTask(ctx context.Context, owner string, lease time.Duration) (*Task, error)
+	// 领取队列消息对应的任务并设置租约：任务待处理，或处理中但租约已过期且仍可重试时领取成功，否则返回nil
+	ClaimTaskByID(ctx context.Context, id, owner string, lease time.Duration) (*Task, error)
+	// 续租，租约已不属于owner时返回false
+	RenewLease(ctx context.Context, id, owner string, lease time.Duration) (bool, error)
+	// 设置本次执行的截止时间
+	SetDeadline(ctx context.Context, id string, deadline *time.Time) error
+	// 获取租约在leaseBefore前到期或截止时间早于deadlineBefore的处理中任务，按ID升序返回ID大于afterID的任务
+	ListStuckTasks(ctx context.Context, leaseBefore, deadlineBefore time.Time, afterID string, limit int) ([]*Task, error)
+	// 任务仍卡住时放回等待处理、增加重试次数并记录原因，返回是否已放回
+	RequeueStuckTask(ctx context.Context, id string, leaseBefore, deadlineBefore time.Time, reason string) (bool, error)
+	// 任务仍卡住时标记失败并记录原因，返回是否已标记
+	FailStuckTask(ctx context.Context, id string, leaseBefore, deadlineBefore time.Time, reason string) (bool, error)
 	// 获取待处理任务
 	GetPendingTasks(ctx context.Context, limit int) ([]*Task, error)
+	// 按状态分页获取任务，按ID升序返回ID大于afterID的任务
+	ListTasksByStatus(ctx context.Context, statuses []TaskStatus, afterID string, limit int) ([]*Task, error)
+	// 分页获取创建时间在[since, until)内且已开始处理的任务，按ID升序返回ID大于afterID的任务
+	ListStartedTasks(ctx context.Context, since, until time.Time, afterID string, limit int) ([]*Task, error)
+	// 增加任务投递序号并返回新序号，投递消息时携带
+	NextEnqueueSeq(ctx context.Context, id string) (int64, error)
 	// 更新任务优先级
 	UpdatePriority(ctx context.Context, id string, priority TaskPriority) error
+	// 以任务仍待处理且老化时间仍为prev为条件记录本次老化时间，任务已被其他巡检老化时返回false
+	MarkAged(ctx context.Context, id string, prev *time.Time, at time.Time) (bool, error)
+	// 更新任务使用的AI驱动与标签
+	UpdateRouting(ctx context.Context, id string, driver ai.DriverType, tags []string) error
 	// 批量更新任务优先级
 	BatchUpdatePriority(ctx context.Context, ids []string, priority TaskPriority) error
 	// 根据条件更新任务优先级
@@ -127,8 +284,32 @@ type TaskRepository interface {
 	UpdatePriorityRule(ctx context.Context, rule *PriorityAdjustRule) error
 	DeletePriorityRule(ctx context.Context, id string) error
 	ListPriorityRules(ctx context.Context, workID, batchID string, taskType TaskType, enabled bool, page, size int) ([]*PriorityAdjustRule, int64, error)
+	GetApplicableRules(ctx context.Context, task *Task) ([]*PriorityAdjustRule, error)
+	EvaluateRule(ctx context.Context, rule *PriorityAdjustRule, task *Task) (bool, error)
+
+	ListRulesByTemplate(ctx context.Context, templateID string) ([]*PriorityAdjustRule, error)
+
+	// 规则模板相关方法
+	CreateRuleTemplate(ctx context.Context, template *RuleTemplate) error
+	GetRuleTemplate(ctx context.Context, id string) (*RuleTemplate, error)
+	UpdateRuleTemplate(ctx context.Context, template *RuleTemplate) error
+	DeleteRuleTemplate(ctx context.Context, id string) error
+	ListRuleTemplates(ctx context.Context, page, size int) ([]*RuleTemplate, int64, error)
+
+	// 规则组相关方法
+	CreateRuleGroup(ctx context.Context, group *RuleGroup) error
+	GetRuleGroup(ctx context.Context, id string) (*RuleGroup, error)
+	UpdateRuleGroup(ctx context.Context, group *RuleGroup) error
+	DeleteRuleGroup(ctx context.Context, id string) error
+	ListRuleGroups(ctx context.Context, enabled bool, page, size int) ([]*RuleGroup, int64, error)
+	AddRuleToGroup(ctx context.Context, groupID, ruleID string) error
+	RemoveRuleFromGroup(ctx context.Context, groupID, ruleID string) error
+	GetGroupRules(ctx context.Context, groupID string) ([]*PriorityAdjustRule, error)
+	EvaluateRuleGroup(ctx context.Context, groupID string, task *Task) ([]*PriorityAdjustRule, error)
 	
 	// 优先级调整日志相关方法
 	CreatePriorityLog(ctx context.Context, log *PriorityAdjustLog) error
 	GetPriorityLogs(ctx context.Context, taskID string, page, size int) ([]*PriorityAdjustLog, int64, error)
+	// 任务是否已被指定规则调整过
+	HasPriorityLog(ctx context.Context, taskID, ruleID string) (bool, error)
 } 
\ No newline at end of file
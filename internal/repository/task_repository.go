@@ -2,10 +2,18 @@ package repository
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"github.com/gogf/gf/v2/frame/g"
+	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"strconv"
+	"time"
 	"ai-translate/internal/model"
+	"ai-translate/internal/infrastructure/ai"
+	"ai-translate/internal/infrastructure/expr"
+	"ai-translate/internal/infrastructure/utils"
 )
 
 // TaskRepositoryImpl 任务仓储实现
@@ -13,9 +21,13 @@ type TaskRepositoryImpl struct {
 	db *gorm.DB
 }
 
-// NewTaskRepository 创建任务仓储
+// NewTaskRepository 创建任务仓储，复用框架数据库配置的连接池
 func NewTaskRepository() (model.TaskRepository, error) {
-	db, err := g.DB().GetDB()
+	sqlDB, err := g.DB().Master()
+	if err != nil {
+		return nil, fmt.Errorf("获取数据库连接失败: %v", err)
+	}
+	db, err := gorm.Open(mysql.New(mysql.Config{Conn: sqlDB}), &gorm.Config{})
 	if err != nil {
 		return nil, fmt.Errorf("获取数据库连接失败: %v", err)
 	}
@@ -25,6 +37,35 @@ func NewTaskRepository() (model.TaskRepository, error) {
 	}, nil
 }
 
+// Transaction 在同一事务中执行fn，fn中的读写使用事务内的仓储
+func (r *TaskRepositoryImpl) Transaction(ctx context.Context, fn func(repository model.TaskRepository) error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(&TaskRepositoryImpl{db: tx})
+	})
+}
+
+// TryLock 在固定连接上以 GET_LOCK 获取命名锁，不等待；fn执行完毕后释放锁，连接断开时锁自动释放
+func (r *TaskRepositoryImpl) TryLock(ctx context.Context, name string, fn func() error) (bool, error) {
+	acquired := false
+	err := r.db.WithContext(ctx).Connection(func(conn *gorm.DB) error {
+		var locked sql.NullInt64
+		if err := conn.Raw("SELECT GET_LOCK(?, 0)", name).Scan(&locked).Error; err != nil {
+			return fmt.Errorf("获取命名锁失败: %v", err)
+		}
+		if !locked.Valid || locked.Int64 != 1 {
+			return nil
+		}
+		acquired = true
+		defer func() {
+			if err := conn.Exec("SELECT RELEASE_LOCK(?)", name).Error; err != nil {
+				g.Log().Warningf(ctx, "释放命名锁失败: %s, %v", name, err)
+			}
+		}()
+		return fn()
+	})
+	return acquired, err
+}
+
 // Create 创建任务
 func (r *TaskRepositoryImpl) Create(ctx context.Context, task *model.Task) error {
 	return r.db.WithContext(ctx).Create(task).Error
@@ -130,9 +171,25 @@ func (r *TaskRepositoryImpl) GetStats(ctx context.Context, workID string) (*mode
 
 // UpdateStatus 更新任务状态
 func (r *TaskRepositoryImpl) UpdateStatus(ctx context.Context, id string, status model.TaskStatus) error {
+	updates := map[string]interface{}{"status": status}
+
+	// 记录首次开始与完成时间，用于统计处理时长与排队等待时长
+	switch status {
+	case model.TaskStatusRunning:
+		updates["started_at"] = gorm.Expr("COALESCE(started_at, ?)", time.Now())
+	case model.TaskStatusCompleted:
+		updates["completed_at"] = time.Now()
+	}
+	// 离开处理中状态时释放租约并清除截止时间
+	if status != model.TaskStatusRunning {
+		updates["lease_owner"] = ""
+		updates["lease_expires_at"] = nil
+		updates["deadline_at"] = nil
+	}
+
 	return r.db.WithContext(ctx).Model(&model.Task{}).
 		Where("id = ?", id).
-		Update("status", status).Error
+		Updates(updates).Error
 }
 
 // IncrementRetryCount 增加重试次数
@@ -142,11 +199,331 @@ func (r *TaskRepositoryImpl) IncrementRetryCount(ctx context.Context, id string)
 		UpdateColumn("retry_count", gorm.Expr("retry_count + ?", 1)).Error
 }
 
-// GetPendingTasks 获取待处理任务
+// UpdateProgress 更新任务进度
+func (r *TaskRepositoryImpl) UpdateProgress(ctx context.Context, id string, progress int, partialResult string) error {
+	return r.db.WithContext(ctx).Model(&model.Task{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"progress":       progress,
+			"partial_result": partialResult,
+		}).Error
+}
+
+// ScheduleRetry 安排任务重试
+func (r *TaskRepositoryImpl) ScheduleRetry(ctx context.Context, id string, errMsg string, nextRetryAt time.Time) error {
+	return r.db.WithContext(ctx).Model(&model.Task{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"status":           model.TaskStatusPending,
+			"error":            errMsg,
+			"retry_count":      gorm.Expr("retry_count + ?", 1),
+			"next_retry_at":    nextRetryAt,
+			"lease_owner":      "",
+			"lease_expires_at": nil,
+			"deadline_at":      nil,
+		}).Error
+}
+
+// MarkFailed 标记任务失败
+func (r *TaskRepositoryImpl) MarkFailed(ctx context.Context, id string, errMsg string) error {
+	return r.db.WithContext(ctx).Model(&model.Task{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"status":           model.TaskStatusFailed,
+			"error":            errMsg,
+			"next_retry_at":    nil,
+			"lease_owner":      "",
+			"lease_expires_at": nil,
+			"deadline_at":      nil,
+		}).Error
+}
+
+// SaveTaskResult 保存任务结果并标记任务完成，内容简介、翻译结果与所属批次、作品的状态在同一事务中写入
+// 以任务未完成为条件更新，重复投递的消息在任务已完成时不做任何修改
+// 指定租约持有者时以任务处理中且租约属于该持有者为条件，租约已被回收的工作协程不会覆盖重新领取后的任务
+func (r *TaskRepositoryImpl) SaveTaskResult(ctx context.Context, task *model.Task, output model.TaskOutput) (bool, error) {
+	saved := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		query := tx.Model(&model.Task{}).Where("id = ? AND status <> ?", task.ID, model.TaskStatusCompleted)
+		if output.LeaseOwner != "" {
+			query = query.Where("status = ? AND lease_owner = ?", model.TaskStatusRunning, output.LeaseOwner)
+		}
+		result := query.
+			Updates(map[string]interface{}{
+				"status":           model.TaskStatusCompleted,
+				"result":           output.Result,
+				"error":            "",
+				"progress":         100,
+				"partial_result":   "",
+				"next_retry_at":    nil,
+				"lease_owner":      "",
+				"lease_expires_at": nil,
+				"deadline_at":      nil,
+				"started_at":       gorm.Expr("COALESCE(started_at, ?)", now),
+				"completed_at":     now,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		saved = true
+
+		switch task.Type {
+		case model.TaskTypeContentGeneration:
+			return saveContentSummary(tx, task, output, now)
+		case model.TaskTypeTranslation:
+			return saveTranslationResult(tx, task, output, now)
+		}
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return saved, nil
+}
+
+// saveContentSummary 写入内容简介并将作品标记为成功，未关联作品的任务只保存任务结果
+func saveContentSummary(tx *gorm.DB, task *model.Task, output model.TaskOutput, now time.Time) error {
+	if task.WorkID == "" {
+		return nil
+	}
+	workID, err := strconv.ParseUint(task.WorkID, 10, 64)
+	if err != nil {
+		return fmt.Errorf("作品ID无效: %s", task.WorkID)
+	}
+
+	summary := &model.ContentSummaryRecord{
+		WorkID:    workID,
+		TaskID:    task.ID,
+		Content:   output.Result,
+		OssURL:    output.ArtifactURL,
+		Status:    1,
+		CreatedAt: now,
+	}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(summary).Error; err != nil {
+		return fmt.Errorf("保存内容简介失败: %v", err)
+	}
+
+	return tx.Table("works").
+		Where("id = ?", workID).
+		Updates(map[string]interface{}{"status": utils.WorkStatusSuccess, "updated_at": now}).Error
+}
+
+// saveTranslationResult 写入翻译结果并将批次标记为已完成，未关联批次的任务只保存任务结果
+func saveTranslationResult(tx *gorm.DB, task *model.Task, output model.TaskOutput, now time.Time) error {
+	if task.BatchID == "" {
+		return nil
+	}
+	batchID, err := strconv.ParseUint(task.BatchID, 10, 64)
+	if err != nil {
+		return fmt.Errorf("批次ID无效: %s", task.BatchID)
+	}
+
+	translation := &model.TranslationResultRecord{
+		BatchID:   batchID,
+		TaskID:    task.ID,
+		SrtURL:    output.ArtifactURL,
+		Status:    utils.TranslationResultStatusSuccess,
+		CreatedAt: now,
+	}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(translation).Error; err != nil {
+		return fmt.Errorf("保存翻译结果失败: %v", err)
+	}
+
+	if err := tx.Table("translation_batches").
+		Where("id = ?", batchID).
+		Updates(map[string]interface{}{"status": utils.TranslationBatchStatusSuccess, "updated_at": now}).Error; err != nil {
+		return err
+	}
+	return tx.Table("works").
+		Where("id = (?)", tx.Table("translation_batches").Select("work_id").Where("id = ?", batchID)).
+		Update("updated_at", now).Error
+}
+
+// runnableRetries 待处理任务仍可执行的重试次数条件，与ai.CanRun一致：最后一次重试放回等待处理时retry_count等于max_retries
+const runnableRetries = "retry_count <= max_retries"
+
+// ClaimTask 领取待处理任务，以 FOR UPDATE SKIP LOCKED 锁定候选任务，并发领取的工作协程会跳过已被锁定的任务
+func (r *TaskRepositoryImpl) ClaimTask(ctx context.Context, owner string, lease time.Duration) (*model.Task, error) {
+	var claimed *model.Task
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		var tasks []*model.Task
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ?", model.TaskStatusPending).
+			Where(runnableRetries).
+			Where("next_retry_at IS NULL OR next_retry_at <= ?", now).
+			Order("priority DESC, created_at ASC").
+			Limit(1).
+			Find(&tasks).Error; err != nil {
+			return err
+		}
+		if len(tasks) == 0 {
+			return nil
+		}
+
+		task := tasks[0]
+		expiresAt := now.Add(lease)
+		if err := tx.Model(&model.Task{}).
+			Where("id = ?", task.ID).
+			Updates(map[string]interface{}{
+				"status":           model.TaskStatusRunning,
+				"lease_owner":      owner,
+				"lease_expires_at": expiresAt,
+				"started_at":       gorm.Expr("COALESCE(started_at, ?)", now),
+			}).Error; err != nil {
+			return err
+		}
+
+		task.Status = model.TaskStatusRunning
+		task.LeaseOwner = owner
+		task.LeaseExpiresAt = &expiresAt
+		if task.StartedAt == nil {
+			task.StartedAt = &now
+		}
+		claimed = task
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return claimed, nil
+}
+
+// ClaimTaskByID 领取队列消息对应的任务，以 FOR UPDATE 锁定任务后判断是否可领取
+// 处理中的任务只有在租约过期（原工作协程崩溃或失联）且仍可重试时才被重新领取，重新领取计为一次重试
+func (r *TaskRepositoryImpl) ClaimTaskByID(ctx context.Context, id, owner string, lease time.Duration) (*model.Task, error) {
+	var claimed *model.Task
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		var tasks []*model.Task
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ?", id).
+			Where("(status = ? AND "+runnableRetries+") OR (status = ? AND lease_expires_at < ? AND retry_count < max_retries)",
+				model.TaskStatusPending, model.TaskStatusRunning, now).
+			Limit(1).
+			Find(&tasks).Error; err != nil {
+			return err
+		}
+		if len(tasks) == 0 {
+			return nil
+		}
+
+		task := tasks[0]
+		expiresAt := now.Add(lease)
+		updates := map[string]interface{}{
+			"status":           model.TaskStatusRunning,
+			"lease_owner":      owner,
+			"lease_expires_at": expiresAt,
+			"started_at":       gorm.Expr("COALESCE(started_at, ?)", now),
+		}
+		if task.Status == model.TaskStatusRunning {
+			updates["retry_count"] = task.RetryCount + 1
+			task.RetryCount++
+		}
+		if err := tx.Model(&model.Task{}).Where("id = ?", task.ID).Updates(updates).Error; err != nil {
+			return err
+		}
+
+		task.Status = model.TaskStatusRunning
+		task.LeaseOwner = owner
+		task.LeaseExpiresAt = &expiresAt
+		if task.StartedAt == nil {
+			task.StartedAt = &now
+		}
+		claimed = task
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return claimed, nil
+}
+
+// RenewLease 续租，只有仍持有租约的工作协程能续租
+func (r *TaskRepositoryImpl) RenewLease(ctx context.Context, id, owner string, lease time.Duration) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&model.Task{}).
+		Where("id = ? AND status = ? AND lease_owner = ?", id, model.TaskStatusRunning, owner).
+		Update("lease_expires_at", time.Now().Add(lease))
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// SetDeadline 设置本次执行的截止时间
+func (r *TaskRepositoryImpl) SetDeadline(ctx context.Context, id string, deadline *time.Time) error {
+	return r.db.WithContext(ctx).Model(&model.Task{}).
+		Where("id = ?", id).
+		Update("deadline_at", deadline).Error
+}
+
+// stuckTasks 租约在leaseBefore前到期或截止时间早于deadlineBefore的处理中任务
+func stuckTasks(db *gorm.DB, leaseBefore, deadlineBefore time.Time) *gorm.DB {
+	return db.Where("status = ?", model.TaskStatusRunning).
+		Where("lease_expires_at < ? OR deadline_at < ?", leaseBefore, deadlineBefore)
+}
+
+// ListStuckTasks 获取租约在leaseBefore前到期或截止时间早于deadlineBefore的处理中任务，按ID升序返回ID大于afterID的任务
+func (r *TaskRepositoryImpl) ListStuckTasks(ctx context.Context, leaseBefore, deadlineBefore time.Time, afterID string, limit int) ([]*model.Task, error) {
+	var tasks []*model.Task
+	if err := stuckTasks(r.db.WithContext(ctx), leaseBefore, deadlineBefore).
+		Where("id > ?", afterID).
+		Order("id ASC").
+		Limit(limit).
+		Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+// RequeueStuckTask 以任务仍卡住为条件放回等待处理，任务在此之前已完成或已被其他实例处理时不做修改
+func (r *TaskRepositoryImpl) RequeueStuckTask(ctx context.Context, id string, leaseBefore, deadlineBefore time.Time, reason string) (bool, error) {
+	result := stuckTasks(r.db.WithContext(ctx).Model(&model.Task{}), leaseBefore, deadlineBefore).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"status":           model.TaskStatusPending,
+			"error":            reason,
+			"retry_count":      gorm.Expr("retry_count + ?", 1),
+			"next_retry_at":    nil,
+			"lease_owner":      "",
+			"lease_expires_at": nil,
+			"deadline_at":      nil,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// FailStuckTask 以任务仍卡住为条件标记失败，任务在此之前已完成或已被其他实例处理时不做修改
+func (r *TaskRepositoryImpl) FailStuckTask(ctx context.Context, id string, leaseBefore, deadlineBefore time.Time, reason string) (bool, error) {
+	result := stuckTasks(r.db.WithContext(ctx).Model(&model.Task{}), leaseBefore, deadlineBefore).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"status":           model.TaskStatusFailed,
+			"error":            reason,
+			"next_retry_at":    nil,
+			"lease_owner":      "",
+			"lease_expires_at": nil,
+			"deadline_at":      nil,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// GetPendingTasks 获取待处理任务，跳过仍在退避等待中的任务
 func (r *TaskRepositoryImpl) GetPendingTasks(ctx context.Context, limit int) ([]*model.Task, error) {
 	var tasks []*model.Task
 	if err := r.db.WithContext(ctx).
-		Where("status = ? AND retry_count < max_retries", model.TaskStatusPending).
+		Where("status = ?", model.TaskStatusPending).
+		Where(runnableRetries).
+		Where("next_retry_at IS NULL OR next_retry_at <= ?", time.Now()).
 		Order("priority DESC, created_at ASC").
 		Limit(limit).
 		Find(&tasks).Error; err != nil {
@@ -155,6 +532,58 @@ func (r *TaskRepositoryImpl) GetPendingTasks(ctx context.Context, limit int) ([]
 	return tasks, nil
 }
 
+// ListTasksByStatus 按状态分页获取任务
+func (r *TaskRepositoryImpl) ListTasksByStatus(ctx context.Context, statuses []model.TaskStatus, afterID string, limit int) ([]*model.Task, error) {
+	var tasks []*model.Task
+	if err := r.db.WithContext(ctx).
+		Where("status IN ?", statuses).
+		Where("id > ?", afterID).
+		Order("id ASC").
+		Limit(limit).
+		Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+// ListStartedTasks 分页获取指定时间段内创建且已开始处理的任务
+func (r *TaskRepositoryImpl) ListStartedTasks(ctx context.Context, since, until time.Time, afterID string, limit int) ([]*model.Task, error) {
+	var tasks []*model.Task
+	if err := r.db.WithContext(ctx).
+		Where("started_at IS NOT NULL").
+		Where("created_at >= ? AND created_at < ?", since, until).
+		Where("id > ?", afterID).
+		Order("id ASC").
+		Limit(limit).
+		Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+// NextEnqueueSeq 增加任务投递序号并返回新序号，增加与读取在同一事务中，并发投递得到不同的序号
+func (r *TaskRepositoryImpl) NextEnqueueSeq(ctx context.Context, id string) (int64, error) {
+	var seq int64
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.Task{}).
+			Where("id = ?", id).
+			UpdateColumn("enqueue_seq", gorm.Expr("enqueue_seq + ?", 1))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return tx.Model(&model.Task{}).
+			Where("id = ?", id).
+			Pluck("enqueue_seq", &seq).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	return seq, nil
+}
+
 // UpdatePriority 更新任务优先级
 func (r *TaskRepositoryImpl) UpdatePriority(ctx context.Context, id string, priority model.TaskPriority) error {
 	return r.db.WithContext(ctx).Model(&model.Task{}).
@@ -162,6 +591,30 @@ func (r *TaskRepositoryImpl) UpdatePriority(ctx context.Context, id string, prio
 		Update("priority", priority).Error
 }
 
+// MarkAged 以任务仍待处理且老化时间未变为条件记录老化时间，重复巡检同一任务时只有一次生效
+func (r *TaskRepositoryImpl) MarkAged(ctx context.Context, id string, prev *time.Time, at time.Time) (bool, error) {
+	query := r.db.WithContext(ctx).Model(&model.Task{}).
+		Where("id = ? AND status = ?", id, model.TaskStatusPending)
+	if prev == nil {
+		query = query.Where("aged_at IS NULL")
+	} else {
+		query = query.Where("aged_at = ?", *prev)
+	}
+	result := query.Update("aged_at", at)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// UpdateRouting 更新任务使用的AI驱动与标签
+func (r *TaskRepositoryImpl) UpdateRouting(ctx context.Context, id string, driver ai.DriverType, tags []string) error {
+	return r.db.WithContext(ctx).Model(&model.Task{}).
+		Where("id = ?", id).
+		Select("driver", "tags").
+		Updates(&model.Task{Driver: driver, Tags: tags}).Error
+}
+
 // BatchUpdatePriority 批量更新任务优先级
 func (r *TaskRepositoryImpl) BatchUpdatePriority(ctx context.Context, ids []string, priority model.TaskPriority) error {
 	return r.db.WithContext(ctx).Model(&model.Task{}).
@@ -237,13 +690,22 @@ func (r *TaskRepositoryImpl) ListPriorityRules(ctx context.Context, workID, batc
 	}
 
 	// 获取分页数据
-	if err := query.Offset((page - 1) * size).Limit(size).Order("created_at DESC").Find(&rules).Error; err != nil {
+	if err := query.Offset((page - 1) * size).Limit(size).Order("sort_order ASC, priority DESC, created_at DESC").Find(&rules).Error; err != nil {
 		return nil, 0, err
 	}
 
 	return rules, total, nil
 }
 
+// ListRulesByTemplate 获取由指定模板实例化的规则
+func (r *TaskRepositoryImpl) ListRulesByTemplate(ctx context.Context, templateID string) ([]*model.PriorityAdjustRule, error) {
+	var rules []*model.PriorityAdjustRule
+	if err := r.db.WithContext(ctx).Where("template_id = ?", templateID).Order("created_at ASC").Find(&rules).Error; err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
 // CreatePriorityLog 创建优先级调整日志
 func (r *TaskRepositoryImpl) CreatePriorityLog(ctx context.Context, log *model.PriorityAdjustLog) error {
 	return r.db.WithContext(ctx).Create(log).Error
@@ -269,6 +731,17 @@ func (r *TaskRepositoryImpl) GetPriorityLogs(ctx context.Context, taskID string,
 	return logs, total, nil
 }
 
+// HasPriorityLog 任务是否已被指定规则调整过
+func (r *TaskRepositoryImpl) HasPriorityLog(ctx context.Context, taskID, ruleID string) (bool, error) {
+	var count int64
+	if err := r.db.WithContext(ctx).Model(&model.PriorityAdjustLog{}).
+		Where("task_id = ? AND rule_id = ?", taskID, ruleID).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
 // CreateRuleTemplate 创建规则模板
 func (r *TaskRepositoryImpl) CreateRuleTemplate(ctx context.Context, template *model.RuleTemplate) error {
 	return r.db.WithContext(ctx).Create(template).Error
@@ -394,7 +867,7 @@ func (r *TaskRepositoryImpl) RemoveRuleFromGroup(ctx context.Context, groupID, r
 	return r.UpdateRuleGroup(ctx, group)
 }
 
-// GetGroupRules 获取组中的规则
+// GetGroupRules 获取组中的规则，按组内顺序返回，已删除的规则被忽略
 func (r *TaskRepositoryImpl) GetGroupRules(ctx context.Context, groupID string) ([]*model.PriorityAdjustRule, error) {
 	group, err := r.GetRuleGroup(ctx, groupID)
 	if err != nil {
@@ -406,35 +879,36 @@ func (r *TaskRepositoryImpl) GetGroupRules(ctx context.Context, groupID string)
 		return nil, err
 	}
 
-	return rules, nil
+	byID := make(map[string]*model.PriorityAdjustRule, len(rules))
+	for _, rule := range rules {
+		byID[rule.ID] = rule
+	}
+	ordered := make([]*model.PriorityAdjustRule, 0, len(rules))
+	for _, id := range group.Rules {
+		if rule, ok := byID[id]; ok {
+			ordered = append(ordered, rule)
+		}
+	}
+
+	return ordered, nil
 }
 
 // EvaluateRule 评估规则
 func (r *TaskRepositoryImpl) EvaluateRule(ctx context.Context, rule *model.PriorityAdjustRule, task *model.Task) (bool, error) {
-	// 检查工作ID
-	if rule.WorkID != "" && rule.WorkID != task.WorkID {
+	// 检查工作ID、批次ID、任务类型与任务状态
+	if !expr.InScope(rule, task) {
 		return false, nil
 	}
 
-	// 检查批次ID
-	if rule.BatchID != "" && rule.BatchID != task.BatchID {
-		return false, nil
-	}
-
-	// 检查任务类型
-	if rule.TaskType != "" && rule.TaskType != task.Type {
-		return false, nil
-	}
-
-	// 检查任务状态
-	if rule.Status != "" && rule.Status != task.Status {
-		return false, nil
+	// 检查条件表达式
+	compiled, err := expr.Compile(rule)
+	if err != nil {
+		return false, err
 	}
-
-	return true, nil
+	return compiled.Match(expr.NewEnv(task)), nil
 }
 
-// EvaluateRuleGroup 评估规则组
+// EvaluateRuleGroup 评估规则组，按组内顺序返回匹配的规则，由调用方按规则组的Strategy决定执行哪些
 func (r *TaskRepositoryImpl) EvaluateRuleGroup(ctx context.Context, groupID string, task *model.Task) ([]*model.PriorityAdjustRule, error) {
 	rules, err := r.GetGroupRules(ctx, groupID)
 	if err != nil {
@@ -464,7 +938,7 @@ func (r *TaskRepositoryImpl) GetApplicableRules(ctx context.Context, task *model
 		Where("(batch_id = ? OR batch_id = '')", task.BatchID).
 		Where("(task_type = ? OR task_type = '')", task.Type).
 		Where("(status = ? OR status = '')", task.Status).
-		Order("priority DESC").
+		Order("sort_order ASC, priority DESC").
 		Find(&rules).Error; err != nil {
 		return nil, err
 	}
@@ -6,79 +6,258 @@ import (
 	"fmt"
 	"github.com/gogf/gf/v2/frame/g"
 	"ai-translate/internal/infrastructure/ai"
+	"ai-translate/internal/infrastructure/event"
+	"ai-translate/internal/infrastructure/expr"
 	"ai-translate/internal/infrastructure/processor"
 	"ai-translate/internal/infrastructure/queue"
+	"ai-translate/internal/infrastructure/scheduler"
+	"ai-translate/internal/infrastructure/storage"
+	"ai-translate/internal/infrastructure/subtitle"
+	"ai-translate/internal/infrastructure/translator"
 	"ai-translate/internal/model"
+	"ai-translate/internal/repository"
 	"time"
-	"ai-translate/internal/utils"
+	"github.com/google/uuid"
 )
 
 // TaskService 任务服务
 type TaskService struct {
 	processor *processor.TaskProcessor
-	aiDrivers map[ai.DriverType]ai.AIService
-	repository *model.TaskRepository
+	scheduler *scheduler.TaskScheduler
+	taskQueue queue.Backend
+	aiDrivers *ai.Registry
+	storage   *storage.OSSService
+	repository model.TaskRepository
 }
 
 // NewTaskService 创建任务服务
 func NewTaskService() (*TaskService, error) {
-	// 创建RabbitMQ客户端
-	rabbitmq, err := queue.NewRabbitMQ()
+	// 获取配置
+	workers := g.Cfg().MustGet(context.Background(), "queue.worker.numWorkers").Int()
+	maxRetries := g.Cfg().MustGet(context.Background(), "queue.worker.maxRetries").Int()
+	consumers := queue.LoadConsumerConfigs(context.Background(), workers)
+
+	// 按配置创建队列后端
+	taskQueue, err := queue.New(context.Background(), consumers)
 	if err != nil {
-		return nil, fmt.Errorf("创建RabbitMQ客户端失败: %v", err)
+		return nil, fmt.Errorf("创建任务队列失败: %v", err)
 	}
 
 	// 创建AI驱动
-	aiDrivers := make(map[ai.DriverType]ai.AIService)
-	
-	// 创建OpenAI驱动
-	openAIService, err := ai.NewAIService(ai.DriverOpenAI)
+	aiDrivers, err := ai.NewRegistry(context.Background())
 	if err != nil {
-		return nil, fmt.Errorf("创建OpenAI服务失败: %v", err)
+		return nil, fmt.Errorf("创建AI驱动失败: %v", err)
 	}
-	aiDrivers[ai.DriverOpenAI] = openAIService
 
-	// 创建Gemini驱动
-	geminiService, err := ai.NewAIService(ai.DriverGemini)
+	// 创建存储服务，任务产物上传到OSS
+	storageService, err := storage.NewOSSService()
 	if err != nil {
-		return nil, fmt.Errorf("创建Gemini服务失败: %v", err)
+		return nil, fmt.Errorf("创建存储服务失败: %v", err)
 	}
-	aiDrivers[ai.DriverGemini] = geminiService
 
-	// 获取配置
-	workers := g.Cfg().MustGet("queue.worker.numWorkers").Int()
-	maxRetries := g.Cfg().MustGet("queue.worker.maxRetries").Int()
+	// 创建任务处理器，各任务类型按队列配置设置工作协程数
+	taskProcessor := processor.NewTaskProcessor(taskQueue, workers, maxRetries)
+	for _, consumer := range consumers {
+		taskProcessor.SetWorkers(consumer.TaskType, consumer.Workers)
+	}
 
-	// 创建任务处理器
-	taskProcessor := processor.NewTaskProcessor(rabbitmq, workers, maxRetries)
+	// 创建任务仓储
+	taskRepository, err := repository.NewTaskRepository()
+	if err != nil {
+		return nil, fmt.Errorf("创建任务仓储失败: %v", err)
+	}
 
 	// 创建任务服务
 	service := &TaskService{
 		processor: taskProcessor,
+		taskQueue: taskQueue,
 		aiDrivers: aiDrivers,
-		repository: model.NewTaskRepository(),
+		storage:   storageService,
+		repository: taskRepository,
+	}
+
+	// 创建调度器，任务由队列消费者处理，调度器只负责监控与规则巡检
+	taskScheduler, err := scheduler.NewTaskScheduler(taskQueue, service.repository, aiDrivers)
+	if err != nil {
+		return nil, fmt.Errorf("创建任务调度器失败: %v", err)
 	}
+	taskScheduler.Rules().OnPriorityRaised(service.requeueWithPriority)
+	taskScheduler.Rules().OnResumed(service.requeueStuck)
+	taskScheduler.OnRequeued(service.requeueStuck)
+	service.scheduler = taskScheduler
 
 	// 注册任务处理函数
-	taskProcessor.RegisterHandler("content_generation", service.handleContentGeneration)
-	taskProcessor.RegisterHandler("translation", service.handleTranslation)
+	taskProcessor.RegisterHandler("content_generation", service.withStatusEvents(service.handleContentGeneration))
+	taskProcessor.RegisterHandler("translation", service.withStatusEvents(service.handleTranslation))
+	taskProcessor.SetFilter(service.acceptMessage)
+	taskProcessor.OnFailure(service.handleFailure)
 
 	return service, nil
 }
 
 // Start 启动任务服务
 func (s *TaskService) Start(ctx context.Context) error {
+	if err := s.scheduler.StartMonitor(ctx); err != nil {
+		return err
+	}
 	return s.processor.Start(ctx)
 }
 
 // Stop 停止任务服务
 func (s *TaskService) Stop() {
+	s.scheduler.Stop()
 	s.processor.Stop()
 }
 
-// CreatePriorityRule 创建优先级调整规则
-func (s *TaskService) CreatePriorityRule(ctx context.Context, rule *model.PriorityAdjustRule) error {
-	return s.repository.CreatePriorityRule(ctx, rule)
+// CreateTask 创建任务并按优先级投递到任务队列
+func (s *TaskService) CreateTask(ctx context.Context, workID, batchID string, taskType model.TaskType, content string, driver ai.DriverType, priority model.TaskPriority, language, sourceLang, targetLang string) (*model.Task, error) {
+	task := &model.Task{
+		ID:         uuid.New().String(),
+		WorkID:     workID,
+		BatchID:    batchID,
+		Type:       taskType,
+		Status:     model.TaskStatusPending,
+		Priority:   priority,
+		Content:    content,
+		Language:   language,
+		SourceLang: sourceLang,
+		TargetLang: targetLang,
+		Driver:     driver,
+		MaxRetries: g.Cfg().MustGet(ctx, "queue.worker.maxRetries").Int(),
+		CreatedAt:  time.Now(),
+		UpdatedAt:  time.Now(),
+	}
+	if err := s.repository.Create(ctx, task); err != nil {
+		return nil, fmt.Errorf("创建任务失败: %v", err)
+	}
+
+	if err := s.enqueue(ctx, task); err != nil {
+		return nil, err
+	}
+	event.TaskStatusChanged(task, model.TaskStatusPending, "")
+
+	return task, nil
+}
+
+// enqueue 按任务当前优先级投递任务消息
+func (s *TaskService) enqueue(ctx context.Context, task *model.Task) error {
+	data, err := json.Marshal(map[string]string{
+		"work_id":     task.WorkID,
+		"batch_id":    task.BatchID,
+		"content":     task.Content,
+		"language":    task.Language,
+		"source_lang": task.SourceLang,
+		"target_lang": task.TargetLang,
+	})
+	if err != nil {
+		return fmt.Errorf("序列化任务数据失败: %v", err)
+	}
+
+	// 每次投递分配新的序号，之前投递的消息在消费时被丢弃
+	seq, err := s.repository.NextEnqueueSeq(ctx, task.ID)
+	if err != nil {
+		return fmt.Errorf("分配任务投递序号失败: %v", err)
+	}
+	task.EnqueueSeq = seq
+
+	msg := &queue.Message{
+		ID:       task.ID,
+		Type:     string(task.Type),
+		Data:     data,
+		Priority: int(task.Priority),
+		Seq:      seq,
+		Retries:  task.RetryCount,
+	}
+	if err := s.taskQueue.Publish(ctx, msg); err != nil {
+		return fmt.Errorf("投递任务失败: %v", err)
+	}
+	return nil
+}
+
+// acceptMessage 判断任务消息是否仍有效
+// 任务已完成或已暂停时丢弃；消息序号与任务当前投递序号不一致时说明任务已重新投递，丢弃原消息
+// 重试与重新放回队列的消息沿用原序号，不受影响
+func (s *TaskService) acceptMessage(ctx context.Context, msg *queue.Message) bool {
+	task, err := s.repository.Get(ctx, msg.ID)
+	if err != nil {
+		// 无法判断时交由处理函数处理
+		return true
+	}
+
+	// 处理中的任务交由领取时按租约判断：原工作协程崩溃后租约过期的任务会被重新领取
+	switch task.Status {
+	case model.TaskStatusCompleted, model.TaskStatusPaused:
+		g.Log().Infof(ctx, "任务状态为%s，丢弃消息: %s", task.Status, task.ID)
+		return false
+	}
+	if msg.Seq != task.EnqueueSeq {
+		g.Log().Infof(ctx, "任务已重新投递，丢弃原消息: task_id=%s, seq=%d, current=%d", task.ID, msg.Seq, task.EnqueueSeq)
+		return false
+	}
+	return true
+}
+
+// requeueWithPriority 任务优先级调高后，将仍在排队的任务按新优先级重新投递，使其排到低优先级任务之前
+// 原消息在消费时由acceptMessage丢弃；优先级调低时不重新投递
+func (s *TaskService) requeueWithPriority(ctx context.Context, task *model.Task, oldPriority, newPriority model.TaskPriority) {
+	if newPriority <= oldPriority || task.Status != model.TaskStatusPending {
+		return
+	}
+
+	requeued := *task
+	requeued.Priority = newPriority
+	if err := s.enqueue(ctx, &requeued); err != nil {
+		g.Log().Warningf(ctx, "按新优先级重新投递任务失败: %s, %v", task.ID, err)
+	}
+}
+
+// ListDeadLetters 列出死信消息
+func (s *TaskService) ListDeadLetters(ctx context.Context, taskType model.TaskType, limit int) ([]*queue.Message, error) {
+	return s.taskQueue.ListDeadLetters(ctx, string(taskType), limit)
+}
+
+// GetDeadLetter 获取死信消息
+func (s *TaskService) GetDeadLetter(ctx context.Context, taskType model.TaskType, id string) (*queue.Message, error) {
+	return s.taskQueue.GetDeadLetter(ctx, string(taskType), id)
+}
+
+// RequeueDeadLetters 重新投递死信消息，并将对应任务恢复为待处理，id为空时重新投递全部
+func (s *TaskService) RequeueDeadLetters(ctx context.Context, taskType model.TaskType, id string) (int, error) {
+	messages, err := s.taskQueue.RequeueDeadLetters(ctx, string(taskType), id)
+	for _, msg := range messages {
+		if err := s.repository.UpdateStatus(ctx, msg.ID, model.TaskStatusPending); err != nil {
+			g.Log().Warningf(ctx, "更新任务状态失败: %s, %v", msg.ID, err)
+			continue
+		}
+		task, err := s.repository.Get(ctx, msg.ID)
+		if err != nil {
+			continue
+		}
+		event.TaskStatusChanged(task, model.TaskStatusPending, "")
+		// 进入死信队列后优先级被调高或已被重新投递的任务，按当前优先级重新投递，原消息将被丢弃
+		if msg.Priority < int(task.Priority) || msg.Seq != task.EnqueueSeq {
+			if err := s.enqueue(ctx, task); err != nil {
+				g.Log().Warningf(ctx, "重新投递任务失败: %s, %v", task.ID, err)
+			}
+		}
+	}
+	return len(messages), err
+}
+
+// PurgeDeadLetters 删除死信消息，id为空时清空死信队列
+func (s *TaskService) PurgeDeadLetters(ctx context.Context, taskType model.TaskType, id string) (int, error) {
+	return s.taskQueue.PurgeDeadLetters(ctx, string(taskType), id)
+}
+
+// CreatePriorityRule 创建优先级调整规则，保存前校验条件与动作，返回与已启用规则的冲突
+func (s *TaskService) CreatePriorityRule(ctx context.Context, rule *model.PriorityAdjustRule) ([]expr.Conflict, error) {
+	if err := s.ValidatePriorityRule(rule); err != nil {
+		return nil, err
+	}
+	if err := s.repository.CreatePriorityRule(ctx, rule); err != nil {
+		return nil, err
+	}
+	return s.ruleConflicts(ctx, rule), nil
 }
 
 // GetPriorityRule 获取优先级调整规则
@@ -86,14 +265,67 @@ func (s *TaskService) GetPriorityRule(ctx context.Context, id string) (*model.Pr
 	return s.repository.GetPriorityRule(ctx, id)
 }
 
-// UpdatePriorityRule 更新优先级调整规则
-func (s *TaskService) UpdatePriorityRule(ctx context.Context, rule *model.PriorityAdjustRule) error {
-	return s.repository.UpdatePriorityRule(ctx, rule)
+// UpdatePriorityRule 更新优先级调整规则，保存前校验条件与动作，返回与已启用规则的冲突
+func (s *TaskService) UpdatePriorityRule(ctx context.Context, rule *model.PriorityAdjustRule) ([]expr.Conflict, error) {
+	if err := s.ValidatePriorityRule(rule); err != nil {
+		return nil, err
+	}
+	if err := s.repository.UpdatePriorityRule(ctx, rule); err != nil {
+		return nil, err
+	}
+	return s.ruleConflicts(ctx, rule), nil
+}
+
+// ruleConflicts 检测规则与其他已启用规则之间的重叠与矛盾，冲突只作提示，不阻止保存
+func (s *TaskService) ruleConflicts(ctx context.Context, rule *model.PriorityAdjustRule) []expr.Conflict {
+	if !rule.Enabled {
+		return nil
+	}
+
+	var others []*model.PriorityAdjustRule
+	for page := 1; ; page++ {
+		list, total, err := s.repository.ListPriorityRules(ctx, "", "", "", true, page, 100)
+		if err != nil {
+			g.Log().Warningf(ctx, "检测规则冲突失败: rule_id=%s, %v", rule.ID, err)
+			return nil
+		}
+		others = append(others, list...)
+		if len(list) == 0 || int64(len(others)) >= total {
+			break
+		}
+	}
+
+	conflicts := expr.FindConflicts(rule, others)
+	for _, conflict := range conflicts {
+		g.Log().Warningf(ctx, "规则冲突: rule_id=%s, other_rule_id=%s, kind=%s, %s", conflict.RuleID, conflict.OtherRuleID, conflict.Kind, conflict.Reason)
+	}
+	return conflicts
+}
+
+// ValidatePriorityRule 编译规则的条件与动作，并检查切换的目标AI驱动是否已配置
+func (s *TaskService) ValidatePriorityRule(rule *model.PriorityAdjustRule) error {
+	compiled, err := expr.Compile(rule)
+	if err != nil {
+		return err
+	}
+	for _, action := range compiled.Actions {
+		if action.Type != expr.ActionReroute {
+			continue
+		}
+		if _, err := s.aiDrivers.Get(ai.DriverType(action.Text)); err != nil {
+			return fmt.Errorf("规则动作无效: %v", err)
+		}
+	}
+	return nil
 }
 
 // DeletePriorityRule 删除优先级调整规则
 func (s *TaskService) DeletePriorityRule(ctx context.Context, id string) error {
-	return s.repository.DeletePriorityRule(ctx, id)
+	if err := s.repository.DeletePriorityRule(ctx, id); err != nil {
+		return err
+	}
+	expr.Evict(id)
+	return nil
 }
 
 // ListPriorityRules 获取优先级调整规则列表
@@ -106,8 +338,12 @@ func (s *TaskService) GetPriorityLogs(ctx context.Context, taskID string, page,
 	return s.repository.GetPriorityLogs(ctx, taskID, page, size)
 }
 
-// CreateRuleTemplate 创建规则模板
+// CreateRuleTemplate 创建规则模板，保存前校验参数定义与实例化结果
 func (s *TaskService) CreateRuleTemplate(ctx context.Context, template *model.RuleTemplate) error {
+	if err := expr.ValidateTemplate(template); err != nil {
+		return err
+	}
+	template.Version = 1
 	return s.repository.CreateRuleTemplate(ctx, template)
 }
 
@@ -116,11 +352,119 @@ func (s *TaskService) GetRuleTemplate(ctx context.Context, id string) (*model.Ru
 	return s.repository.GetRuleTemplate(ctx, id)
 }
 
-// UpdateRuleTemplate 更新规则模板
+// UpdateRuleTemplate 更新规则模板，模板版本加一；已实例化的规则需调用ReapplyRuleTemplate后才会更新
 func (s *TaskService) UpdateRuleTemplate(ctx context.Context, template *model.RuleTemplate) error {
+	existing, err := s.repository.GetRuleTemplate(ctx, template.ID)
+	if err != nil {
+		return err
+	}
+	if err := expr.ValidateTemplate(template); err != nil {
+		return err
+	}
+
+	template.Version = existing.Version + 1
+	template.CreatedAt = existing.CreatedAt
 	return s.repository.UpdateRuleTemplate(ctx, template)
 }
 
+// InstantiateRuleTemplate 按每组参数实例化一条规则，任一组参数无效或任一规则创建失败时不创建任何规则
+func (s *TaskService) InstantiateRuleTemplate(ctx context.Context, templateID string, paramSets []map[string]string, enabled bool) ([]*model.PriorityAdjustRule, error) {
+	template, err := s.repository.GetRuleTemplate(ctx, templateID)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := make([]*model.PriorityAdjustRule, 0, len(paramSets))
+	for i, params := range paramSets {
+		rule, err := expr.Instantiate(template, params)
+		if err != nil {
+			return nil, fmt.Errorf("第%d组参数: %v", i+1, err)
+		}
+		if err := s.ValidatePriorityRule(rule); err != nil {
+			return nil, fmt.Errorf("第%d组参数: %v", i+1, err)
+		}
+		rule.ID = uuid.New().String()
+		rule.Enabled = enabled
+		rule.CreatedAt = time.Now()
+		rule.UpdatedAt = time.Now()
+		rules = append(rules, rule)
+	}
+
+	// 所有规则在同一事务中创建，任一失败时全部回滚
+	err = s.repository.Transaction(ctx, func(repository model.TaskRepository) error {
+		for _, rule := range rules {
+			if err := repository.CreatePriorityRule(ctx, rule); err != nil {
+				return fmt.Errorf("创建规则失败: %v", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// ReapplyRuleTemplate 将模板的最新版本重新应用到由旧版本实例化的规则，沿用各规则实例化时的参数
+// 规则的名称、描述与启用状态保持不变；返回已更新的规则ID，以及无法用原参数实例化的规则ID与原因
+func (s *TaskService) ReapplyRuleTemplate(ctx context.Context, templateID string) ([]string, map[string]string, error) {
+	template, err := s.repository.GetRuleTemplate(ctx, templateID)
+	if err != nil {
+		return nil, nil, err
+	}
+	rules, err := s.repository.ListRulesByTemplate(ctx, templateID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	declared := make(map[string]bool, len(template.Params))
+	for _, param := range template.Params {
+		declared[param.Name] = true
+	}
+
+	var updated []string
+	failed := make(map[string]string)
+	for _, rule := range rules {
+		if rule.TemplateVersion >= template.Version {
+			continue
+		}
+
+		// 新版本删除的参数不再传入
+		params := make(map[string]string, len(rule.TemplateParams))
+		for name, value := range rule.TemplateParams {
+			if declared[name] {
+				params[name] = value
+			}
+		}
+
+		instance, err := expr.Instantiate(template, params)
+		if err == nil {
+			err = s.ValidatePriorityRule(instance)
+		}
+		if err != nil {
+			failed[rule.ID] = err.Error()
+			continue
+		}
+
+		rule.WorkID = instance.WorkID
+		rule.BatchID = instance.BatchID
+		rule.TaskType = instance.TaskType
+		rule.Status = instance.Status
+		rule.Condition = instance.Condition
+		rule.Action = instance.Action
+		rule.Priority = instance.Priority
+		rule.TemplateVersion = instance.TemplateVersion
+		rule.TemplateParams = instance.TemplateParams
+		rule.UpdatedAt = time.Now()
+		if err := s.repository.UpdatePriorityRule(ctx, rule); err != nil {
+			return updated, failed, fmt.Errorf("更新规则失败: %v", err)
+		}
+		updated = append(updated, rule.ID)
+	}
+
+	return updated, failed, nil
+}
+
 // DeleteRuleTemplate 删除规则模板
 func (s *TaskService) DeleteRuleTemplate(ctx context.Context, id string) error {
 	return s.repository.DeleteRuleTemplate(ctx, id)
@@ -131,9 +475,15 @@ func (s *TaskService) ListRuleTemplates(ctx context.Context, page, size int) ([]
 	return s.repository.ListRuleTemplates(ctx, page, size)
 }
 
-// CreateRuleGroup 创建规则组
-func (s *TaskService) CreateRuleGroup(ctx context.Context, group *model.RuleGroup) error {
-	return s.repository.CreateRuleGroup(ctx, group)
+// CreateRuleGroup 创建规则组，返回组内规则之间的冲突
+func (s *TaskService) CreateRuleGroup(ctx context.Context, group *model.RuleGroup) ([]expr.Conflict, error) {
+	if err := validateGroupStrategy(group); err != nil {
+		return nil, err
+	}
+	if err := s.repository.CreateRuleGroup(ctx, group); err != nil {
+		return nil, err
+	}
+	return s.groupConflicts(ctx, group.ID, ""), nil
 }
 
 // GetRuleGroup 获取规则组
@@ -141,11 +491,78 @@ func (s *TaskService) GetRuleGroup(ctx context.Context, id string) (*model.RuleG
 	return s.repository.GetRuleGroup(ctx, id)
 }
 
-// UpdateRuleGroup 更新规则组
-func (s *TaskService) UpdateRuleGroup(ctx context.Context, group *model.RuleGroup) error {
+// UpdateRuleGroup 更新规则组，返回组内规则之间的冲突
+func (s *TaskService) UpdateRuleGroup(ctx context.Context, group *model.RuleGroup) ([]expr.Conflict, error) {
+	if err := validateGroupStrategy(group); err != nil {
+		return nil, err
+	}
+	if err := s.repository.UpdateRuleGroup(ctx, group); err != nil {
+		return nil, err
+	}
+	return s.groupConflicts(ctx, group.ID, ""), nil
+}
+
+// validateGroupStrategy 校验规则组的冲突处理方式，为空时使用first_match
+func validateGroupStrategy(group *model.RuleGroup) error {
+	switch group.Strategy {
+	case "":
+		group.Strategy = model.RuleGroupFirstMatch
+	case model.RuleGroupFirstMatch, model.RuleGroupHighest, model.RuleGroupLowest, model.RuleGroupCumulative:
+	default:
+		return fmt.Errorf("规则组处理方式无效: %q", group.Strategy)
+	}
+	return nil
+}
+
+// ReorderGroupRules 调整组内规则的评估顺序，ruleIDs必须恰好包含组内的全部规则
+func (s *TaskService) ReorderGroupRules(ctx context.Context, groupID string, ruleIDs []string) error {
+	group, err := s.repository.GetRuleGroup(ctx, groupID)
+	if err != nil {
+		return err
+	}
+
+	members := make(map[string]bool, len(group.Rules))
+	for _, id := range group.Rules {
+		members[id] = true
+	}
+	if len(ruleIDs) != len(members) {
+		return fmt.Errorf("规则顺序必须包含组内全部%d条规则", len(members))
+	}
+	for _, id := range ruleIDs {
+		if !members[id] {
+			return fmt.Errorf("规则不在组内或重复: %s", id)
+		}
+		delete(members, id)
+	}
+
+	group.Rules = ruleIDs
+	group.UpdatedAt = time.Now()
 	return s.repository.UpdateRuleGroup(ctx, group)
 }
 
+// groupConflicts 检测组内规则之间的重叠与矛盾，ruleID不为空时只检测该规则与其他规则
+func (s *TaskService) groupConflicts(ctx context.Context, groupID, ruleID string) []expr.Conflict {
+	rules, err := s.repository.GetGroupRules(ctx, groupID)
+	if err != nil {
+		g.Log().Warningf(ctx, "检测规则组冲突失败: group_id=%s, %v", groupID, err)
+		return nil
+	}
+
+	var conflicts []expr.Conflict
+	for i, rule := range rules {
+		switch {
+		case ruleID == "":
+			conflicts = append(conflicts, expr.FindConflicts(rule, rules[i+1:])...)
+		case rule.ID == ruleID:
+			conflicts = append(conflicts, expr.FindConflicts(rule, rules)...)
+		}
+	}
+	for _, conflict := range conflicts {
+		g.Log().Warningf(ctx, "规则组内规则冲突: group_id=%s, rule_id=%s, other_rule_id=%s, kind=%s, %s", groupID, conflict.RuleID, conflict.OtherRuleID, conflict.Kind, conflict.Reason)
+	}
+	return conflicts
+}
+
 // DeleteRuleGroup 删除规则组
 func (s *TaskService) DeleteRuleGroup(ctx context.Context, id string) error {
 	return s.repository.DeleteRuleGroup(ctx, id)
@@ -156,9 +573,12 @@ func (s *TaskService) ListRuleGroups(ctx context.Context, enabled bool, page, si
 	return s.repository.ListRuleGroups(ctx, enabled, page, size)
 }
 
-// AddRuleToGroup 添加规则到组
-func (s *TaskService) AddRuleToGroup(ctx context.Context, groupID, ruleID string) error {
-	return s.repository.AddRuleToGroup(ctx, groupID, ruleID)
+// AddRuleToGroup 添加规则到组末尾，返回该规则与组内其他规则的冲突
+func (s *TaskService) AddRuleToGroup(ctx context.Context, groupID, ruleID string) ([]expr.Conflict, error) {
+	if err := s.repository.AddRuleToGroup(ctx, groupID, ruleID); err != nil {
+		return nil, err
+	}
+	return s.groupConflicts(ctx, groupID, ruleID), nil
 }
 
 // RemoveRuleFromGroup 从组中移除规则
@@ -171,6 +591,53 @@ func (s *TaskService) GetGroupRules(ctx context.Context, groupID string) ([]*mod
 	return s.repository.GetGroupRules(ctx, groupID)
 }
 
+// SimulatePriorityRule 对当前待处理与已暂停的任务模拟执行草稿规则，不写入任何数据
+func (s *TaskService) SimulatePriorityRule(ctx context.Context, rule *model.PriorityAdjustRule, limit int) ([]*scheduler.SimulatedChange, error) {
+	if err := s.ValidatePriorityRule(rule); err != nil {
+		return nil, err
+	}
+	return s.scheduler.Rules().Simulate(ctx, []scheduler.RuleChain{{Rules: []*model.PriorityAdjustRule{rule}}}, limit)
+}
+
+// SimulateRuleGroup 对当前待处理与已暂停的任务模拟执行规则组，组内未启用的规则同样参与模拟
+func (s *TaskService) SimulateRuleGroup(ctx context.Context, groupID string, limit int) ([]*scheduler.SimulatedChange, error) {
+	chain, err := s.groupChain(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+	return s.scheduler.Rules().Simulate(ctx, []scheduler.RuleChain{chain}, limit)
+}
+
+// ReplayPriorityRule 在历史任务上回放草稿规则，估算排队等待时长的变化
+func (s *TaskService) ReplayPriorityRule(ctx context.Context, rule *model.PriorityAdjustRule, since, until time.Time, limit int) (*scheduler.ReplayResult, error) {
+	if err := s.ValidatePriorityRule(rule); err != nil {
+		return nil, err
+	}
+	return s.scheduler.Rules().Replay(ctx, []scheduler.RuleChain{{Rules: []*model.PriorityAdjustRule{rule}}}, since, until, limit)
+}
+
+// ReplayRuleGroup 在历史任务上回放规则组，估算排队等待时长的变化
+func (s *TaskService) ReplayRuleGroup(ctx context.Context, groupID string, since, until time.Time, limit int) (*scheduler.ReplayResult, error) {
+	chain, err := s.groupChain(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+	return s.scheduler.Rules().Replay(ctx, []scheduler.RuleChain{chain}, since, until, limit)
+}
+
+// groupChain 获取规则组中的规则与处理方式，规则按组内顺序排列
+func (s *TaskService) groupChain(ctx context.Context, groupID string) (scheduler.RuleChain, error) {
+	group, err := s.repository.GetRuleGroup(ctx, groupID)
+	if err != nil {
+		return scheduler.RuleChain{}, err
+	}
+	rules, err := s.repository.GetGroupRules(ctx, groupID)
+	if err != nil {
+		return scheduler.RuleChain{}, err
+	}
+	return scheduler.RuleChain{Strategy: group.Strategy, Rules: rules}, nil
+}
+
 // EvaluateRule 评估规则
 func (s *TaskService) EvaluateRule(ctx context.Context, rule *model.PriorityAdjustRule, task *model.Task) (bool, error) {
 	return s.repository.EvaluateRule(ctx, rule, task)
@@ -186,61 +653,65 @@ func (s *TaskService) GetApplicableRules(ctx context.Context, task *model.Task)
 	return s.repository.GetApplicableRules(ctx, task)
 }
 
-// adjustTaskPriority 调整任务优先级
-func (s *TaskService) adjustTaskPriority(ctx context.Context, task *model.Task) error {
-	// 获取适用的规则
-	rules, err := s.GetApplicableRules(ctx, task)
-	if err != nil {
-		return fmt.Errorf("获取适用规则失败: %v", err)
-	}
-
-	// 遍历规则，找到第一个匹配的规则
-	for _, rule := range rules {
-		// 评估规则
-		matched, err := s.EvaluateRule(ctx, rule, task)
+// withStatusEvents 包装任务处理函数，处理开始时领取任务并持有租约，成功时发布完成事件，失败由handleFailure处理
+// 任务已被其他工作协程持有、已完成或无需处理时直接确认消息；处理期间定期续租，租约失效时任务已被回收，不再处理结果
+func (s *TaskService) withStatusEvents(handler processor.TaskHandler) processor.TaskHandler {
+	return func(ctx context.Context, taskID string, data []byte) error {
+		task, owner, err := s.scheduler.ClaimByID(ctx, taskID)
 		if err != nil {
-			return fmt.Errorf("评估规则失败: %v", err)
+			return fmt.Errorf("领取任务失败: %w", err)
 		}
-
-		if !matched {
-			continue
+		if task == nil {
+			g.Log().Infof(ctx, "任务已被领取或无需处理，忽略消息: %s", taskID)
+			return nil
 		}
-
-		// 如果优先级相同，不需要调整
-		if rule.Priority == task.Priority {
-			continue
+		event.TaskStatusChanged(task, model.TaskStatusRunning, "")
+
+		// 超过任务类型的执行时限时取消处理，未结束的任务由调度器回收
+		taskCtx, cancel := scheduler.WithDeadline(ctx, s.repository, task)
+		stopLease := s.scheduler.KeepLease(ctx, taskID, owner, cancel)
+		err = scheduler.TimeoutError(taskCtx, task, handler(taskCtx, taskID, data))
+		lost := stopLease()
+		cancel()
+		if lost {
+			g.Log().Warningf(ctx, "任务租约已失效，放弃处理结果: %s", taskID)
+			return nil
 		}
-
-		// 记录旧优先级
-		oldPriority := task.Priority
-
-		// 更新任务优先级
-		if err := s.repository.UpdatePriority(ctx, task.ID, rule.Priority); err != nil {
-			return fmt.Errorf("更新任务优先级失败: %v", err)
+		if err != nil {
+			return err
 		}
+		event.TaskStatusChanged(task, model.TaskStatusCompleted, "")
+		return nil
+	}
+}
 
-		// 创建优先级调整日志
-		log := &model.PriorityAdjustLog{
-			ID:          utils.GenerateUUID(),
-			TaskID:      task.ID,
-			RuleID:      rule.ID,
-			OldPriority: oldPriority,
-			NewPriority: rule.Priority,
-			Reason:      rule.Description,
-			CreatedAt:   time.Now(),
-		}
-		if err := s.repository.CreatePriorityLog(ctx, log); err != nil {
-			return fmt.Errorf("创建优先级调整日志失败: %v", err)
-		}
+// requeueStuck 调度器将卡住的任务放回等待处理或规则恢复暂停的任务后重新投递，原消息在任务处理中或暂停时已被确认或丢弃
+func (s *TaskService) requeueStuck(ctx context.Context, task *model.Task) {
+	if err := s.enqueue(ctx, task); err != nil {
+		g.Log().Warningf(ctx, "重新投递卡住的任务失败: %s, %v", task.ID, err)
+	}
+}
 
-		// 记录日志
-		g.Log().Infof(ctx, "任务优先级已调整: task_id=%s, old_priority=%d, new_priority=%d, reason=%s",
-			task.ID, oldPriority, rule.Priority, rule.Description)
+// handleFailure 记录任务失败原因：retryAt非零时任务等待重试，否则标记为失败
+func (s *TaskService) handleFailure(ctx context.Context, taskID string, err error, retryAt time.Time) {
+	task, getErr := s.repository.Get(ctx, taskID)
+	if getErr != nil {
+		g.Log().Errorf(ctx, "获取任务信息失败: %s, %v", taskID, getErr)
+		return
+	}
 
-		return nil
+	if !retryAt.IsZero() {
+		if err := s.repository.ScheduleRetry(ctx, taskID, err.Error(), retryAt); err != nil {
+			g.Log().Errorf(ctx, "安排任务重试失败: %s, %v", taskID, err)
+		}
+		event.TaskStatusChanged(task, model.TaskStatusPending, err.Error())
+		return
 	}
 
-	return nil
+	if err := s.repository.MarkFailed(ctx, taskID, err.Error()); err != nil {
+		g.Log().Errorf(ctx, "更新任务状态失败: %s, %v", taskID, err)
+	}
+	event.TaskStatusChanged(task, model.TaskStatusFailed, err.Error())
 }
 
 // handleContentGeneration 处理内容生成任务
@@ -251,37 +722,36 @@ func (s *TaskService) handleContentGeneration(ctx context.Context, taskID string
 		BatchID   string `json:"batch_id"`
 		Content   string `json:"content"`
 		Language  string `json:"language"`
-		Driver    string `json:"driver"`
 	}
 	if err := json.Unmarshal(data, &taskData); err != nil {
 		return fmt.Errorf("解析任务数据失败: %v", err)
 	}
 
-	// 获取AI驱动
-	driver := ai.DriverType(taskData.Driver)
-	aiService, ok := s.aiDrivers[driver]
-	if !ok {
-		return fmt.Errorf("不支持的AI驱动类型: %s", driver)
+	// 获取任务信息
+	task, err := s.repository.Get(ctx, taskID)
+	if err != nil {
+		return fmt.Errorf("获取任务信息失败: %v", err)
 	}
 
-	// 生成内容
-	generatedContent, err := aiService.GenerateContent(ctx, taskData.Content, taskData.Language)
+	// 按任务指定的驱动获取AI服务
+	aiService, err := s.aiDrivers.Get(task.Driver)
 	if err != nil {
-		return fmt.Errorf("生成内容失败: %v", err)
+		return err
 	}
 
-	// 获取任务信息
-	task, err := s.repository.Get(ctx, taskID)
+	// 流式生成内容，部分结果随生成限频写入任务
+	update := s.progressUpdater(ctx, task)
+	generatedContent, err := aiService.GenerateContentStream(ctx, taskData.Content, taskData.Language, translator.StreamProgress(update, translator.ProgressInterval))
 	if err != nil {
-		return fmt.Errorf("获取任务信息失败: %v", err)
+		return fmt.Errorf("生成内容失败: %w", err)
 	}
 
-	// 调整任务优先级
-	if err := s.adjustTaskPriority(ctx, task); err != nil {
-		g.Log().Warningf(ctx, "调整任务优先级失败: %v", err)
-	}
+	update(translator.Progress{Done: 1, Total: 1, Partial: generatedContent})
 
-	// TODO: 保存生成的内容到数据库
+	// 上传生成的内容并保存结果
+	if err := s.saveResult(ctx, task, generatedContent, "content_summaries", ".txt"); err != nil {
+		return err
+	}
 	g.Log().Infof(ctx, "内容生成成功: %s", taskID)
 
 	return nil
@@ -296,53 +766,209 @@ func (s *TaskService) handleTranslation(ctx context.Context, taskID string, data
 		Content    string `json:"content"`
 		SourceLang string `json:"source_lang"`
 		TargetLang string `json:"target_lang"`
-		Driver     string `json:"driver"`
 	}
 	if err := json.Unmarshal(data, &taskData); err != nil {
 		return fmt.Errorf("解析任务数据失败: %v", err)
 	}
 
-	// 获取AI驱动
-	driver := ai.DriverType(taskData.Driver)
-	aiService, ok := s.aiDrivers[driver]
-	if !ok {
-		return fmt.Errorf("不支持的AI驱动类型: %s", driver)
+	// 获取任务信息
+	task, err := s.repository.Get(ctx, taskID)
+	if err != nil {
+		return fmt.Errorf("获取任务信息失败: %v", err)
 	}
 
-	// 翻译内容
-	translatedContent, err := aiService.Translate(ctx, taskData.Content, taskData.SourceLang, taskData.TargetLang)
+	// 按任务指定的驱动获取AI服务
+	aiService, err := s.aiDrivers.Get(task.Driver)
 	if err != nil {
-		return fmt.Errorf("翻译内容失败: %v", err)
+		return err
 	}
 
-	// 获取任务信息
-	task, err := s.repository.Get(ctx, taskID)
+	// 翻译内容，字幕按条目上报进度，已完成条目写入部分结果
+	config := translator.NewConfig(ctx, string(s.aiDrivers.Resolve(task.Driver)))
+	translatedContent, err := translator.TranslateContent(ctx, aiService, config, taskData.Content, taskData.SourceLang, taskData.TargetLang, s.progressReporter(ctx, task))
 	if err != nil {
-		return fmt.Errorf("获取任务信息失败: %v", err)
+		return fmt.Errorf("翻译内容失败: %w", err)
 	}
 
-	// 调整任务优先级
-	if err := s.adjustTaskPriority(ctx, task); err != nil {
-		g.Log().Warningf(ctx, "调整任务优先级失败: %v", err)
+	// 上传翻译结果并保存，字幕以原格式上传
+	ext := ".txt"
+	if doc, err := subtitle.Parse([]byte(translatedContent)); err == nil && len(doc.Cues) > 0 {
+		ext = ".srt"
+		if doc.Format == subtitle.FormatWebVTT {
+			ext = ".vtt"
+		}
+	}
+	if err := s.saveResult(ctx, task, translatedContent, "translations", ext); err != nil {
+		return err
 	}
-
-	// TODO: 保存翻译结果到数据库
 	g.Log().Infof(ctx, "翻译成功: %s", taskID)
 
 	return nil
 }
 
+// saveResult 上传任务产物并在一个事务中保存任务结果
+// 对象键由任务ID决定，重复投递的消息覆盖同一对象，不会产生多余的产物
+func (s *TaskService) saveResult(ctx context.Context, task *model.Task, content, prefix, ext string) error {
+	objectKey := prefix + "/" + task.ID + ext
+	url, err := s.storage.UploadContent(objectKey, []byte(content))
+	if err != nil {
+		return fmt.Errorf("上传任务结果失败: %w", err)
+	}
+
+	// 以租约仍属于领取任务的工作协程为条件保存，租约已被回收的结果被丢弃
+	saved, err := s.repository.SaveTaskResult(ctx, task, model.TaskOutput{Result: content, ArtifactURL: url, LeaseOwner: task.LeaseOwner})
+	if err != nil {
+		return fmt.Errorf("保存任务结果失败: %w", err)
+	}
+	if !saved {
+		g.Log().Infof(ctx, "任务结果已保存或租约已失效，忽略结果: %s", task.ID)
+	}
+	return nil
+}
+
+// progressReporter 创建任务进度回调，按translator.ProgressInterval限频写入任务进度与部分结果并发布进度事件
+func (s *TaskService) progressReporter(ctx context.Context, task *model.Task) translator.ProgressFunc {
+	return translator.ThrottleProgress(s.progressUpdater(ctx, task), translator.ProgressInterval)
+}
+
+// progressUpdater 创建不限频的任务进度回调，写入任务进度与部分结果并发布进度事件
+func (s *TaskService) progressUpdater(ctx context.Context, task *model.Task) translator.ProgressFunc {
+	return func(progress translator.Progress) {
+		if err := s.repository.UpdateProgress(ctx, task.ID, progress.Percent(), progress.Partial); err != nil {
+			g.Log().Warningf(ctx, "更新任务进度失败: %s, %v", task.ID, err)
+		}
+		event.TaskProgressed(task, progress.Percent())
+	}
+}
+
+// GetTask 获取任务
+func (s *TaskService) GetTask(ctx context.Context, taskID string) (*model.Task, error) {
+	return s.repository.Get(ctx, taskID)
+}
+
+// ListTasks 获取任务列表
+func (s *TaskService) ListTasks(ctx context.Context, workID, batchID string, taskType model.TaskType, status model.TaskStatus, page, size int) ([]*model.Task, int64, error) {
+	return s.repository.List(ctx, workID, batchID, taskType, status, page, size)
+}
+
+// GetTaskStats 获取任务统计
+func (s *TaskService) GetTaskStats(ctx context.Context, workID string) (*model.TaskStats, error) {
+	return s.repository.GetStats(ctx, workID)
+}
+
+// UpdateTaskStatus 更新任务状态
+func (s *TaskService) UpdateTaskStatus(ctx context.Context, taskID string, status model.TaskStatus) error {
+	task, err := s.repository.Get(ctx, taskID)
+	if err != nil {
+		return err
+	}
+	if err := s.repository.UpdateStatus(ctx, taskID, status); err != nil {
+		return err
+	}
+	event.TaskStatusChanged(task, status, "")
+	return nil
+}
+
+// DeleteTask 删除任务
+func (s *TaskService) DeleteTask(ctx context.Context, taskID string) error {
+	return s.repository.Delete(ctx, taskID)
+}
+
+// RetryTask 手动重试失败的任务，重置重试次数后重新投递
+func (s *TaskService) RetryTask(ctx context.Context, taskID string) error {
+	task, err := s.repository.Get(ctx, taskID)
+	if err != nil {
+		return err
+	}
+	if task.Status != model.TaskStatusFailed {
+		return fmt.Errorf("任务未失败: %s", task.Status)
+	}
+
+	task.Status = model.TaskStatusPending
+	task.RetryCount = 0
+	task.Error = ""
+	task.UpdatedAt = time.Now()
+	if err := s.repository.Update(ctx, task); err != nil {
+		return err
+	}
+	event.TaskStatusChanged(task, model.TaskStatusPending, "")
+	return s.enqueue(ctx, task)
+}
+
+// ResumeTask 恢复已暂停的任务，放回等待处理并重新投递，暂停期间排队的消息已被丢弃
+func (s *TaskService) ResumeTask(ctx context.Context, taskID string) error {
+	task, err := s.repository.Get(ctx, taskID)
+	if err != nil {
+		return err
+	}
+	if task.Status != model.TaskStatusPaused {
+		return fmt.Errorf("任务未暂停: %s", task.Status)
+	}
+	if err := s.repository.UpdateStatus(ctx, taskID, model.TaskStatusPending); err != nil {
+		return err
+	}
+	task.Status = model.TaskStatusPending
+	event.TaskStatusChanged(task, model.TaskStatusPending, "")
+	return s.enqueue(ctx, task)
+}
+
 // UpdateTaskPriority 更新任务优先级
 func (s *TaskService) UpdateTaskPriority(ctx context.Context, taskID string, priority model.TaskPriority) error {
-	return s.repository.UpdatePriority(ctx, taskID, priority)
+	task, err := s.repository.Get(ctx, taskID)
+	if err != nil {
+		return err
+	}
+	if err := s.repository.UpdatePriority(ctx, taskID, priority); err != nil {
+		return err
+	}
+	event.TaskPriorityAdjusted(task, task.Priority, priority, "手动调整")
+	s.requeueWithPriority(ctx, task, task.Priority, priority)
+	return nil
 }
 
 // BatchUpdateTaskPriority 批量更新任务优先级
 func (s *TaskService) BatchUpdateTaskPriority(ctx context.Context, taskIDs []string, priority model.TaskPriority) error {
-	return s.repository.BatchUpdatePriority(ctx, taskIDs, priority)
+	// 记录调整前的任务，用于重新投递排队中的任务
+	var tasks []*model.Task
+	for _, id := range taskIDs {
+		if task, err := s.repository.Get(ctx, id); err == nil {
+			tasks = append(tasks, task)
+		}
+	}
+
+	if err := s.repository.BatchUpdatePriority(ctx, taskIDs, priority); err != nil {
+		return err
+	}
+	for _, task := range tasks {
+		event.TaskPriorityAdjusted(task, task.Priority, priority, "批量调整")
+		s.requeueWithPriority(ctx, task, task.Priority, priority)
+	}
+	return nil
 }
 
 // UpdateTaskPriorityByCondition 根据条件更新任务优先级
 func (s *TaskService) UpdateTaskPriorityByCondition(ctx context.Context, workID, batchID string, taskType model.TaskType, status model.TaskStatus, priority model.TaskPriority) error {
-	return s.repository.UpdatePriorityByCondition(ctx, workID, batchID, taskType, status, priority)
+	// 记录调整前排队中的任务，用于重新投递
+	var queued []*model.Task
+	if status == "" || status == model.TaskStatusPending {
+		for page := 1; ; page++ {
+			tasks, total, err := s.repository.List(ctx, workID, batchID, taskType, model.TaskStatusPending, page, 100)
+			if err != nil {
+				return err
+			}
+			queued = append(queued, tasks...)
+			if len(tasks) == 0 || int64(len(queued)) >= total {
+				break
+			}
+		}
+	}
+
+	if err := s.repository.UpdatePriorityByCondition(ctx, workID, batchID, taskType, status, priority); err != nil {
+		return err
+	}
+	for _, task := range queued {
+		event.TaskPriorityAdjusted(task, task.Priority, priority, "按条件调整")
+		s.requeueWithPriority(ctx, task, task.Priority, priority)
+	}
+	return nil
 } 
\ No newline at end of file
@@ -38,7 +38,7 @@ CREATE TABLE IF NOT EXISTS works (
     user_id BIGINT UNSIGNED NOT NULL,
     video_url VARCHAR(255) NOT NULL,
     subtitle_url VARCHAR(255) NOT NULL,
-    status TINYINT NOT NULL DEFAULT 0,
+    status TINYINT NOT NULL DEFAULT 0 COMMENT '0:等待中 1:运行中 2:成功 3:失败 4:已取消',
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
     FOREIGN KEY (user_id) REFERENCES users(id)
@@ -48,10 +48,12 @@ CREATE TABLE IF NOT EXISTS works (
 CREATE TABLE IF NOT EXISTS content_summaries (
     id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
     work_id BIGINT UNSIGNED NOT NULL,
+    task_id VARCHAR(64) NULL COMMENT '生成简介的任务ID，保证重复投递不产生重复记录',
     content TEXT NOT NULL,
     oss_url VARCHAR(255) NOT NULL,
     status TINYINT NOT NULL DEFAULT 0,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uk_task_id (task_id),
     FOREIGN KEY (work_id) REFERENCES works(id)
 );
 
@@ -61,7 +63,7 @@ CREATE TABLE IF NOT EXISTS translation_batches (
     work_id BIGINT UNSIGNED NOT NULL,
     target_language VARCHAR(10) NOT NULL,
     terminology_url VARCHAR(255),
-    status TINYINT NOT NULL DEFAULT 0,
+    status TINYINT NOT NULL DEFAULT 0 COMMENT '0:等待中 1:运行中 2:成功 3:失败 4:已取消',
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
     FOREIGN KEY (work_id) REFERENCES works(id)
@@ -71,9 +73,12 @@ CREATE TABLE IF NOT EXISTS translation_batches (
 CREATE TABLE IF NOT EXISTS translation_results (
     id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
     batch_id BIGINT UNSIGNED NOT NULL,
+    task_id VARCHAR(64) NULL COMMENT '翻译任务ID，保证重复投递不产生重复记录',
     srt_url VARCHAR(255) NOT NULL,
     status TINYINT NOT NULL DEFAULT 0,
+    violations TEXT COMMENT '重试后仍未遵守术语表的条目，JSON数组',
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uk_task_id (task_id),
     FOREIGN KEY (batch_id) REFERENCES translation_batches(id)
 );
 
@@ -92,12 +97,83 @@ CREATE TABLE IF NOT EXISTS tasks (
     id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
     type TINYINT NOT NULL COMMENT '1:内容生成 2:翻译',
     priority TINYINT NOT NULL DEFAULT 0,
-    status TINYINT NOT NULL DEFAULT 0,
+    status TINYINT NOT NULL DEFAULT 0 COMMENT '0:等待中 1:完成 2:暂停 3:失败 4:等待依赖 5:已取消',
+    work_id BIGINT UNSIGNED NOT NULL DEFAULT 0 COMMENT '所属作品ID',
     reference_id BIGINT UNSIGNED NOT NULL COMMENT '关联ID',
+    driver VARCHAR(50) NOT NULL DEFAULT '' COMMENT 'AI驱动名，为空时使用默认驱动',
+    error TEXT COMMENT '失败原因',
+    partial_result MEDIUMTEXT COMMENT '已完成分块的译文，恢复处理时跳过这些分块',
     retry_count INT NOT NULL DEFAULT 0,
     max_retry INT NOT NULL DEFAULT 3,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    INDEX idx_tasks_work_id (work_id),
+    INDEX idx_tasks_reference (type, reference_id)
+);
+
+-- 任务依赖表
+CREATE TABLE IF NOT EXISTS task_dependencies (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    work_id BIGINT UNSIGNED NOT NULL COMMENT '所属作品ID',
+    task_id BIGINT UNSIGNED NOT NULL COMMENT '被阻塞的任务',
+    depends_on_id BIGINT UNSIGNED NOT NULL COMMENT '依赖的任务',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uk_task_dependency (task_id, depends_on_id),
+    INDEX idx_task_dependencies_depends_on (depends_on_id),
+    INDEX idx_task_dependencies_work_id (work_id)
+);
+
+-- 任务发件箱表，与任务在同一事务中写入，由中继投递到任务队列
+CREATE TABLE IF NOT EXISTS task_outbox (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    task_id BIGINT UNSIGNED NOT NULL COMMENT '入队的任务',
+    payload TEXT NOT NULL COMMENT '入队的任务快照',
+    status TINYINT NOT NULL DEFAULT 0 COMMENT '0:待投递 1:已投递 2:已跳过 3:投递失败',
+    attempts INT NOT NULL DEFAULT 0 COMMENT '投递失败次数',
+    last_error TEXT COMMENT '最近一次投递失败原因',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    published_at TIMESTAMP NULL,
+    INDEX idx_task_outbox_status (status, id),
+    INDEX idx_task_outbox_task_id (task_id)
+);
+
+-- 队列任务表，由任务队列消费者与调度器领取处理，租约与截止时间用于回收卡住的任务
+CREATE TABLE IF NOT EXISTS queue_tasks (
+    id VARCHAR(64) PRIMARY KEY,
+    work_id VARCHAR(64) NOT NULL DEFAULT '',
+    batch_id VARCHAR(64) NOT NULL DEFAULT '',
+    type VARCHAR(32) NOT NULL COMMENT 'content_generation:内容生成 translation:翻译',
+    status VARCHAR(16) NOT NULL DEFAULT 'pending' COMMENT 'pending:等待处理 running:处理中 completed:已完成 failed:失败 paused:已暂停',
+    priority TINYINT NOT NULL DEFAULT 0,
+    content LONGTEXT,
+    language VARCHAR(10) NOT NULL DEFAULT '',
+    source_lang VARCHAR(10) NOT NULL DEFAULT '',
+    target_lang VARCHAR(10) NOT NULL DEFAULT '',
+    result LONGTEXT,
+    error TEXT COMMENT '失败原因',
+    progress INT NOT NULL DEFAULT 0 COMMENT '完成百分比（0-100）',
+    partial_result LONGTEXT COMMENT '处理中的部分结果',
+    driver VARCHAR(50) NOT NULL DEFAULT '' COMMENT 'AI驱动名，为空时使用默认驱动',
+    tags TEXT COMMENT '规则动作添加的标签，JSON数组',
+    enqueue_seq BIGINT NOT NULL DEFAULT 0 COMMENT '最近一次投递到队列的序号',
+    retry_count INT NOT NULL DEFAULT 0,
+    max_retries INT NOT NULL DEFAULT 3,
+    next_retry_at DATETIME(3) NULL COMMENT '下次重试时间，退避期间不会被领取',
+    lease_owner VARCHAR(128) NOT NULL DEFAULT '' COMMENT '持有租约的工作协程，为空表示未被领取',
+    lease_expires_at DATETIME(3) NULL COMMENT '租约到期时间',
+    deadline_at DATETIME(3) NULL COMMENT '本次执行的截止时间',
+    aged_at DATETIME(3) NULL COMMENT '最近一次老化提升优先级的时间',
+    started_at DATETIME(3) NULL,
+    completed_at DATETIME(3) NULL,
+    created_at DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+    updated_at DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3) ON UPDATE CURRENT_TIMESTAMP(3),
+    INDEX idx_queue_tasks_work_id (work_id),
+    INDEX idx_queue_tasks_batch_id (batch_id),
+    INDEX idx_queue_tasks_type (type),
+    INDEX idx_queue_tasks_claim (status, priority, created_at),
+    INDEX idx_queue_tasks_next_retry_at (next_retry_at),
+    INDEX idx_queue_tasks_lease_expires_at (lease_expires_at),
+    INDEX idx_queue_tasks_deadline_at (deadline_at)
 );
 
 -- 初始化管理员账号
@@ -0,0 +1,69 @@
+-- 作品与翻译批次状态迁移，并补齐任务持久化与依赖调度新增的表结构
+-- 旧状态 作品：0:待生成简介 1:简介已生成；翻译批次：0:处理中 1:已完成
+-- 新状态 两者均为：0:等待中 1:运行中 2:成功 3:失败 4:已取消
+-- 旧值1与新值1含义不同，本脚本只能在升级时执行一次，重复执行会把运行中的记录改为成功
+
+USE ai_translate;
+
+START TRANSACTION;
+
+-- 简介已生成 -> 成功，待生成简介保持为等待中
+UPDATE works SET status = 2 WHERE status = 1;
+
+-- 已完成 -> 成功，处理中保持为等待中，由任务处理时更新为运行中
+UPDATE translation_batches SET status = 2 WHERE status = 1;
+
+COMMIT;
+
+-- ALTER TABLE会隐式提交事务，放在数据迁移之后执行
+ALTER TABLE works MODIFY status TINYINT NOT NULL DEFAULT 0 COMMENT '0:等待中 1:运行中 2:成功 3:失败 4:已取消';
+ALTER TABLE translation_batches MODIFY status TINYINT NOT NULL DEFAULT 0 COMMENT '0:等待中 1:运行中 2:成功 3:失败 4:已取消';
+
+-- 内容简介与翻译结果按任务ID去重，重复投递的消息不会产生重复记录
+ALTER TABLE content_summaries
+    ADD COLUMN task_id VARCHAR(64) NULL COMMENT '生成简介的任务ID，保证重复投递不产生重复记录' AFTER work_id,
+    ADD UNIQUE KEY uk_task_id (task_id);
+ALTER TABLE translation_results
+    ADD COLUMN task_id VARCHAR(64) NULL COMMENT '翻译任务ID，保证重复投递不产生重复记录' AFTER batch_id,
+    ADD COLUMN violations TEXT COMMENT '重试后仍未遵守术语表的条目，JSON数组' AFTER status,
+    ADD UNIQUE KEY uk_task_id (task_id);
+
+-- 调度任务新增所属作品、AI驱动、失败原因与部分结果
+ALTER TABLE tasks
+    MODIFY status TINYINT NOT NULL DEFAULT 0 COMMENT '0:等待中 1:完成 2:暂停 3:失败 4:等待依赖 5:已取消',
+    ADD COLUMN work_id BIGINT UNSIGNED NOT NULL DEFAULT 0 COMMENT '所属作品ID' AFTER status,
+    ADD COLUMN driver VARCHAR(50) NOT NULL DEFAULT '' COMMENT 'AI驱动名，为空时使用默认驱动' AFTER reference_id,
+    ADD COLUMN error TEXT COMMENT '失败原因' AFTER driver,
+    ADD COLUMN partial_result MEDIUMTEXT COMMENT '已完成分块的译文，恢复处理时跳过这些分块' AFTER error,
+    ADD INDEX idx_tasks_work_id (work_id),
+    ADD INDEX idx_tasks_reference (type, reference_id);
+
+-- 回填已有任务的所属作品：内容生成任务关联作品，翻译任务关联翻译批次
+UPDATE tasks SET work_id = reference_id WHERE type = 1;
+UPDATE tasks t JOIN translation_batches b ON b.id = t.reference_id SET t.work_id = b.work_id WHERE t.type = 2;
+
+-- 任务依赖表
+CREATE TABLE IF NOT EXISTS task_dependencies (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    work_id BIGINT UNSIGNED NOT NULL COMMENT '所属作品ID',
+    task_id BIGINT UNSIGNED NOT NULL COMMENT '被阻塞的任务',
+    depends_on_id BIGINT UNSIGNED NOT NULL COMMENT '依赖的任务',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uk_task_dependency (task_id, depends_on_id),
+    INDEX idx_task_dependencies_depends_on (depends_on_id),
+    INDEX idx_task_dependencies_work_id (work_id)
+);
+
+-- 任务发件箱表，与任务在同一事务中写入，由中继投递到任务队列
+CREATE TABLE IF NOT EXISTS task_outbox (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    task_id BIGINT UNSIGNED NOT NULL COMMENT '入队的任务',
+    payload TEXT NOT NULL COMMENT '入队的任务快照',
+    status TINYINT NOT NULL DEFAULT 0 COMMENT '0:待投递 1:已投递 2:已跳过 3:投递失败',
+    attempts INT NOT NULL DEFAULT 0 COMMENT '投递失败次数',
+    last_error TEXT COMMENT '最近一次投递失败原因',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    published_at TIMESTAMP NULL,
+    INDEX idx_task_outbox_status (status, id),
+    INDEX idx_task_outbox_task_id (task_id)
+);
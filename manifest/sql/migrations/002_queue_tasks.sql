@@ -0,0 +1,43 @@
+-- 队列任务表
+-- 队列任务原先与工作流任务共用tasks表，两者字段不兼容，改为独立的queue_tasks表
+-- 新增租约、截止时间、重试退避、投递序号、进度、部分结果、标签与老化时间字段，可重复执行
+
+USE ai_translate;
+
+CREATE TABLE IF NOT EXISTS queue_tasks (
+    id VARCHAR(64) PRIMARY KEY,
+    work_id VARCHAR(64) NOT NULL DEFAULT '',
+    batch_id VARCHAR(64) NOT NULL DEFAULT '',
+    type VARCHAR(32) NOT NULL COMMENT 'content_generation:内容生成 translation:翻译',
+    status VARCHAR(16) NOT NULL DEFAULT 'pending' COMMENT 'pending:等待处理 running:处理中 completed:已完成 failed:失败 paused:已暂停',
+    priority TINYINT NOT NULL DEFAULT 0,
+    content LONGTEXT,
+    language VARCHAR(10) NOT NULL DEFAULT '',
+    source_lang VARCHAR(10) NOT NULL DEFAULT '',
+    target_lang VARCHAR(10) NOT NULL DEFAULT '',
+    result LONGTEXT,
+    error TEXT COMMENT '失败原因',
+    progress INT NOT NULL DEFAULT 0 COMMENT '完成百分比（0-100）',
+    partial_result LONGTEXT COMMENT '处理中的部分结果',
+    driver VARCHAR(50) NOT NULL DEFAULT '' COMMENT 'AI驱动名，为空时使用默认驱动',
+    tags TEXT COMMENT '规则动作添加的标签，JSON数组',
+    enqueue_seq BIGINT NOT NULL DEFAULT 0 COMMENT '最近一次投递到队列的序号',
+    retry_count INT NOT NULL DEFAULT 0,
+    max_retries INT NOT NULL DEFAULT 3,
+    next_retry_at DATETIME(3) NULL COMMENT '下次重试时间，退避期间不会被领取',
+    lease_owner VARCHAR(128) NOT NULL DEFAULT '' COMMENT '持有租约的工作协程，为空表示未被领取',
+    lease_expires_at DATETIME(3) NULL COMMENT '租约到期时间',
+    deadline_at DATETIME(3) NULL COMMENT '本次执行的截止时间',
+    aged_at DATETIME(3) NULL COMMENT '最近一次老化提升优先级的时间',
+    started_at DATETIME(3) NULL,
+    completed_at DATETIME(3) NULL,
+    created_at DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+    updated_at DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3) ON UPDATE CURRENT_TIMESTAMP(3),
+    INDEX idx_queue_tasks_work_id (work_id),
+    INDEX idx_queue_tasks_batch_id (batch_id),
+    INDEX idx_queue_tasks_type (type),
+    INDEX idx_queue_tasks_claim (status, priority, created_at),
+    INDEX idx_queue_tasks_next_retry_at (next_retry_at),
+    INDEX idx_queue_tasks_lease_expires_at (lease_expires_at),
+    INDEX idx_queue_tasks_deadline_at (deadline_at)
+);